@@ -1,3 +1,5 @@
+//go:build wireinject
+
 package account
 
 import (
@@ -20,6 +22,6 @@ func Init() *wego.App {
 		repository.NewAccountRepository,
 		service.NewAccountService,
 		grpc.NewAccountServiceServer,
-		wire.Struct(new(wego.App), "GRPCServer"))
+		wire.Struct(new(wego.App), "GRPCServer", "L"))
 	return new(wego.App)
 }
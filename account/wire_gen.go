@@ -28,6 +28,7 @@ func Init() *wego.App {
 	server := ioc.InitGRPCxServer(accountServiceServer, client, loggerV1)
 	app := &wego.App{
 		GRPCServer: server,
+		L:          loggerV1,
 	}
 	return app
 }
@@ -4,10 +4,14 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/robfig/cron/v3"
 	"webook/internal/events"
+	"webook/internal/repository/dao"
+	"webook/pkg/migrator/scheduler"
 )
 
 type App struct {
 	server    *gin.Engine
 	consumers []events.Consumer
 	cron      *cron.Cron
+	// 线上库迁移到 MongoDB，路由已经注册到 server 上面了
+	articleMigrator *scheduler.Scheduler[dao.PublishedArticle]
 }
@@ -0,0 +1,67 @@
+package events
+
+import (
+	"context"
+	"github.com/IBM/sarama"
+	"time"
+	"webook/interactive/repository"
+	"webook/pkg/logger"
+	"webook/pkg/saramax"
+)
+
+// AggregateInteractiveReadEventConsumer 批量消费阅读事件，并且在本地把同一个 (biz, bizId) 的阅读合并成一个增量
+// 热点文章一批里面可能有几百次阅读，合并之后只需要更新一行数据库和一个缓存 key
+// 和 InteractiveReadEventConsumer 用的是同一个消费者组，两者只能启用一个
+type AggregateInteractiveReadEventConsumer struct {
+	repo     repository.InteractiveRepository
+	client   sarama.Client
+	producer sarama.SyncProducer
+	l        logger.LoggerV1
+	cg       *saramax.GroupConsumer
+}
+
+func NewAggregateInteractiveReadEventConsumer(repo repository.InteractiveRepository,
+	client sarama.Client, producer sarama.SyncProducer, l logger.LoggerV1) *AggregateInteractiveReadEventConsumer {
+	return &AggregateInteractiveReadEventConsumer{repo: repo, client: client, producer: producer, l: l}
+}
+
+func (i *AggregateInteractiveReadEventConsumer) Start() error {
+	h := saramax.NewBatchHandler[ReadEvent](i.Consume, i.l,
+		saramax.WithBatchSize(500),
+		saramax.WithFlushInterval(time.Second),
+		saramax.WithRetry(3, saramax.ExponentialBackoff(time.Millisecond*100, time.Second)),
+		saramax.WithDeadLetter(saramax.NewDeadLetter(i.producer, 3, time.Second*30)))
+	i.cg = saramax.NewGroupConsumer(i.client, "interactive",
+		[]string{TopicReadEvent, saramax.RetryTopic(TopicReadEvent)}, h, i.l)
+	return i.cg.Start()
+}
+
+func (i *AggregateInteractiveReadEventConsumer) Stop(ctx context.Context) error {
+	return i.cg.Stop(ctx)
+}
+
+func (i *AggregateInteractiveReadEventConsumer) Consume(msgs []*sarama.ConsumerMessage, events []ReadEvent) error {
+	bizs, bizIds, deltas := aggregateReadEvents(events)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	return i.repo.BatchAddReadCnt(ctx, bizs, bizIds, deltas)
+}
+
+// aggregateReadEvents 按照 (biz, bizId) 合并，保持第一次出现的顺序
+func aggregateReadEvents(events []ReadEvent) ([]string, []int64, []int64) {
+	idx := make(map[int64]int, len(events))
+	bizs := make([]string, 0, len(events))
+	bizIds := make([]int64, 0, len(events))
+	deltas := make([]int64, 0, len(events))
+	for _, evt := range events {
+		if j, ok := idx[evt.Aid]; ok {
+			deltas[j]++
+			continue
+		}
+		idx[evt.Aid] = len(bizIds)
+		bizs = append(bizs, "article")
+		bizIds = append(bizIds, evt.Aid)
+		deltas = append(deltas, 1)
+	}
+	return bizs, bizIds, deltas
+}
@@ -0,0 +1,42 @@
+package events
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAggregateReadEvents(t *testing.T) {
+	testCases := []struct {
+		name       string
+		events     []ReadEvent
+		wantBizIds []int64
+		wantDeltas []int64
+	}{
+		{
+			name:       "空",
+			events:     []ReadEvent{},
+			wantBizIds: []int64{},
+			wantDeltas: []int64{},
+		},
+		{
+			name: "热点文章合并",
+			events: []ReadEvent{
+				{Aid: 1, Uid: 1}, {Aid: 2, Uid: 1}, {Aid: 1, Uid: 2},
+				{Aid: 1, Uid: 3}, {Aid: 3, Uid: 1}, {Aid: 2, Uid: 2},
+			},
+			wantBizIds: []int64{1, 2, 3},
+			wantDeltas: []int64{3, 2, 1},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bizs, bizIds, deltas := aggregateReadEvents(tc.events)
+			assert.Equal(t, tc.wantBizIds, bizIds)
+			assert.Equal(t, tc.wantDeltas, deltas)
+			assert.Len(t, bizs, len(bizIds))
+			for _, biz := range bizs {
+				assert.Equal(t, "article", biz)
+			}
+		})
+	}
+}
@@ -13,6 +13,7 @@ type BatchInteractiveReadEventConsumer struct {
 	repo   repository.InteractiveRepository
 	client sarama.Client
 	l      logger.LoggerV1
+	cg     *saramax.GroupConsumer
 }
 
 func NewBatchInteractiveReadEventConsumer(repo repository.InteractiveRepository, client sarama.Client, l logger.LoggerV1) *BatchInteractiveReadEventConsumer {
@@ -20,17 +21,13 @@ func NewBatchInteractiveReadEventConsumer(repo repository.InteractiveRepository,
 }
 
 func (i *BatchInteractiveReadEventConsumer) Start() error {
-	cg, err := sarama.NewConsumerGroupFromClient("interactive", i.client)
-	if err != nil {
-		return err
-	}
-	go func() {
-		er := cg.Consume(context.Background(), []string{TopicReadEvent}, saramax.NewBatchHandler[ReadEvent](i.BatchConsume, i.l))
-		if er != nil {
-			i.l.Error("退出消费", logger.Error(er))
-		}
-	}()
-	return err
+	i.cg = saramax.NewGroupConsumer(i.client, "interactive", []string{TopicReadEvent},
+		saramax.NewBatchHandler[ReadEvent](i.BatchConsume, i.l), i.l)
+	return i.cg.Start()
+}
+
+func (i *BatchInteractiveReadEventConsumer) Stop(ctx context.Context) error {
+	return i.cg.Stop(ctx)
 }
 
 func (i *BatchInteractiveReadEventConsumer) BatchConsume(msgs []*sarama.ConsumerMessage, events []ReadEvent) error {
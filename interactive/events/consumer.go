@@ -12,14 +12,22 @@ import (
 
 const TopicReadEvent = "article_read"
 
+func init() {
+	saramax.DefaultRegistry.Register(TopicReadEvent, "article.read",
+		saramax.Version{Version: 1, Sample: ReadEvent{}})
+}
+
 type InteractiveReadEventConsumer struct {
-	repo   repository.InteractiveRepository
-	client sarama.Client
-	l      logger.LoggerV1
-	vector *prometheus.SummaryVec
+	repo     repository.InteractiveRepository
+	client   sarama.Client
+	producer sarama.SyncProducer
+	l        logger.LoggerV1
+	vector   *prometheus.SummaryVec
+	cg       *saramax.GroupConsumer
 }
 
-func NewInteractiveReadEventConsumer(repo repository.InteractiveRepository, client sarama.Client, l logger.LoggerV1) *InteractiveReadEventConsumer {
+func NewInteractiveReadEventConsumer(repo repository.InteractiveRepository, client sarama.Client,
+	producer sarama.SyncProducer, l logger.LoggerV1) *InteractiveReadEventConsumer {
 	vector := prometheus.NewSummaryVec(prometheus.SummaryOpts{
 		Namespace: "harmonic",
 		Subsystem: "webook",
@@ -38,24 +46,23 @@ func NewInteractiveReadEventConsumer(repo repository.InteractiveRepository, clie
 	}, []string{"topic"})
 	prometheus.MustRegister(vector)
 	return &InteractiveReadEventConsumer{repo: repo,
-		client: client,
-		l:      l,
-		vector: vector}
+		client:   client,
+		producer: producer,
+		l:        l,
+		vector:   vector}
 }
 
 func (i *InteractiveReadEventConsumer) Start() error {
-	cg, err := sarama.NewConsumerGroupFromClient("interactive", i.client)
-	if err != nil {
-		return err
-	}
+	h := saramax.NewHandler[ReadEvent](i.Consume, i.l,
+		saramax.WithRetry(3, saramax.ExponentialBackoff(time.Millisecond*100, time.Second)),
+		saramax.WithDeadLetter(saramax.NewDeadLetter(i.producer, 3, time.Second*30)))
+	i.cg = saramax.NewGroupConsumer(i.client, "interactive",
+		[]string{TopicReadEvent, saramax.RetryTopic(TopicReadEvent)}, h, i.l)
+	return i.cg.Start()
+}
 
-	go func() {
-		er := cg.Consume(context.Background(), []string{TopicReadEvent}, saramax.NewHandler[ReadEvent](i.Consume, i.l))
-		if er != nil {
-			i.l.Error("退出消费", logger.Error(er))
-		}
-	}()
-	return err
+func (i *InteractiveReadEventConsumer) Stop(ctx context.Context) error {
+	return i.cg.Stop(ctx)
 }
 
 func (i *InteractiveReadEventConsumer) Consume(msg *sarama.ConsumerMessage, event ReadEvent) error {
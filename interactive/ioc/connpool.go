@@ -1,10 +1,34 @@
 package ioc
 
 import (
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
 	"webook/pkg/gormx/connpool"
 	"webook/pkg/logger"
+	"webook/pkg/migrator/compensator"
+	"webook/pkg/migrator/events"
 )
 
-func InitDoubleWritePool(src SrcDB, dst DstDB, l logger.LoggerV1) *connpool.DoubleWritePool {
-	return connpool.NewDoubleWritePool(src, dst, l)
+func InitDoubleWritePool(src SrcDB, dst DstDB, l logger.LoggerV1, producer events.Producer) *connpool.DoubleWritePool {
+	// 补偿记录写在当时的主库上，重放记录写在重放的那一边，两边都要建表
+	// 不能用双写的 DB
+	for _, db := range []*gorm.DB{src, dst} {
+		err := db.AutoMigrate(&compensator.MigratorCompensation{}, &compensator.MigratorCompensationApplied{})
+		if err != nil {
+			panic(err)
+		}
+	}
+	return connpool.NewDoubleWritePool(src, dst, l).
+		// 能解析出 id 的交给修复程序，其它的记下 SQL 重放
+		Compensator(compensator.NewCompensator(src, dst, l).Producer("interactives", producer)).
+		Metrics(prometheus.CounterOpts{
+			Namespace: "harmonic",
+			Subsystem: "webook_intr",
+			Name:      "double_write_secondary",
+			Help:      "统计双写时次要库的写入结果",
+		})
+}
+
+func InitCompensationReplayer(src SrcDB, dst DstDB, l logger.LoggerV1) *compensator.Replayer {
+	return compensator.NewReplayer(src, dst, l)
 }
@@ -5,24 +5,33 @@ import (
 	"google.golang.org/grpc"
 	grpc2 "webook/interactive/grpc"
 	"webook/pkg/grpcx"
+	"webook/pkg/grpcx/balancer/p2c"
+	"webook/pkg/grpcx/interceptor/auth"
+	"webook/pkg/jwtx"
 	"webook/pkg/logger"
 )
 
-func NewGrpcxServer(intrSvc *grpc2.InteractiveServiceServer, l logger.LoggerV1) *grpcx.Server {
+func NewGrpcxServer(intrSvc *grpc2.InteractiveServiceServer, verifier *jwtx.Verifier, l logger.LoggerV1) *grpcx.Server {
 	type Config struct {
 		Port     int    `yaml:"port"`
 		EtcdAddr string `yaml:"etcdAddr"`
 		Name     string `yaml:"name"`
 	}
 
-	server := grpc.NewServer()
-	// 反向注册
-	intrSvc.Register(server)
 	var cfg Config
 	err := viper.UnmarshalKey("grpc.server", &cfg)
 	if err != nil {
 		panic(err)
 	}
+	// 通过 trailer 把负载告诉客户端，客户端用 p2c 挑负载低的节点
+	interceptors := []grpc.UnaryServerInterceptor{p2c.NewLoadReporter().BuildServerUnaryInterceptor()}
+	if verifier != nil {
+		// 验证调用方带过来的用户 token
+		interceptors = append(interceptors, auth.NewInterceptorBuilder(verifier).BuildServerUnaryInterceptor())
+	}
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
+	// 反向注册
+	intrSvc.Register(server)
 	return &grpcx.Server{
 		Server:   server,
 		EtcdAddr: cfg.EtcdAddr,
@@ -0,0 +1,20 @@
+package ioc
+
+import (
+	"github.com/spf13/viper"
+	"time"
+	"webook/pkg/jwtx"
+	"webook/pkg/logger"
+)
+
+// InitVerifier 用 web 的 JWKS 验证用户的 token，没有配置的时候返回 nil
+// gRPC 接口不验证，后台接口全部拒绝
+// web 暂时不可用也不影响启动，拉取到 JWKS 之前带 token 的请求都会被拒绝
+func InitVerifier(l logger.LoggerV1) *jwtx.Verifier {
+	url := viper.GetString("jwks")
+	if url == "" {
+		l.Warn("没有配置 JWKS，不验证用户的 token")
+		return nil
+	}
+	return jwtx.NewRemoteVerifier(url, time.Minute*5, l)
+}
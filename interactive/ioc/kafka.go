@@ -5,7 +5,9 @@ import (
 	"github.com/spf13/viper"
 	"webook/interactive/events"
 	"webook/interactive/repository/dao"
+	"webook/pkg/migrator/compensator"
 	"webook/pkg/migrator/events/fixer"
+	"webook/pkg/saramax"
 )
 
 func InitSaramaClient() sarama.Client {
@@ -34,6 +36,7 @@ func InitSyncProducer(c sarama.Client) sarama.SyncProducer {
 	return p
 }
 
-func InitConsumers(c1 *events.InteractiveReadEventConsumer, c2 *fixer.FixConsumer[dao.Interactive]) []events.Consumer {
-	return []events.Consumer{c1, c2}
+func InitConsumers(c1 *events.AggregateInteractiveReadEventConsumer, c2 *fixer.FixConsumer[dao.Interactive],
+	c3 *compensator.Replayer) []saramax.Consumer {
+	return []saramax.Consumer{c1, c2, c3}
 }
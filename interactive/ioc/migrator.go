@@ -4,14 +4,20 @@ import (
 	"github.com/IBM/sarama"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
 	"github.com/spf13/viper"
+	"gorm.io/gorm"
 	"webook/interactive/repository/dao"
 	"webook/pkg/ginx"
+	"webook/pkg/ginx/middleware/auth"
 	"webook/pkg/gormx/connpool"
+	"webook/pkg/jwtx"
 	"webook/pkg/logger"
+	"webook/pkg/migrator/cdc"
 	"webook/pkg/migrator/events"
 	"webook/pkg/migrator/events/fixer"
 	"webook/pkg/migrator/scheduler"
+	"webook/pkg/migrator/validator"
 )
 
 func InitGinxServer(l logger.LoggerV1,
@@ -19,6 +25,9 @@ func InitGinxServer(l logger.LoggerV1,
 	dst DstDB,
 	pool *connpool.DoubleWritePool,
 	producer events.Producer,
+	redisClient redis.Cmdable,
+	client sarama.Client,
+	verifier *jwtx.Verifier,
 ) *ginx.Server {
 	ginx.InitCounter(prometheus.CounterOpts{
 		Namespace: "harmonic",
@@ -26,13 +35,31 @@ func InitGinxServer(l logger.LoggerV1,
 		Name:      "biz_code",
 		Help:      "统计业务错误码",
 	})
-	sch := scheduler.NewScheduler[dao.Interactive](l, src, dst, pool, producer)
+	// 迁移状态保存在源库里面，所有实例一起切换
+	var db *gorm.DB = src
+	err := db.AutoMigrate(&scheduler.MigratorState{}, &scheduler.MigratorAudit{})
+	if err != nil {
+		panic(err)
+	}
+	sch := scheduler.NewScheduler[dao.Interactive](l, src, dst, pool, producer).
+		Checkpoint(validator.NewRedisCheckpointStore(redisClient)).
+		// 全量校验每个分片 10 万个 id，每个实例同时校验 4 个分片
+		Sharding(redisClient, 100000, 4).
+		// canal 把两个库的 binlog 都投递到这个 topic
+		Binlog(cdc.NewKafkaSource(client, "webook_binlog", "migrator_interactive", l)).
+		State(scheduler.NewGORMStateStore(db))
 	engine := gin.Default()
-	sch.RegisterRoutes(engine)
-	return &ginx.Server{
+	registry := ginx.NewRegistry()
+	// 只有管理员可以操作迁移
+	engine.Use(auth.NewBuilder(registry, auth.BearerAuthenticate(verifier)).Build())
+	sch.RegisterRoutes(engine, registry)
+	server := &ginx.Server{
 		Engine: engine,
 		Addr:   viper.GetString("migrator.http.addr"),
 	}
+	// 应用退出的时候停掉监听和校验
+	server.RegisterOnShutdown(sch.Close)
+	return server
 }
 
 func InitInteractiveProducer(producer sarama.SyncProducer) events.Producer {
@@ -44,5 +71,14 @@ func InitFixerConsumer(client sarama.Client, l logger.LoggerV1, src SrcDB, dst D
 	if err != nil {
 		panic(err)
 	}
+	if viper.GetBool("migrator.fix.dryRun") {
+		// 修复计划写到源库里面，DBA 审核之后再关掉 dryRun 修复
+		var db *gorm.DB = src
+		err = db.AutoMigrate(&fixer.MigratorFixPlan{})
+		if err != nil {
+			panic(err)
+		}
+		consumer.DryRun(db)
+	}
 	return consumer
 }
@@ -9,24 +9,8 @@ func main() {
 	initViper()
 
 	app := InitInteractiveAPP()
-	println(app)
-	for _, c := range app.consumers {
-		err := c.Start()
-		if err != nil {
-			panic(err)
-		}
-	}
-
-	// 开 数据迁移 服务
-	go func() {
-		er := app.ginxServer.Start()
-		if er != nil {
-			panic(er)
-		}
-	}()
-
-	// 开 grpc 服务
-	err := app.server.Serve()
+	// 启动 grpc 服务、数据迁移的 http 服务和消费者，收到退出信号后统一关闭
+	err := app.Run()
 	if err != nil {
 		panic(err)
 	}
@@ -22,6 +22,7 @@ const fieldCollectCnt = "collect_cnt"
 
 type InteractiveCache interface {
 	IncrReadCntIfPresent(ctx context.Context, biz string, bizId int64) error
+	AddReadCntIfPresent(ctx context.Context, biz string, bizId int64, delta int64) error
 	IncrLikeCntIfPresent(ctx context.Context, biz string, bizId int64) error
 	DecrLikeCntIfPresent(ctx context.Context, biz string, bizId int64) error
 	IncrCollectCntIfPresent(ctx context.Context, biz string, bizId int64) error
@@ -127,6 +128,11 @@ func (i *InteractiveRedisCache) IncrReadCntIfPresent(ctx context.Context, biz st
 
 }
 
+func (i *InteractiveRedisCache) AddReadCntIfPresent(ctx context.Context, biz string, bizId int64, delta int64) error {
+	key := i.key(biz, bizId)
+	return i.client.Eval(ctx, luaIncrCnt, []string{key}, fieldReadCnt, delta).Err()
+}
+
 func (i *InteractiveRedisCache) key(biz string, bizId int64) string {
 	return fmt.Sprintf("interactive:article:%s:%d", biz, bizId)
 }
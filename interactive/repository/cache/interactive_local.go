@@ -4,11 +4,14 @@ import (
 	"context"
 	"errors"
 	"github.com/ecodeclub/ekit/syncx/atomicx"
+	"sync"
 	"time"
 	"webook/interactive/domain"
 )
 
 type InteractiveLocalCache struct {
+	// lock 只用来串行化写，读不加锁
+	lock       sync.Mutex
 	topLike    *atomicx.Value[[]domain.Interactive]
 	ddl        *atomicx.Value[time.Time]
 	expiration time.Duration
@@ -25,6 +28,25 @@ func (i *InteractiveLocalCache) IncrReadCntIfPresent(ctx context.Context, biz st
 	panic("implement me")
 }
 
+// AddReadCntIfPresent 本地只缓存了点赞榜，在榜单里面的才更新
+// 榜单是共享的，复制一份再替换，不能直接改
+func (i *InteractiveLocalCache) AddReadCntIfPresent(ctx context.Context, biz string, bizId int64, delta int64) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	intrs := i.topLike.Load()
+	for idx, intr := range intrs {
+		if intr.Biz != biz || intr.BizId != bizId {
+			continue
+		}
+		updated := make([]domain.Interactive, len(intrs))
+		copy(updated, intrs)
+		updated[idx].ReadCnt += delta
+		i.topLike.Store(updated)
+		return nil
+	}
+	return nil
+}
+
 func (i *InteractiveLocalCache) IncrLikeCntIfPresent(ctx context.Context, biz string, bizId int64) error {
 	//TODO implement me
 	panic("implement me")
@@ -62,6 +84,8 @@ func (i *InteractiveLocalCache) GetTopNLike(ctx context.Context) ([]domain.Inter
 
 // SetTopNLike assignment week9
 func (i *InteractiveLocalCache) SetTopNLike(ctx context.Context, intrs []domain.Interactive) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
 	i.topLike.Store(intrs)
 	i.ddl.Store(time.Now().Add(i.expiration))
 	return nil
@@ -73,6 +73,37 @@ func (d *DoubleWriteDAO) IncrReadCnt(ctx context.Context, biz string, bizId int6
 	}
 }
 
+func (d *DoubleWriteDAO) BatchAddReadCnt(ctx context.Context, bizs []string, bizIds []int64, deltas []int64) error {
+	pattern := d.pattern.Load()
+	switch pattern {
+	case PatternSrcOnly:
+		return d.src.BatchAddReadCnt(ctx, bizs, bizIds, deltas)
+	case PatternSrcFirst:
+		err := d.src.BatchAddReadCnt(ctx, bizs, bizIds, deltas)
+		if err != nil {
+			return err
+		}
+		err = d.dst.BatchAddReadCnt(ctx, bizs, bizIds, deltas)
+		if err != nil {
+			d.l.Error("SrcFirst阶段，写入 dst 失败", logger.Error(err))
+		}
+		return nil
+	case PatternDstFirst:
+		err := d.dst.BatchAddReadCnt(ctx, bizs, bizIds, deltas)
+		if err == nil {
+			er := d.src.BatchAddReadCnt(ctx, bizs, bizIds, deltas)
+			if er != nil {
+				d.l.Error("DstFirst阶段，写入 src 失败", logger.Error(er))
+			}
+		}
+		return err
+	case PatternDstOnly:
+		return d.dst.BatchAddReadCnt(ctx, bizs, bizIds, deltas)
+	default:
+		return errUnKnownPattern
+	}
+}
+
 func (d *DoubleWriteDAO) BatchIncrReadCnt(ctx context.Context, bizs []string, bizIds []int64) error {
 	pattern := d.pattern.Load()
 	switch pattern {
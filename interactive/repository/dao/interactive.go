@@ -11,6 +11,8 @@ import (
 type InteractiveDAO interface {
 	IncrReadCnt(ctx context.Context, biz string, bizId int64) error
 	BatchIncrReadCnt(ctx context.Context, bizs []string, bizIds []int64) error
+	// BatchAddReadCnt 每一个 (biz, bizId) 的阅读数加上对应的 delta，三个切片长度必须一致
+	BatchAddReadCnt(ctx context.Context, bizs []string, bizIds []int64, deltas []int64) error
 	InsertLikeInfo(ctx context.Context, biz string, bizId int64, uid int64) error
 	DeleteLikeInfo(ctx context.Context, biz string, bizId int64, uid int64) error
 	InsertCollectionBiz(ctx context.Context, biz string, bizId int64, cid int64, uid int64) error
@@ -175,6 +177,37 @@ func (g *GORMInteractiveDAO) BatchIncrReadCnt(ctx context.Context, bizs []string
 	})
 }
 
+func (g *GORMInteractiveDAO) BatchAddReadCnt(ctx context.Context, bizs []string, bizIds []int64, deltas []int64) error {
+	return g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		newDAO := &GORMInteractiveDAO{db: tx}
+		for i := 0; i < len(bizs); i++ {
+			err := newDAO.addReadCnt(ctx, bizs[i], bizIds[i], deltas[i])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (g *GORMInteractiveDAO) addReadCnt(ctx context.Context, biz string, bizId int64, delta int64) error {
+	now := time.Now().UnixMilli()
+	return g.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"read_cnt": gorm.Expr("`read_cnt` + ?", delta),
+				"utime":    now,
+			}),
+		}).
+		Create(&Interactive{
+			Biz:     biz,
+			BizId:   bizId,
+			ReadCnt: delta,
+			Ctime:   now,
+			Utime:   now,
+		}).Error
+}
+
 func (g *GORMInteractiveDAO) IncrReadCnt(ctx context.Context, biz string, bizId int64) error {
 	now := time.Now().UnixMilli()
 	return g.db.WithContext(ctx).
@@ -238,11 +271,7 @@ func (i Interactive) ID() int64 {
 }
 
 func (i Interactive) CompareTo(dst migrator.Entity) bool {
-	val, ok := dst.(Interactive)
-	if !ok {
-		return false
-	}
-	return i == val
+	return migrator.Equal(i, dst)
 }
 
 // GetTopNLike assignment week9
@@ -14,6 +14,8 @@ type InteractiveRepository interface {
 	IncrReadCnt(ctx context.Context, biz string, bizId int64) error
 	// BatchIncrReadCnt bizs 和 bizIds 长度必须一致
 	BatchIncrReadCnt(ctx context.Context, bizs []string, bizIds []int64) error
+	// BatchAddReadCnt 按照合并之后的增量更新阅读数，三个切片长度必须一致
+	BatchAddReadCnt(ctx context.Context, bizs []string, bizIds []int64, deltas []int64) error
 	IncrLike(ctx context.Context, biz string, bizId int64, uid int64) error
 	DecrLike(ctx context.Context, biz string, bizId int64, uid int64) error
 	AddCollectionItem(ctx context.Context, biz string, bizId int64, cid int64, uid int64) error
@@ -148,6 +150,24 @@ func (c *CachedInteractiveRepository) BatchIncrReadCnt(ctx context.Context, bizs
 	return nil
 }
 
+func (c *CachedInteractiveRepository) BatchAddReadCnt(ctx context.Context, bizs []string, bizIds []int64, deltas []int64) error {
+	err := c.dao.BatchAddReadCnt(ctx, bizs, bizIds, deltas)
+	if err != nil {
+		return err
+	}
+	// 数据库已经更新成功了，缓存更新失败也不能返回 error，不然消息重新消费会重复计数
+	for i := 0; i < len(bizs); i++ {
+		er := c.cache.AddReadCntIfPresent(ctx, bizs[i], bizIds[i], deltas[i])
+		if er != nil {
+			c.l.Error("更新缓存阅读数失败",
+				logger.String("biz", bizs[i]),
+				logger.Int64("bizId", bizIds[i]),
+				logger.Error(er))
+		}
+	}
+	return nil
+}
+
 func (c *CachedInteractiveRepository) IncrReadCnt(ctx context.Context, biz string, bizId int64) error {
 	// 更新数据库
 	err := c.dao.IncrReadCnt(ctx, biz, bizId)
@@ -11,6 +11,7 @@ import (
 	"webook/interactive/repository/cache"
 	"webook/interactive/repository/dao"
 	"webook/interactive/service"
+	"webook/pkg/wego"
 )
 
 var thirdPartySet = wire.NewSet(
@@ -23,6 +24,7 @@ var thirdPartySet = wire.NewSet(
 	ioc.InitLogger,
 	ioc.InitSaramaClient,
 	ioc.InitSyncProducer,
+	ioc.InitVerifier,
 )
 
 var interactiveSvcProvider = wire.NewSet(
@@ -35,17 +37,18 @@ var interactiveSvcProvider = wire.NewSet(
 var migratorSarama = wire.NewSet(
 	ioc.InitInteractiveProducer,
 	ioc.InitFixerConsumer,
+	ioc.InitCompensationReplayer,
 )
 
-func InitInteractiveAPP() *App {
+func InitInteractiveAPP() *wego.App {
 	wire.Build(thirdPartySet, interactiveSvcProvider, migratorSarama,
 		grpc.NewInteractiveServiceServer,
 		//grpc.NewInteractiveRepositoryServer,
-		events.NewInteractiveReadEventConsumer,
+		events.NewAggregateInteractiveReadEventConsumer,
 		ioc.InitGinxServer,
 		ioc.InitConsumers,
 		ioc.NewGrpcxServer,
 		//ioc.NewGrpcxRepoServer,
-		wire.Struct(new(App), "*"))
-	return new(App)
+		wire.Struct(new(wego.App), "GRPCServer", "WebServer", "Consumers", "L"))
+	return new(wego.App)
 }
@@ -15,6 +15,7 @@ import (
 	"webook/interactive/repository/cache"
 	"webook/interactive/repository/dao"
 	"webook/interactive/service"
+	"webook/pkg/wego"
 )
 
 import (
@@ -23,38 +24,41 @@ import (
 
 // Injectors from wire.go:
 
-func InitInteractiveAPP() *App {
+func InitInteractiveAPP() *wego.App {
 	loggerV1 := ioc.InitLogger()
 	srcDB := ioc.InitSrcDB(loggerV1)
 	dstDB := ioc.InitDstDB(loggerV1)
-	doubleWritePool := ioc.InitDoubleWritePool(srcDB, dstDB, loggerV1)
+	client := ioc.InitSaramaClient()
+	syncProducer := ioc.InitSyncProducer(client)
+	producer := ioc.InitInteractiveProducer(syncProducer)
+	doubleWritePool := ioc.InitDoubleWritePool(srcDB, dstDB, loggerV1, producer)
 	db := ioc.InitBizDB(doubleWritePool)
 	interactiveDAO := dao.NewGORMInteractiveDAO(db)
 	cmdable := ioc.InitRedis()
 	interactiveCache := cache.NewInteractiveRedisCache(cmdable)
 	interactiveRepository := repository.NewCachedInteractiveRepository(interactiveDAO, interactiveCache, loggerV1)
-	client := ioc.InitSaramaClient()
-	interactiveReadEventConsumer := events.NewInteractiveReadEventConsumer(interactiveRepository, client, loggerV1)
+	aggregateInteractiveReadEventConsumer := events.NewAggregateInteractiveReadEventConsumer(interactiveRepository, client, syncProducer, loggerV1)
 	fixConsumer := ioc.InitFixerConsumer(client, loggerV1, srcDB, dstDB)
-	v := ioc.InitConsumers(interactiveReadEventConsumer, fixConsumer)
+	replayer := ioc.InitCompensationReplayer(srcDB, dstDB, loggerV1)
+	v := ioc.InitConsumers(aggregateInteractiveReadEventConsumer, fixConsumer, replayer)
 	interactiveService := service.NewInteractiveService(interactiveRepository)
 	interactiveServiceServer := grpc.NewInteractiveServiceServer(interactiveService)
-	server := ioc.NewGrpcxServer(interactiveServiceServer, loggerV1)
-	syncProducer := ioc.InitSyncProducer(client)
-	producer := ioc.InitInteractiveProducer(syncProducer)
-	ginxServer := ioc.InitGinxServer(loggerV1, srcDB, dstDB, doubleWritePool, producer)
-	app := &App{
-		consumers:  v,
-		server:     server,
-		ginxServer: ginxServer,
+	verifier := ioc.InitVerifier(loggerV1)
+	server := ioc.NewGrpcxServer(interactiveServiceServer, verifier, loggerV1)
+	ginxServer := ioc.InitGinxServer(loggerV1, srcDB, dstDB, doubleWritePool, producer, cmdable, client, verifier)
+	app := &wego.App{
+		GRPCServer: server,
+		WebServer:  ginxServer,
+		Consumers:  v,
+		L:          loggerV1,
 	}
 	return app
 }
 
 // wire.go:
 
-var thirdPartySet = wire.NewSet(ioc.InitSrcDB, ioc.InitDstDB, ioc.InitBizDB, ioc.InitDoubleWritePool, ioc.InitRedis, ioc.InitLogger, ioc.InitSaramaClient, ioc.InitSyncProducer)
+var thirdPartySet = wire.NewSet(ioc.InitSrcDB, ioc.InitDstDB, ioc.InitBizDB, ioc.InitDoubleWritePool, ioc.InitRedis, ioc.InitLogger, ioc.InitSaramaClient, ioc.InitSyncProducer, ioc.InitVerifier)
 
 var interactiveSvcProvider = wire.NewSet(service.NewInteractiveService, repository.NewCachedInteractiveRepository, dao.NewGORMInteractiveDAO, cache.NewInteractiveRedisCache)
 
-var migratorSarama = wire.NewSet(ioc.InitInteractiveProducer, ioc.InitFixerConsumer)
+var migratorSarama = wire.NewSet(ioc.InitInteractiveProducer, ioc.InitFixerConsumer, ioc.InitCompensationReplayer)
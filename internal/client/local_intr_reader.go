@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	intrv1 "webook/api/proto/gen/intr/v1"
+	"webook/interactive/repository/cache"
+)
+
+// LocalInteractiveReader Interactive 服务熔断之后的降级逻辑
+// 只读 Interactive 服务放在 redis 里面的计数，不在主程序里面读写它的数据库
+// 是否点赞、收藏这些缓存里面没有，都当作没有；写操作直接失败
+type LocalInteractiveReader struct {
+	cache cache.InteractiveCache
+}
+
+func NewLocalInteractiveReader(cache cache.InteractiveCache) *LocalInteractiveReader {
+	return &LocalInteractiveReader{cache: cache}
+}
+
+// Fallback 作为客户端熔断之后的降级逻辑
+func (l *LocalInteractiveReader) Fallback(ctx context.Context, method string, req, reply any) error {
+	switch method {
+	case intrv1.InteractiveService_Get_FullMethodName:
+		in := req.(*intrv1.GetRequest)
+		intr, ok := l.get(ctx, in.GetBiz(), in.GetBizId())
+		if !ok {
+			// 缓存里面没有，也只能当作没有人看过
+			intr = &intrv1.Interactive{Biz: in.GetBiz(), BizId: in.GetBizId()}
+		}
+		reply.(*intrv1.GetResponse).Intr = intr
+		return nil
+	case intrv1.InteractiveService_GetByIds_FullMethodName:
+		in := req.(*intrv1.GetByIdsRequest)
+		// 和 Interactive 服务一样，没有数据的 id 不返回
+		intrs := make(map[int64]*intrv1.Interactive, len(in.GetIds()))
+		for _, id := range in.GetIds() {
+			intr, ok := l.get(ctx, in.GetBiz(), id)
+			if ok {
+				intrs[id] = intr
+			}
+		}
+		reply.(*intrv1.GetByIdsResponse).Intrs = intrs
+		return nil
+	default:
+		return status.Errorf(codes.Unavailable, "熔断")
+	}
+}
+
+// get 缓存里面没有，或者 redis 也出问题了，都返回 false
+func (l *LocalInteractiveReader) get(ctx context.Context, biz string, bizId int64) (*intrv1.Interactive, bool) {
+	intr, err := l.cache.Get(ctx, biz, bizId)
+	if err != nil {
+		return nil, false
+	}
+	return &intrv1.Interactive{
+		Biz:        biz,
+		BizId:      bizId,
+		ReadCnt:    intr.ReadCnt,
+		LikeCnt:    intr.LikeCnt,
+		CollectCnt: intr.CollectCnt,
+	}, true
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"testing"
+	intrv1 "webook/api/proto/gen/intr/v1"
+	"webook/interactive/domain"
+	"webook/interactive/repository/cache"
+)
+
+func TestLocalInteractiveReader_Fallback(t *testing.T) {
+	testCases := []struct {
+		name   string
+		method string
+		req    any
+		reply  proto.Message
+
+		wantReply proto.Message
+		wantCode  codes.Code
+	}{
+		{
+			name:   "读缓存里面的计数",
+			method: intrv1.InteractiveService_Get_FullMethodName,
+			req:    &intrv1.GetRequest{Biz: "article", BizId: 1, Uid: 123},
+			reply:  &intrv1.GetResponse{},
+			wantReply: &intrv1.GetResponse{Intr: &intrv1.Interactive{
+				Biz: "article", BizId: 1, ReadCnt: 10, LikeCnt: 2, CollectCnt: 1,
+			}},
+		},
+		{
+			name:      "缓存里面没有",
+			method:    intrv1.InteractiveService_Get_FullMethodName,
+			req:       &intrv1.GetRequest{Biz: "article", BizId: 2, Uid: 123},
+			reply:     &intrv1.GetResponse{},
+			wantReply: &intrv1.GetResponse{Intr: &intrv1.Interactive{Biz: "article", BizId: 2}},
+		},
+		{
+			name:   "批量读只返回缓存里面有的",
+			method: intrv1.InteractiveService_GetByIds_FullMethodName,
+			req:    &intrv1.GetByIdsRequest{Biz: "article", Ids: []int64{1, 2}},
+			reply:  &intrv1.GetByIdsResponse{},
+			wantReply: &intrv1.GetByIdsResponse{Intrs: map[int64]*intrv1.Interactive{
+				1: {Biz: "article", BizId: 1, ReadCnt: 10, LikeCnt: 2, CollectCnt: 1},
+			}},
+		},
+		{
+			name:     "写操作直接失败",
+			method:   intrv1.InteractiveService_Like_FullMethodName,
+			req:      &intrv1.LikeRequest{Biz: "article", BizId: 1, Uid: 123},
+			reply:    &intrv1.LikeResponse{},
+			wantCode: codes.Unavailable,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewLocalInteractiveReader(&memoryInteractiveCache{data: map[int64]domain.Interactive{
+				1: {BizId: 1, ReadCnt: 10, LikeCnt: 2, CollectCnt: 1},
+			}})
+			err := l.Fallback(context.Background(), tc.method, tc.req, tc.reply)
+			assert.Equal(t, tc.wantCode, status.Code(err))
+			if err != nil {
+				return
+			}
+			assert.True(t, proto.Equal(tc.wantReply, tc.reply))
+		})
+	}
+}
+
+type memoryInteractiveCache struct {
+	cache.InteractiveCache
+	data map[int64]domain.Interactive
+}
+
+func (m *memoryInteractiveCache) Get(ctx context.Context, biz string, bizId int64) (domain.Interactive, error) {
+	intr, ok := m.data[bizId]
+	if !ok {
+		return domain.Interactive{}, errors.New("key 不存在")
+	}
+	return intr, nil
+}
@@ -0,0 +1,14 @@
+package domain
+
+// Identity 第三方登录的身份，同一个 Provider 下 Subject 唯一
+type Identity struct {
+	Uid int64
+	// Provider 例如 wechat、github、google
+	Provider string
+	// Subject 用户在 Provider 那边的唯一标识，微信是 openid，OIDC 是 sub
+	Subject string
+	// UnionId 只有微信有
+	UnionId string
+	Email   string
+	Name    string
+}
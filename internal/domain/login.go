@@ -0,0 +1,13 @@
+package domain
+
+import "time"
+
+// LoginBlock 账号或者 IP 登录失败太多被限制的情况
+type LoginBlock struct {
+	// Fails 账号连续失败的次数
+	Fails int64
+	// Wait 还要等多久才能再次登录，0 表示不限制
+	Wait time.Duration
+	// Locked 账号被锁定，只能等锁定过期或者短信解锁
+	Locked bool
+}
@@ -0,0 +1,44 @@
+package domain
+
+import "time"
+
+// Media 用户上传的图片，同样的内容只存一份
+type Media struct {
+	Id int64
+	// Owner 发起这次上传的用户
+	Owner int64
+	// Hash 内容的 SHA-256，十六进制
+	Hash string
+	// Nonce 上传用的临时 key 的一部分，只签发给发起上传的用户
+	Nonce       string
+	Size        int64
+	ContentType string
+	Status      MediaStatus
+	// URL 公开访问的地址
+	URL   string
+	Ctime time.Time
+}
+
+type MediaStatus uint8
+
+func (s MediaStatus) ToUint8() uint8 {
+	return uint8(s)
+}
+
+const (
+	// MediaStatusUnknown 这是一个未知状态
+	MediaStatusUnknown MediaStatus = iota
+	// MediaStatusPending 已经签发了上传地址，还没有确认上传完成
+	MediaStatusPending
+	// MediaStatusUploaded 上传完成，并且校验过大小、类型和摘要
+	MediaStatusUploaded
+)
+
+// MediaBiz 上传的用途，不同用途允许的类型和大小不一样
+type MediaBiz string
+
+const (
+	MediaBizAvatar MediaBiz = "avatar"
+	// MediaBizArticle 文章里面的图片
+	MediaBizArticle MediaBiz = "article"
+)
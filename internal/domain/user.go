@@ -6,6 +6,8 @@ type User struct {
 	Id       int64
 	Email    string
 	Password string
+	// EmailVerified 没有验证过的邮箱不能用来找回密码以外的场景
+	EmailVerified bool
 
 	Phone string
 
@@ -16,6 +18,32 @@ type User struct {
 	NickName string
 	Birthday string
 	AboutMe  string
+	// Avatar 头像的地址，来自 Media
+	Avatar string
 
-	WechatInfo WechatInfo
+	// Roles 例如 ginx.RoleAdmin，登录的时候放进 token 里面
+	Roles []string
+
+	Totp Totp
+}
+
+// Totp 两步验证
+type Totp struct {
+	// Secret 已经生成，但是 Enabled 为 false，说明用户还没有确认
+	Secret  string
+	Enabled bool
+	// RecoveryCodes 恢复码的摘要，用掉一个删掉一个
+	RecoveryCodes []string
+	// LastStep 最近一次用过的动态码所在的周期
+	LastStep int64
 }
+
+// LoginMethod 登录方式，用户至少要保留一种
+// 第三方登录的 LoginMethod 就是 Identity.Provider
+type LoginMethod string
+
+const (
+	// LoginMethodEmail 邮箱和密码
+	LoginMethodEmail LoginMethod = "email"
+	LoginMethodPhone LoginMethod = "phone"
+)
@@ -12,6 +12,18 @@ const (
 	UserInvalidOrPassword = 401002
 	// UserDuplicateEmail 邮箱冲突
 	UserDuplicateEmail = 401003
+	// UserInvalidToken 邮件里面的链接无效或者已经过期
+	UserInvalidToken = 401004
+	// UserTooManyRequests 发送邮件太频繁
+	UserTooManyRequests = 401005
+	// UserMfaRequired 密码正确，还需要输入两步验证的动态码
+	UserMfaRequired = 401006
+	// UserInvalidMfaCode 动态码或者恢复码不对
+	UserInvalidMfaCode = 401007
+	// UserBindingConflict 邮箱、手机号或者微信已经绑定了其它账号
+	UserBindingConflict = 401008
+	// UserLoginLocked 登录失败太多，账号被锁定，可以用短信解锁
+	UserLoginLocked = 401009
 )
 
 // Article 部分，模块代码使用 02
@@ -20,3 +32,12 @@ const (
 	ArticleInvalidInput        = 402001
 	ArticleInternalServerError = 502001
 )
+
+// Media 部分，模块代码使用 03
+const (
+	// MediaInvalidInput 类型不支持、文件太大或者摘要格式不对
+	MediaInvalidInput = 403001
+	// MediaNotUploaded 还没有上传，或者上传的内容和声明的不一致
+	MediaNotUploaded         = 403002
+	MediaInternalServerError = 503001
+)
@@ -14,20 +14,17 @@ type HistoryRecordConsumer struct {
 	repo   repository.HistoryRecordRepository
 	client sarama.Client
 	l      logger.LoggerV1
+	cg     *saramax.GroupConsumer
 }
 
 func (h *HistoryRecordConsumer) Start() error {
-	cg, err := sarama.NewConsumerGroupFromClient("history", h.client)
-	if err != nil {
-		return err
-	}
-	go func() {
-		er := cg.Consume(context.Background(), []string{TopicReadEvent}, saramax.NewBatchHandler[ReadEvent](h.BatchConsume, h.l))
-		if er != nil {
-			h.l.Error("退出消费", logger.Error(er))
-		}
-	}()
-	return err
+	h.cg = saramax.NewGroupConsumer(h.client, "history", []string{TopicReadEvent},
+		saramax.NewBatchHandler[ReadEvent](h.BatchConsume, h.l), h.l)
+	return h.cg.Start()
+}
+
+func (h *HistoryRecordConsumer) Stop(ctx context.Context) error {
+	return h.cg.Stop(ctx)
 }
 
 func (h *HistoryRecordConsumer) BatchConsume(msgs []*sarama.ConsumerMessage, events []ReadEvent) error {
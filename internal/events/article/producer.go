@@ -1,14 +1,20 @@
 package article
 
 import (
-	"encoding/json"
+	"context"
 	"github.com/IBM/sarama"
 	"github.com/prometheus/client_golang/prometheus"
 	"time"
+	"webook/pkg/saramax"
 )
 
 const TopicReadEvent = "article_read"
 
+func init() {
+	saramax.DefaultRegistry.Register(TopicReadEvent, "article.read",
+		saramax.Version{Version: 1, Sample: ReadEvent{}})
+}
+
 type Producer interface {
 	ProduceReadEvent(evt ReadEvent) error
 }
@@ -47,15 +53,12 @@ func NewSaramaSyncProducer(producer sarama.SyncProducer) Producer {
 }
 
 func (s *SaramaSyncProducer) ProduceReadEvent(evt ReadEvent) error {
-	val, err := json.Marshal(evt)
+	msg, err := saramax.DefaultRegistry.NewMessage(context.Background(), TopicReadEvent, evt)
 	if err != nil {
 		return err
 	}
 	start := time.Now()
-	_, _, err = s.producer.SendMessage(&sarama.ProducerMessage{
-		Topic: TopicReadEvent,
-		Value: sarama.StringEncoder(val),
-	})
+	_, _, err = s.producer.SendMessage(msg)
 	duration := time.Since(start).Milliseconds()
 	s.vector.WithLabelValues(TopicReadEvent).Observe(float64(duration))
 	return err
@@ -0,0 +1,55 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: .\internal\events\user\producer.go
+//
+// Generated by this command:
+//
+//	mockgen -source .\internal\events\user\producer.go -destination .\internal\events\user\mocks\producer_mock.go -package usermocks
+//
+
+// Package usermocks is a generated GoMock package.
+package usermocks
+
+import (
+	context "context"
+	reflect "reflect"
+	user "webook/internal/events/user"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockProducer is a mock of Producer interface.
+type MockProducer struct {
+	ctrl     *gomock.Controller
+	recorder *MockProducerMockRecorder
+}
+
+// MockProducerMockRecorder is the mock recorder for MockProducer.
+type MockProducerMockRecorder struct {
+	mock *MockProducer
+}
+
+// NewMockProducer creates a new mock instance.
+func NewMockProducer(ctrl *gomock.Controller) *MockProducer {
+	mock := &MockProducer{ctrl: ctrl}
+	mock.recorder = &MockProducerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockProducer) EXPECT() *MockProducerMockRecorder {
+	return m.recorder
+}
+
+// ProduceLoginRiskEvent mocks base method.
+func (m *MockProducer) ProduceLoginRiskEvent(ctx context.Context, evt user.LoginRiskEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProduceLoginRiskEvent", ctx, evt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ProduceLoginRiskEvent indicates an expected call of ProduceLoginRiskEvent.
+func (mr *MockProducerMockRecorder) ProduceLoginRiskEvent(ctx, evt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProduceLoginRiskEvent", reflect.TypeOf((*MockProducer)(nil).ProduceLoginRiskEvent), ctx, evt)
+}
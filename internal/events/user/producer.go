@@ -0,0 +1,60 @@
+package user
+
+import (
+	"context"
+	"github.com/IBM/sarama"
+	"webook/pkg/saramax"
+)
+
+const TopicLoginRiskEvent = "user_login_risk"
+
+// 可疑登录的原因
+const (
+	// RiskRepeatedFailure 账号连续登录失败，开始限制
+	RiskRepeatedFailure = "repeated_failure"
+	// RiskAccountLocked 账号失败太多被锁定
+	RiskAccountLocked = "account_locked"
+	// RiskIpBlocked 同一个 IP 失败太多，可能在撞库
+	RiskIpBlocked = "ip_blocked"
+)
+
+func init() {
+	saramax.DefaultRegistry.Register(TopicLoginRiskEvent, "user.login_risk",
+		saramax.Version{Version: 1, Sample: LoginRiskEvent{}})
+}
+
+type Producer interface {
+	ProduceLoginRiskEvent(ctx context.Context, evt LoginRiskEvent) error
+}
+
+// LoginRiskEvent 可疑的登录行为，给风控分析用
+type LoginRiskEvent struct {
+	// Account 登录用的邮箱或者手机号，不一定是注册过的
+	Account   string
+	Ip        string
+	UserAgent string
+	Reason    string
+	// Fails 账号连续失败的次数
+	Fails int64
+	// Ctime 毫秒
+	Ctime int64
+}
+
+type SaramaSyncProducer struct {
+	producer sarama.SyncProducer
+}
+
+func NewSaramaSyncProducer(producer sarama.SyncProducer) Producer {
+	return &SaramaSyncProducer{
+		producer: producer,
+	}
+}
+
+func (s *SaramaSyncProducer) ProduceLoginRiskEvent(ctx context.Context, evt LoginRiskEvent) error {
+	msg, err := saramax.DefaultRegistry.NewMessage(ctx, TopicLoginRiskEvent, evt)
+	if err != nil {
+		return err
+	}
+	_, _, err = s.producer.SendMessage(msg)
+	return err
+}
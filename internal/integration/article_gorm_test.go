@@ -13,6 +13,7 @@ import (
 	"webook/internal/integration/startup"
 	"webook/internal/repository/dao"
 	ijwt "webook/internal/web/jwt"
+	"webook/pkg/ginx"
 )
 
 // 测试套件
@@ -32,7 +33,7 @@ func (s *ArticleHandlerSuite) SetupSuite() {
 			UserId: 123,
 		})
 	})
-	hdl.RegisterRoutes(server)
+	hdl.RegisterRoutes(server, ginx.NewRegistry())
 	s.server = server
 
 }
@@ -17,6 +17,7 @@ import (
 	"webook/internal/integration/startup"
 	"webook/internal/repository/dao"
 	ijwt "webook/internal/web/jwt"
+	"webook/pkg/ginx"
 )
 
 // 测试套件
@@ -42,7 +43,7 @@ func (s *ArticleMongoDBHandlerSuite) SetupSuite() {
 			UserId: 123,
 		})
 	})
-	hdl.RegisterRoutes(server)
+	hdl.RegisterRoutes(server, ginx.NewRegistry())
 	s.server = server
 }
 
@@ -0,0 +1,178 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+	"webook/internal/integration/startup"
+	"webook/internal/repository/dao"
+	"webook/internal/service/oauth2"
+	"webook/internal/service/oauth2/oidc"
+	"webook/internal/service/oauth2/oidc/oidctest"
+	"webook/internal/web"
+	"webook/pkg/ginx"
+)
+
+func TestOAuth2Handler_OIDC(t *testing.T) {
+	db := startup.InitDB()
+	fake := oidctest.NewServer()
+	defer fake.Close()
+	p, err := oidc.NewProvider(context.Background(), oidc.Config{
+		Name:         "fake",
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  "http://localhost:8080/oauth2/fake/callback",
+		Issuer:       fake.Issuer(),
+		Scopes:       []string{"openid", "email", "profile"},
+	}, http.DefaultClient)
+	require.NoError(t, err)
+	server := gin.New()
+	// 回调只会登录，用不到 BindingService
+	web.NewOAuth2Handler(oauth2.NewRegistry(p), startup.InitJwtHdl(), startup.InitUserSvc(), nil).
+		RegisterRoutes(server, ginx.NewRegistry())
+
+	testCases := []struct {
+		name string
+		user oidctest.User
+		// before 准备数据
+		before func(t *testing.T)
+		// state 回调的时候带的 state，模拟 CSRF
+		state func(state string) string
+		// after 验证和删除数据
+		after func(t *testing.T)
+
+		wantMsg string
+	}{
+		{
+			name: "第一次登录，自动注册",
+			user: oidctest.User{Subject: "oidc-1", Email: "oidc-1@webook.com", Name: "oidc-1"},
+			before: func(t *testing.T) {
+			},
+			state: func(state string) string {
+				return state
+			},
+			after: func(t *testing.T) {
+				identity := findIdentity(t, db, "oidc-1")
+				var u dao.User
+				err := db.Where("id=?", identity.Uid).First(&u).Error
+				require.NoError(t, err)
+				assert.Equal(t, "oidc-1", u.NickName)
+				assert.Equal(t, "oidc-1@webook.com", identity.Email)
+				db.Where("id=?", identity.Uid).Delete(&dao.User{})
+				db.Where("id=?", identity.Id).Delete(&dao.UserIdentity{})
+			},
+			wantMsg: "登录成功",
+		},
+		{
+			name: "已经注册过，登录原来的账号",
+			user: oidctest.User{Subject: "oidc-2"},
+			before: func(t *testing.T) {
+				now := time.Now().UnixMilli()
+				u := dao.User{Id: 10002, Ctime: now, Utime: now}
+				require.NoError(t, db.Create(&u).Error)
+				require.NoError(t, db.Create(&dao.UserIdentity{
+					Uid: 10002, Provider: "fake", Subject: "oidc-2", Ctime: now, Utime: now,
+				}).Error)
+			},
+			state: func(state string) string {
+				return state
+			},
+			after: func(t *testing.T) {
+				identity := findIdentity(t, db, "oidc-2")
+				assert.Equal(t, int64(10002), identity.Uid)
+				var cnt int64
+				err := db.Model(&dao.UserIdentity{}).Where("provider=? AND subject=?", "fake", "oidc-2").
+					Count(&cnt).Error
+				require.NoError(t, err)
+				assert.Equal(t, int64(1), cnt)
+				db.Where("id=?", 10002).Delete(&dao.User{})
+				db.Where("id=?", identity.Id).Delete(&dao.UserIdentity{})
+			},
+			wantMsg: "登录成功",
+		},
+		{
+			name: "state 不对",
+			user: oidctest.User{Subject: "oidc-3"},
+			before: func(t *testing.T) {
+			},
+			state: func(state string) string {
+				return "hacker"
+			},
+			after: func(t *testing.T) {
+				var cnt int64
+				err := db.Model(&dao.UserIdentity{}).Where("provider=? AND subject=?", "fake", "oidc-3").
+					Count(&cnt).Error
+				require.NoError(t, err)
+				assert.Equal(t, int64(0), cnt)
+			},
+			wantMsg: "非法请求",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.before(t)
+			defer tc.after(t)
+			fake.SetUser(tc.user)
+
+			// 1. 拿到跳转地址和 state cookie
+			req := httptest.NewRequest(http.MethodGet, "/oauth2/fake/authurl", nil)
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+			require.Equal(t, http.StatusOK, recorder.Code)
+			var res ginx.Result
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+			cookies := recorder.Result().Cookies()
+			require.Len(t, cookies, 1)
+
+			// 2. 授权，从跳转地址里面拿到授权码
+			location := authorize(t, res.Data.(string))
+
+			// 3. 回调
+			params := location.Query()
+			params.Set("state", tc.state(params.Get("state")))
+			req = httptest.NewRequest(http.MethodGet, "/oauth2/fake/callback?"+params.Encode(), nil)
+			req.AddCookie(cookies[0])
+			recorder = httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+			require.Equal(t, http.StatusOK, recorder.Code)
+			res = ginx.Result{}
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+			assert.Equal(t, tc.wantMsg, res.Msg)
+			if tc.wantMsg == "登录成功" {
+				assert.NotEmpty(t, recorder.Header().Get("x-jwt-token"))
+			}
+		})
+	}
+}
+
+// authorize 不跟随跳转，返回授权服务器跳回来的地址
+func authorize(t *testing.T, authURL string) *url.URL {
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	return location
+}
+
+func findIdentity(t *testing.T, db *gorm.DB, subject string) dao.UserIdentity {
+	var identity dao.UserIdentity
+	err := db.Where("provider=? AND subject=?", "fake", subject).First(&identity).Error
+	require.NoError(t, err)
+	return identity
+}
@@ -0,0 +1,150 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"webook/internal/integration/startup"
+	"webook/internal/web"
+	ijwt "webook/internal/web/jwt"
+	"webook/pkg/ginx"
+)
+
+func TestUserHandler_RefreshToken(t *testing.T) {
+	const uid = int64(123)
+	rdb := startup.InitRedis()
+	hdl := startup.InitJwtHdl()
+	server := gin.New()
+	// RefreshToken 用不到 UserService、CodeService 和 AccountService
+	web.NewUserHandler(nil, hdl, nil, nil, nil, nil, nil, nil).RegisterRoutes(server, ginx.NewRegistry())
+
+	testCases := []struct {
+		name string
+		// before 登录，返回要提交的长 token
+		before func(t *testing.T) string
+		// after 验证和删除数据
+		after func(t *testing.T, ssid string)
+
+		wantCode int
+	}{
+		{
+			name: "刷新成功，换了新的长 token",
+			before: func(t *testing.T) string {
+				return login(t, hdl, uid)
+			},
+			after: func(t *testing.T, ssid string) {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+				defer cancel()
+				ok, err := rdb.HExists(ctx, sessionsKey(uid), ssid).Result()
+				require.NoError(t, err)
+				assert.True(t, ok)
+				clearSessions(t, rdb, uid, ssid)
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "用过的长 token 再次提交，整个会话作废",
+			before: func(t *testing.T) string {
+				tokenStr := login(t, hdl, uid)
+				recorder := refresh(server, tokenStr)
+				require.Equal(t, http.StatusOK, recorder.Code)
+				return tokenStr
+			},
+			after: func(t *testing.T, ssid string) {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+				defer cancel()
+				ok, err := rdb.HExists(ctx, sessionsKey(uid), ssid).Result()
+				require.NoError(t, err)
+				assert.False(t, ok)
+				cnt, err := rdb.Exists(ctx, refreshKey(uid, ssid)).Result()
+				require.NoError(t, err)
+				assert.Equal(t, int64(0), cnt)
+				clearSessions(t, rdb, uid, ssid)
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name: "会话已经退出登录",
+			before: func(t *testing.T) string {
+				tokenStr := login(t, hdl, uid)
+				rc, err := hdl.VerifyRefreshToken(tokenStr)
+				require.NoError(t, err)
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+				defer cancel()
+				err = rdb.HDel(ctx, sessionsKey(uid), rc.Ssid).Err()
+				require.NoError(t, err)
+				return tokenStr
+			},
+			after: func(t *testing.T, ssid string) {
+				clearSessions(t, rdb, uid, ssid)
+			},
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tokenStr := tc.before(t)
+			rc, err := hdl.VerifyRefreshToken(tokenStr)
+			require.NoError(t, err)
+			defer tc.after(t, rc.Ssid)
+
+			recorder := refresh(server, tokenStr)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			if tc.wantCode != http.StatusOK {
+				return
+			}
+			newToken := recorder.Header().Get("x-refresh-token")
+			assert.NotEqual(t, tokenStr, newToken)
+			assert.NotEmpty(t, recorder.Header().Get("x-jwt-token"))
+
+			// 新的长 token 可以继续刷新，而且过期时间没有变
+			newRc, err := hdl.VerifyRefreshToken(newToken)
+			require.NoError(t, err)
+			assert.Equal(t, rc.Ssid, newRc.Ssid)
+			assert.Equal(t, rc.ExpiresAt.Unix(), newRc.ExpiresAt.Unix())
+			recorder = refresh(server, newToken)
+			assert.Equal(t, http.StatusOK, recorder.Code)
+		})
+	}
+}
+
+// login 登录，返回长 token
+func login(t *testing.T, hdl ijwt.Handler, uid int64) string {
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/users/login", nil)
+	err := hdl.SetLoginToken(ctx, uid, nil)
+	require.NoError(t, err)
+	return recorder.Header().Get("x-refresh-token")
+}
+
+func refresh(server *gin.Engine, tokenStr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/users/refresh_token", nil)
+	req.Header.Set("Authorization", "Bearer "+tokenStr)
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func clearSessions(t *testing.T, rdb redis.Cmdable, uid int64, ssid string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	err := rdb.Del(ctx, sessionsKey(uid), fmt.Sprintf("users:sessions:%d:order", uid), refreshKey(uid, ssid)).Err()
+	assert.NoError(t, err)
+}
+
+func sessionsKey(uid int64) string {
+	return fmt.Sprintf("users:sessions:%d", uid)
+}
+
+func refreshKey(uid int64, ssid string) string {
+	return fmt.Sprintf("users:sessions:%d:refresh:%s", uid, ssid)
+}
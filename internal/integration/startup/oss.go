@@ -0,0 +1,12 @@
+package startup
+
+import (
+	"webook/internal/repository/dao"
+	"webook/internal/repository/dao/s3test"
+)
+
+// InitObjectDAO 集成测试用本地的 S3 兼容服务器
+func InitObjectDAO() dao.ObjectDAO {
+	server := s3test.NewServer()
+	return dao.NewS3ObjectDAO(server.Client(), s3test.Bucket, server.URL+"/"+s3test.Bucket)
+}
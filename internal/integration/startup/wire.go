@@ -5,11 +5,14 @@ package startup
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/wire"
+	intrv1 "webook/api/proto/gen/intr/v1"
 	repository2 "webook/interactive/repository"
 	cache2 "webook/interactive/repository/cache"
 	dao2 "webook/interactive/repository/dao"
 	service2 "webook/interactive/service"
+	"webook/internal/client"
 	"webook/internal/events/article"
+	"webook/internal/events/user"
 	"webook/internal/job"
 	"webook/internal/repository"
 	"webook/internal/repository/cache"
@@ -31,7 +34,14 @@ var userSvcProvider = wire.NewSet(
 	dao.NewUserDAO,
 	cache.NewRedisUserCache,
 	repository.NewCachedUserRepository,
-	service.NewUserService)
+	dao.NewUserIdentityDAO,
+	repository.NewUserIdentityRepository,
+	service.NewUserService,
+	cache.NewRedisUserTokenCache,
+	repository.NewCachedUserTokenRepository,
+	ioc.InitEmailService,
+	ioc.InitAccountService,
+	service.NewTotpService)
 
 var articleSvcProvider = wire.NewSet(
 	dao.NewArticleGORMDAO,
@@ -53,6 +63,12 @@ var interactiveSvcProvider = wire.NewSet(
 	cache2.NewInteractiveRedisCache,
 )
 
+// 集成测试不启动 Interactive 的 gRPC 服务，直接调用本地实现
+var intrClientProvider = wire.NewSet(
+	client.NewLocalInteractiveServiceAdapter,
+	wire.Bind(new(intrv1.InteractiveServiceClient), new(*client.LocalInteractiveServiceAdapter)),
+)
+
 var jobProviderSet = wire.NewSet(
 	service.NewCronJobService,
 	repository.NewPreemptJobRepository,
@@ -65,6 +81,7 @@ func InitWebServer() *gin.Engine {
 		userSvcProvider,
 		articleSvcProvider,
 		interactiveSvcProvider,
+		intrClientProvider,
 		cache.NewRedisCodeCache,
 		repository.NewCachedCodeRepository,
 		// service 部分
@@ -74,14 +91,26 @@ func InitWebServer() *gin.Engine {
 		// 指定啥也不干的 wechat service
 		InitWechatService,
 		service.NewCodeService,
+		ioc.InitBindingService,
+		cache.NewRedisLoginGuardCache,
+		repository.NewCachedLoginGuardRepository,
+		user.NewSaramaSyncProducer,
+		ioc.InitLoginGuardService,
+		dao.NewGORMMediaDAO,
+		InitObjectDAO,
+		repository.NewMediaRepository,
+		service.NewMediaService,
 		// handler 部分
 		web.NewUserHandler,
-		web.NewOAuth2WechatHandler,
+		web.NewOAuth2Handler,
+		web.NewMediaHandler,
+		ioc.InitOAuth2Registry,
 		web.NewArticleHandler,
 		//web.NewObservabilityHandler,
-		ijwt.NewRedisJWTHandler,
+		ioc.InitJWTHandler,
 
 		// gin 的中间件
+		ioc.InitRouteRegistry,
 		ioc.InitGinMiddlewares,
 
 		// Web 服务器
@@ -95,6 +124,7 @@ func InitArticleHandler(dao dao.ArticleDAO) *web.ArticleHandler {
 	wire.Build(thirdProvider,
 		userSvcProvider,
 		interactiveSvcProvider,
+		intrClientProvider,
 		article.NewSaramaSyncProducer,
 		cache.NewArticleRedisCache,
 		//wire.InterfaceValue(new(article.ArticleDAO), dao),
@@ -106,7 +136,7 @@ func InitArticleHandler(dao dao.ArticleDAO) *web.ArticleHandler {
 
 func InitUserSvc() service.UserService {
 	wire.Build(thirdProvider, userSvcProvider)
-	return service.NewUserService(nil)
+	return service.NewUserService(nil, nil)
 }
 
 func InitAsyncSmsService(svc sms.Service) *async.Service {
@@ -120,6 +150,7 @@ func InitAsyncSmsService(svc sms.Service) *async.Service {
 func InitRankingService() service.RankingService {
 	wire.Build(thirdProvider,
 		interactiveSvcProvider,
+		intrClientProvider,
 		articleSvcProvider,
 		// 用不上这个 user repo，所以随便搞一个
 		wire.InterfaceValue(new(repository.UserRepository),
@@ -139,6 +170,6 @@ func InitJobScheduler() *job.Scheduler {
 }
 
 func InitJwtHdl() ijwt.Handler {
-	wire.Build(thirdProvider, ijwt.NewRedisJWTHandler)
-	return ijwt.NewRedisJWTHandler(nil)
+	wire.Build(thirdProvider, ioc.InitJWTHandler)
+	return ijwt.NewRedisJWTHandler(nil, nil, nil)
 }
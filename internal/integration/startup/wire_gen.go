@@ -13,7 +13,9 @@ import (
 	cache2 "webook/interactive/repository/cache"
 	dao2 "webook/interactive/repository/dao"
 	service2 "webook/interactive/service"
+	client2 "webook/internal/client"
 	"webook/internal/events/article"
+	"webook/internal/events/user"
 	"webook/internal/job"
 	"webook/internal/repository"
 	"webook/internal/repository/cache"
@@ -31,34 +33,54 @@ import (
 //go:generate wire
 func InitWebServer() *gin.Engine {
 	cmdable := InitRedis()
-	handler := jwt.NewRedisJWTHandler(cmdable)
 	loggerV1 := InitLogger()
-	v := ioc.InitGinMiddlewares(cmdable, handler, loggerV1)
+	handler := ioc.InitJWTHandler(cmdable, loggerV1)
+	registry := ioc.InitRouteRegistry()
+	v := ioc.InitGinMiddlewares(cmdable, handler, registry, loggerV1)
 	db := InitDB()
 	userDAO := dao.NewUserDAO(db)
 	userCache := cache.NewRedisUserCache(cmdable)
 	userRepository := repository.NewCachedUserRepository(userDAO, userCache)
-	userService := service.NewUserService(userRepository)
+	userIdentityDAO := dao.NewUserIdentityDAO(db)
+	userIdentityRepository := repository.NewUserIdentityRepository(userIdentityDAO)
+	userService := service.NewUserService(userRepository, userIdentityRepository)
 	codeCache := cache.NewRedisCodeCache(cmdable)
 	codeRepository := repository.NewCachedCodeRepository(codeCache)
 	smsService := ioc.InitMemorySMSService()
 	codeService := service.NewCodeService(codeRepository, smsService)
-	userHandler := web.NewUserHandler(userService, handler, codeService)
+	userTokenCache := cache.NewRedisUserTokenCache(cmdable)
+	userTokenRepository := repository.NewCachedUserTokenRepository(userTokenCache)
+	emailService := ioc.InitEmailService()
+	accountService := ioc.InitAccountService(userRepository, userTokenRepository, emailService)
+	totpService := service.NewTotpService(userRepository)
+	bindingService := ioc.InitBindingService(userRepository, userIdentityRepository, codeService, totpService, userTokenRepository, emailService)
+	loginGuardCache := cache.NewRedisLoginGuardCache(cmdable)
+	loginGuardRepository := repository.NewCachedLoginGuardRepository(loginGuardCache)
+	client := InitSaramaClient()
+	syncProducer := InitSyncProducer(client)
+	userProducer := user.NewSaramaSyncProducer(syncProducer)
+	loginGuardService := ioc.InitLoginGuardService(cmdable, loginGuardRepository, userRepository, codeService, userProducer, loggerV1)
+	mediaDAO := dao.NewGORMMediaDAO(db)
+	objectDAO := InitObjectDAO()
+	mediaRepository := repository.NewMediaRepository(mediaDAO, objectDAO)
+	mediaService := service.NewMediaService(mediaRepository, userRepository)
+	userHandler := web.NewUserHandler(userService, handler, codeService, accountService, totpService, bindingService, loginGuardService, mediaService)
 	wechatService := InitWechatService(loggerV1)
-	oAuth2WechatHandler := web.NewOAuth2WechatHandler(wechatService, handler, userService)
+	oauth2Registry := ioc.InitOAuth2Registry(wechatService, loggerV1)
+	oAuth2Handler := web.NewOAuth2Handler(oauth2Registry, handler, userService, bindingService)
 	articleDAO := dao.NewArticleGORMDAO(db)
 	articleCache := cache.NewArticleRedisCache(cmdable)
 	articleRepository := repository.NewCachedArticleRepository(articleDAO, articleCache, userRepository)
-	client := InitSaramaClient()
-	syncProducer := InitSyncProducer(client)
 	producer := article.NewSaramaSyncProducer(syncProducer)
 	articleService := service.NewArticleService(articleRepository, producer)
 	interactiveDAO := dao2.NewGORMInteractiveDAO(db)
 	interactiveCache := cache2.NewInteractiveRedisCache(cmdable)
 	interactiveRepository := repository2.NewCachedInteractiveRepository(interactiveDAO, interactiveCache, loggerV1)
 	interactiveService := service2.NewInteractiveService(interactiveRepository)
-	articleHandler := web.NewArticleHandler(loggerV1, articleService, interactiveService)
-	engine := ioc.InitWebServer(v, userHandler, oAuth2WechatHandler, articleHandler, loggerV1)
+	localInteractiveServiceAdapter := client2.NewLocalInteractiveServiceAdapter(interactiveService)
+	articleHandler := web.NewArticleHandler(loggerV1, articleService, localInteractiveServiceAdapter)
+	mediaHandler := web.NewMediaHandler(mediaService)
+	engine := ioc.InitWebServer(v, registry, userHandler, oAuth2Handler, articleHandler, mediaHandler, loggerV1)
 	return engine
 }
 
@@ -79,7 +101,8 @@ func InitArticleHandler(dao3 dao.ArticleDAO) *web.ArticleHandler {
 	interactiveCache := cache2.NewInteractiveRedisCache(cmdable)
 	interactiveRepository := repository2.NewCachedInteractiveRepository(interactiveDAO, interactiveCache, loggerV1)
 	interactiveService := service2.NewInteractiveService(interactiveRepository)
-	articleHandler := web.NewArticleHandler(loggerV1, articleService, interactiveService)
+	localInteractiveServiceAdapter := client2.NewLocalInteractiveServiceAdapter(interactiveService)
+	articleHandler := web.NewArticleHandler(loggerV1, articleService, localInteractiveServiceAdapter)
 	return articleHandler
 }
 
@@ -89,7 +112,9 @@ func InitUserSvc() service.UserService {
 	cmdable := InitRedis()
 	userCache := cache.NewRedisUserCache(cmdable)
 	userRepository := repository.NewCachedUserRepository(userDAO, userCache)
-	userService := service.NewUserService(userRepository)
+	userIdentityDAO := dao.NewUserIdentityDAO(db)
+	userIdentityRepository := repository.NewUserIdentityRepository(userIdentityDAO)
+	userService := service.NewUserService(userRepository, userIdentityRepository)
 	return userService
 }
 
@@ -120,7 +145,8 @@ func InitRankingService() service.RankingService {
 	articleService := service.NewArticleService(articleRepository, producer)
 	rankingCache := cache.NewRankingRedisCache(cmdable)
 	rankingRepository := repository.NewCachedOnlyRankingRepository(rankingCache)
-	rankingService := service.NewBatchRankingService(interactiveService, articleService, rankingRepository)
+	localInteractiveServiceAdapter := client2.NewLocalInteractiveServiceAdapter(interactiveService)
+	rankingService := service.NewBatchRankingService(localInteractiveServiceAdapter, articleService, rankingRepository)
 	return rankingService
 }
 
@@ -151,7 +177,8 @@ func InitJobScheduler() *job.Scheduler {
 
 func InitJwtHdl() jwt.Handler {
 	cmdable := InitRedis()
-	handler := jwt.NewRedisJWTHandler(cmdable)
+	loggerV1 := InitLogger()
+	handler := ioc.InitJWTHandler(cmdable, loggerV1)
 	return handler
 }
 
@@ -163,7 +190,7 @@ var thirdProvider = wire.NewSet(InitRedis, InitDB,
 	InitSaramaClient,
 )
 
-var userSvcProvider = wire.NewSet(dao.NewUserDAO, cache.NewRedisUserCache, repository.NewCachedUserRepository, service.NewUserService)
+var userSvcProvider = wire.NewSet(dao.NewUserDAO, cache.NewRedisUserCache, repository.NewCachedUserRepository, dao.NewUserIdentityDAO, repository.NewUserIdentityRepository, service.NewUserService, cache.NewRedisUserTokenCache, repository.NewCachedUserTokenRepository, ioc.InitEmailService, ioc.InitAccountService, service.NewTotpService)
 
 var articleSvcProvider = wire.NewSet(dao.NewArticleGORMDAO, article.NewSaramaSyncProducer, cache.NewArticleRedisCache, repository.NewCachedArticleRepository, service.NewArticleService)
 
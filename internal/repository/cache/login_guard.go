@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+	"webook/internal/domain"
+)
+
+var (
+	//go:embed lua/incr_login_fail.lua
+	luaIncrLoginFail string
+	//go:embed lua/check_login_block.lua
+	luaCheckLoginBlock string
+)
+
+// LoginGuardCache 登录失败计数
+// 账号连续失败几次之后，每次失败都要等一段时间才能再试，等待时间翻倍，失败太多直接锁定
+type LoginGuardCache interface {
+	// Fail 记录账号登录失败一次
+	Fail(ctx context.Context, account string) (domain.LoginBlock, error)
+	// Block 返回账号和 IP 里面限制得最严的那个，没有限制的时候 Wait 是 0
+	Block(ctx context.Context, account, ip string) (domain.LoginBlock, error)
+	// BlockIP 暂时不让这个 IP 登录
+	BlockIP(ctx context.Context, ip string, expiration time.Duration) error
+	// Reset 登录成功或者解锁之后清空失败次数
+	Reset(ctx context.Context, account string) error
+}
+
+type RedisLoginGuardCache struct {
+	cmd redis.Cmdable
+	// window 这么久没有再失败，失败次数清零
+	window time.Duration
+	// free 前几次失败不限制
+	free int
+	// max 失败这么多次锁定账号
+	max      int
+	lock     time.Duration
+	maxDelay time.Duration
+}
+
+func NewRedisLoginGuardCache(cmd redis.Cmdable) LoginGuardCache {
+	return &RedisLoginGuardCache{
+		cmd:      cmd,
+		window:   time.Minute * 15,
+		free:     3,
+		max:      10,
+		lock:     time.Minute * 30,
+		maxDelay: time.Minute,
+	}
+}
+
+func (c *RedisLoginGuardCache) Fail(ctx context.Context, account string) (domain.LoginBlock, error) {
+	res, err := c.cmd.Eval(ctx, luaIncrLoginFail, []string{c.cntKey(account), c.accountKey(account)},
+		int64(c.window/time.Second), c.free, c.max,
+		int64(c.lock/time.Second), int64(c.maxDelay/time.Second)).Int64Slice()
+	if err != nil {
+		return domain.LoginBlock{}, err
+	}
+	return domain.LoginBlock{
+		Fails:  res[0],
+		Wait:   time.Duration(res[1]) * time.Second,
+		Locked: res[2] == 1,
+	}, nil
+}
+
+func (c *RedisLoginGuardCache) Block(ctx context.Context, account, ip string) (domain.LoginBlock, error) {
+	res, err := c.cmd.Eval(ctx, luaCheckLoginBlock, []string{c.accountKey(account), c.ipKey(ip)}).Int64Slice()
+	if err != nil {
+		return domain.LoginBlock{}, err
+	}
+	return domain.LoginBlock{
+		Wait:   time.Duration(res[1]) * time.Millisecond,
+		Locked: res[0] == 1,
+	}, nil
+}
+
+func (c *RedisLoginGuardCache) BlockIP(ctx context.Context, ip string, expiration time.Duration) error {
+	return c.cmd.Set(ctx, c.ipKey(ip), "delay", expiration).Err()
+}
+
+func (c *RedisLoginGuardCache) Reset(ctx context.Context, account string) error {
+	return c.cmd.Del(ctx, c.cntKey(account), c.accountKey(account)).Err()
+}
+
+func (c *RedisLoginGuardCache) cntKey(account string) string {
+	return fmt.Sprintf("login_fail:cnt:%s", account)
+}
+
+func (c *RedisLoginGuardCache) accountKey(account string) string {
+	return fmt.Sprintf("login_fail:block:account:%s", account)
+}
+
+func (c *RedisLoginGuardCache) ipKey(ip string) string {
+	return fmt.Sprintf("login_fail:block:ip:%s", ip)
+}
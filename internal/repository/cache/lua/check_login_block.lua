@@ -0,0 +1,16 @@
+-- 账号和 IP 限制登录的 key，值是 delay 或者 lock
+-- 返回 {是否锁定, 还要等多少毫秒}，锁定优先
+local res = {0, 0}
+for _, key in ipairs(KEYS) do
+    local val = redis.call("get", key)
+    if val then
+        local ttl = redis.call("pttl", key)
+        if val == "lock" then
+            return {1, ttl}
+        end
+        if ttl > res[2] then
+            res[2] = ttl
+        end
+    end
+end
+return res
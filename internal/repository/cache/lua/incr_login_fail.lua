@@ -0,0 +1,31 @@
+-- 账号连续失败的次数
+local cntKey = KEYS[1]
+-- 限制登录的 key，值是 delay 或者 lock，过期了就能再次登录
+local blockKey = KEYS[2]
+-- 多少秒之内没有再失败，次数清零
+local window = tonumber(ARGV[1])
+-- 前几次失败不限制
+local free = tonumber(ARGV[2])
+-- 失败多少次锁定账号
+local max = tonumber(ARGV[3])
+-- 锁定多少秒
+local lock = tonumber(ARGV[4])
+-- 最多延迟多少秒
+local maxDelay = tonumber(ARGV[5])
+
+local cnt = redis.call("incr", cntKey)
+if cnt >= max then
+    -- 锁定过期之后次数还在，再错一次就会被重新锁定
+    redis.call("expire", cntKey, lock + window)
+    redis.call("set", blockKey, "lock", "EX", lock)
+    return {cnt, lock, 1}
+end
+
+redis.call("expire", cntKey, window)
+if cnt <= free then
+    return {cnt, 0, 0}
+end
+-- 每多错一次，等待的时间翻倍
+local delay = math.min(math.floor(2 ^ (cnt - free)), maxDelay)
+redis.call("set", blockKey, "delay", "EX", delay)
+return {cnt, delay, 0}
@@ -0,0 +1,25 @@
+-- token 对应的 key，值是用户 id
+local tokenKey = KEYS[1]
+-- 用户当前有效的 token 对应的 key
+local userKey = KEYS[2]
+local uid = ARGV[1]
+-- 过期时间，秒
+local expiration = tonumber(ARGV[2])
+-- 两次申请至少间隔多少秒
+local interval = tonumber(ARGV[3])
+
+local ttl = tonumber(redis.call("ttl", userKey))
+if ttl > expiration - interval then
+    -- 申请太频繁
+    return -1
+end
+
+-- 同一个用户只有最新的 token 有效
+local old = redis.call("get", userKey)
+if old then
+    redis.call("del", old)
+end
+
+redis.call("set", tokenKey, uid, "EX", expiration)
+redis.call("set", userKey, tokenKey, "EX", expiration)
+return 0
@@ -14,6 +14,7 @@ var ErrKeyNotExist = redis.Nil
 type UserCache interface {
 	Get(ctx context.Context, uid int64) (domain.User, error)
 	Set(ctx context.Context, du domain.User) error
+	Del(ctx context.Context, uid int64) error
 }
 
 type RedisUserCache struct {
@@ -49,8 +50,11 @@ func (c *RedisUserCache) Get(ctx context.Context, uid int64) (domain.User, error
 	return u, err
 }
 
+// Set 不缓存密码和两步验证的密钥、恢复码，要用这些的时候直接查数据库
 func (c *RedisUserCache) Set(ctx context.Context, du domain.User) error {
 	key := c.key(du.Id)
+	du.Password = ""
+	du.Totp = domain.Totp{Enabled: du.Totp.Enabled}
 	data, err := json.Marshal(du)
 	if err != nil {
 		return err
@@ -58,5 +62,9 @@ func (c *RedisUserCache) Set(ctx context.Context, du domain.User) error {
 	return c.cmd.Set(ctx, key, data, c.expiration).Err()
 }
 
+func (c *RedisUserCache) Del(ctx context.Context, uid int64) error {
+	return c.cmd.Del(ctx, c.key(uid)).Err()
+}
+
 type MemoryUserCache struct {
 }
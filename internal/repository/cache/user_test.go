@@ -1 +1,45 @@
 package cache
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository/cache/redismocks"
+)
+
+func TestRedisUserCache_Set(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := redismocks.NewMockCmdable(ctrl)
+	var saved []byte
+	cmd.EXPECT().Set(gomock.Any(), "user:info:123", gomock.Any(), time.Minute*15).
+		DoAndReturn(func(ctx context.Context, key string, val any, exp time.Duration) *redis.StatusCmd {
+			saved = val.([]byte)
+			return redis.NewStatusCmd(ctx)
+		})
+
+	err := NewRedisUserCache(cmd).Set(context.Background(), domain.User{
+		Id:       123,
+		Email:    "123@qq.com",
+		Password: "hash",
+		Totp: domain.Totp{
+			Secret:        "secret",
+			Enabled:       true,
+			RecoveryCodes: []string{"code"},
+		},
+	})
+	require.NoError(t, err)
+
+	// 密码和两步验证的密钥、恢复码不进缓存
+	var u domain.User
+	require.NoError(t, json.Unmarshal(saved, &u))
+	assert.Equal(t, "123@qq.com", u.Email)
+	assert.Empty(t, u.Password)
+	assert.Equal(t, domain.Totp{Enabled: true}, u.Totp)
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+var (
+	//go:embed lua/set_user_token.lua
+	luaSetUserToken string
+
+	ErrTokenSendTooMany = errors.New("申请太频繁")
+	ErrTokenNotFound    = errors.New("token 不存在或者已经过期")
+)
+
+// UserTokenCache 验证邮箱、重置密码这类发到用户邮箱里面的一次性 token
+type UserTokenCache interface {
+	// Set 同一个 biz 下，用户只有最新的 token 有效，而且申请有最小间隔
+	Set(ctx context.Context, biz string, uid int64, token string, expiration time.Duration) error
+	// Consume 返回 token 对应的用户 id，token 只能用一次
+	Consume(ctx context.Context, biz string, token string) (int64, error)
+}
+
+type RedisUserTokenCache struct {
+	cmd      redis.Cmdable
+	interval time.Duration
+}
+
+func NewRedisUserTokenCache(cmd redis.Cmdable) UserTokenCache {
+	return &RedisUserTokenCache{
+		cmd:      cmd,
+		interval: time.Minute,
+	}
+}
+
+func (c *RedisUserTokenCache) Set(ctx context.Context, biz string, uid int64, token string, expiration time.Duration) error {
+	res, err := c.cmd.Eval(ctx, luaSetUserToken, []string{c.tokenKey(biz, token), c.userKey(biz, uid)},
+		uid, int64(expiration/time.Second), int64(c.interval/time.Second)).Int()
+	if err != nil {
+		return err
+	}
+	if res == -1 {
+		return ErrTokenSendTooMany
+	}
+	return nil
+}
+
+func (c *RedisUserTokenCache) Consume(ctx context.Context, biz string, token string) (int64, error) {
+	uid, err := c.cmd.GetDel(ctx, c.tokenKey(biz, token)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, ErrTokenNotFound
+	}
+	return uid, err
+}
+
+// tokenKey 只存 token 的摘要，redis 里面的数据泄露了也拿不到 token
+func (c *RedisUserTokenCache) tokenKey(biz string, token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return fmt.Sprintf("user_token:%s:%s", biz, hex.EncodeToString(sum[:]))
+}
+
+func (c *RedisUserTokenCache) userKey(biz string, uid int64) string {
+	return fmt.Sprintf("user_token:%s:uid:%d", biz, uid)
+}
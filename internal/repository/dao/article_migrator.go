@@ -0,0 +1,167 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"github.com/ecodeclub/ekit/syncx/atomicx"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+	"webook/pkg/gormx/connpool"
+	"webook/pkg/logger"
+	"webook/pkg/migrator"
+)
+
+// 线上库 published_articles 从 MySQL 迁移到 MongoDB
+
+func (p PublishedArticle) ID() int64 {
+	return p.Id
+}
+
+func (p PublishedArticle) CompareTo(dst migrator.Entity) bool {
+	return migrator.Equal(p, dst)
+}
+
+// PublishedArticleMongoStore MongoDB 里面的线上库实现 migrator.Store，用于双写、校验和修复
+type PublishedArticleMongoStore struct {
+	liveCol *mongo.Collection
+}
+
+// NewPublishedArticleMongoStore 和 MongoDBArticleDAO 用同一个 collection
+func NewPublishedArticleMongoStore(mdb *mongo.Database) migrator.Store[PublishedArticle] {
+	return &PublishedArticleMongoStore{liveCol: mdb.Collection("published_articles")}
+}
+
+func (p *PublishedArticleMongoStore) FindByIds(ctx context.Context, ids []int64) ([]PublishedArticle, error) {
+	return p.find(ctx, bson.D{bson.E{Key: "id", Value: bson.M{"$in": ids}}}, options.Find())
+}
+
+func (p *PublishedArticleMongoStore) Scan(ctx context.Context, after, until int64, limit int) ([]PublishedArticle, error) {
+	cond := bson.M{"$gt": after}
+	if until > 0 {
+		cond["$lte"] = until
+	}
+	return p.find(ctx, bson.D{bson.E{Key: "id", Value: cond}},
+		options.Find().SetSort(bson.D{bson.E{Key: "id", Value: 1}}).SetLimit(int64(limit)))
+}
+
+func (p *PublishedArticleMongoStore) MaxId(ctx context.Context) (int64, error) {
+	var art PublishedArticle
+	err := p.liveCol.FindOne(ctx, bson.D{},
+		options.FindOne().SetSort(bson.D{bson.E{Key: "id", Value: -1}})).Decode(&art)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	return art.Id, err
+}
+
+func (p *PublishedArticleMongoStore) Upsert(ctx context.Context, art PublishedArticle) error {
+	_, err := p.liveCol.ReplaceOne(ctx, bson.D{bson.E{Key: "id", Value: art.Id}}, art,
+		options.Replace().SetUpsert(true))
+	return err
+}
+
+func (p *PublishedArticleMongoStore) Delete(ctx context.Context, id int64) error {
+	_, err := p.liveCol.DeleteOne(ctx, bson.D{bson.E{Key: "id", Value: id}})
+	return err
+}
+
+func (p *PublishedArticleMongoStore) find(ctx context.Context, filter bson.D, opts *options.FindOptions) ([]PublishedArticle, error) {
+	cursor, err := p.liveCol.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var res []PublishedArticle
+	err = cursor.All(ctx, &res)
+	return res, err
+}
+
+// DoubleWriteArticleDAO 迁移期间把线上库同步写到 MongoDB
+// 制作库还是只在 MySQL 里面，线上库的数据是在 MySQL 的事务里面和制作库一起写的，
+// 所以写入总是先写 MySQL，成功之后再把线上库的数据同步到 MongoDB
+// pattern 决定线上库从哪一边读，以及要不要同步到 MongoDB，不一致的数据靠校验和修复兜底
+type DoubleWriteArticleDAO struct {
+	ArticleDAO
+	dst      ArticleDAO
+	dstStore migrator.Store[PublishedArticle]
+	pattern  *atomicx.Value[string]
+	l        logger.LoggerV1
+}
+
+// NewDoubleWriteArticleDAO dst 用来读 MongoDB 里面的线上库，dstStore 用来同步
+func NewDoubleWriteArticleDAO(src ArticleDAO, dst ArticleDAO, dstStore migrator.Store[PublishedArticle],
+	l logger.LoggerV1) *DoubleWriteArticleDAO {
+	return &DoubleWriteArticleDAO{
+		ArticleDAO: src,
+		dst:        dst,
+		dstStore:   dstStore,
+		pattern:    atomicx.NewValueOf(connpool.PatternSrcOnly),
+		l:          l,
+	}
+}
+
+func (d *DoubleWriteArticleDAO) UpdatePattern(pattern string) error {
+	switch pattern {
+	case connpool.PatternSrcOnly, connpool.PatternSrcFirst, connpool.PatternDstFirst, connpool.PatternDstOnly:
+		d.pattern.Store(pattern)
+		return nil
+	default:
+		return errors.New("未知的双写模式")
+	}
+}
+
+func (d *DoubleWriteArticleDAO) Sync(ctx context.Context, art Article) (int64, error) {
+	id, err := d.ArticleDAO.Sync(ctx, art)
+	if err != nil {
+		return id, err
+	}
+	return id, d.syncToDst(ctx, id)
+}
+
+func (d *DoubleWriteArticleDAO) SyncStatus(ctx context.Context, uid int64, id int64, status uint8) error {
+	err := d.ArticleDAO.SyncStatus(ctx, uid, id, status)
+	if err != nil {
+		return err
+	}
+	return d.syncToDst(ctx, id)
+}
+
+func (d *DoubleWriteArticleDAO) GetPubById(ctx context.Context, id int64) (PublishedArticle, error) {
+	switch d.pattern.Load() {
+	case connpool.PatternDstFirst, connpool.PatternDstOnly:
+		return d.dst.GetPubById(ctx, id)
+	default:
+		return d.ArticleDAO.GetPubById(ctx, id)
+	}
+}
+
+func (d *DoubleWriteArticleDAO) ListPub(ctx context.Context, start time.Time, offset int, limit int) ([]PublishedArticle, error) {
+	switch d.pattern.Load() {
+	case connpool.PatternDstFirst, connpool.PatternDstOnly:
+		return d.dst.ListPub(ctx, start, offset, limit)
+	default:
+		return d.ArticleDAO.ListPub(ctx, start, offset, limit)
+	}
+}
+
+// syncToDst 把 MySQL 里面的线上库数据整行同步到 MongoDB
+// SrcFirst 的时候 MongoDB 只是备份，失败了只记录日志；DstFirst 和 DstOnly 的时候读的是 MongoDB，失败了要返回给调用方
+func (d *DoubleWriteArticleDAO) syncToDst(ctx context.Context, id int64) error {
+	pattern := d.pattern.Load()
+	if pattern == connpool.PatternSrcOnly {
+		return nil
+	}
+	pub, err := d.ArticleDAO.GetPubById(ctx, id)
+	if err == nil {
+		err = d.dstStore.Upsert(ctx, pub)
+	}
+	if err == nil {
+		return nil
+	}
+	d.l.Error("同步线上库到 MongoDB 失败", logger.Int64("id", id), logger.Error(err))
+	if pattern == connpool.PatternSrcFirst {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,86 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"github.com/ecodeclub/ekit/syncx/atomicx"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"webook/pkg/gormx/connpool"
+	"webook/pkg/logger"
+	"webook/pkg/migrator"
+)
+
+func TestDoubleWriteArticleDAO_SyncStatus(t *testing.T) {
+	testCases := []struct {
+		name      string
+		pattern   string
+		upsertErr error
+
+		wantErr    error
+		wantSynced bool
+	}{
+		{
+			name:    "SrcOnly 不同步",
+			pattern: connpool.PatternSrcOnly,
+		},
+		{
+			name:       "SrcFirst 同步",
+			pattern:    connpool.PatternSrcFirst,
+			wantSynced: true,
+		},
+		{
+			name:      "SrcFirst 同步失败只记录日志",
+			pattern:   connpool.PatternSrcFirst,
+			upsertErr: errors.New("mongo error"),
+		},
+		{
+			name:      "DstFirst 同步失败要返回",
+			pattern:   connpool.PatternDstFirst,
+			upsertErr: errors.New("mongo error"),
+			wantErr:   errors.New("mongo error"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &memoryPubStore{err: tc.upsertErr, data: map[int64]PublishedArticle{}}
+			d := &DoubleWriteArticleDAO{
+				ArticleDAO: &fakeArticleDAO{pub: PublishedArticle{Id: 1, Title: "标题", Status: 2}},
+				dstStore:   store,
+				pattern:    atomicx.NewValueOf(tc.pattern),
+				l:          logger.NewNoOpLogger(),
+			}
+			err := d.SyncStatus(context.Background(), 123, 1, 2)
+			assert.Equal(t, tc.wantErr, err)
+			_, ok := store.data[1]
+			assert.Equal(t, tc.wantSynced, ok)
+		})
+	}
+}
+
+type fakeArticleDAO struct {
+	ArticleDAO
+	pub PublishedArticle
+}
+
+func (f *fakeArticleDAO) SyncStatus(ctx context.Context, uid int64, id int64, status uint8) error {
+	return nil
+}
+
+func (f *fakeArticleDAO) GetPubById(ctx context.Context, id int64) (PublishedArticle, error) {
+	return f.pub, nil
+}
+
+type memoryPubStore struct {
+	migrator.Store[PublishedArticle]
+	err  error
+	data map[int64]PublishedArticle
+}
+
+func (m *memoryPubStore) Upsert(ctx context.Context, art PublishedArticle) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.data[art.Id] = art
+	return nil
+}
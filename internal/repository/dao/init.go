@@ -12,12 +12,18 @@ import (
 // InitTables 使用GORM自带的建表功能
 // 这是种不太好的做法
 func InitTables(db *gorm.DB) error {
-	return db.AutoMigrate(&User{},
+	err := db.AutoMigrate(&User{},
+		&UserIdentity{},
+		&Media{},
 		&AsyncSms{},
 		&Article{},
 		&PublishedArticle{},
 		&Job{},
 	)
+	if err != nil {
+		return err
+	}
+	return MigrateWechatIdentities(db)
 }
 
 func InitCollection(mdb *mongo.Database) error {
@@ -0,0 +1,76 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"time"
+)
+
+// MediaDAO 上传文件的元数据，内容在对象存储里面，见 ObjectDAO
+type MediaDAO interface {
+	Insert(ctx context.Context, m Media) (int64, error)
+	FindById(ctx context.Context, id int64) (Media, error)
+	// FindByHash 同样的内容可能有好几条记录，返回状态是 status 的第一条
+	FindByHash(ctx context.Context, hash string, status uint8) (Media, error)
+	UpdateStatus(ctx context.Context, id int64, status uint8) error
+	Delete(ctx context.Context, id int64) error
+}
+
+type GORMMediaDAO struct {
+	db *gorm.DB
+}
+
+func NewGORMMediaDAO(db *gorm.DB) MediaDAO {
+	return &GORMMediaDAO{
+		db: db,
+	}
+}
+
+type Media struct {
+	Id int64 `gorm:"primaryKey, autoIncrement"`
+	// Owner 发起这次上传的用户
+	Owner int64 `gorm:"index"`
+	// Hash 内容的 SHA-256，同样的内容只存一份
+	// 每一次上传都有自己的记录，所以不是唯一索引，上传完成之后才会被别人复用
+	Hash string `gorm:"type:char(64);index"`
+	// Nonce 和 Id 一起组成上传用的临时 key
+	Nonce       string `gorm:"type:char(32)"`
+	Size        int64
+	ContentType string `gorm:"type:varchar(128)"`
+	Status      uint8
+	Ctime       int64
+	Utime       int64
+}
+
+func (dao *GORMMediaDAO) Insert(ctx context.Context, m Media) (int64, error) {
+	now := time.Now().UnixMilli()
+	m.Ctime = now
+	m.Utime = now
+	err := dao.db.WithContext(ctx).Create(&m).Error
+	return m.Id, err
+}
+
+func (dao *GORMMediaDAO) FindById(ctx context.Context, id int64) (Media, error) {
+	var m Media
+	err := dao.db.WithContext(ctx).Where("id=?", id).First(&m).Error
+	return m, err
+}
+
+func (dao *GORMMediaDAO) FindByHash(ctx context.Context, hash string, status uint8) (Media, error) {
+	var m Media
+	err := dao.db.WithContext(ctx).Where("hash=? AND status=?", hash, status).
+		Order("id").First(&m).Error
+	return m, err
+}
+
+func (dao *GORMMediaDAO) UpdateStatus(ctx context.Context, id int64, status uint8) error {
+	return dao.db.WithContext(ctx).Model(&Media{}).Where("id=?", id).
+		Updates(map[string]any{
+			"status": status,
+			"utime":  time.Now().UnixMilli(),
+		}).Error
+}
+
+func (dao *GORMMediaDAO) Delete(ctx context.Context, id int64) error {
+	return dao.db.WithContext(ctx).Where("id=?", id).Delete(&Media{}).Error
+}
@@ -0,0 +1,114 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: .\internal\repository\dao\media.go
+//
+// Generated by this command:
+//
+//	mockgen -source .\internal\repository\dao\media.go -destination .\internal\repository\dao\mocks\media_mock.go -package daomocks
+//
+
+// Package daomocks is a generated GoMock package.
+package daomocks
+
+import (
+	context "context"
+	reflect "reflect"
+	dao "webook/internal/repository/dao"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockMediaDAO is a mock of MediaDAO interface.
+type MockMediaDAO struct {
+	ctrl     *gomock.Controller
+	recorder *MockMediaDAOMockRecorder
+}
+
+// MockMediaDAOMockRecorder is the mock recorder for MockMediaDAO.
+type MockMediaDAOMockRecorder struct {
+	mock *MockMediaDAO
+}
+
+// NewMockMediaDAO creates a new mock instance.
+func NewMockMediaDAO(ctrl *gomock.Controller) *MockMediaDAO {
+	mock := &MockMediaDAO{ctrl: ctrl}
+	mock.recorder = &MockMediaDAOMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMediaDAO) EXPECT() *MockMediaDAOMockRecorder {
+	return m.recorder
+}
+
+// Delete mocks base method.
+func (m *MockMediaDAO) Delete(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockMediaDAOMockRecorder) Delete(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockMediaDAO)(nil).Delete), ctx, id)
+}
+
+// FindByHash mocks base method.
+func (m *MockMediaDAO) FindByHash(ctx context.Context, hash string, status uint8) (dao.Media, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByHash", ctx, hash, status)
+	ret0, _ := ret[0].(dao.Media)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByHash indicates an expected call of FindByHash.
+func (mr *MockMediaDAOMockRecorder) FindByHash(ctx, hash, status any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByHash", reflect.TypeOf((*MockMediaDAO)(nil).FindByHash), ctx, hash, status)
+}
+
+// FindById mocks base method.
+func (m *MockMediaDAO) FindById(ctx context.Context, id int64) (dao.Media, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindById", ctx, id)
+	ret0, _ := ret[0].(dao.Media)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindById indicates an expected call of FindById.
+func (mr *MockMediaDAOMockRecorder) FindById(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindById", reflect.TypeOf((*MockMediaDAO)(nil).FindById), ctx, id)
+}
+
+// Insert mocks base method.
+func (m_2 *MockMediaDAO) Insert(ctx context.Context, m dao.Media) (int64, error) {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "Insert", ctx, m)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Insert indicates an expected call of Insert.
+func (mr *MockMediaDAOMockRecorder) Insert(ctx, m any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Insert", reflect.TypeOf((*MockMediaDAO)(nil).Insert), ctx, m)
+}
+
+// UpdateStatus mocks base method.
+func (m *MockMediaDAO) UpdateStatus(ctx context.Context, id int64, status uint8) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateStatus", ctx, id, status)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateStatus indicates an expected call of UpdateStatus.
+func (mr *MockMediaDAOMockRecorder) UpdateStatus(ctx, id, status any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateStatus", reflect.TypeOf((*MockMediaDAO)(nil).UpdateStatus), ctx, id, status)
+}
@@ -0,0 +1,115 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: .\internal\repository\dao\user_identity.go
+//
+// Generated by this command:
+//
+//	mockgen -source .\internal\repository\dao\user_identity.go -destination .\internal\repository\dao\mocks\user_identity_mock.go -package daomocks
+//
+
+// Package daomocks is a generated GoMock package.
+package daomocks
+
+import (
+	context "context"
+	reflect "reflect"
+	dao "webook/internal/repository/dao"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockUserIdentityDAO is a mock of UserIdentityDAO interface.
+type MockUserIdentityDAO struct {
+	ctrl     *gomock.Controller
+	recorder *MockUserIdentityDAOMockRecorder
+}
+
+// MockUserIdentityDAOMockRecorder is the mock recorder for MockUserIdentityDAO.
+type MockUserIdentityDAOMockRecorder struct {
+	mock *MockUserIdentityDAO
+}
+
+// NewMockUserIdentityDAO creates a new mock instance.
+func NewMockUserIdentityDAO(ctrl *gomock.Controller) *MockUserIdentityDAO {
+	mock := &MockUserIdentityDAO{ctrl: ctrl}
+	mock.recorder = &MockUserIdentityDAOMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUserIdentityDAO) EXPECT() *MockUserIdentityDAOMockRecorder {
+	return m.recorder
+}
+
+// Delete mocks base method.
+func (m *MockUserIdentityDAO) Delete(ctx context.Context, uid int64, provider string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, uid, provider)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockUserIdentityDAOMockRecorder) Delete(ctx, uid, provider any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockUserIdentityDAO)(nil).Delete), ctx, uid, provider)
+}
+
+// FindByProvider mocks base method.
+func (m *MockUserIdentityDAO) FindByProvider(ctx context.Context, provider, subject string) (dao.UserIdentity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByProvider", ctx, provider, subject)
+	ret0, _ := ret[0].(dao.UserIdentity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByProvider indicates an expected call of FindByProvider.
+func (mr *MockUserIdentityDAOMockRecorder) FindByProvider(ctx, provider, subject any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByProvider", reflect.TypeOf((*MockUserIdentityDAO)(nil).FindByProvider), ctx, provider, subject)
+}
+
+// FindByUid mocks base method.
+func (m *MockUserIdentityDAO) FindByUid(ctx context.Context, uid int64) ([]dao.UserIdentity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByUid", ctx, uid)
+	ret0, _ := ret[0].([]dao.UserIdentity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByUid indicates an expected call of FindByUid.
+func (mr *MockUserIdentityDAOMockRecorder) FindByUid(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByUid", reflect.TypeOf((*MockUserIdentityDAO)(nil).FindByUid), ctx, uid)
+}
+
+// Insert mocks base method.
+func (m *MockUserIdentityDAO) Insert(ctx context.Context, identity dao.UserIdentity) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Insert", ctx, identity)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Insert indicates an expected call of Insert.
+func (mr *MockUserIdentityDAOMockRecorder) Insert(ctx, identity any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Insert", reflect.TypeOf((*MockUserIdentityDAO)(nil).Insert), ctx, identity)
+}
+
+// InsertWithUser mocks base method.
+func (m *MockUserIdentityDAO) InsertWithUser(ctx context.Context, identity dao.UserIdentity) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertWithUser", ctx, identity)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertWithUser indicates an expected call of InsertWithUser.
+func (mr *MockUserIdentityDAOMockRecorder) InsertWithUser(ctx, identity any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertWithUser", reflect.TypeOf((*MockUserIdentityDAO)(nil).InsertWithUser), ctx, identity)
+}
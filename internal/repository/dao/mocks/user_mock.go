@@ -40,6 +40,34 @@ func (m *MockUserDAO) EXPECT() *MockUserDAOMockRecorder {
 	return m.recorder
 }
 
+// BindEmail mocks base method.
+func (m *MockUserDAO) BindEmail(ctx context.Context, userId int64, email, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BindEmail", ctx, userId, email, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BindEmail indicates an expected call of BindEmail.
+func (mr *MockUserDAOMockRecorder) BindEmail(ctx, userId, email, password any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BindEmail", reflect.TypeOf((*MockUserDAO)(nil).BindEmail), ctx, userId, email, password)
+}
+
+// BindPhone mocks base method.
+func (m *MockUserDAO) BindPhone(ctx context.Context, userId int64, phone string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BindPhone", ctx, userId, phone)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BindPhone indicates an expected call of BindPhone.
+func (mr *MockUserDAOMockRecorder) BindPhone(ctx, userId, phone any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BindPhone", reflect.TypeOf((*MockUserDAO)(nil).BindPhone), ctx, userId, phone)
+}
+
 // FindByEmail mocks base method.
 func (m *MockUserDAO) FindByEmail(ctx context.Context, email string) (dao.User, error) {
 	m.ctrl.T.Helper()
@@ -70,21 +98,6 @@ func (mr *MockUserDAOMockRecorder) FindByPhone(ctx, phone any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByPhone", reflect.TypeOf((*MockUserDAO)(nil).FindByPhone), ctx, phone)
 }
 
-// FindByWechat mocks base method.
-func (m *MockUserDAO) FindByWechat(ctx context.Context, openID string) (dao.User, error) {
-	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindByWechat", ctx, openID)
-	ret0, _ := ret[0].(dao.User)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
-}
-
-// FindByWechat indicates an expected call of FindByWechat.
-func (mr *MockUserDAOMockRecorder) FindByWechat(ctx, openID any) *gomock.Call {
-	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByWechat", reflect.TypeOf((*MockUserDAO)(nil).FindByWechat), ctx, openID)
-}
-
 // FindUserInfoById mocks base method.
 func (m *MockUserDAO) FindUserInfoById(ctx context.Context, userId int64) (dao.User, error) {
 	m.ctrl.T.Helper()
@@ -114,6 +127,36 @@ func (mr *MockUserDAOMockRecorder) Insert(ctx, user any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Insert", reflect.TypeOf((*MockUserDAO)(nil).Insert), ctx, user)
 }
 
+// UnbindEmail mocks base method.
+func (m *MockUserDAO) UnbindEmail(ctx context.Context, userId int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnbindEmail", ctx, userId)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UnbindEmail indicates an expected call of UnbindEmail.
+func (mr *MockUserDAOMockRecorder) UnbindEmail(ctx, userId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnbindEmail", reflect.TypeOf((*MockUserDAO)(nil).UnbindEmail), ctx, userId)
+}
+
+// UnbindPhone mocks base method.
+func (m *MockUserDAO) UnbindPhone(ctx context.Context, userId int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnbindPhone", ctx, userId)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UnbindPhone indicates an expected call of UnbindPhone.
+func (mr *MockUserDAOMockRecorder) UnbindPhone(ctx, userId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnbindPhone", reflect.TypeOf((*MockUserDAO)(nil).UnbindPhone), ctx, userId)
+}
+
 // Update mocks base method.
 func (m *MockUserDAO) Update(ctx context.Context, user dao.User) error {
 	m.ctrl.T.Helper()
@@ -127,3 +170,104 @@ func (mr *MockUserDAOMockRecorder) Update(ctx, user any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockUserDAO)(nil).Update), ctx, user)
 }
+
+// UpdateAvatar mocks base method.
+func (m *MockUserDAO) UpdateAvatar(ctx context.Context, userId int64, avatar string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAvatar", ctx, userId, avatar)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateAvatar indicates an expected call of UpdateAvatar.
+func (mr *MockUserDAOMockRecorder) UpdateAvatar(ctx, userId, avatar any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAvatar", reflect.TypeOf((*MockUserDAO)(nil).UpdateAvatar), ctx, userId, avatar)
+}
+
+// UpdateEmail mocks base method.
+func (m *MockUserDAO) UpdateEmail(ctx context.Context, userId int64, email string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateEmail", ctx, userId, email)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateEmail indicates an expected call of UpdateEmail.
+func (mr *MockUserDAOMockRecorder) UpdateEmail(ctx, userId, email any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateEmail", reflect.TypeOf((*MockUserDAO)(nil).UpdateEmail), ctx, userId, email)
+}
+
+// UpdateEmailVerified mocks base method.
+func (m *MockUserDAO) UpdateEmailVerified(ctx context.Context, userId int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateEmailVerified", ctx, userId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateEmailVerified indicates an expected call of UpdateEmailVerified.
+func (mr *MockUserDAOMockRecorder) UpdateEmailVerified(ctx, userId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateEmailVerified", reflect.TypeOf((*MockUserDAO)(nil).UpdateEmailVerified), ctx, userId)
+}
+
+// UpdatePassword mocks base method.
+func (m *MockUserDAO) UpdatePassword(ctx context.Context, userId int64, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePassword", ctx, userId, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePassword indicates an expected call of UpdatePassword.
+func (mr *MockUserDAOMockRecorder) UpdatePassword(ctx, userId, password any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePassword", reflect.TypeOf((*MockUserDAO)(nil).UpdatePassword), ctx, userId, password)
+}
+
+// UpdateTotp mocks base method.
+func (m *MockUserDAO) UpdateTotp(ctx context.Context, userId int64, secret string, enabled bool, recoveryCodes string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTotp", ctx, userId, secret, enabled, recoveryCodes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateTotp indicates an expected call of UpdateTotp.
+func (mr *MockUserDAOMockRecorder) UpdateTotp(ctx, userId, secret, enabled, recoveryCodes any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTotp", reflect.TypeOf((*MockUserDAO)(nil).UpdateTotp), ctx, userId, secret, enabled, recoveryCodes)
+}
+
+// UpdateTotpLastStep mocks base method.
+func (m *MockUserDAO) UpdateTotpLastStep(ctx context.Context, userId, step int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTotpLastStep", ctx, userId, step)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateTotpLastStep indicates an expected call of UpdateTotpLastStep.
+func (mr *MockUserDAOMockRecorder) UpdateTotpLastStep(ctx, userId, step any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTotpLastStep", reflect.TypeOf((*MockUserDAO)(nil).UpdateTotpLastStep), ctx, userId, step)
+}
+
+// UpdateTotpRecoveryCodes mocks base method.
+func (m *MockUserDAO) UpdateTotpRecoveryCodes(ctx context.Context, userId int64, old, recoveryCodes string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTotpRecoveryCodes", ctx, userId, old, recoveryCodes)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateTotpRecoveryCodes indicates an expected call of UpdateTotpRecoveryCodes.
+func (mr *MockUserDAOMockRecorder) UpdateTotpRecoveryCodes(ctx, userId, old, recoveryCodes any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTotpRecoveryCodes", reflect.TypeOf((*MockUserDAO)(nil).UpdateTotpRecoveryCodes), ctx, userId, old, recoveryCodes)
+}
@@ -17,8 +17,17 @@ type MongoDBArticleDAO struct {
 }
 
 func (m *MongoDBArticleDAO) ListPub(ctx context.Context, start time.Time, offset int, limit int) ([]PublishedArticle, error) {
-	//TODO implement me
-	panic("implement me")
+	const ArticleStatusPublished = 2
+	filter := bson.D{bson.E{Key: "utime", Value: bson.M{"$lt": start.UnixMilli()}},
+		bson.E{Key: "status", Value: ArticleStatusPublished}}
+	cursor, err := m.liveCol.Find(ctx, filter,
+		options.Find().SetSkip(int64(offset)).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	var res []PublishedArticle
+	err = cursor.All(ctx, &res)
+	return res, err
 }
 
 func (m *MongoDBArticleDAO) GetById(ctx context.Context, id int64) (Article, error) {
@@ -27,8 +36,9 @@ func (m *MongoDBArticleDAO) GetById(ctx context.Context, id int64) (Article, err
 }
 
 func (m *MongoDBArticleDAO) GetPubById(ctx context.Context, id int64) (PublishedArticle, error) {
-	//TODO implement me
-	panic("implement me")
+	var res PublishedArticle
+	err := m.liveCol.FindOne(ctx, bson.D{bson.E{Key: "id", Value: id}}).Decode(&res)
+	return res, err
 }
 
 func (m *MongoDBArticleDAO) GetByAuthor(ctx context.Context, uid int64, offset int, limit int) ([]Article, error) {
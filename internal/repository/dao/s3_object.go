@@ -0,0 +1,105 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/ecodeclub/ekit"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var ErrObjectNotFound = errors.New("对象不存在")
+
+// ObjectDAO 对象存储，上传走预签名的地址，不经过我们的服务器
+type ObjectDAO interface {
+	// PresignPut 客户端用返回的地址直接 PUT，Content-Type 必须和签名的时候一样
+	PresignPut(ctx context.Context, key string, contentType string, expiration time.Duration) (string, error)
+	// Get 调用方负责关闭 Body
+	Get(ctx context.Context, key string) (Object, error)
+	Delete(ctx context.Context, key string) error
+	// Copy 在对象存储里面直接复制，内容不经过我们的服务器，src 不存在的时候返回 ErrObjectNotFound
+	Copy(ctx context.Context, src string, dst string) error
+	// URL 公开访问的地址，一般是 CDN
+	URL(key string) string
+}
+
+type Object struct {
+	Body        io.ReadCloser
+	Size        int64
+	ContentType string
+}
+
+type S3ObjectDAO struct {
+	oss    *s3.S3
+	bucket string
+	// baseURL 公开访问的地址前缀，不带最后的 /
+	baseURL string
+}
+
+func NewS3ObjectDAO(oss *s3.S3, bucket string, baseURL string) ObjectDAO {
+	return &S3ObjectDAO{
+		oss:     oss,
+		bucket:  bucket,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+func (o *S3ObjectDAO) PresignPut(ctx context.Context, key string, contentType string, expiration time.Duration) (string, error) {
+	req, _ := o.oss.PutObjectRequest(&s3.PutObjectInput{
+		Bucket:      ekit.ToPtr[string](o.bucket),
+		Key:         ekit.ToPtr[string](key),
+		ContentType: ekit.ToPtr[string](contentType),
+	})
+	req.SetContext(ctx)
+	return req.Presign(expiration)
+}
+
+func (o *S3ObjectDAO) Get(ctx context.Context, key string) (Object, error) {
+	res, err := o.oss.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: ekit.ToPtr[string](o.bucket),
+		Key:    ekit.ToPtr[string](key),
+	})
+	if err != nil {
+		var ae awserr.RequestFailure
+		if errors.As(err, &ae) && ae.StatusCode() == http.StatusNotFound {
+			return Object{}, ErrObjectNotFound
+		}
+		return Object{}, err
+	}
+	return Object{
+		Body:        res.Body,
+		Size:        aws.Int64Value(res.ContentLength),
+		ContentType: aws.StringValue(res.ContentType),
+	}, nil
+}
+
+func (o *S3ObjectDAO) Delete(ctx context.Context, key string) error {
+	_, err := o.oss.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: ekit.ToPtr[string](o.bucket),
+		Key:    ekit.ToPtr[string](key),
+	})
+	return err
+}
+
+func (o *S3ObjectDAO) Copy(ctx context.Context, src string, dst string) error {
+	_, err := o.oss.CopyObjectWithContext(ctx, &s3.CopyObjectInput{
+		Bucket:     ekit.ToPtr[string](o.bucket),
+		Key:        ekit.ToPtr[string](dst),
+		CopySource: ekit.ToPtr[string](url.PathEscape(o.bucket) + "/" + (&url.URL{Path: src}).EscapedPath()),
+	})
+	var ae awserr.RequestFailure
+	if errors.As(err, &ae) && ae.StatusCode() == http.StatusNotFound {
+		return ErrObjectNotFound
+	}
+	return err
+}
+
+func (o *S3ObjectDAO) URL(key string) string {
+	return o.baseURL + "/" + key
+}
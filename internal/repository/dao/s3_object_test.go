@@ -0,0 +1,107 @@
+package dao
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+	"webook/internal/repository/dao/s3test"
+)
+
+func TestS3ObjectDAO(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+	dao := NewS3ObjectDAO(server.Client(), s3test.Bucket, "https://cdn.webook.com/")
+	ctx := context.Background()
+
+	testCases := []struct {
+		name        string
+		key         string
+		contentType string
+		expiration  time.Duration
+		// wait 拿到上传地址之后过多久才上传
+		wait time.Duration
+
+		wantCode int
+	}{
+		{
+			name:        "上传成功",
+			key:         "media/abc",
+			contentType: "image/png",
+			expiration:  time.Minute,
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:        "上传地址过期",
+			key:         "media/expired",
+			contentType: "image/png",
+			expiration:  time.Second,
+			wait:        time.Second * 2,
+			wantCode:    http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			url, err := dao.PresignPut(ctx, tc.key, tc.contentType, tc.expiration)
+			require.NoError(t, err)
+			time.Sleep(tc.wait)
+			req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader([]byte("png content")))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", tc.contentType)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, tc.wantCode, resp.StatusCode)
+			if tc.wantCode != http.StatusOK {
+				_, err = dao.Get(ctx, tc.key)
+				assert.Equal(t, ErrObjectNotFound, err)
+				return
+			}
+
+			obj, err := dao.Get(ctx, tc.key)
+			require.NoError(t, err)
+			data, err := io.ReadAll(obj.Body)
+			obj.Body.Close()
+			require.NoError(t, err)
+			assert.Equal(t, "png content", string(data))
+			assert.Equal(t, int64(len(data)), obj.Size)
+			assert.Equal(t, tc.contentType, obj.ContentType)
+			assert.Equal(t, "https://cdn.webook.com/"+tc.key, dao.URL(tc.key))
+
+			require.NoError(t, dao.Delete(ctx, tc.key))
+			_, err = dao.Get(ctx, tc.key)
+			assert.Equal(t, ErrObjectNotFound, err)
+		})
+	}
+}
+
+func TestS3ObjectDAO_Copy(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+	dao := NewS3ObjectDAO(server.Client(), s3test.Bucket, "https://cdn.webook.com/")
+	ctx := context.Background()
+
+	err := dao.Copy(ctx, "media/tmp/1/nonce", "media/abc")
+	assert.Equal(t, ErrObjectNotFound, err)
+
+	url, err := dao.PresignPut(ctx, "media/tmp/1/nonce", "image/png", time.Minute)
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader([]byte("png content")))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "image/png")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.NoError(t, dao.Copy(ctx, "media/tmp/1/nonce", "media/abc"))
+	data, contentType, ok := server.Object("media/abc")
+	assert.True(t, ok)
+	assert.Equal(t, "png content", string(data))
+	assert.Equal(t, "image/png", contentType)
+}
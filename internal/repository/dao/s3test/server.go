@@ -0,0 +1,176 @@
+// Package s3test 本地的 S3 兼容服务器，测试上传和预签名用，不需要真的对象存储
+package s3test
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/ecodeclub/ekit"
+	"html"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bucket 服务器里面只有这一个 bucket
+const Bucket = "webook-test"
+
+type object struct {
+	data        []byte
+	contentType string
+	etag        string
+}
+
+// Server 只支持路径形式（/bucket/key）的 PUT、GET、HEAD 和 DELETE
+// 带了 x-amz-copy-source 头部的 PUT 是 CopyObject，只能在这个 bucket 里面复制
+// 不校验签名，但是会检查预签名的地址有没有过期
+type Server struct {
+	*httptest.Server
+
+	lock    sync.RWMutex
+	objects map[string]object
+}
+
+func NewServer() *Server {
+	s := &Server{
+		objects: make(map[string]object),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Client 连到这个服务器的 S3 客户端
+func (s *Server) Client() *s3.S3 {
+	sess := session.Must(session.NewSession(&aws.Config{
+		Credentials:      credentials.NewStaticCredentials("test", "test", ""),
+		Region:           ekit.ToPtr[string]("us-east-1"),
+		Endpoint:         ekit.ToPtr[string](s.URL),
+		S3ForcePathStyle: ekit.ToPtr[bool](true),
+	}))
+	return s3.New(sess)
+}
+
+// Object 直接读取存储的内容，不存在的时候返回 false
+func (s *Server) Object(key string) ([]byte, string, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	obj, ok := s.objects[key]
+	return obj.data, obj.contentType, ok
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != Bucket || key == "" {
+		s.error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	if s.expired(r) {
+		s.error(w, http.StatusForbidden, "AccessDenied")
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		if src := r.Header.Get("X-Amz-Copy-Source"); src != "" {
+			s.copy(w, src, key)
+			return
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			s.error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		sum := md5.Sum(data)
+		obj := object{
+			data:        data,
+			contentType: r.Header.Get("Content-Type"),
+			etag:        `"` + hex.EncodeToString(sum[:]) + `"`,
+		}
+		s.lock.Lock()
+		s.objects[key] = obj
+		s.lock.Unlock()
+		w.Header().Set("ETag", obj.etag)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet, http.MethodHead:
+		s.lock.RLock()
+		obj, ok := s.objects[key]
+		s.lock.RUnlock()
+		if !ok {
+			s.error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Type", obj.contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+		w.Header().Set("ETag", obj.etag)
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(obj.data)
+		}
+	case http.MethodDelete:
+		s.lock.Lock()
+		delete(s.objects, key)
+		s.lock.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// copy src 是 URL 编码过的 bucket/key，前面可能带 /
+func (s *Server) copy(w http.ResponseWriter, src string, key string) {
+	src, err := url.PathUnescape(strings.TrimPrefix(src, "/"))
+	if err != nil {
+		s.error(w, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+	bucket, srcKey, _ := strings.Cut(src, "/")
+	if bucket != Bucket {
+		s.error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	s.lock.Lock()
+	obj, ok := s.objects[srcKey]
+	if ok {
+		s.objects[key] = obj
+	}
+	s.lock.Unlock()
+	if !ok {
+		s.error(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprintf(w, "<CopyObjectResult><LastModified>%s</LastModified><ETag>%s</ETag></CopyObjectResult>",
+		time.Now().UTC().Format(time.RFC3339), html.EscapeString(obj.etag))
+}
+
+// expired 预签名的地址带了 X-Amz-Date 和 X-Amz-Expires
+func (s *Server) expired(r *http.Request) bool {
+	q := r.URL.Query()
+	if q.Get("X-Amz-Expires") == "" {
+		return false
+	}
+	date, err := time.Parse("20060102T150405Z", q.Get("X-Amz-Date"))
+	if err != nil {
+		return true
+	}
+	seconds, err := strconv.Atoi(q.Get("X-Amz-Expires"))
+	if err != nil {
+		return true
+	}
+	return time.Now().After(date.Add(time.Duration(seconds) * time.Second))
+}
+
+func (s *Server) error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
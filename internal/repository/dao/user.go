@@ -11,6 +11,7 @@ import (
 
 var (
 	ErrDuplicateUser  = errors.New("用户冲突")
+	ErrAlreadyBound   = errors.New("已经绑定过了")
 	ErrRecordNotFound = gorm.ErrRecordNotFound
 )
 
@@ -20,7 +21,30 @@ type UserDAO interface {
 	FindByPhone(ctx context.Context, phone string) (User, error)
 	Update(ctx context.Context, user User) error
 	FindUserInfoById(ctx context.Context, userId int64) (User, error)
-	FindByWechat(ctx context.Context, openID string) (User, error)
+	UpdatePassword(ctx context.Context, userId int64, password string) error
+	UpdateEmailVerified(ctx context.Context, userId int64) error
+	UpdateAvatar(ctx context.Context, userId int64, avatar string) error
+	// UpdateTotp 开启或者关闭两步验证，LastStep 不变
+	UpdateTotp(ctx context.Context, userId int64, secret string, enabled bool, recoveryCodes string) error
+	// UpdateTotpLastStep 只有 step 比记录的大才更新，返回是否更新了
+	UpdateTotpLastStep(ctx context.Context, userId int64, step int64) (bool, error)
+	// UpdateTotpRecoveryCodes 恢复码还是 old 的时候才更新，返回是否更新了
+	UpdateTotpRecoveryCodes(ctx context.Context, userId int64, old string, recoveryCodes string) (bool, error)
+
+	// 绑定登录方式，已经被其它用户绑定的时候返回 ErrDuplicateUser
+	// 自己已经绑定过了返回 ErrAlreadyBound，要换成新的走 UpdateEmail
+
+	// BindEmail 绑定之前已经验证过邮箱了
+	BindEmail(ctx context.Context, userId int64, email string, password string) error
+	BindPhone(ctx context.Context, userId int64, phone string) error
+	// UpdateEmail 换了邮箱需要重新验证，没有绑定过邮箱的时候返回 false
+	UpdateEmail(ctx context.Context, userId int64, email string) (bool, error)
+
+	// 解绑登录方式，解绑之后没有其它登录方式的时候不更新，返回 false
+	// 第三方登录的解绑在 UserIdentityDAO 里面
+
+	UnbindEmail(ctx context.Context, userId int64) (bool, error)
+	UnbindPhone(ctx context.Context, userId int64) (bool, error)
 }
 
 type GORMUserDAO struct {
@@ -38,6 +62,8 @@ type User struct {
 	Id       int64          `gorm:"primaryKey, autoIncrement"`
 	Email    sql.NullString `gorm:"unique"`
 	Password string
+	// EmailVerified 邮箱是否已经验证过
+	EmailVerified bool
 
 	Phone sql.NullString `gorm:"unique"`
 
@@ -50,10 +76,24 @@ type User struct {
 	NickName string `gorm:"type=varchar(128)"`
 	Birthday int64
 	AboutMe  string `gorm:"type=varchar(4096)"`
+	// Avatar 头像的地址
+	Avatar string `gorm:"type=varchar(1024)"`
 
 	// 注意索引问题
+	// 已经迁移到 user_identities，只在 MigrateWechatIdentities 里面使用
 	WechatOpenId  sql.NullString `gorm:"unique"`
 	WechatUnionId sql.NullString
+
+	// Roles 逗号分隔，目前只能直接改数据库
+	Roles string `gorm:"type=varchar(256)"`
+
+	// 两步验证
+	TotpSecret  string `gorm:"type=varchar(64)"`
+	TotpEnabled bool
+	// TotpRecoveryCodes 恢复码的摘要，逗号分隔
+	TotpRecoveryCodes string `gorm:"type=varchar(1024)"`
+	// TotpLastStep 同一个动态码不能用两次
+	TotpLastStep int64
 }
 
 func (dao *GORMUserDAO) Insert(ctx context.Context, user User) error {
@@ -83,16 +123,146 @@ func (dao *GORMUserDAO) FindByPhone(ctx context.Context, phone string) (User, er
 	return user, err
 }
 
-func (dao *GORMUserDAO) FindByWechat(ctx context.Context, openID string) (User, error) {
-	var user User
-	err := dao.db.WithContext(ctx).Where("wechat_open_id=?", openID).Find(&user).Error
-	return user, err
-}
-
 func (dao *GORMUserDAO) Update(ctx context.Context, user User) error {
 	return dao.db.WithContext(ctx).Where("id=?", user.Id).Updates(&user).Error
 }
 
+// UpdatePassword password 是加密之后的
+func (dao *GORMUserDAO) UpdatePassword(ctx context.Context, userId int64, password string) error {
+	return dao.db.WithContext(ctx).Model(&User{}).Where("id=?", userId).
+		Updates(map[string]any{
+			"password": password,
+			"utime":    time.Now().UnixMilli(),
+		}).Error
+}
+
+func (dao *GORMUserDAO) UpdateEmailVerified(ctx context.Context, userId int64) error {
+	return dao.db.WithContext(ctx).Model(&User{}).Where("id=?", userId).
+		Updates(map[string]any{
+			"email_verified": true,
+			"utime":          time.Now().UnixMilli(),
+		}).Error
+}
+
+func (dao *GORMUserDAO) UpdateAvatar(ctx context.Context, userId int64, avatar string) error {
+	return dao.db.WithContext(ctx).Model(&User{}).Where("id=?", userId).
+		Updates(map[string]any{
+			"avatar": avatar,
+			"utime":  time.Now().UnixMilli(),
+		}).Error
+}
+
+func (dao *GORMUserDAO) UpdateTotp(ctx context.Context, userId int64, secret string, enabled bool, recoveryCodes string) error {
+	return dao.db.WithContext(ctx).Model(&User{}).Where("id=?", userId).
+		Updates(map[string]any{
+			"totp_secret":         secret,
+			"totp_enabled":        enabled,
+			"totp_recovery_codes": recoveryCodes,
+			"utime":               time.Now().UnixMilli(),
+		}).Error
+}
+
+func (dao *GORMUserDAO) UpdateTotpLastStep(ctx context.Context, userId int64, step int64) (bool, error) {
+	res := dao.db.WithContext(ctx).Model(&User{}).
+		Where("id=? AND totp_last_step < ?", userId, step).
+		Updates(map[string]any{
+			"totp_last_step": step,
+			"utime":          time.Now().UnixMilli(),
+		})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (dao *GORMUserDAO) UpdateTotpRecoveryCodes(ctx context.Context, userId int64, old string, recoveryCodes string) (bool, error) {
+	res := dao.db.WithContext(ctx).Model(&User{}).
+		Where("id=? AND totp_recovery_codes = ?", userId, old).
+		Updates(map[string]any{
+			"totp_recovery_codes": recoveryCodes,
+			"utime":               time.Now().UnixMilli(),
+		})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (dao *GORMUserDAO) BindEmail(ctx context.Context, userId int64, email string, password string) error {
+	return dao.bind(ctx, userId, "email", map[string]any{
+		"email":          email,
+		"password":       password,
+		"email_verified": true,
+	})
+}
+
+func (dao *GORMUserDAO) BindPhone(ctx context.Context, userId int64, phone string) error {
+	return dao.bind(ctx, userId, "phone", map[string]any{
+		"phone": phone,
+	})
+}
+
+// bind column 还没有绑定的时候才更新，避免覆盖掉已经绑定的
+func (dao *GORMUserDAO) bind(ctx context.Context, userId int64, column string, fields map[string]any) error {
+	fields["utime"] = time.Now().UnixMilli()
+	res := dao.db.WithContext(ctx).Model(&User{}).
+		Where("id=?", userId).Where(column + " IS NULL").
+		Updates(fields)
+	if err := dao.duplicate(res.Error); err != nil {
+		return err
+	}
+	if res.RowsAffected == 0 {
+		return ErrAlreadyBound
+	}
+	return nil
+}
+
+func (dao *GORMUserDAO) UpdateEmail(ctx context.Context, userId int64, email string) (bool, error) {
+	res := dao.db.WithContext(ctx).Model(&User{}).
+		Where("id=? AND email IS NOT NULL", userId).
+		Updates(map[string]any{
+			"email":          email,
+			"email_verified": false,
+			"utime":          time.Now().UnixMilli(),
+		})
+	if err := dao.duplicate(res.Error); err != nil {
+		return false, err
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// duplicate 唯一索引冲突，说明已经被其它用户绑定了
+func (dao *GORMUserDAO) duplicate(err error) error {
+	if me, ok := err.(*mysql.MySQLError); ok {
+		const duplicateErr uint16 = 1062
+		if me.Number == duplicateErr {
+			return ErrDuplicateUser
+		}
+	}
+	return err
+}
+
+// UnbindEmail 邮箱和密码一起清掉
+func (dao *GORMUserDAO) UnbindEmail(ctx context.Context, userId int64) (bool, error) {
+	return dao.unbind(ctx, userId, "(phone IS NOT NULL OR "+hasIdentity+")", map[string]any{
+		"email":          nil,
+		"password":       "",
+		"email_verified": false,
+	})
+}
+
+func (dao *GORMUserDAO) UnbindPhone(ctx context.Context, userId int64) (bool, error) {
+	return dao.unbind(ctx, userId, "(email IS NOT NULL OR "+hasIdentity+")", map[string]any{
+		"phone": nil,
+	})
+}
+
+// hasIdentity 绑定了第三方登录
+const hasIdentity = "EXISTS (SELECT 1 FROM user_identities WHERE user_identities.uid = users.id)"
+
+// unbind others 是其它登录方式至少有一个的条件，放在 WHERE 里面，避免并发解绑之后一个登录方式都没有
+func (dao *GORMUserDAO) unbind(ctx context.Context, userId int64, others string, fields map[string]any) (bool, error) {
+	fields["utime"] = time.Now().UnixMilli()
+	res := dao.db.WithContext(ctx).Model(&User{}).
+		Where("id=?", userId).Where(others).
+		Updates(fields)
+	return res.RowsAffected > 0, res.Error
+}
+
 func (dao *GORMUserDAO) FindUserInfoById(ctx context.Context, userId int64) (User, error) {
 	var user User
 	err := dao.db.WithContext(ctx).Where("id=?", userId).Find(&user).Error
@@ -0,0 +1,128 @@
+package dao
+
+import (
+	"context"
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type UserIdentityDAO interface {
+	FindByProvider(ctx context.Context, provider string, subject string) (UserIdentity, error)
+	FindByUid(ctx context.Context, uid int64) ([]UserIdentity, error)
+	// Insert 绑定到已有的用户，已经被其它用户绑定的时候返回 ErrDuplicateUser
+	Insert(ctx context.Context, identity UserIdentity) error
+	// InsertWithUser 第一次用第三方登录，同时创建用户，返回用户 ID
+	InsertWithUser(ctx context.Context, identity UserIdentity) (int64, error)
+	// Delete 删掉之后没有其它登录方式的时候不删除，返回 false
+	Delete(ctx context.Context, uid int64, provider string) (bool, error)
+}
+
+type GORMUserIdentityDAO struct {
+	db *gorm.DB
+}
+
+func NewUserIdentityDAO(db *gorm.DB) UserIdentityDAO {
+	return &GORMUserIdentityDAO{
+		db: db,
+	}
+}
+
+// UserIdentity 第三方登录的身份，一个用户在同一个 Provider 下只有一个身份
+type UserIdentity struct {
+	Id       int64  `gorm:"primaryKey, autoIncrement"`
+	Uid      int64  `gorm:"uniqueIndex:uid_provider"`
+	Provider string `gorm:"type:varchar(32);uniqueIndex:provider_subject;uniqueIndex:uid_provider"`
+	Subject  string `gorm:"type:varchar(128);uniqueIndex:provider_subject"`
+	UnionId  string `gorm:"type:varchar(128)"`
+	Email    string `gorm:"type:varchar(256)"`
+	Name     string `gorm:"type:varchar(128)"`
+	Ctime    int64
+	Utime    int64
+}
+
+func (dao *GORMUserIdentityDAO) FindByProvider(ctx context.Context, provider string, subject string) (UserIdentity, error) {
+	var identity UserIdentity
+	err := dao.db.WithContext(ctx).Where("provider=? AND subject=?", provider, subject).
+		First(&identity).Error
+	return identity, err
+}
+
+func (dao *GORMUserIdentityDAO) FindByUid(ctx context.Context, uid int64) ([]UserIdentity, error) {
+	var res []UserIdentity
+	err := dao.db.WithContext(ctx).Where("uid=?", uid).Find(&res).Error
+	return res, err
+}
+
+func (dao *GORMUserIdentityDAO) Insert(ctx context.Context, identity UserIdentity) error {
+	return dao.insert(dao.db.WithContext(ctx), identity)
+}
+
+func (dao *GORMUserIdentityDAO) InsertWithUser(ctx context.Context, identity UserIdentity) (int64, error) {
+	var uid int64
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UnixMilli()
+		u := User{
+			NickName: identity.Name,
+			Ctime:    now,
+			Utime:    now,
+		}
+		err := tx.Create(&u).Error
+		if err != nil {
+			return err
+		}
+		uid = u.Id
+		identity.Uid = u.Id
+		return dao.insert(tx, identity)
+	})
+	return uid, err
+}
+
+func (dao *GORMUserIdentityDAO) insert(db *gorm.DB, identity UserIdentity) error {
+	now := time.Now().UnixMilli()
+	identity.Ctime = now
+	identity.Utime = now
+	err := db.Create(&identity).Error
+	if me, ok := err.(*mysql.MySQLError); ok {
+		const duplicateErr uint16 = 1062
+		if me.Number == duplicateErr {
+			return ErrDuplicateUser
+		}
+	}
+	return err
+}
+
+// Delete 先锁住用户，和 GORMUserDAO 的 unbind 互斥，避免并发解绑之后一种登录方式都没有
+func (dao *GORMUserIdentityDAO) Delete(ctx context.Context, uid int64, provider string) (bool, error) {
+	var deleted bool
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var u User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id=?", uid).First(&u).Error
+		if err != nil {
+			return err
+		}
+		var others int64
+		err = tx.Model(&UserIdentity{}).
+			Where("uid=? AND provider<>?", uid, provider).Count(&others).Error
+		if err != nil {
+			return err
+		}
+		if !u.Email.Valid && !u.Phone.Valid && others == 0 {
+			return nil
+		}
+		res := tx.Where("uid=? AND provider=?", uid, provider).Delete(&UserIdentity{})
+		deleted = res.RowsAffected > 0
+		return res.Error
+	})
+	return deleted, err
+}
+
+// MigrateWechatIdentities 把 users 表里面的微信 openid 搬到 user_identities，可以重复执行
+func MigrateWechatIdentities(db *gorm.DB) error {
+	now := time.Now().UnixMilli()
+	return db.Exec("INSERT IGNORE INTO user_identities (uid, provider, subject, union_id, ctime, utime) "+
+		"SELECT id, 'wechat', wechat_open_id, IFNULL(wechat_union_id, ''), ?, ? FROM users WHERE wechat_open_id IS NOT NULL",
+		now, now).Error
+}
@@ -100,3 +100,63 @@ func TestGORMUserDAO_Insert(t *testing.T) {
 		})
 	}
 }
+
+func TestGORMUserDAO_BindPhone(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(t *testing.T) *sql.DB
+
+		wantErr error
+	}{
+		{
+			name: "绑定成功",
+			mock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				assert.NoError(t, err)
+				mock.ExpectExec("UPDATE `users` SET .* WHERE id=\\? AND phone IS NULL").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+		},
+		{
+			name: "已经绑定过了",
+			mock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				assert.NoError(t, err)
+				mock.ExpectExec("UPDATE `users` SET .* WHERE id=\\? AND phone IS NULL").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				return db
+			},
+			wantErr: ErrAlreadyBound,
+		},
+		{
+			name: "被其它用户绑定了",
+			mock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				assert.NoError(t, err)
+				mock.ExpectExec("UPDATE `users` SET .* WHERE id=\\? AND phone IS NULL").
+					WillReturnError(&mysqlDriver.MySQLError{Number: 1062})
+				return db
+			},
+			wantErr: ErrDuplicateUser,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sqlDB := tc.mock(t)
+			db, err := gorm.Open(mysql.New(mysql.Config{
+				Conn:                      sqlDB,
+				SkipInitializeWithVersion: true,
+			}), &gorm.Config{
+				DisableAutomaticPing:   true,
+				SkipDefaultTransaction: true,
+			})
+			assert.NoError(t, err)
+
+			dao := NewUserDAO(db)
+			err = dao.BindPhone(context.Background(), 123, "13800138000")
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository/cache"
+)
+
+type LoginGuardRepository interface {
+	Fail(ctx context.Context, account string) (domain.LoginBlock, error)
+	Block(ctx context.Context, account, ip string) (domain.LoginBlock, error)
+	BlockIP(ctx context.Context, ip string, expiration time.Duration) error
+	Reset(ctx context.Context, account string) error
+}
+
+type CachedLoginGuardRepository struct {
+	cache cache.LoginGuardCache
+}
+
+func NewCachedLoginGuardRepository(c cache.LoginGuardCache) LoginGuardRepository {
+	return &CachedLoginGuardRepository{
+		cache: c,
+	}
+}
+
+func (r *CachedLoginGuardRepository) Fail(ctx context.Context, account string) (domain.LoginBlock, error) {
+	return r.cache.Fail(ctx, account)
+}
+
+func (r *CachedLoginGuardRepository) Block(ctx context.Context, account, ip string) (domain.LoginBlock, error) {
+	return r.cache.Block(ctx, account, ip)
+}
+
+func (r *CachedLoginGuardRepository) BlockIP(ctx context.Context, ip string, expiration time.Duration) error {
+	return r.cache.BlockIP(ctx, ip, expiration)
+}
+
+func (r *CachedLoginGuardRepository) Reset(ctx context.Context, account string) error {
+	return r.cache.Reset(ctx, account)
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository/dao"
+)
+
+var (
+	ErrMediaNotFound  = dao.ErrRecordNotFound
+	ErrObjectNotFound = dao.ErrObjectNotFound
+)
+
+// MediaRepository 每一次上传先传到只属于这次上传的临时 key，校验通过之后再复制到按照摘要命名的 key
+// 这样别人没办法覆盖已经上传好的内容
+type MediaRepository interface {
+	// Create 会生成 Nonce
+	Create(ctx context.Context, m domain.Media) (int64, error)
+	FindById(ctx context.Context, id int64) (domain.Media, error)
+	// FindByHash 只找上传完成的
+	FindByHash(ctx context.Context, hash string) (domain.Media, error)
+	// Publish 把临时 key 的内容复制到按照摘要命名的 key，并且标记为上传完成
+	Publish(ctx context.Context, m domain.Media) error
+	// Delete 连同临时 key 里面的内容一起删掉
+	Delete(ctx context.Context, m domain.Media) error
+	// UploadURL 客户端用这个地址直接上传到临时 key
+	UploadURL(ctx context.Context, m domain.Media, expiration time.Duration) (string, error)
+	// Inspect 读取临时 key 里面实际的内容，返回实际的大小、类型和摘要，类型是按照内容判断的
+	// 最多读 limit+1 个字节，超过 limit 的时候 Hash 是空的
+	Inspect(ctx context.Context, m domain.Media, limit int64) (domain.Media, error)
+}
+
+type mediaRepository struct {
+	dao     dao.MediaDAO
+	objects dao.ObjectDAO
+}
+
+func NewMediaRepository(d dao.MediaDAO, objects dao.ObjectDAO) MediaRepository {
+	return &mediaRepository{
+		dao:     d,
+		objects: objects,
+	}
+}
+
+func (repo *mediaRepository) Create(ctx context.Context, m domain.Media) (int64, error) {
+	nonce := make([]byte, 16)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return 0, err
+	}
+	m.Nonce = hex.EncodeToString(nonce)
+	return repo.dao.Insert(ctx, repo.toEntity(m))
+}
+
+func (repo *mediaRepository) FindById(ctx context.Context, id int64) (domain.Media, error) {
+	m, err := repo.dao.FindById(ctx, id)
+	if err != nil {
+		return domain.Media{}, err
+	}
+	return repo.toDomain(m), nil
+}
+
+func (repo *mediaRepository) FindByHash(ctx context.Context, hash string) (domain.Media, error) {
+	m, err := repo.dao.FindByHash(ctx, hash, domain.MediaStatusUploaded.ToUint8())
+	if err != nil {
+		return domain.Media{}, err
+	}
+	return repo.toDomain(m), nil
+}
+
+func (repo *mediaRepository) Publish(ctx context.Context, m domain.Media) error {
+	// 同样的内容并发上传的时候会复制好几次，内容都是一样的
+	err := repo.objects.Copy(ctx, repo.stagingKey(m), repo.key(m.Hash))
+	if err != nil {
+		return err
+	}
+	err = repo.dao.UpdateStatus(ctx, m.Id, domain.MediaStatusUploaded.ToUint8())
+	if err != nil {
+		return err
+	}
+	// 删除失败的，交给对象存储的生命周期规则清理
+	_ = repo.objects.Delete(ctx, repo.stagingKey(m))
+	return nil
+}
+
+func (repo *mediaRepository) Delete(ctx context.Context, m domain.Media) error {
+	err := repo.objects.Delete(ctx, repo.stagingKey(m))
+	if err != nil {
+		return err
+	}
+	return repo.dao.Delete(ctx, m.Id)
+}
+
+func (repo *mediaRepository) UploadURL(ctx context.Context, m domain.Media, expiration time.Duration) (string, error) {
+	return repo.objects.PresignPut(ctx, repo.stagingKey(m), m.ContentType, expiration)
+}
+
+func (repo *mediaRepository) Inspect(ctx context.Context, m domain.Media, limit int64) (domain.Media, error) {
+	obj, err := repo.objects.Get(ctx, repo.stagingKey(m))
+	if err != nil {
+		return domain.Media{}, err
+	}
+	defer obj.Body.Close()
+	// 对象上的 Content-Type 是客户端上传的时候自己填的，要按照内容判断
+	r := bufio.NewReaderSize(io.LimitReader(obj.Body, limit+1), 512)
+	head, err := r.Peek(512)
+	if err != nil && err != io.EOF {
+		return domain.Media{}, err
+	}
+	m.ContentType = http.DetectContentType(head)
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return domain.Media{}, err
+	}
+	m.Size = n
+	m.Hash = ""
+	if n <= limit {
+		m.Hash = hex.EncodeToString(h.Sum(nil))
+	}
+	return m, nil
+}
+
+// key 用内容的摘要做 key，同样的内容只存一份
+func (repo *mediaRepository) key(hash string) string {
+	return "media/" + hash
+}
+
+// stagingKey 上传用的临时 key，每一次上传都不一样
+func (repo *mediaRepository) stagingKey(m domain.Media) string {
+	return fmt.Sprintf("media/tmp/%d/%s", m.Id, m.Nonce)
+}
+
+func (repo *mediaRepository) toEntity(m domain.Media) dao.Media {
+	return dao.Media{
+		Id:          m.Id,
+		Owner:       m.Owner,
+		Hash:        m.Hash,
+		Nonce:       m.Nonce,
+		Size:        m.Size,
+		ContentType: m.ContentType,
+		Status:      m.Status.ToUint8(),
+	}
+}
+
+func (repo *mediaRepository) toDomain(m dao.Media) domain.Media {
+	return domain.Media{
+		Id:          m.Id,
+		Owner:       m.Owner,
+		Hash:        m.Hash,
+		Nonce:       m.Nonce,
+		Size:        m.Size,
+		ContentType: m.ContentType,
+		Status:      domain.MediaStatus(m.Status),
+		URL:         repo.objects.URL(repo.key(m.Hash)),
+		Ctime:       time.UnixMilli(m.Ctime),
+	}
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository/dao"
+	daomocks "webook/internal/repository/dao/mocks"
+	"webook/internal/repository/dao/s3test"
+)
+
+func TestMediaRepository_Inspect(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+	// Inspect 用不到 MediaDAO
+	repo := NewMediaRepository(nil, dao.NewS3ObjectDAO(server.Client(), s3test.Bucket, server.URL))
+
+	content := []byte("\x89PNG\r\n\x1a\nfake png content")
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	html := []byte("<html><script>alert(1)</script></html>")
+	htmlSum := sha256.Sum256(html)
+
+	testCases := []struct {
+		name string
+		// upload 上传的内容，nil 表示没有上传
+		upload []byte
+		limit  int64
+
+		wantMedia domain.Media
+		wantErr   error
+	}{
+		{
+			name:   "内容一致",
+			upload: content,
+			limit:  int64(len(content)),
+			wantMedia: domain.Media{
+				Hash:        hash,
+				Size:        int64(len(content)),
+				ContentType: "image/png",
+			},
+		},
+		{
+			name:   "比声明的大",
+			upload: append(content, 'x'),
+			limit:  int64(len(content)),
+			wantMedia: domain.Media{
+				Size:        int64(len(content)) + 1,
+				ContentType: "image/png",
+			},
+		},
+		{
+			// 上传的时候 Content-Type 填的是 image/png
+			name:   "声明的类型和内容不一致",
+			upload: html,
+			limit:  int64(len(html)),
+			wantMedia: domain.Media{
+				Hash:        hex.EncodeToString(htmlSum[:]),
+				Size:        int64(len(html)),
+				ContentType: "text/html; charset=utf-8",
+			},
+		},
+		{
+			name:    "没有上传",
+			limit:   int64(len(content)),
+			wantErr: ErrObjectNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := domain.Media{Id: 1, Hash: hash, Nonce: "nonce", ContentType: "image/png"}
+			ctx := context.Background()
+			if tc.upload != nil {
+				upload(t, repo, m, tc.upload)
+				defer repo.(*mediaRepository).objects.Delete(ctx, "media/tmp/1/nonce")
+			}
+			actual, err := repo.Inspect(ctx, m, tc.limit)
+			if tc.wantErr == nil {
+				tc.wantMedia.Id, tc.wantMedia.Nonce = m.Id, m.Nonce
+			}
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantMedia, actual)
+		})
+	}
+}
+
+func TestMediaRepository_Publish(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mediaDAO := daomocks.NewMockMediaDAO(ctrl)
+	repo := NewMediaRepository(mediaDAO, dao.NewS3ObjectDAO(server.Client(), s3test.Bucket, server.URL))
+
+	content := []byte("fake png content")
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	m := domain.Media{Id: 1, Hash: hash, Nonce: "nonce", ContentType: "image/png"}
+	ctx := context.Background()
+
+	// 还没有上传
+	err := repo.Publish(ctx, m)
+	assert.Equal(t, ErrObjectNotFound, err)
+	_, _, ok := server.Object("media/" + hash)
+	assert.False(t, ok)
+
+	upload(t, repo, m, content)
+	mediaDAO.EXPECT().UpdateStatus(gomock.Any(), int64(1), domain.MediaStatusUploaded.ToUint8()).Return(nil)
+	err = repo.Publish(ctx, m)
+	require.NoError(t, err)
+	data, contentType, ok := server.Object("media/" + hash)
+	assert.True(t, ok)
+	assert.Equal(t, content, data)
+	assert.Equal(t, "image/png", contentType)
+	// 临时 key 复制之后就删掉了
+	_, _, ok = server.Object("media/tmp/1/nonce")
+	assert.False(t, ok)
+}
+
+// upload 模拟客户端用预签名的地址上传
+func upload(t *testing.T, repo MediaRepository, m domain.Media, content []byte) {
+	url, err := repo.UploadURL(context.Background(), m, time.Minute)
+	require.NoError(t, err)
+	assert.Contains(t, url, "/media/tmp/")
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(content))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", m.ContentType)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
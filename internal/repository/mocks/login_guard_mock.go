@@ -0,0 +1,100 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: .\internal\repository\login_guard.go
+//
+// Generated by this command:
+//
+//	mockgen -source .\internal\repository\login_guard.go -destination .\internal\repository\mocks\login_guard_mock.go -package repomocks
+//
+
+// Package repomocks is a generated GoMock package.
+package repomocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+	domain "webook/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockLoginGuardRepository is a mock of LoginGuardRepository interface.
+type MockLoginGuardRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockLoginGuardRepositoryMockRecorder
+}
+
+// MockLoginGuardRepositoryMockRecorder is the mock recorder for MockLoginGuardRepository.
+type MockLoginGuardRepositoryMockRecorder struct {
+	mock *MockLoginGuardRepository
+}
+
+// NewMockLoginGuardRepository creates a new mock instance.
+func NewMockLoginGuardRepository(ctrl *gomock.Controller) *MockLoginGuardRepository {
+	mock := &MockLoginGuardRepository{ctrl: ctrl}
+	mock.recorder = &MockLoginGuardRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLoginGuardRepository) EXPECT() *MockLoginGuardRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Block mocks base method.
+func (m *MockLoginGuardRepository) Block(ctx context.Context, account, ip string) (domain.LoginBlock, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Block", ctx, account, ip)
+	ret0, _ := ret[0].(domain.LoginBlock)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Block indicates an expected call of Block.
+func (mr *MockLoginGuardRepositoryMockRecorder) Block(ctx, account, ip any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Block", reflect.TypeOf((*MockLoginGuardRepository)(nil).Block), ctx, account, ip)
+}
+
+// BlockIP mocks base method.
+func (m *MockLoginGuardRepository) BlockIP(ctx context.Context, ip string, expiration time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BlockIP", ctx, ip, expiration)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BlockIP indicates an expected call of BlockIP.
+func (mr *MockLoginGuardRepositoryMockRecorder) BlockIP(ctx, ip, expiration any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockIP", reflect.TypeOf((*MockLoginGuardRepository)(nil).BlockIP), ctx, ip, expiration)
+}
+
+// Fail mocks base method.
+func (m *MockLoginGuardRepository) Fail(ctx context.Context, account string) (domain.LoginBlock, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Fail", ctx, account)
+	ret0, _ := ret[0].(domain.LoginBlock)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Fail indicates an expected call of Fail.
+func (mr *MockLoginGuardRepositoryMockRecorder) Fail(ctx, account any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Fail", reflect.TypeOf((*MockLoginGuardRepository)(nil).Fail), ctx, account)
+}
+
+// Reset mocks base method.
+func (m *MockLoginGuardRepository) Reset(ctx context.Context, account string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reset", ctx, account)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reset indicates an expected call of Reset.
+func (mr *MockLoginGuardRepositoryMockRecorder) Reset(ctx, account any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reset", reflect.TypeOf((*MockLoginGuardRepository)(nil).Reset), ctx, account)
+}
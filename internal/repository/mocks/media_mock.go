@@ -0,0 +1,145 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: .\internal\repository\media.go
+//
+// Generated by this command:
+//
+//	mockgen -source .\internal\repository\media.go -destination .\internal\repository\mocks\media_mock.go -package repomocks
+//
+
+// Package repomocks is a generated GoMock package.
+package repomocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+	domain "webook/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockMediaRepository is a mock of MediaRepository interface.
+type MockMediaRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockMediaRepositoryMockRecorder
+}
+
+// MockMediaRepositoryMockRecorder is the mock recorder for MockMediaRepository.
+type MockMediaRepositoryMockRecorder struct {
+	mock *MockMediaRepository
+}
+
+// NewMockMediaRepository creates a new mock instance.
+func NewMockMediaRepository(ctrl *gomock.Controller) *MockMediaRepository {
+	mock := &MockMediaRepository{ctrl: ctrl}
+	mock.recorder = &MockMediaRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMediaRepository) EXPECT() *MockMediaRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m_2 *MockMediaRepository) Create(ctx context.Context, m domain.Media) (int64, error) {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "Create", ctx, m)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockMediaRepositoryMockRecorder) Create(ctx, m any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockMediaRepository)(nil).Create), ctx, m)
+}
+
+// Delete mocks base method.
+func (m_2 *MockMediaRepository) Delete(ctx context.Context, m domain.Media) error {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "Delete", ctx, m)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockMediaRepositoryMockRecorder) Delete(ctx, m any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockMediaRepository)(nil).Delete), ctx, m)
+}
+
+// FindByHash mocks base method.
+func (m *MockMediaRepository) FindByHash(ctx context.Context, hash string) (domain.Media, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByHash", ctx, hash)
+	ret0, _ := ret[0].(domain.Media)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByHash indicates an expected call of FindByHash.
+func (mr *MockMediaRepositoryMockRecorder) FindByHash(ctx, hash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByHash", reflect.TypeOf((*MockMediaRepository)(nil).FindByHash), ctx, hash)
+}
+
+// FindById mocks base method.
+func (m *MockMediaRepository) FindById(ctx context.Context, id int64) (domain.Media, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindById", ctx, id)
+	ret0, _ := ret[0].(domain.Media)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindById indicates an expected call of FindById.
+func (mr *MockMediaRepositoryMockRecorder) FindById(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindById", reflect.TypeOf((*MockMediaRepository)(nil).FindById), ctx, id)
+}
+
+// Inspect mocks base method.
+func (m_2 *MockMediaRepository) Inspect(ctx context.Context, m domain.Media, limit int64) (domain.Media, error) {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "Inspect", ctx, m, limit)
+	ret0, _ := ret[0].(domain.Media)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Inspect indicates an expected call of Inspect.
+func (mr *MockMediaRepositoryMockRecorder) Inspect(ctx, m, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Inspect", reflect.TypeOf((*MockMediaRepository)(nil).Inspect), ctx, m, limit)
+}
+
+// Publish mocks base method.
+func (m_2 *MockMediaRepository) Publish(ctx context.Context, m domain.Media) error {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "Publish", ctx, m)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Publish indicates an expected call of Publish.
+func (mr *MockMediaRepositoryMockRecorder) Publish(ctx, m any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Publish", reflect.TypeOf((*MockMediaRepository)(nil).Publish), ctx, m)
+}
+
+// UploadURL mocks base method.
+func (m_2 *MockMediaRepository) UploadURL(ctx context.Context, m domain.Media, expiration time.Duration) (string, error) {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "UploadURL", ctx, m, expiration)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UploadURL indicates an expected call of UploadURL.
+func (mr *MockMediaRepositoryMockRecorder) UploadURL(ctx, m, expiration any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadURL", reflect.TypeOf((*MockMediaRepository)(nil).UploadURL), ctx, m, expiration)
+}
@@ -0,0 +1,115 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: .\internal\repository\user_identity.go
+//
+// Generated by this command:
+//
+//	mockgen -source .\internal\repository\user_identity.go -destination .\internal\repository\mocks\user_identity_mock.go -package repomocks
+//
+
+// Package repomocks is a generated GoMock package.
+package repomocks
+
+import (
+	context "context"
+	reflect "reflect"
+	domain "webook/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockUserIdentityRepository is a mock of UserIdentityRepository interface.
+type MockUserIdentityRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockUserIdentityRepositoryMockRecorder
+}
+
+// MockUserIdentityRepositoryMockRecorder is the mock recorder for MockUserIdentityRepository.
+type MockUserIdentityRepositoryMockRecorder struct {
+	mock *MockUserIdentityRepository
+}
+
+// NewMockUserIdentityRepository creates a new mock instance.
+func NewMockUserIdentityRepository(ctrl *gomock.Controller) *MockUserIdentityRepository {
+	mock := &MockUserIdentityRepository{ctrl: ctrl}
+	mock.recorder = &MockUserIdentityRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUserIdentityRepository) EXPECT() *MockUserIdentityRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockUserIdentityRepository) Create(ctx context.Context, identity domain.Identity) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, identity)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockUserIdentityRepositoryMockRecorder) Create(ctx, identity any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockUserIdentityRepository)(nil).Create), ctx, identity)
+}
+
+// CreateWithUser mocks base method.
+func (m *MockUserIdentityRepository) CreateWithUser(ctx context.Context, identity domain.Identity) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWithUser", ctx, identity)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWithUser indicates an expected call of CreateWithUser.
+func (mr *MockUserIdentityRepositoryMockRecorder) CreateWithUser(ctx, identity any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWithUser", reflect.TypeOf((*MockUserIdentityRepository)(nil).CreateWithUser), ctx, identity)
+}
+
+// Delete mocks base method.
+func (m *MockUserIdentityRepository) Delete(ctx context.Context, uid int64, provider string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, uid, provider)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockUserIdentityRepositoryMockRecorder) Delete(ctx, uid, provider any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockUserIdentityRepository)(nil).Delete), ctx, uid, provider)
+}
+
+// FindByProvider mocks base method.
+func (m *MockUserIdentityRepository) FindByProvider(ctx context.Context, provider, subject string) (domain.Identity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByProvider", ctx, provider, subject)
+	ret0, _ := ret[0].(domain.Identity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByProvider indicates an expected call of FindByProvider.
+func (mr *MockUserIdentityRepositoryMockRecorder) FindByProvider(ctx, provider, subject any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByProvider", reflect.TypeOf((*MockUserIdentityRepository)(nil).FindByProvider), ctx, provider, subject)
+}
+
+// FindByUid mocks base method.
+func (m *MockUserIdentityRepository) FindByUid(ctx context.Context, uid int64) ([]domain.Identity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByUid", ctx, uid)
+	ret0, _ := ret[0].([]domain.Identity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByUid indicates an expected call of FindByUid.
+func (mr *MockUserIdentityRepositoryMockRecorder) FindByUid(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByUid", reflect.TypeOf((*MockUserIdentityRepository)(nil).FindByUid), ctx, uid)
+}
@@ -40,6 +40,49 @@ func (m *MockUserRepository) EXPECT() *MockUserRepositoryMockRecorder {
 	return m.recorder
 }
 
+// BindEmail mocks base method.
+func (m *MockUserRepository) BindEmail(ctx context.Context, userID int64, email, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BindEmail", ctx, userID, email, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BindEmail indicates an expected call of BindEmail.
+func (mr *MockUserRepositoryMockRecorder) BindEmail(ctx, userID, email, password any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BindEmail", reflect.TypeOf((*MockUserRepository)(nil).BindEmail), ctx, userID, email, password)
+}
+
+// BindPhone mocks base method.
+func (m *MockUserRepository) BindPhone(ctx context.Context, userID int64, phone string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BindPhone", ctx, userID, phone)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BindPhone indicates an expected call of BindPhone.
+func (mr *MockUserRepositoryMockRecorder) BindPhone(ctx, userID, phone any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BindPhone", reflect.TypeOf((*MockUserRepository)(nil).BindPhone), ctx, userID, phone)
+}
+
+// ChangeEmail mocks base method.
+func (m *MockUserRepository) ChangeEmail(ctx context.Context, userID int64, email string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangeEmail", ctx, userID, email)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ChangeEmail indicates an expected call of ChangeEmail.
+func (mr *MockUserRepositoryMockRecorder) ChangeEmail(ctx, userID, email any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangeEmail", reflect.TypeOf((*MockUserRepository)(nil).ChangeEmail), ctx, userID, email)
+}
+
 // Create mocks base method.
 func (m *MockUserRepository) Create(ctx context.Context, u domain.User) error {
 	m.ctrl.T.Helper()
@@ -83,34 +126,34 @@ func (mr *MockUserRepositoryMockRecorder) FindByEmail(ctx, email any) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByEmail", reflect.TypeOf((*MockUserRepository)(nil).FindByEmail), ctx, email)
 }
 
-// FindByPhone mocks base method.
-func (m *MockUserRepository) FindByPhone(ctx context.Context, phone string) (domain.User, error) {
+// FindById mocks base method.
+func (m *MockUserRepository) FindById(ctx context.Context, userID int64) (domain.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindByPhone", ctx, phone)
+	ret := m.ctrl.Call(m, "FindById", ctx, userID)
 	ret0, _ := ret[0].(domain.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// FindByPhone indicates an expected call of FindByPhone.
-func (mr *MockUserRepositoryMockRecorder) FindByPhone(ctx, phone any) *gomock.Call {
+// FindById indicates an expected call of FindById.
+func (mr *MockUserRepositoryMockRecorder) FindById(ctx, userID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByPhone", reflect.TypeOf((*MockUserRepository)(nil).FindByPhone), ctx, phone)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindById", reflect.TypeOf((*MockUserRepository)(nil).FindById), ctx, userID)
 }
 
-// FindByWechat mocks base method.
-func (m *MockUserRepository) FindByWechat(ctx context.Context, openId string) (domain.User, error) {
+// FindByPhone mocks base method.
+func (m *MockUserRepository) FindByPhone(ctx context.Context, phone string) (domain.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindByWechat", ctx, openId)
+	ret := m.ctrl.Call(m, "FindByPhone", ctx, phone)
 	ret0, _ := ret[0].(domain.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// FindByWechat indicates an expected call of FindByWechat.
-func (mr *MockUserRepositoryMockRecorder) FindByWechat(ctx, openId any) *gomock.Call {
+// FindByPhone indicates an expected call of FindByPhone.
+func (mr *MockUserRepositoryMockRecorder) FindByPhone(ctx, phone any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByWechat", reflect.TypeOf((*MockUserRepository)(nil).FindByWechat), ctx, openId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByPhone", reflect.TypeOf((*MockUserRepository)(nil).FindByPhone), ctx, phone)
 }
 
 // FindUserInfoById mocks base method.
@@ -127,3 +170,104 @@ func (mr *MockUserRepositoryMockRecorder) FindUserInfoById(ctx, userID any) *gom
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindUserInfoById", reflect.TypeOf((*MockUserRepository)(nil).FindUserInfoById), ctx, userID)
 }
+
+// MarkEmailVerified mocks base method.
+func (m *MockUserRepository) MarkEmailVerified(ctx context.Context, userID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkEmailVerified", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkEmailVerified indicates an expected call of MarkEmailVerified.
+func (mr *MockUserRepositoryMockRecorder) MarkEmailVerified(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkEmailVerified", reflect.TypeOf((*MockUserRepository)(nil).MarkEmailVerified), ctx, userID)
+}
+
+// Unbind mocks base method.
+func (m *MockUserRepository) Unbind(ctx context.Context, userID int64, method domain.LoginMethod) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unbind", ctx, userID, method)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Unbind indicates an expected call of Unbind.
+func (mr *MockUserRepositoryMockRecorder) Unbind(ctx, userID, method any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unbind", reflect.TypeOf((*MockUserRepository)(nil).Unbind), ctx, userID, method)
+}
+
+// UpdateAvatar mocks base method.
+func (m *MockUserRepository) UpdateAvatar(ctx context.Context, userID int64, avatar string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAvatar", ctx, userID, avatar)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateAvatar indicates an expected call of UpdateAvatar.
+func (mr *MockUserRepositoryMockRecorder) UpdateAvatar(ctx, userID, avatar any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAvatar", reflect.TypeOf((*MockUserRepository)(nil).UpdateAvatar), ctx, userID, avatar)
+}
+
+// UpdatePassword mocks base method.
+func (m *MockUserRepository) UpdatePassword(ctx context.Context, userID int64, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePassword", ctx, userID, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePassword indicates an expected call of UpdatePassword.
+func (mr *MockUserRepositoryMockRecorder) UpdatePassword(ctx, userID, password any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePassword", reflect.TypeOf((*MockUserRepository)(nil).UpdatePassword), ctx, userID, password)
+}
+
+// UpdateTotp mocks base method.
+func (m *MockUserRepository) UpdateTotp(ctx context.Context, userID int64, totp domain.Totp) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTotp", ctx, userID, totp)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateTotp indicates an expected call of UpdateTotp.
+func (mr *MockUserRepositoryMockRecorder) UpdateTotp(ctx, userID, totp any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTotp", reflect.TypeOf((*MockUserRepository)(nil).UpdateTotp), ctx, userID, totp)
+}
+
+// UseRecoveryCode mocks base method.
+func (m *MockUserRepository) UseRecoveryCode(ctx context.Context, userID int64, old, remaining []string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", ctx, userID, old, remaining)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockUserRepositoryMockRecorder) UseRecoveryCode(ctx, userID, old, remaining any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockUserRepository)(nil).UseRecoveryCode), ctx, userID, old, remaining)
+}
+
+// UseTotpStep mocks base method.
+func (m *MockUserRepository) UseTotpStep(ctx context.Context, userID, step int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseTotpStep", ctx, userID, step)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseTotpStep indicates an expected call of UseTotpStep.
+func (mr *MockUserRepositoryMockRecorder) UseTotpStep(ctx, userID, step any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseTotpStep", reflect.TypeOf((*MockUserRepository)(nil).UseTotpStep), ctx, userID, step)
+}
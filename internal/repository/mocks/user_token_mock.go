@@ -0,0 +1,70 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: .\internal\repository\user_token.go
+//
+// Generated by this command:
+//
+//	mockgen -source .\internal\repository\user_token.go -destination .\internal\repository\mocks\user_token_mock.go -package repomocks
+//
+
+// Package repomocks is a generated GoMock package.
+package repomocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockUserTokenRepository is a mock of UserTokenRepository interface.
+type MockUserTokenRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockUserTokenRepositoryMockRecorder
+}
+
+// MockUserTokenRepositoryMockRecorder is the mock recorder for MockUserTokenRepository.
+type MockUserTokenRepositoryMockRecorder struct {
+	mock *MockUserTokenRepository
+}
+
+// NewMockUserTokenRepository creates a new mock instance.
+func NewMockUserTokenRepository(ctrl *gomock.Controller) *MockUserTokenRepository {
+	mock := &MockUserTokenRepository{ctrl: ctrl}
+	mock.recorder = &MockUserTokenRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUserTokenRepository) EXPECT() *MockUserTokenRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Consume mocks base method.
+func (m *MockUserTokenRepository) Consume(ctx context.Context, biz, token string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Consume", ctx, biz, token)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Consume indicates an expected call of Consume.
+func (mr *MockUserTokenRepositoryMockRecorder) Consume(ctx, biz, token any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Consume", reflect.TypeOf((*MockUserTokenRepository)(nil).Consume), ctx, biz, token)
+}
+
+// Set mocks base method.
+func (m *MockUserTokenRepository) Set(ctx context.Context, biz string, uid int64, token string, expiration time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Set", ctx, biz, uid, token, expiration)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Set indicates an expected call of Set.
+func (mr *MockUserTokenRepositoryMockRecorder) Set(ctx, biz, uid, token, expiration any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockUserTokenRepository)(nil).Set), ctx, biz, uid, token, expiration)
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 	"webook/internal/domain"
 	"webook/internal/repository/cache"
@@ -13,6 +15,7 @@ import (
 
 var (
 	ErrDuplicateUser = dao.ErrDuplicateUser
+	ErrAlreadyBound  = dao.ErrAlreadyBound
 	ErrUserNotFound  = dao.ErrRecordNotFound
 )
 
@@ -21,8 +24,29 @@ type UserRepository interface {
 	FindByEmail(ctx context.Context, email string) (domain.User, error)
 	EditUserInfo(ctx context.Context, userID int64, name string, birthday string, me string) error
 	FindUserInfoById(ctx context.Context, userID int64) (domain.User, error)
+	// FindById 直接查数据库，缓存里面没有密码和两步验证的密钥，要用这些的时候用这个
+	FindById(ctx context.Context, userID int64) (domain.User, error)
 	FindByPhone(ctx context.Context, phone string) (domain.User, error)
-	FindByWechat(ctx context.Context, openId string) (domain.User, error)
+	// UpdatePassword password 是加密之后的
+	UpdatePassword(ctx context.Context, userID int64, password string) error
+	MarkEmailVerified(ctx context.Context, userID int64) error
+	UpdateAvatar(ctx context.Context, userID int64, avatar string) error
+	// UpdateTotp 保存两步验证的密钥、开关和恢复码
+	UpdateTotp(ctx context.Context, userID int64, totp domain.Totp) error
+	// UseTotpStep 记录用过的动态码，已经用过了返回 false
+	UseTotpStep(ctx context.Context, userID int64, step int64) (bool, error)
+	// UseRecoveryCode 恢复码从 old 换成 remaining，被并发修改了返回 false
+	UseRecoveryCode(ctx context.Context, userID int64, old []string, remaining []string) (bool, error)
+
+	// 绑定登录方式，已经被其它用户绑定的时候返回 ErrDuplicateUser，自己已经绑定过了返回 ErrAlreadyBound
+
+	// BindEmail password 是加密之后的，调用之前要确认邮箱是用户本人的
+	BindEmail(ctx context.Context, userID int64, email string, password string) error
+	BindPhone(ctx context.Context, userID int64, phone string) error
+	// ChangeEmail 换成新的邮箱，没有绑定过邮箱的时候返回 false
+	ChangeEmail(ctx context.Context, userID int64, email string) (bool, error)
+	// Unbind 只能解绑邮箱和手机号，解绑之后没有其它登录方式的时候不解绑，返回 false
+	Unbind(ctx context.Context, userID int64, method domain.LoginMethod) (bool, error)
 }
 
 type CachedUserRepository struct {
@@ -62,15 +86,16 @@ func (repo *CachedUserRepository) EditUserInfo(ctx context.Context, userID int64
 	if err != nil {
 		return err
 	}
-	du := repo.toDomain(user)
-	// 刷新缓存
-	go func() {
-		err = repo.cache.Set(ctx, du)
-		if err != nil {
-			// 记录日志
-		}
-	}()
-	return nil
+	// user 里面只有改了的字段，不能拿它刷新缓存，直接删掉
+	return repo.cache.Del(ctx, userID)
+}
+
+func (repo *CachedUserRepository) FindById(ctx context.Context, userID int64) (domain.User, error) {
+	u, err := repo.dao.FindUserInfoById(ctx, userID)
+	if err != nil {
+		return domain.User{}, err
+	}
+	return repo.toDomain(u), nil
 }
 
 func (repo *CachedUserRepository) FindUserInfoById(ctx context.Context, userID int64) (domain.User, error) {
@@ -153,14 +178,6 @@ func (repo *CachedUserRepository) FindByPhone(ctx context.Context, phone string)
 	return repo.toDomain(u), nil
 }
 
-func (repo *CachedUserRepository) FindByWechat(ctx context.Context, openId string) (domain.User, error) {
-	u, err := repo.dao.FindByWechat(ctx, openId)
-	if err != nil {
-		return domain.User{}, err
-	}
-	return repo.toDomain(u), nil
-}
-
 func (repo *CachedUserRepository) timeStoUnix(timeS string) int64 {
 	// 将字符串转为 time.Time 类型
 	birth, _ := time.ParseInLocation(time.DateOnly, timeS, time.Local)
@@ -169,6 +186,98 @@ func (repo *CachedUserRepository) timeStoUnix(timeS string) int64 {
 	return birthUnix
 }
 
+func (repo *CachedUserRepository) UpdatePassword(ctx context.Context, userID int64, password string) error {
+	err := repo.dao.UpdatePassword(ctx, userID, password)
+	if err != nil {
+		return err
+	}
+	// 缓存里面有旧的密码，直接删掉
+	return repo.cache.Del(ctx, userID)
+}
+
+func (repo *CachedUserRepository) MarkEmailVerified(ctx context.Context, userID int64) error {
+	err := repo.dao.UpdateEmailVerified(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return repo.cache.Del(ctx, userID)
+}
+
+func (repo *CachedUserRepository) UpdateAvatar(ctx context.Context, userID int64, avatar string) error {
+	err := repo.dao.UpdateAvatar(ctx, userID, avatar)
+	if err != nil {
+		return err
+	}
+	return repo.cache.Del(ctx, userID)
+}
+
+func (repo *CachedUserRepository) UpdateTotp(ctx context.Context, userID int64, totp domain.Totp) error {
+	err := repo.dao.UpdateTotp(ctx, userID, totp.Secret, totp.Enabled, strings.Join(totp.RecoveryCodes, ","))
+	if err != nil {
+		return err
+	}
+	return repo.cache.Del(ctx, userID)
+}
+
+func (repo *CachedUserRepository) UseTotpStep(ctx context.Context, userID int64, step int64) (bool, error) {
+	ok, err := repo.dao.UpdateTotpLastStep(ctx, userID, step)
+	if err != nil || !ok {
+		return ok, err
+	}
+	return true, repo.cache.Del(ctx, userID)
+}
+
+func (repo *CachedUserRepository) UseRecoveryCode(ctx context.Context, userID int64, old []string, remaining []string) (bool, error) {
+	ok, err := repo.dao.UpdateTotpRecoveryCodes(ctx, userID, strings.Join(old, ","), strings.Join(remaining, ","))
+	if err != nil || !ok {
+		return ok, err
+	}
+	return true, repo.cache.Del(ctx, userID)
+}
+
+func (repo *CachedUserRepository) BindEmail(ctx context.Context, userID int64, email string, password string) error {
+	err := repo.dao.BindEmail(ctx, userID, email, password)
+	if err != nil {
+		return err
+	}
+	return repo.cache.Del(ctx, userID)
+}
+
+func (repo *CachedUserRepository) BindPhone(ctx context.Context, userID int64, phone string) error {
+	err := repo.dao.BindPhone(ctx, userID, phone)
+	if err != nil {
+		return err
+	}
+	return repo.cache.Del(ctx, userID)
+}
+
+func (repo *CachedUserRepository) ChangeEmail(ctx context.Context, userID int64, email string) (bool, error) {
+	ok, err := repo.dao.UpdateEmail(ctx, userID, email)
+	if err != nil || !ok {
+		return ok, err
+	}
+	return true, repo.cache.Del(ctx, userID)
+}
+
+func (repo *CachedUserRepository) Unbind(ctx context.Context, userID int64, method domain.LoginMethod) (bool, error) {
+	var (
+		ok  bool
+		err error
+	)
+	switch method {
+	case domain.LoginMethodEmail:
+		ok, err = repo.dao.UnbindEmail(ctx, userID)
+	case domain.LoginMethodPhone:
+		ok, err = repo.dao.UnbindPhone(ctx, userID)
+	default:
+		return false, fmt.Errorf("未知的登录方式 %s", method)
+	}
+	if err != nil || !ok {
+		return ok, err
+	}
+	return true, repo.cache.Del(ctx, userID)
+}
+
 func (repo *CachedUserRepository) toEntity(u domain.User) dao.User {
 	birthUnix := repo.timeStoUnix(u.Birthday)
 	return dao.User{
@@ -181,18 +290,17 @@ func (repo *CachedUserRepository) toEntity(u domain.User) dao.User {
 			String: u.Phone,
 			Valid:  u.Phone != "",
 		},
-		Password: u.Password,
-		Birthday: birthUnix,
-		AboutMe:  u.AboutMe,
-		NickName: u.NickName,
-		WechatOpenId: sql.NullString{
-			String: u.WechatInfo.OpenId,
-			Valid:  u.WechatInfo.OpenId != "",
-		},
-		WechatUnionId: sql.NullString{
-			String: u.WechatInfo.UnionId,
-			Valid:  u.WechatInfo.UnionId != "",
-		},
+		Password:          u.Password,
+		EmailVerified:     u.EmailVerified,
+		Birthday:          birthUnix,
+		AboutMe:           u.AboutMe,
+		NickName:          u.NickName,
+		Avatar:            u.Avatar,
+		Roles:             strings.Join(u.Roles, ","),
+		TotpSecret:        u.Totp.Secret,
+		TotpEnabled:       u.Totp.Enabled,
+		TotpRecoveryCodes: strings.Join(u.Totp.RecoveryCodes, ","),
+		TotpLastStep:      u.Totp.LastStep,
 	}
 }
 
@@ -206,17 +314,30 @@ func (repo *CachedUserRepository) toDomain(u dao.User) domain.User {
 		birthdayString = birthTime.Format(time.DateOnly)
 	}
 	return domain.User{
-		Id:       u.Id,
-		Email:    u.Email.String,
-		Phone:    u.Phone.String,
-		Password: u.Password,
-		NickName: u.NickName,
-		AboutMe:  u.AboutMe,
-		Birthday: birthdayString,
-		Ctime:    time.UnixMilli(u.Ctime),
-		WechatInfo: domain.WechatInfo{
-			OpenId:  u.WechatOpenId.String,
-			UnionId: u.WechatUnionId.String,
+		Id:            u.Id,
+		Email:         u.Email.String,
+		Phone:         u.Phone.String,
+		Password:      u.Password,
+		EmailVerified: u.EmailVerified,
+		NickName:      u.NickName,
+		AboutMe:       u.AboutMe,
+		Avatar:        u.Avatar,
+		Birthday:      birthdayString,
+		Ctime:         time.UnixMilli(u.Ctime),
+		Roles:         repo.split(u.Roles),
+		Totp: domain.Totp{
+			Secret:        u.TotpSecret,
+			Enabled:       u.TotpEnabled,
+			RecoveryCodes: repo.split(u.TotpRecoveryCodes),
+			LastStep:      u.TotpLastStep,
 		},
 	}
 }
+
+// split 逗号分隔的字段
+func (repo *CachedUserRepository) split(val string) []string {
+	if val == "" {
+		return nil
+	}
+	return strings.Split(val, ",")
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"webook/internal/domain"
+	"webook/internal/repository/dao"
+)
+
+var ErrIdentityNotFound = dao.ErrRecordNotFound
+
+type UserIdentityRepository interface {
+	// FindByProvider 没有找到返回 ErrIdentityNotFound
+	FindByProvider(ctx context.Context, provider string, subject string) (domain.Identity, error)
+	FindByUid(ctx context.Context, uid int64) ([]domain.Identity, error)
+	// Create 绑定到 identity.Uid，已经被其它用户绑定的时候返回 ErrDuplicateUser
+	Create(ctx context.Context, identity domain.Identity) error
+	// CreateWithUser 同时创建一个新用户，返回用户 ID
+	CreateWithUser(ctx context.Context, identity domain.Identity) (int64, error)
+	// Delete 解绑之后没有其它登录方式的时候不解绑，返回 false
+	Delete(ctx context.Context, uid int64, provider string) (bool, error)
+}
+
+type userIdentityRepository struct {
+	dao dao.UserIdentityDAO
+}
+
+func NewUserIdentityRepository(dao dao.UserIdentityDAO) UserIdentityRepository {
+	return &userIdentityRepository{
+		dao: dao,
+	}
+}
+
+func (repo *userIdentityRepository) FindByProvider(ctx context.Context, provider string, subject string) (domain.Identity, error) {
+	identity, err := repo.dao.FindByProvider(ctx, provider, subject)
+	if err != nil {
+		return domain.Identity{}, err
+	}
+	return repo.toDomain(identity), nil
+}
+
+func (repo *userIdentityRepository) FindByUid(ctx context.Context, uid int64) ([]domain.Identity, error) {
+	identities, err := repo.dao.FindByUid(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Identity, 0, len(identities))
+	for _, identity := range identities {
+		res = append(res, repo.toDomain(identity))
+	}
+	return res, nil
+}
+
+func (repo *userIdentityRepository) Create(ctx context.Context, identity domain.Identity) error {
+	return repo.dao.Insert(ctx, repo.toEntity(identity))
+}
+
+func (repo *userIdentityRepository) CreateWithUser(ctx context.Context, identity domain.Identity) (int64, error) {
+	return repo.dao.InsertWithUser(ctx, repo.toEntity(identity))
+}
+
+func (repo *userIdentityRepository) Delete(ctx context.Context, uid int64, provider string) (bool, error) {
+	return repo.dao.Delete(ctx, uid, provider)
+}
+
+func (repo *userIdentityRepository) toEntity(identity domain.Identity) dao.UserIdentity {
+	return dao.UserIdentity{
+		Uid:      identity.Uid,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		UnionId:  identity.UnionId,
+		Email:    identity.Email,
+		Name:     identity.Name,
+	}
+}
+
+func (repo *userIdentityRepository) toDomain(identity dao.UserIdentity) domain.Identity {
+	return domain.Identity{
+		Uid:      identity.Uid,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		UnionId:  identity.UnionId,
+		Email:    identity.Email,
+		Name:     identity.Name,
+	}
+}
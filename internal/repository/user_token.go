@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"webook/internal/repository/cache"
+)
+
+var (
+	ErrTokenSendTooMany = cache.ErrTokenSendTooMany
+	ErrTokenNotFound    = cache.ErrTokenNotFound
+)
+
+type UserTokenRepository interface {
+	Set(ctx context.Context, biz string, uid int64, token string, expiration time.Duration) error
+	Consume(ctx context.Context, biz string, token string) (int64, error)
+}
+
+type CachedUserTokenRepository struct {
+	cache cache.UserTokenCache
+}
+
+func NewCachedUserTokenRepository(c cache.UserTokenCache) UserTokenRepository {
+	return &CachedUserTokenRepository{
+		cache: c,
+	}
+}
+
+func (r *CachedUserTokenRepository) Set(ctx context.Context, biz string, uid int64, token string, expiration time.Duration) error {
+	return r.cache.Set(ctx, biz, uid, token, expiration)
+}
+
+func (r *CachedUserTokenRepository) Consume(ctx context.Context, biz string, token string) (int64, error) {
+	return r.cache.Consume(ctx, biz, token)
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"time"
+	"webook/internal/repository"
+	"webook/internal/service/email"
+)
+
+const (
+	bizVerifyEmail   = "verify_email"
+	bizResetPassword = "reset_password"
+)
+
+var (
+	ErrTokenSendTooMany = repository.ErrTokenSendTooMany
+	ErrInvalidToken     = errors.New("链接无效或者已经过期")
+	ErrNoEmail          = errors.New("没有绑定邮箱")
+)
+
+// AccountService 邮箱验证、找回密码和修改密码
+// 修改密码之后要让所有会话退出登录，这一步由 web 层完成
+type AccountService interface {
+	// SendVerifyEmail 给用户绑定的邮箱发送验证链接
+	SendVerifyEmail(ctx context.Context, uid int64) error
+	VerifyEmail(ctx context.Context, token string) error
+	// SendResetPasswordEmail 邮箱没有注册也返回 nil，避免被用来探测哪些邮箱注册过
+	SendResetPasswordEmail(ctx context.Context, email string) error
+	// ResetPassword 返回密码被重置的用户
+	ResetPassword(ctx context.Context, token string, password string) (int64, error)
+	// ChangePassword 旧密码不对的时候返回 ErrInvalidUserOrPassword
+	ChangePassword(ctx context.Context, uid int64, oldPassword string, newPassword string) error
+}
+
+type accountService struct {
+	repo      repository.UserRepository
+	tokenRepo repository.UserTokenRepository
+	email     email.Service
+	// baseURL 前端页面的地址，邮件里面的链接是 baseURL/verify_email?token=xxx
+	baseURL string
+}
+
+func NewAccountService(repo repository.UserRepository, tokenRepo repository.UserTokenRepository,
+	emailSvc email.Service, baseURL string) AccountService {
+	return &accountService{
+		repo:      repo,
+		tokenRepo: tokenRepo,
+		email:     emailSvc,
+		baseURL:   baseURL,
+	}
+}
+
+func (svc *accountService) SendVerifyEmail(ctx context.Context, uid int64) error {
+	u, err := svc.repo.FindUserInfoById(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if u.Email == "" {
+		return ErrNoEmail
+	}
+	if u.EmailVerified {
+		return nil
+	}
+	token, err := issueToken(ctx, svc.tokenRepo, bizVerifyEmail, uid, time.Hour*24)
+	if err != nil {
+		return err
+	}
+	return svc.email.Send(ctx, "验证你的邮箱",
+		fmt.Sprintf("点击链接完成邮箱验证，24 小时内有效：%s/verify_email?token=%s", svc.baseURL, token), u.Email)
+}
+
+func (svc *accountService) VerifyEmail(ctx context.Context, token string) error {
+	uid, err := consumeToken(ctx, svc.tokenRepo, bizVerifyEmail, token)
+	if err != nil {
+		return err
+	}
+	return svc.repo.MarkEmailVerified(ctx, uid)
+}
+
+func (svc *accountService) SendResetPasswordEmail(ctx context.Context, email string) error {
+	u, err := svc.repo.FindByEmail(ctx, email)
+	if errors.Is(err, repository.ErrUserNotFound) || (err == nil && u.Id == 0) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	token, err := issueToken(ctx, svc.tokenRepo, bizResetPassword, u.Id, time.Minute*30)
+	if err != nil {
+		return err
+	}
+	return svc.email.Send(ctx, "重置密码",
+		fmt.Sprintf("点击链接重置密码，30 分钟内有效：%s/reset_password?token=%s\n如果不是你本人操作，请忽略这封邮件", svc.baseURL, token), u.Email)
+}
+
+func (svc *accountService) ResetPassword(ctx context.Context, token string, password string) (int64, error) {
+	uid, err := consumeToken(ctx, svc.tokenRepo, bizResetPassword, token)
+	if err != nil {
+		return 0, err
+	}
+	err = svc.updatePassword(ctx, uid, password)
+	if err != nil {
+		return 0, err
+	}
+	// 能收到邮件，说明邮箱是用户本人的
+	err = svc.repo.MarkEmailVerified(ctx, uid)
+	return uid, err
+}
+
+func (svc *accountService) ChangePassword(ctx context.Context, uid int64, oldPassword string, newPassword string) error {
+	// 缓存里面没有密码
+	u, err := svc.repo.FindById(ctx, uid)
+	if err != nil {
+		return err
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(oldPassword))
+	if err != nil {
+		return ErrInvalidUserOrPassword
+	}
+	return svc.updatePassword(ctx, uid, newPassword)
+}
+
+func (svc *accountService) updatePassword(ctx context.Context, uid int64, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return svc.repo.UpdatePassword(ctx, uid, string(hash))
+}
+
+// issueToken 绑定邮箱也要发 token，所以不放在 accountService 上面
+func issueToken(ctx context.Context, tokenRepo repository.UserTokenRepository,
+	biz string, uid int64, expiration time.Duration) (string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+	err = tokenRepo.Set(ctx, biz, uid, token, expiration)
+	return token, err
+}
+
+func consumeToken(ctx context.Context, tokenRepo repository.UserTokenRepository, biz string, token string) (int64, error) {
+	uid, err := tokenRepo.Consume(ctx, biz, token)
+	if errors.Is(err, repository.ErrTokenNotFound) {
+		return 0, ErrInvalidToken
+	}
+	return uid, err
+}
+
+// generateToken token 会出现在链接里面，所以用 URL 安全的编码
+func generateToken() (string, error) {
+	data := make([]byte, 32)
+	_, err := rand.Read(data)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	repomocks "webook/internal/repository/mocks"
+	"webook/internal/service/email"
+	emailmocks "webook/internal/service/email/mocks"
+)
+
+func Test_accountService_SendResetPasswordEmail(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		mock func(ctrl *gomock.Controller) (repository.UserRepository, repository.UserTokenRepository, email.Service)
+
+		email string
+
+		wantErr error
+	}{
+		{
+			name: "发送成功",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.UserTokenRepository, email.Service) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				tokenRepo := repomocks.NewMockUserTokenRepository(ctrl)
+				emailSvc := emailmocks.NewMockService(ctrl)
+				repo.EXPECT().FindByEmail(gomock.Any(), "123@qq.com").
+					Return(domain.User{Id: 123, Email: "123@qq.com"}, nil)
+				tokenRepo.EXPECT().Set(gomock.Any(), bizResetPassword, int64(123), gomock.Any(), time.Minute*30).
+					Return(nil)
+				emailSvc.EXPECT().Send(gomock.Any(), "重置密码", gomock.Any(), "123@qq.com").
+					Return(nil)
+				return repo, tokenRepo, emailSvc
+			},
+			email: "123@qq.com",
+		},
+		{
+			name: "邮箱没有注册，不发送也不报错",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.UserTokenRepository, email.Service) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByEmail(gomock.Any(), "123@qq.com").
+					Return(domain.User{}, nil)
+				return repo, repomocks.NewMockUserTokenRepository(ctrl), emailmocks.NewMockService(ctrl)
+			},
+			email: "123@qq.com",
+		},
+		{
+			name: "发送太频繁",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.UserTokenRepository, email.Service) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				tokenRepo := repomocks.NewMockUserTokenRepository(ctrl)
+				repo.EXPECT().FindByEmail(gomock.Any(), "123@qq.com").
+					Return(domain.User{Id: 123, Email: "123@qq.com"}, nil)
+				tokenRepo.EXPECT().Set(gomock.Any(), bizResetPassword, int64(123), gomock.Any(), time.Minute*30).
+					Return(repository.ErrTokenSendTooMany)
+				return repo, tokenRepo, emailmocks.NewMockService(ctrl)
+			},
+			email:   "123@qq.com",
+			wantErr: ErrTokenSendTooMany,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo, tokenRepo, emailSvc := tc.mock(ctrl)
+			svc := NewAccountService(repo, tokenRepo, emailSvc, "http://localhost:3000")
+			err := svc.SendResetPasswordEmail(context.Background(), tc.email)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func Test_accountService_ChangePassword(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("hello#world123"), bcrypt.DefaultCost)
+	assert.NoError(t, err)
+	testCases := []struct {
+		name string
+
+		mock func(ctrl *gomock.Controller) repository.UserRepository
+
+		oldPassword string
+		newPassword string
+
+		wantErr error
+	}{
+		{
+			name: "修改成功",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).
+					Return(domain.User{Id: 123, Password: string(hash)}, nil)
+				repo.EXPECT().UpdatePassword(gomock.Any(), int64(123), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, uid int64, password string) error {
+						// 存进去的是加密之后的新密码
+						return bcrypt.CompareHashAndPassword([]byte(password), []byte("hello#world456"))
+					})
+				return repo
+			},
+			oldPassword: "hello#world123",
+			newPassword: "hello#world456",
+		},
+		{
+			name: "原密码不对",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).
+					Return(domain.User{Id: 123, Password: string(hash)}, nil)
+				return repo
+			},
+			oldPassword: "hello#world000",
+			newPassword: "hello#world456",
+			wantErr:     ErrInvalidUserOrPassword,
+		},
+		{
+			name: "系统错误",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).
+					Return(domain.User{}, errors.New("db Err"))
+				return repo
+			},
+			oldPassword: "hello#world123",
+			newPassword: "hello#world456",
+			wantErr:     errors.New("db Err"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewAccountService(tc.mock(ctrl), nil, nil, "")
+			err := svc.ChangePassword(context.Background(), 123, tc.oldPassword, tc.newPassword)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
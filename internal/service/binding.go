@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"net/url"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/internal/service/email"
+)
+
+const (
+	bizBindPhone = "bind_phone"
+	bizBindEmail = "bind_email"
+)
+
+var (
+	ErrInvalidCode     = errors.New("验证码不对")
+	ErrBindingConflict = errors.New("已经绑定了其它账号")
+	ErrNotBound        = errors.New("没有绑定这种登录方式")
+	ErrLastLoginMethod = errors.New("至少要保留一种登录方式")
+)
+
+// BindingService 已经登录的用户绑定和解绑邮箱、手机号和第三方登录
+// 避免同一个人用不同的方式登录之后变成好几个账号
+type BindingService interface {
+	// SendBindEmail 给要绑定的邮箱发确认链接，确认之前邮箱不能用来登录，也不占用这个邮箱
+	// 已经绑定过邮箱的返回 ErrBindingConflict，要换邮箱走 ChangeEmail
+	SendBindEmail(ctx context.Context, uid int64, email string) error
+	// BindEmail token 来自确认邮件，同时设置邮箱登录的密码
+	// token 不对或者不是发给这个用户、这个邮箱的返回 ErrInvalidToken
+	BindEmail(ctx context.Context, uid int64, email string, password string, token string) error
+	// ChangeEmail 换成新的邮箱，需要原密码或者两步验证的动态码，totpCode 不为空的时候用动态码验证
+	// 换了之后邮箱需要重新验证
+	ChangeEmail(ctx context.Context, uid int64, email string, password string, totpCode string) error
+	SendBindPhoneCode(ctx context.Context, phone string) error
+	BindPhone(ctx context.Context, uid int64, phone string, code string) error
+	// BindIdentity identity 由 web 层走完 OAuth2 之后拿到
+	BindIdentity(ctx context.Context, uid int64, identity domain.Identity) error
+	// Identities 绑定的第三方登录
+	Identities(ctx context.Context, uid int64) ([]domain.Identity, error)
+	// Unbind method 是 email、phone 或者第三方登录的 Provider
+	Unbind(ctx context.Context, uid int64, method domain.LoginMethod) error
+}
+
+type bindingService struct {
+	repo         repository.UserRepository
+	identityRepo repository.UserIdentityRepository
+	codeSvc      CodeService
+	totpSvc      TotpService
+	tokenRepo    repository.UserTokenRepository
+	email        email.Service
+	// baseURL 前端页面的地址，确认链接是 baseURL/bind_email?email=xxx&token=xxx
+	baseURL string
+}
+
+func NewBindingService(repo repository.UserRepository, identityRepo repository.UserIdentityRepository,
+	codeSvc CodeService, totpSvc TotpService, tokenRepo repository.UserTokenRepository,
+	emailSvc email.Service, baseURL string) BindingService {
+	return &bindingService{
+		repo:         repo,
+		identityRepo: identityRepo,
+		codeSvc:      codeSvc,
+		totpSvc:      totpSvc,
+		tokenRepo:    tokenRepo,
+		email:        emailSvc,
+		baseURL:      baseURL,
+	}
+}
+
+func (svc *bindingService) SendBindEmail(ctx context.Context, uid int64, email string) error {
+	bound, err := svc.bound(ctx, uid, domain.LoginMethodEmail)
+	if err != nil {
+		return err
+	}
+	if bound {
+		return ErrBindingConflict
+	}
+	token, err := issueToken(ctx, svc.tokenRepo, svc.bindEmailBiz(email), uid, time.Minute*30)
+	if err != nil {
+		return err
+	}
+	return svc.email.Send(ctx, "绑定邮箱",
+		fmt.Sprintf("点击链接完成邮箱绑定，30 分钟内有效：%s/bind_email?email=%s&token=%s\n如果不是你本人操作，请忽略这封邮件",
+			svc.baseURL, url.QueryEscape(email), token), email)
+}
+
+func (svc *bindingService) BindEmail(ctx context.Context, uid int64, email string, password string, token string) error {
+	// 先确认邮箱是用户本人的，再占用这个邮箱
+	tokenUid, err := consumeToken(ctx, svc.tokenRepo, svc.bindEmailBiz(email), token)
+	if err != nil {
+		return err
+	}
+	if tokenUid != uid {
+		return ErrInvalidToken
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return svc.conflict(svc.repo.BindEmail(ctx, uid, email, string(hash)))
+}
+
+// bindEmailBiz token 和邮箱绑在一起，不能拿发到一个邮箱的 token 去绑定另外一个邮箱
+func (svc *bindingService) bindEmailBiz(email string) string {
+	return fmt.Sprintf("%s:%s", bizBindEmail, email)
+}
+
+func (svc *bindingService) ChangeEmail(ctx context.Context, uid int64, email string, password string, totpCode string) error {
+	// 缓存里面没有密码
+	u, err := svc.repo.FindById(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if u.Email == "" {
+		return ErrNotBound
+	}
+	if totpCode != "" {
+		err = svc.totpSvc.Verify(ctx, uid, totpCode)
+		if err != nil {
+			return err
+		}
+	} else if bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)) != nil {
+		return ErrInvalidUserOrPassword
+	}
+	ok, err := svc.repo.ChangeEmail(ctx, uid, email)
+	if err != nil {
+		return svc.conflict(err)
+	}
+	if !ok {
+		// 验证之后被并发解绑了
+		return ErrNotBound
+	}
+	return nil
+}
+
+func (svc *bindingService) SendBindPhoneCode(ctx context.Context, phone string) error {
+	return svc.codeSvc.Send(ctx, bizBindPhone, phone)
+}
+
+func (svc *bindingService) BindPhone(ctx context.Context, uid int64, phone string, code string) error {
+	ok, err := svc.codeSvc.Verify(ctx, bizBindPhone, phone, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidCode
+	}
+	return svc.conflict(svc.repo.BindPhone(ctx, uid, phone))
+}
+
+func (svc *bindingService) BindIdentity(ctx context.Context, uid int64, identity domain.Identity) error {
+	identity.Uid = uid
+	return svc.conflict(svc.identityRepo.Create(ctx, identity))
+}
+
+func (svc *bindingService) Identities(ctx context.Context, uid int64) ([]domain.Identity, error) {
+	return svc.identityRepo.FindByUid(ctx, uid)
+}
+
+func (svc *bindingService) Unbind(ctx context.Context, uid int64, method domain.LoginMethod) error {
+	bound, err := svc.bound(ctx, uid, method)
+	if err != nil {
+		return err
+	}
+	if !bound {
+		return ErrNotBound
+	}
+	// 是不是最后一种登录方式由数据库判断，这样并发解绑的时候也不会一种都不剩
+	var ok bool
+	switch method {
+	case domain.LoginMethodEmail, domain.LoginMethodPhone:
+		ok, err = svc.repo.Unbind(ctx, uid, method)
+	default:
+		ok, err = svc.identityRepo.Delete(ctx, uid, string(method))
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLastLoginMethod
+	}
+	return nil
+}
+
+func (svc *bindingService) bound(ctx context.Context, uid int64, method domain.LoginMethod) (bool, error) {
+	switch method {
+	case domain.LoginMethodEmail, domain.LoginMethodPhone:
+		u, err := svc.repo.FindById(ctx, uid)
+		if err != nil {
+			return false, err
+		}
+		if method == domain.LoginMethodEmail {
+			return u.Email != "", nil
+		}
+		return u.Phone != "", nil
+	default:
+		identities, err := svc.identityRepo.FindByUid(ctx, uid)
+		if err != nil {
+			return false, err
+		}
+		for _, identity := range identities {
+			if identity.Provider == string(method) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// conflict 唯一索引冲突，说明已经被其它账号绑定了；或者自己已经绑定过了，不能直接覆盖
+func (svc *bindingService) conflict(err error) error {
+	if errors.Is(err, repository.ErrDuplicateUser) || errors.Is(err, repository.ErrAlreadyBound) {
+		return ErrBindingConflict
+	}
+	return err
+}
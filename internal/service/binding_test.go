@@ -0,0 +1,380 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	repomocks "webook/internal/repository/mocks"
+	"webook/internal/service/email"
+	emailmocks "webook/internal/service/email/mocks"
+	svcmocks "webook/internal/service/mocks"
+)
+
+func Test_bindingService_SendBindEmail(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		mock func(ctrl *gomock.Controller) (repository.UserRepository, repository.UserTokenRepository, email.Service)
+
+		wantErr error
+	}{
+		{
+			name: "发送成功",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.UserTokenRepository, email.Service) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				tokenRepo := repomocks.NewMockUserTokenRepository(ctrl)
+				emailSvc := emailmocks.NewMockService(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).Return(domain.User{Id: 123}, nil)
+				tokenRepo.EXPECT().Set(gomock.Any(), bizBindEmail+":123@qq.com", int64(123), gomock.Any(), time.Minute*30).
+					Return(nil)
+				// 邮件发到要绑定的邮箱，这时候还没有绑定
+				emailSvc.EXPECT().Send(gomock.Any(), "绑定邮箱", gomock.Any(), "123@qq.com").Return(nil)
+				return repo, tokenRepo, emailSvc
+			},
+		},
+		{
+			name: "已经绑定过邮箱",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.UserTokenRepository, email.Service) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).
+					Return(domain.User{Id: 123, Email: "456@qq.com"}, nil)
+				return repo, repomocks.NewMockUserTokenRepository(ctrl), emailmocks.NewMockService(ctrl)
+			},
+			wantErr: ErrBindingConflict,
+		},
+		{
+			name: "发送太频繁",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.UserTokenRepository, email.Service) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				tokenRepo := repomocks.NewMockUserTokenRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).Return(domain.User{Id: 123}, nil)
+				tokenRepo.EXPECT().Set(gomock.Any(), bizBindEmail+":123@qq.com", int64(123), gomock.Any(), time.Minute*30).
+					Return(repository.ErrTokenSendTooMany)
+				return repo, tokenRepo, emailmocks.NewMockService(ctrl)
+			},
+			wantErr: ErrTokenSendTooMany,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo, tokenRepo, emailSvc := tc.mock(ctrl)
+			svc := NewBindingService(repo, nil, nil, nil, tokenRepo, emailSvc, "http://localhost:3000")
+			err := svc.SendBindEmail(context.Background(), 123, "123@qq.com")
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func Test_bindingService_BindEmail(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		mock func(ctrl *gomock.Controller) (repository.UserRepository, repository.UserTokenRepository)
+
+		wantErr error
+	}{
+		{
+			name: "绑定成功",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.UserTokenRepository) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				tokenRepo := repomocks.NewMockUserTokenRepository(ctrl)
+				tokenRepo.EXPECT().Consume(gomock.Any(), bizBindEmail+":123@qq.com", "token").Return(int64(123), nil)
+				repo.EXPECT().BindEmail(gomock.Any(), int64(123), "123@qq.com", gomock.Any()).
+					DoAndReturn(func(ctx context.Context, uid int64, email string, password string) error {
+						// 存进去的是加密之后的密码
+						return bcrypt.CompareHashAndPassword([]byte(password), []byte("hello#world123"))
+					})
+				return repo, tokenRepo
+			},
+		},
+		{
+			name: "token 不对，不占用邮箱",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.UserTokenRepository) {
+				tokenRepo := repomocks.NewMockUserTokenRepository(ctrl)
+				tokenRepo.EXPECT().Consume(gomock.Any(), bizBindEmail+":123@qq.com", "token").
+					Return(int64(0), repository.ErrTokenNotFound)
+				return repomocks.NewMockUserRepository(ctrl), tokenRepo
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "token 是发给其它用户的",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.UserTokenRepository) {
+				tokenRepo := repomocks.NewMockUserTokenRepository(ctrl)
+				tokenRepo.EXPECT().Consume(gomock.Any(), bizBindEmail+":123@qq.com", "token").Return(int64(456), nil)
+				return repomocks.NewMockUserRepository(ctrl), tokenRepo
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "邮箱已经被其它账号绑定",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.UserTokenRepository) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				tokenRepo := repomocks.NewMockUserTokenRepository(ctrl)
+				tokenRepo.EXPECT().Consume(gomock.Any(), bizBindEmail+":123@qq.com", "token").Return(int64(123), nil)
+				repo.EXPECT().BindEmail(gomock.Any(), int64(123), "123@qq.com", gomock.Any()).
+					Return(repository.ErrDuplicateUser)
+				return repo, tokenRepo
+			},
+			wantErr: ErrBindingConflict,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo, tokenRepo := tc.mock(ctrl)
+			svc := NewBindingService(repo, nil, nil, nil, tokenRepo, nil, "")
+			err := svc.BindEmail(context.Background(), 123, "123@qq.com", "hello#world123", "token")
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func Test_bindingService_BindPhone(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		mock func(ctrl *gomock.Controller) (repository.UserRepository, CodeService)
+
+		wantErr error
+	}{
+		{
+			name: "绑定成功",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, CodeService) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), bizBindPhone, "13800138000", "123456").Return(true, nil)
+				repo.EXPECT().BindPhone(gomock.Any(), int64(123), "13800138000").Return(nil)
+				return repo, codeSvc
+			},
+		},
+		{
+			name: "验证码不对",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, CodeService) {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), bizBindPhone, "13800138000", "123456").Return(false, nil)
+				return repomocks.NewMockUserRepository(ctrl), codeSvc
+			},
+			wantErr: ErrInvalidCode,
+		},
+		{
+			name: "手机号已经被其它账号绑定",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, CodeService) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), bizBindPhone, "13800138000", "123456").Return(true, nil)
+				repo.EXPECT().BindPhone(gomock.Any(), int64(123), "13800138000").Return(repository.ErrDuplicateUser)
+				return repo, codeSvc
+			},
+			wantErr: ErrBindingConflict,
+		},
+		{
+			name: "已经绑定过手机号",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, CodeService) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), bizBindPhone, "13800138000", "123456").Return(true, nil)
+				repo.EXPECT().BindPhone(gomock.Any(), int64(123), "13800138000").Return(repository.ErrAlreadyBound)
+				return repo, codeSvc
+			},
+			wantErr: ErrBindingConflict,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo, codeSvc := tc.mock(ctrl)
+			svc := NewBindingService(repo, nil, codeSvc, nil, nil, nil, "")
+			err := svc.BindPhone(context.Background(), 123, "13800138000", "123456")
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func Test_bindingService_ChangeEmail(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("hello#world123"), bcrypt.DefaultCost)
+	assert.NoError(t, err)
+	u := domain.User{Id: 123, Email: "123@qq.com", Password: string(hash)}
+	testCases := []struct {
+		name string
+
+		mock func(ctrl *gomock.Controller) (repository.UserRepository, TotpService)
+
+		password string
+		totpCode string
+
+		wantErr error
+	}{
+		{
+			name: "用原密码修改",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, TotpService) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).Return(u, nil)
+				repo.EXPECT().ChangeEmail(gomock.Any(), int64(123), "456@qq.com").Return(true, nil)
+				return repo, svcmocks.NewMockTotpService(ctrl)
+			},
+			password: "hello#world123",
+		},
+		{
+			name: "用动态码修改",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, TotpService) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				totpSvc := svcmocks.NewMockTotpService(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).Return(u, nil)
+				totpSvc.EXPECT().Verify(gomock.Any(), int64(123), "123456").Return(nil)
+				repo.EXPECT().ChangeEmail(gomock.Any(), int64(123), "456@qq.com").Return(true, nil)
+				return repo, totpSvc
+			},
+			totpCode: "123456",
+		},
+		{
+			name: "原密码不对",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, TotpService) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).Return(u, nil)
+				return repo, svcmocks.NewMockTotpService(ctrl)
+			},
+			password: "wrong",
+			wantErr:  ErrInvalidUserOrPassword,
+		},
+		{
+			name: "动态码不对",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, TotpService) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				totpSvc := svcmocks.NewMockTotpService(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).Return(u, nil)
+				totpSvc.EXPECT().Verify(gomock.Any(), int64(123), "123456").Return(ErrTotpInvalidCode)
+				return repo, totpSvc
+			},
+			totpCode: "123456",
+			wantErr:  ErrTotpInvalidCode,
+		},
+		{
+			name: "没有绑定邮箱",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, TotpService) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).
+					Return(domain.User{Id: 123, Phone: "13800138000"}, nil)
+				return repo, svcmocks.NewMockTotpService(ctrl)
+			},
+			password: "hello#world123",
+			wantErr:  ErrNotBound,
+		},
+		{
+			name: "新邮箱已经被其它账号绑定",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, TotpService) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).Return(u, nil)
+				repo.EXPECT().ChangeEmail(gomock.Any(), int64(123), "456@qq.com").
+					Return(false, repository.ErrDuplicateUser)
+				return repo, svcmocks.NewMockTotpService(ctrl)
+			},
+			password: "hello#world123",
+			wantErr:  ErrBindingConflict,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo, totpSvc := tc.mock(ctrl)
+			svc := NewBindingService(repo, nil, nil, totpSvc, nil, nil, "")
+			err := svc.ChangeEmail(context.Background(), 123, "456@qq.com", tc.password, tc.totpCode)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func Test_bindingService_Unbind(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		mock func(ctrl *gomock.Controller) (repository.UserRepository, repository.UserIdentityRepository)
+
+		method domain.LoginMethod
+
+		wantErr error
+	}{
+		{
+			name: "解绑手机号",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.UserIdentityRepository) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).
+					Return(domain.User{Id: 123, Email: "123@qq.com", Phone: "13800138000"}, nil)
+				repo.EXPECT().Unbind(gomock.Any(), int64(123), domain.LoginMethodPhone).Return(true, nil)
+				return repo, repomocks.NewMockUserIdentityRepository(ctrl)
+			},
+			method: domain.LoginMethodPhone,
+		},
+		{
+			name: "解绑第三方登录",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.UserIdentityRepository) {
+				identityRepo := repomocks.NewMockUserIdentityRepository(ctrl)
+				identityRepo.EXPECT().FindByUid(gomock.Any(), int64(123)).
+					Return([]domain.Identity{{Uid: 123, Provider: "github", Subject: "1"}}, nil)
+				identityRepo.EXPECT().Delete(gomock.Any(), int64(123), "github").Return(true, nil)
+				return repomocks.NewMockUserRepository(ctrl), identityRepo
+			},
+			method: "github",
+		},
+		{
+			name: "没有绑定",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.UserIdentityRepository) {
+				identityRepo := repomocks.NewMockUserIdentityRepository(ctrl)
+				identityRepo.EXPECT().FindByUid(gomock.Any(), int64(123)).
+					Return([]domain.Identity{{Uid: 123, Provider: "github", Subject: "1"}}, nil)
+				return repomocks.NewMockUserRepository(ctrl), identityRepo
+			},
+			method:  "wechat",
+			wantErr: ErrNotBound,
+		},
+		{
+			name: "最后一种登录方式",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.UserIdentityRepository) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).
+					Return(domain.User{Id: 123, Email: "123@qq.com"}, nil)
+				repo.EXPECT().Unbind(gomock.Any(), int64(123), domain.LoginMethodEmail).Return(false, nil)
+				return repo, repomocks.NewMockUserIdentityRepository(ctrl)
+			},
+			method:  domain.LoginMethodEmail,
+			wantErr: ErrLastLoginMethod,
+		},
+		{
+			name: "系统错误",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.UserIdentityRepository) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).
+					Return(domain.User{}, errors.New("db Err"))
+				return repo, repomocks.NewMockUserIdentityRepository(ctrl)
+			},
+			method:  domain.LoginMethodEmail,
+			wantErr: errors.New("db Err"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo, identityRepo := tc.mock(ctrl)
+			svc := NewBindingService(repo, identityRepo, nil, nil, nil, nil, "")
+			err := svc.Unbind(context.Background(), 123, tc.method)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
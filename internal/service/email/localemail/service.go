@@ -0,0 +1,19 @@
+package localemail
+
+import (
+	"context"
+	"log"
+)
+
+// Service 只打印日志，开发和测试环境用
+type Service struct {
+}
+
+func (s Service) Send(ctx context.Context, subject, content string, to ...string) error {
+	log.Println("邮件：", to, subject, content)
+	return nil
+}
+
+func NewService() *Service {
+	return &Service{}
+}
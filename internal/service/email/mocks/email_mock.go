@@ -0,0 +1,59 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: .\internal\service\email\types.go
+//
+// Generated by this command:
+//
+//	mockgen -source .\internal\service\email\types.go -destination .\internal\service\email\mocks\email_mock.go -package emailmocks
+//
+
+// Package emailmocks is a generated GoMock package.
+package emailmocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockService is a mock of Service interface.
+type MockService struct {
+	ctrl     *gomock.Controller
+	recorder *MockServiceMockRecorder
+}
+
+// MockServiceMockRecorder is the mock recorder for MockService.
+type MockServiceMockRecorder struct {
+	mock *MockService
+}
+
+// NewMockService creates a new mock instance.
+func NewMockService(ctrl *gomock.Controller) *MockService {
+	mock := &MockService{ctrl: ctrl}
+	mock.recorder = &MockServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockService) EXPECT() *MockServiceMockRecorder {
+	return m.recorder
+}
+
+// Send mocks base method.
+func (m *MockService) Send(ctx context.Context, subject, content string, to ...string) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, subject, content}
+	for _, a := range to {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Send", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Send indicates an expected call of Send.
+func (mr *MockServiceMockRecorder) Send(ctx, subject, content any, to ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, subject, content}, to...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockService)(nil).Send), varargs...)
+}
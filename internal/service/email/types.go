@@ -0,0 +1,9 @@
+package email
+
+import "context"
+
+// Service 发送邮件的抽象
+// 用于屏蔽不同邮件服务商的区别
+type Service interface {
+	Send(ctx context.Context, subject, content string, to ...string) error
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/events/user"
+	"webook/internal/repository"
+	"webook/pkg/limiter"
+	"webook/pkg/logger"
+)
+
+const (
+	bizLoginUnlock = "login_unlock"
+	// riskEventBuffer 等待发送到 kafka 的可疑事件最多有多少个，满了之后直接丢弃
+	riskEventBuffer = 1024
+)
+
+var (
+	ErrLoginLocked      = errors.New("账号已经被锁定")
+	ErrLoginTooFrequent = errors.New("登录失败太多，请稍后再试")
+)
+
+// LoginGuardService 防止暴力破解密码和短信验证码
+// 账号连续失败几次之后每次都要等一会儿才能再试，失败太多就锁定，可以等锁定过期，也可以用短信解锁
+// 同一个 IP 失败太多，说明可能在撞库，这个 IP 暂时不能登录
+type LoginGuardService interface {
+	// Check 登录之前调用，被限制的时候返回 ErrLoginLocked 或者 ErrLoginTooFrequent，以及还要等多久
+	Check(ctx context.Context, account string, ip string) (time.Duration, error)
+	// Fail 登录失败之后调用，可疑的情况会发到 kafka
+	Fail(ctx context.Context, account string, ip string, userAgent string) error
+	// Succeed 登录成功之后清空失败次数
+	Succeed(ctx context.Context, account string) error
+	// SendUnlockCode 给账号绑定的手机号发送解锁的验证码
+	// 账号不存在或者没有绑定手机号也返回 nil，避免被用来探测哪些账号注册过
+	SendUnlockCode(ctx context.Context, account string) error
+	// Unlock 验证码不对的时候返回 ErrInvalidCode
+	Unlock(ctx context.Context, account string, code string) error
+}
+
+type loginGuardService struct {
+	repo     repository.LoginGuardRepository
+	userRepo repository.UserRepository
+	codeSvc  CodeService
+	// ipLimiter 只统计失败的登录
+	ipLimiter limiter.Limiter
+	// ipBlock IP 失败太多之后多久不能登录
+	ipBlock  time.Duration
+	producer user.Producer
+	// events 只有一个 goroutine 负责发送，kafka 很慢的时候也不会堆积 goroutine
+	events chan user.LoginRiskEvent
+	l      logger.LoggerV1
+}
+
+func NewLoginGuardService(repo repository.LoginGuardRepository,
+	userRepo repository.UserRepository,
+	codeSvc CodeService,
+	ipLimiter limiter.Limiter,
+	producer user.Producer,
+	l logger.LoggerV1) LoginGuardService {
+	svc := &loginGuardService{
+		repo:      repo,
+		userRepo:  userRepo,
+		codeSvc:   codeSvc,
+		ipLimiter: ipLimiter,
+		ipBlock:   time.Minute * 15,
+		producer:  producer,
+		events:    make(chan user.LoginRiskEvent, riskEventBuffer),
+		l:         l,
+	}
+	go svc.send()
+	return svc
+}
+
+func (svc *loginGuardService) Check(ctx context.Context, account string, ip string) (time.Duration, error) {
+	account = normalizeAccount(account)
+	block, err := svc.repo.Block(ctx, account, ip)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case block.Locked:
+		return block.Wait, ErrLoginLocked
+	case block.Wait > 0:
+		return block.Wait, ErrLoginTooFrequent
+	default:
+		return 0, nil
+	}
+}
+
+func (svc *loginGuardService) Fail(ctx context.Context, account string, ip string, userAgent string) error {
+	account = normalizeAccount(account)
+	block, err := svc.repo.Fail(ctx, account)
+	if err != nil {
+		return err
+	}
+	evt := user.LoginRiskEvent{
+		Account:   account,
+		Ip:        ip,
+		UserAgent: userAgent,
+		Fails:     block.Fails,
+	}
+	switch {
+	case block.Locked:
+		evt.Reason = user.RiskAccountLocked
+		svc.report(ctx, evt)
+	case block.Wait > 0:
+		evt.Reason = user.RiskRepeatedFailure
+		svc.report(ctx, evt)
+	}
+
+	limited, err := svc.ipLimiter.Limit(ctx, "login_fail:ip:"+ip)
+	if err != nil {
+		return err
+	}
+	if !limited {
+		return nil
+	}
+	evt.Reason = user.RiskIpBlocked
+	svc.report(ctx, evt)
+	return svc.repo.BlockIP(ctx, ip, svc.ipBlock)
+}
+
+func (svc *loginGuardService) Succeed(ctx context.Context, account string) error {
+	return svc.repo.Reset(ctx, normalizeAccount(account))
+}
+
+func (svc *loginGuardService) SendUnlockCode(ctx context.Context, account string) error {
+	account = normalizeAccount(account)
+	u, err := svc.findByAccount(ctx, account)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if u.Phone == "" {
+		return nil
+	}
+	return svc.codeSvc.Send(ctx, bizLoginUnlock, u.Phone)
+}
+
+func (svc *loginGuardService) Unlock(ctx context.Context, account string, code string) error {
+	account = normalizeAccount(account)
+	u, err := svc.findByAccount(ctx, account)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return ErrInvalidCode
+	}
+	if err != nil {
+		return err
+	}
+	if u.Phone == "" {
+		return ErrInvalidCode
+	}
+	ok, err := svc.codeSvc.Verify(ctx, bizLoginUnlock, u.Phone, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidCode
+	}
+	return svc.repo.Reset(ctx, account)
+}
+
+// normalizeAccount 换个大小写或者加个空格就是另外一个账号的话，失败次数就限制不住了
+func normalizeAccount(account string) string {
+	return strings.ToLower(strings.TrimSpace(account))
+}
+
+// findByAccount 账号是登录时候用的邮箱或者手机号
+func (svc *loginGuardService) findByAccount(ctx context.Context, account string) (domain.User, error) {
+	if strings.Contains(account, "@") {
+		return svc.userRepo.FindByEmail(ctx, account)
+	}
+	return svc.userRepo.FindByPhone(ctx, account)
+}
+
+// report 发送失败不影响登录，只记日志；来不及发送的直接丢弃
+func (svc *loginGuardService) report(ctx context.Context, evt user.LoginRiskEvent) {
+	evt.Ctime = time.Now().UnixMilli()
+	select {
+	case svc.events <- evt:
+	default:
+		svc.l.Warn("LoginRiskEvent 太多，来不及发送，丢弃",
+			logger.String("account", evt.Account),
+			logger.String("reason", evt.Reason))
+	}
+}
+
+// send 一直运行到进程退出
+func (svc *loginGuardService) send() {
+	for evt := range svc.events {
+		er := svc.producer.ProduceLoginRiskEvent(context.Background(), evt)
+		if er != nil {
+			svc.l.Error("发送 LoginRiskEvent 失败",
+				logger.String("account", evt.Account),
+				logger.String("reason", evt.Reason),
+				logger.Error(er))
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"sync"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/events/user"
+	usermocks "webook/internal/events/user/mocks"
+	"webook/internal/repository"
+	repomocks "webook/internal/repository/mocks"
+	svcmocks "webook/internal/service/mocks"
+	"webook/pkg/limiter"
+	limitermocks "webook/pkg/limiter/mocks"
+	"webook/pkg/logger"
+)
+
+func Test_loginGuardService_Fail(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		// wg 发送到 kafka 是由后台的 goroutine 异步完成的，要等发送完再检查
+		mock func(ctrl *gomock.Controller, wg *sync.WaitGroup) (repository.LoginGuardRepository, limiter.Limiter, user.Producer)
+
+		wantErr error
+	}{
+		{
+			name: "前几次失败不限制",
+			mock: func(ctrl *gomock.Controller, wg *sync.WaitGroup) (repository.LoginGuardRepository, limiter.Limiter, user.Producer) {
+				repo := repomocks.NewMockLoginGuardRepository(ctrl)
+				repo.EXPECT().Fail(gomock.Any(), "123@qq.com").Return(domain.LoginBlock{Fails: 1}, nil)
+				ipLimiter := limitermocks.NewMockLimiter(ctrl)
+				ipLimiter.EXPECT().Limit(gomock.Any(), "login_fail:ip:127.0.0.1").Return(false, nil)
+				return repo, ipLimiter, usermocks.NewMockProducer(ctrl)
+			},
+		},
+		{
+			name: "账号被锁定",
+			mock: func(ctrl *gomock.Controller, wg *sync.WaitGroup) (repository.LoginGuardRepository, limiter.Limiter, user.Producer) {
+				repo := repomocks.NewMockLoginGuardRepository(ctrl)
+				repo.EXPECT().Fail(gomock.Any(), "123@qq.com").
+					Return(domain.LoginBlock{Fails: 10, Wait: time.Minute * 30, Locked: true}, nil)
+				ipLimiter := limitermocks.NewMockLimiter(ctrl)
+				ipLimiter.EXPECT().Limit(gomock.Any(), "login_fail:ip:127.0.0.1").Return(false, nil)
+				producer := usermocks.NewMockProducer(ctrl)
+				wg.Add(1)
+				producer.EXPECT().ProduceLoginRiskEvent(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, evt user.LoginRiskEvent) error {
+						defer wg.Done()
+						assert.Equal(t, user.RiskAccountLocked, evt.Reason)
+						assert.Equal(t, int64(10), evt.Fails)
+						return nil
+					})
+				return repo, ipLimiter, producer
+			},
+		},
+		{
+			name: "IP 失败太多",
+			mock: func(ctrl *gomock.Controller, wg *sync.WaitGroup) (repository.LoginGuardRepository, limiter.Limiter, user.Producer) {
+				repo := repomocks.NewMockLoginGuardRepository(ctrl)
+				repo.EXPECT().Fail(gomock.Any(), "123@qq.com").Return(domain.LoginBlock{Fails: 1}, nil)
+				repo.EXPECT().BlockIP(gomock.Any(), "127.0.0.1", time.Minute*15).Return(nil)
+				ipLimiter := limitermocks.NewMockLimiter(ctrl)
+				ipLimiter.EXPECT().Limit(gomock.Any(), "login_fail:ip:127.0.0.1").Return(true, nil)
+				producer := usermocks.NewMockProducer(ctrl)
+				wg.Add(1)
+				producer.EXPECT().ProduceLoginRiskEvent(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, evt user.LoginRiskEvent) error {
+						defer wg.Done()
+						assert.Equal(t, user.RiskIpBlocked, evt.Reason)
+						assert.Equal(t, "127.0.0.1", evt.Ip)
+						// 发送失败只记日志
+						return errors.New("kafka Err")
+					})
+				return repo, ipLimiter, producer
+			},
+		},
+		{
+			name: "redis 出错",
+			mock: func(ctrl *gomock.Controller, wg *sync.WaitGroup) (repository.LoginGuardRepository, limiter.Limiter, user.Producer) {
+				repo := repomocks.NewMockLoginGuardRepository(ctrl)
+				repo.EXPECT().Fail(gomock.Any(), "123@qq.com").Return(domain.LoginBlock{}, errors.New("redis Err"))
+				return repo, limitermocks.NewMockLimiter(ctrl), usermocks.NewMockProducer(ctrl)
+			},
+			wantErr: errors.New("redis Err"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			var wg sync.WaitGroup
+			repo, ipLimiter, producer := tc.mock(ctrl, &wg)
+			svc := NewLoginGuardService(repo, nil, nil, ipLimiter, producer, logger.NewNoOpLogger())
+			err := svc.Fail(context.Background(), "123@qq.com", "127.0.0.1", "Chrome")
+			assert.Equal(t, tc.wantErr, err)
+			wg.Wait()
+		})
+	}
+}
+
+// 大小写和空格不一样的是同一个账号，失败次数要算在一起
+func Test_loginGuardService_normalizeAccount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := repomocks.NewMockLoginGuardRepository(ctrl)
+	repo.EXPECT().Block(gomock.Any(), "123@qq.com", "127.0.0.1").Return(domain.LoginBlock{}, nil)
+	repo.EXPECT().Fail(gomock.Any(), "123@qq.com").Return(domain.LoginBlock{Fails: 1}, nil)
+	repo.EXPECT().Reset(gomock.Any(), "123@qq.com").Return(nil)
+	ipLimiter := limitermocks.NewMockLimiter(ctrl)
+	ipLimiter.EXPECT().Limit(gomock.Any(), "login_fail:ip:127.0.0.1").Return(false, nil)
+	svc := NewLoginGuardService(repo, nil, nil, ipLimiter, usermocks.NewMockProducer(ctrl), logger.NewNoOpLogger())
+	ctx := context.Background()
+
+	_, err := svc.Check(ctx, "123@QQ.com", "127.0.0.1")
+	assert.NoError(t, err)
+	err = svc.Fail(ctx, " 123@qq.COM ", "127.0.0.1", "Chrome")
+	assert.NoError(t, err)
+	err = svc.Succeed(ctx, "123@Qq.Com")
+	assert.NoError(t, err)
+}
+
+func Test_loginGuardService_report(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	// 没有启动发送的 goroutine，模拟 kafka 一直发不出去
+	svc := &loginGuardService{
+		producer: usermocks.NewMockProducer(ctrl),
+		events:   make(chan user.LoginRiskEvent, 1),
+		l:        logger.NewNoOpLogger(),
+	}
+	svc.report(context.Background(), user.LoginRiskEvent{Account: "123@qq.com", Reason: user.RiskAccountLocked})
+	// 满了之后直接丢弃，不会阻塞登录
+	svc.report(context.Background(), user.LoginRiskEvent{Account: "123@qq.com", Reason: user.RiskIpBlocked})
+	assert.Len(t, svc.events, 1)
+	evt := <-svc.events
+	assert.Equal(t, user.RiskAccountLocked, evt.Reason)
+	assert.True(t, evt.Ctime > 0)
+}
+
+func Test_loginGuardService_Unlock(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		mock func(ctrl *gomock.Controller) (repository.LoginGuardRepository, repository.UserRepository, CodeService)
+
+		account string
+
+		wantErr error
+	}{
+		{
+			name: "邮箱账号解锁",
+			mock: func(ctrl *gomock.Controller) (repository.LoginGuardRepository, repository.UserRepository, CodeService) {
+				repo := repomocks.NewMockLoginGuardRepository(ctrl)
+				userRepo := repomocks.NewMockUserRepository(ctrl)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				userRepo.EXPECT().FindByEmail(gomock.Any(), "123@qq.com").
+					Return(domain.User{Id: 123, Email: "123@qq.com", Phone: "13800138000"}, nil)
+				codeSvc.EXPECT().Verify(gomock.Any(), bizLoginUnlock, "13800138000", "123456").Return(true, nil)
+				repo.EXPECT().Reset(gomock.Any(), "123@qq.com").Return(nil)
+				return repo, userRepo, codeSvc
+			},
+			account: "123@qq.com",
+		},
+		{
+			name: "验证码不对",
+			mock: func(ctrl *gomock.Controller) (repository.LoginGuardRepository, repository.UserRepository, CodeService) {
+				userRepo := repomocks.NewMockUserRepository(ctrl)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				userRepo.EXPECT().FindByPhone(gomock.Any(), "13800138000").
+					Return(domain.User{Id: 123, Phone: "13800138000"}, nil)
+				codeSvc.EXPECT().Verify(gomock.Any(), bizLoginUnlock, "13800138000", "123456").Return(false, nil)
+				return repomocks.NewMockLoginGuardRepository(ctrl), userRepo, codeSvc
+			},
+			account: "13800138000",
+			wantErr: ErrInvalidCode,
+		},
+		{
+			name: "没有绑定手机号",
+			mock: func(ctrl *gomock.Controller) (repository.LoginGuardRepository, repository.UserRepository, CodeService) {
+				userRepo := repomocks.NewMockUserRepository(ctrl)
+				userRepo.EXPECT().FindByEmail(gomock.Any(), "123@qq.com").
+					Return(domain.User{Id: 123, Email: "123@qq.com"}, nil)
+				return repomocks.NewMockLoginGuardRepository(ctrl), userRepo, svcmocks.NewMockCodeService(ctrl)
+			},
+			account: "123@qq.com",
+			wantErr: ErrInvalidCode,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo, userRepo, codeSvc := tc.mock(ctrl)
+			svc := NewLoginGuardService(repo, userRepo, codeSvc, nil, nil, logger.NewNoOpLogger())
+			err := svc.Unlock(context.Background(), tc.account, "123456")
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+)
+
+var (
+	ErrInvalidMedia     = errors.New("不支持的文件类型，或者文件太大")
+	ErrMediaNotUploaded = errors.New("文件还没有上传")
+	ErrMediaMismatch    = errors.New("上传的内容和声明的不一致")
+)
+
+// mediaLimit 不同用途允许的类型和大小
+type mediaLimit struct {
+	maxSize      int64
+	contentTypes []string
+}
+
+var mediaLimits = map[domain.MediaBiz]mediaLimit{
+	domain.MediaBizAvatar: {
+		maxSize:      2 << 20,
+		contentTypes: []string{"image/jpeg", "image/png", "image/webp"},
+	},
+	domain.MediaBizArticle: {
+		maxSize:      10 << 20,
+		contentTypes: []string{"image/jpeg", "image/png", "image/gif", "image/webp"},
+	},
+}
+
+// MediaService 上传头像和文章里面的图片
+// 客户端先算好内容的 SHA-256，拿预签名的地址直接上传到对象存储的临时 key，再调用 Confirm
+// 同样的内容只存一份，已经有人传完的不用再传
+type MediaService interface {
+	// Upload m 里面是客户端声明的 Hash、Size 和 ContentType
+	// 同样的内容已经上传过的时候，返回的上传地址是空的，直接用 Media.URL
+	Upload(ctx context.Context, uid int64, biz domain.MediaBiz, m domain.Media) (domain.Media, string, error)
+	// Confirm 校验临时 key 里面实际的内容，一致的时候复制到正式的 key
+	// 和声明的不一致的时候删掉，返回 ErrMediaMismatch。只有发起上传的用户可以确认
+	Confirm(ctx context.Context, uid int64, id int64) (domain.Media, error)
+	// SetAvatar 用已经上传好的图片做头像，返回头像的地址
+	SetAvatar(ctx context.Context, uid int64, mediaId int64) (string, error)
+}
+
+type mediaService struct {
+	repo     repository.MediaRepository
+	userRepo repository.UserRepository
+	// expiration 上传地址的有效期
+	expiration time.Duration
+}
+
+func NewMediaService(repo repository.MediaRepository, userRepo repository.UserRepository) MediaService {
+	return &mediaService{
+		repo:       repo,
+		userRepo:   userRepo,
+		expiration: time.Minute * 15,
+	}
+}
+
+func (svc *mediaService) Upload(ctx context.Context, uid int64, biz domain.MediaBiz, m domain.Media) (domain.Media, string, error) {
+	m.Hash = strings.ToLower(m.Hash)
+	if !svc.validHash(m.Hash) || !svc.allowed(biz, m) {
+		return domain.Media{}, "", ErrInvalidMedia
+	}
+	existing, err := svc.repo.FindByHash(ctx, m.Hash)
+	switch {
+	case err == nil:
+		return existing, "", nil
+	case !errors.Is(err, repository.ErrMediaNotFound):
+		return domain.Media{}, "", err
+	}
+
+	// 别人正在上传同样的内容也不复用，每一次上传都有自己的临时 key
+	m.Owner = uid
+	m.Status = domain.MediaStatusPending
+	id, err := svc.repo.Create(ctx, m)
+	if err != nil {
+		return domain.Media{}, "", err
+	}
+	m, err = svc.repo.FindById(ctx, id)
+	if err != nil {
+		return domain.Media{}, "", err
+	}
+	url, err := svc.repo.UploadURL(ctx, m, svc.expiration)
+	return m, url, err
+}
+
+func (svc *mediaService) Confirm(ctx context.Context, uid int64, id int64) (domain.Media, error) {
+	m, err := svc.repo.FindById(ctx, id)
+	if errors.Is(err, repository.ErrMediaNotFound) {
+		return domain.Media{}, ErrMediaNotUploaded
+	}
+	if err != nil {
+		return domain.Media{}, err
+	}
+	// 别人的上传不能替他确认，也不能让他知道这个 id 存在
+	if m.Owner != uid {
+		return domain.Media{}, ErrMediaNotUploaded
+	}
+	if m.Status == domain.MediaStatusUploaded {
+		return m, nil
+	}
+	actual, err := svc.repo.Inspect(ctx, m, m.Size)
+	if errors.Is(err, repository.ErrObjectNotFound) {
+		return domain.Media{}, ErrMediaNotUploaded
+	}
+	if err != nil {
+		return domain.Media{}, err
+	}
+	if actual.Hash != m.Hash || actual.Size != m.Size || actual.ContentType != m.ContentType {
+		// 不能让别人用同样的 Hash 拿到错误的内容，删掉重新上传
+		err = svc.repo.Delete(ctx, m)
+		if err != nil {
+			return domain.Media{}, err
+		}
+		return domain.Media{}, ErrMediaMismatch
+	}
+	err = svc.repo.Publish(ctx, m)
+	if err != nil {
+		return domain.Media{}, err
+	}
+	m.Status = domain.MediaStatusUploaded
+	return m, nil
+}
+
+func (svc *mediaService) SetAvatar(ctx context.Context, uid int64, mediaId int64) (string, error) {
+	m, err := svc.repo.FindById(ctx, mediaId)
+	if errors.Is(err, repository.ErrMediaNotFound) {
+		return "", ErrMediaNotUploaded
+	}
+	if err != nil {
+		return "", err
+	}
+	if m.Status != domain.MediaStatusUploaded {
+		return "", ErrMediaNotUploaded
+	}
+	// 可能是按照文章图片上传的，要按照头像的要求再检查一遍
+	if !svc.allowed(domain.MediaBizAvatar, m) {
+		return "", ErrInvalidMedia
+	}
+	return m.URL, svc.userRepo.UpdateAvatar(ctx, uid, m.URL)
+}
+
+func (svc *mediaService) allowed(biz domain.MediaBiz, m domain.Media) bool {
+	limit, ok := mediaLimits[biz]
+	if !ok {
+		return false
+	}
+	return m.Size > 0 && m.Size <= limit.maxSize && slices.Contains(limit.contentTypes, m.ContentType)
+}
+
+// validHash SHA-256 的十六进制
+func (svc *mediaService) validHash(hash string) bool {
+	if len(hash) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
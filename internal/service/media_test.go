@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"strings"
+	"testing"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	repomocks "webook/internal/repository/mocks"
+)
+
+func Test_mediaService_Upload(t *testing.T) {
+	hash := strings.Repeat("ab", 32)
+	testCases := []struct {
+		name string
+
+		mock func(ctrl *gomock.Controller) repository.MediaRepository
+
+		biz   domain.MediaBiz
+		media domain.Media
+
+		wantMedia     domain.Media
+		wantUploadURL string
+		wantErr       error
+	}{
+		{
+			name: "第一次上传",
+			mock: func(ctrl *gomock.Controller) repository.MediaRepository {
+				repo := repomocks.NewMockMediaRepository(ctrl)
+				repo.EXPECT().FindByHash(gomock.Any(), hash).Return(domain.Media{}, repository.ErrMediaNotFound)
+				repo.EXPECT().Create(gomock.Any(), domain.Media{
+					Owner: 123, Hash: hash, Size: 1024, ContentType: "image/png", Status: domain.MediaStatusPending,
+				}).Return(int64(1), nil)
+				m := domain.Media{Id: 1, Owner: 123, Hash: hash, Nonce: "nonce", Size: 1024, ContentType: "image/png",
+					Status: domain.MediaStatusPending, URL: "https://cdn/media/" + hash}
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).Return(m, nil)
+				repo.EXPECT().UploadURL(gomock.Any(), m, gomock.Any()).Return("https://oss/upload", nil)
+				return repo
+			},
+			biz:   domain.MediaBizAvatar,
+			media: domain.Media{Hash: strings.ToUpper(hash), Size: 1024, ContentType: "image/png"},
+			wantMedia: domain.Media{Id: 1, Owner: 123, Hash: hash, Nonce: "nonce", Size: 1024, ContentType: "image/png",
+				Status: domain.MediaStatusPending, URL: "https://cdn/media/" + hash},
+			wantUploadURL: "https://oss/upload",
+		},
+		{
+			// 别人拿到的上传地址不会再签给第二个人，第二个人有自己的临时 key
+			name: "别人正在上传同样的内容",
+			mock: func(ctrl *gomock.Controller) repository.MediaRepository {
+				repo := repomocks.NewMockMediaRepository(ctrl)
+				repo.EXPECT().FindByHash(gomock.Any(), hash).Return(domain.Media{}, repository.ErrMediaNotFound)
+				repo.EXPECT().Create(gomock.Any(), domain.Media{
+					Owner: 123, Hash: hash, Size: 1024, ContentType: "image/png", Status: domain.MediaStatusPending,
+				}).Return(int64(3), nil)
+				m := domain.Media{Id: 3, Owner: 123, Hash: hash, Nonce: "nonce3", Size: 1024, ContentType: "image/png",
+					Status: domain.MediaStatusPending, URL: "https://cdn/media/" + hash}
+				repo.EXPECT().FindById(gomock.Any(), int64(3)).Return(m, nil)
+				repo.EXPECT().UploadURL(gomock.Any(), m, gomock.Any()).Return("https://oss/upload3", nil)
+				return repo
+			},
+			biz:   domain.MediaBizArticle,
+			media: domain.Media{Hash: hash, Size: 1024, ContentType: "image/png"},
+			wantMedia: domain.Media{Id: 3, Owner: 123, Hash: hash, Nonce: "nonce3", Size: 1024, ContentType: "image/png",
+				Status: domain.MediaStatusPending, URL: "https://cdn/media/" + hash},
+			wantUploadURL: "https://oss/upload3",
+		},
+		{
+			name: "同样的内容已经上传过",
+			mock: func(ctrl *gomock.Controller) repository.MediaRepository {
+				repo := repomocks.NewMockMediaRepository(ctrl)
+				repo.EXPECT().FindByHash(gomock.Any(), hash).Return(domain.Media{
+					Id: 2, Owner: 456, Hash: hash, Size: 1024, ContentType: "image/png",
+					Status: domain.MediaStatusUploaded, URL: "https://cdn/media/" + hash,
+				}, nil)
+				return repo
+			},
+			biz:   domain.MediaBizArticle,
+			media: domain.Media{Hash: hash, Size: 1024, ContentType: "image/png"},
+			wantMedia: domain.Media{Id: 2, Owner: 456, Hash: hash, Size: 1024, ContentType: "image/png",
+				Status: domain.MediaStatusUploaded, URL: "https://cdn/media/" + hash},
+		},
+		{
+			name: "头像太大",
+			mock: func(ctrl *gomock.Controller) repository.MediaRepository {
+				return repomocks.NewMockMediaRepository(ctrl)
+			},
+			biz:     domain.MediaBizAvatar,
+			media:   domain.Media{Hash: hash, Size: 3 << 20, ContentType: "image/png"},
+			wantErr: ErrInvalidMedia,
+		},
+		{
+			name: "不支持的类型",
+			mock: func(ctrl *gomock.Controller) repository.MediaRepository {
+				return repomocks.NewMockMediaRepository(ctrl)
+			},
+			biz:     domain.MediaBizArticle,
+			media:   domain.Media{Hash: hash, Size: 1024, ContentType: "text/html"},
+			wantErr: ErrInvalidMedia,
+		},
+		{
+			name: "摘要格式不对",
+			mock: func(ctrl *gomock.Controller) repository.MediaRepository {
+				return repomocks.NewMockMediaRepository(ctrl)
+			},
+			biz:     domain.MediaBizArticle,
+			media:   domain.Media{Hash: "abc", Size: 1024, ContentType: "image/png"},
+			wantErr: ErrInvalidMedia,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewMediaService(tc.mock(ctrl), nil)
+			m, uploadURL, err := svc.Upload(context.Background(), 123, tc.biz, tc.media)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantMedia, m)
+			assert.Equal(t, tc.wantUploadURL, uploadURL)
+		})
+	}
+}
+
+func Test_mediaService_Confirm(t *testing.T) {
+	hash := strings.Repeat("ab", 32)
+	pending := domain.Media{Id: 1, Owner: 123, Hash: hash, Nonce: "nonce", Size: 1024, ContentType: "image/png",
+		Status: domain.MediaStatusPending}
+	testCases := []struct {
+		name string
+		// uid 发起确认的用户
+		uid int64
+
+		mock func(ctrl *gomock.Controller) repository.MediaRepository
+
+		wantStatus domain.MediaStatus
+		wantErr    error
+	}{
+		{
+			name: "校验通过",
+			uid:  123,
+			mock: func(ctrl *gomock.Controller) repository.MediaRepository {
+				repo := repomocks.NewMockMediaRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).Return(pending, nil)
+				repo.EXPECT().Inspect(gomock.Any(), pending, int64(1024)).Return(pending, nil)
+				repo.EXPECT().Publish(gomock.Any(), pending).Return(nil)
+				return repo
+			},
+			wantStatus: domain.MediaStatusUploaded,
+		},
+		{
+			name: "内容和声明的不一致",
+			uid:  123,
+			mock: func(ctrl *gomock.Controller) repository.MediaRepository {
+				repo := repomocks.NewMockMediaRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).Return(pending, nil)
+				actual := pending
+				actual.Hash = strings.Repeat("cd", 32)
+				repo.EXPECT().Inspect(gomock.Any(), pending, int64(1024)).Return(actual, nil)
+				repo.EXPECT().Delete(gomock.Any(), pending).Return(nil)
+				return repo
+			},
+			wantErr: ErrMediaMismatch,
+		},
+		{
+			name: "别人的上传",
+			uid:  456,
+			mock: func(ctrl *gomock.Controller) repository.MediaRepository {
+				repo := repomocks.NewMockMediaRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).Return(pending, nil)
+				return repo
+			},
+			wantErr: ErrMediaNotUploaded,
+		},
+		{
+			name: "还没有上传",
+			uid:  123,
+			mock: func(ctrl *gomock.Controller) repository.MediaRepository {
+				repo := repomocks.NewMockMediaRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).Return(pending, nil)
+				repo.EXPECT().Inspect(gomock.Any(), pending, int64(1024)).
+					Return(domain.Media{}, repository.ErrObjectNotFound)
+				return repo
+			},
+			wantErr: ErrMediaNotUploaded,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewMediaService(tc.mock(ctrl), nil)
+			m, err := svc.Confirm(context.Background(), tc.uid, 1)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantStatus, m.Status)
+		})
+	}
+}
@@ -13,6 +13,7 @@ import (
 	context "context"
 	reflect "reflect"
 	time "time"
+	intrv2 "webook/api/proto/gen/intr/v2"
 	domain "webook/internal/domain"
 
 	gomock "go.uber.org/mock/gomock"
@@ -41,6 +42,34 @@ func (m *MockArticleService) EXPECT() *MockArticleServiceMockRecorder {
 	return m.recorder
 }
 
+// CancelLikeIntr mocks base method.
+func (m *MockArticleService) CancelLikeIntr(ctx context.Context, biz string, id, uid int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelLikeIntr", ctx, biz, id, uid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelLikeIntr indicates an expected call of CancelLikeIntr.
+func (mr *MockArticleServiceMockRecorder) CancelLikeIntr(ctx, biz, id, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelLikeIntr", reflect.TypeOf((*MockArticleService)(nil).CancelLikeIntr), ctx, biz, id, uid)
+}
+
+// CollectIntr mocks base method.
+func (m *MockArticleService) CollectIntr(ctx context.Context, biz string, id, cid, uid int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CollectIntr", ctx, biz, id, cid, uid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CollectIntr indicates an expected call of CollectIntr.
+func (mr *MockArticleServiceMockRecorder) CollectIntr(ctx, biz, id, cid, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CollectIntr", reflect.TypeOf((*MockArticleService)(nil).CollectIntr), ctx, biz, id, cid, uid)
+}
+
 // GetByAuthor mocks base method.
 func (m *MockArticleService) GetByAuthor(ctx context.Context, uid int64, offset, limit int) ([]domain.Article, error) {
 	m.ctrl.T.Helper()
@@ -71,6 +100,21 @@ func (mr *MockArticleServiceMockRecorder) GetById(ctx, id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetById", reflect.TypeOf((*MockArticleService)(nil).GetById), ctx, id)
 }
 
+// GetIntr mocks base method.
+func (m *MockArticleService) GetIntr(ctx context.Context, biz string, id, uid int64) (*intrv2.Interactive, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIntr", ctx, biz, id, uid)
+	ret0, _ := ret[0].(*intrv2.Interactive)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIntr indicates an expected call of GetIntr.
+func (mr *MockArticleServiceMockRecorder) GetIntr(ctx, biz, id, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIntr", reflect.TypeOf((*MockArticleService)(nil).GetIntr), ctx, biz, id, uid)
+}
+
 // GetPubById mocks base method.
 func (m *MockArticleService) GetPubById(ctx context.Context, id, uid int64) (domain.Article, error) {
 	m.ctrl.T.Helper()
@@ -86,6 +130,20 @@ func (mr *MockArticleServiceMockRecorder) GetPubById(ctx, id, uid any) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPubById", reflect.TypeOf((*MockArticleService)(nil).GetPubById), ctx, id, uid)
 }
 
+// LikeIntr mocks base method.
+func (m *MockArticleService) LikeIntr(ctx context.Context, biz string, id, uid int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LikeIntr", ctx, biz, id, uid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LikeIntr indicates an expected call of LikeIntr.
+func (mr *MockArticleServiceMockRecorder) LikeIntr(ctx, biz, id, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LikeIntr", reflect.TypeOf((*MockArticleService)(nil).LikeIntr), ctx, biz, id, uid)
+}
+
 // ListPub mocks base method.
 func (m *MockArticleService) ListPub(ctx context.Context, start time.Time, offset, limit int) ([]domain.Article, error) {
 	m.ctrl.T.Helper()
@@ -0,0 +1,154 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: .\internal\service\binding.go
+//
+// Generated by this command:
+//
+//	mockgen -source .\internal\service\binding.go -destination .\internal\service\mocks\binding_mock.go -package svcmocks
+//
+
+// Package svcmocks is a generated GoMock package.
+package svcmocks
+
+import (
+	context "context"
+	reflect "reflect"
+	domain "webook/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockBindingService is a mock of BindingService interface.
+type MockBindingService struct {
+	ctrl     *gomock.Controller
+	recorder *MockBindingServiceMockRecorder
+}
+
+// MockBindingServiceMockRecorder is the mock recorder for MockBindingService.
+type MockBindingServiceMockRecorder struct {
+	mock *MockBindingService
+}
+
+// NewMockBindingService creates a new mock instance.
+func NewMockBindingService(ctrl *gomock.Controller) *MockBindingService {
+	mock := &MockBindingService{ctrl: ctrl}
+	mock.recorder = &MockBindingServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBindingService) EXPECT() *MockBindingServiceMockRecorder {
+	return m.recorder
+}
+
+// BindEmail mocks base method.
+func (m *MockBindingService) BindEmail(ctx context.Context, uid int64, email, password, token string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BindEmail", ctx, uid, email, password, token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BindEmail indicates an expected call of BindEmail.
+func (mr *MockBindingServiceMockRecorder) BindEmail(ctx, uid, email, password, token any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BindEmail", reflect.TypeOf((*MockBindingService)(nil).BindEmail), ctx, uid, email, password, token)
+}
+
+// BindIdentity mocks base method.
+func (m *MockBindingService) BindIdentity(ctx context.Context, uid int64, identity domain.Identity) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BindIdentity", ctx, uid, identity)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BindIdentity indicates an expected call of BindIdentity.
+func (mr *MockBindingServiceMockRecorder) BindIdentity(ctx, uid, identity any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BindIdentity", reflect.TypeOf((*MockBindingService)(nil).BindIdentity), ctx, uid, identity)
+}
+
+// BindPhone mocks base method.
+func (m *MockBindingService) BindPhone(ctx context.Context, uid int64, phone, code string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BindPhone", ctx, uid, phone, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BindPhone indicates an expected call of BindPhone.
+func (mr *MockBindingServiceMockRecorder) BindPhone(ctx, uid, phone, code any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BindPhone", reflect.TypeOf((*MockBindingService)(nil).BindPhone), ctx, uid, phone, code)
+}
+
+// ChangeEmail mocks base method.
+func (m *MockBindingService) ChangeEmail(ctx context.Context, uid int64, email, password, totpCode string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangeEmail", ctx, uid, email, password, totpCode)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ChangeEmail indicates an expected call of ChangeEmail.
+func (mr *MockBindingServiceMockRecorder) ChangeEmail(ctx, uid, email, password, totpCode any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangeEmail", reflect.TypeOf((*MockBindingService)(nil).ChangeEmail), ctx, uid, email, password, totpCode)
+}
+
+// Identities mocks base method.
+func (m *MockBindingService) Identities(ctx context.Context, uid int64) ([]domain.Identity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Identities", ctx, uid)
+	ret0, _ := ret[0].([]domain.Identity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Identities indicates an expected call of Identities.
+func (mr *MockBindingServiceMockRecorder) Identities(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Identities", reflect.TypeOf((*MockBindingService)(nil).Identities), ctx, uid)
+}
+
+// SendBindEmail mocks base method.
+func (m *MockBindingService) SendBindEmail(ctx context.Context, uid int64, email string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendBindEmail", ctx, uid, email)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendBindEmail indicates an expected call of SendBindEmail.
+func (mr *MockBindingServiceMockRecorder) SendBindEmail(ctx, uid, email any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendBindEmail", reflect.TypeOf((*MockBindingService)(nil).SendBindEmail), ctx, uid, email)
+}
+
+// SendBindPhoneCode mocks base method.
+func (m *MockBindingService) SendBindPhoneCode(ctx context.Context, phone string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendBindPhoneCode", ctx, phone)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendBindPhoneCode indicates an expected call of SendBindPhoneCode.
+func (mr *MockBindingServiceMockRecorder) SendBindPhoneCode(ctx, phone any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendBindPhoneCode", reflect.TypeOf((*MockBindingService)(nil).SendBindPhoneCode), ctx, phone)
+}
+
+// Unbind mocks base method.
+func (m *MockBindingService) Unbind(ctx context.Context, uid int64, method domain.LoginMethod) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unbind", ctx, uid, method)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unbind indicates an expected call of Unbind.
+func (mr *MockBindingServiceMockRecorder) Unbind(ctx, uid, method any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unbind", reflect.TypeOf((*MockBindingService)(nil).Unbind), ctx, uid, method)
+}
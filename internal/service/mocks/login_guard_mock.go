@@ -0,0 +1,112 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: .\internal\service\login_guard.go
+//
+// Generated by this command:
+//
+//	mockgen -source .\internal\service\login_guard.go -destination .\internal\service\mocks\login_guard_mock.go -package svcmocks
+//
+
+// Package svcmocks is a generated GoMock package.
+package svcmocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockLoginGuardService is a mock of LoginGuardService interface.
+type MockLoginGuardService struct {
+	ctrl     *gomock.Controller
+	recorder *MockLoginGuardServiceMockRecorder
+}
+
+// MockLoginGuardServiceMockRecorder is the mock recorder for MockLoginGuardService.
+type MockLoginGuardServiceMockRecorder struct {
+	mock *MockLoginGuardService
+}
+
+// NewMockLoginGuardService creates a new mock instance.
+func NewMockLoginGuardService(ctrl *gomock.Controller) *MockLoginGuardService {
+	mock := &MockLoginGuardService{ctrl: ctrl}
+	mock.recorder = &MockLoginGuardServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLoginGuardService) EXPECT() *MockLoginGuardServiceMockRecorder {
+	return m.recorder
+}
+
+// Check mocks base method.
+func (m *MockLoginGuardService) Check(ctx context.Context, account, ip string) (time.Duration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Check", ctx, account, ip)
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Check indicates an expected call of Check.
+func (mr *MockLoginGuardServiceMockRecorder) Check(ctx, account, ip any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*MockLoginGuardService)(nil).Check), ctx, account, ip)
+}
+
+// Fail mocks base method.
+func (m *MockLoginGuardService) Fail(ctx context.Context, account, ip, userAgent string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Fail", ctx, account, ip, userAgent)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Fail indicates an expected call of Fail.
+func (mr *MockLoginGuardServiceMockRecorder) Fail(ctx, account, ip, userAgent any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Fail", reflect.TypeOf((*MockLoginGuardService)(nil).Fail), ctx, account, ip, userAgent)
+}
+
+// SendUnlockCode mocks base method.
+func (m *MockLoginGuardService) SendUnlockCode(ctx context.Context, account string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendUnlockCode", ctx, account)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendUnlockCode indicates an expected call of SendUnlockCode.
+func (mr *MockLoginGuardServiceMockRecorder) SendUnlockCode(ctx, account any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendUnlockCode", reflect.TypeOf((*MockLoginGuardService)(nil).SendUnlockCode), ctx, account)
+}
+
+// Succeed mocks base method.
+func (m *MockLoginGuardService) Succeed(ctx context.Context, account string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Succeed", ctx, account)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Succeed indicates an expected call of Succeed.
+func (mr *MockLoginGuardServiceMockRecorder) Succeed(ctx, account any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Succeed", reflect.TypeOf((*MockLoginGuardService)(nil).Succeed), ctx, account)
+}
+
+// Unlock mocks base method.
+func (m *MockLoginGuardService) Unlock(ctx context.Context, account, code string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unlock", ctx, account, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unlock indicates an expected call of Unlock.
+func (mr *MockLoginGuardServiceMockRecorder) Unlock(ctx, account, code any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unlock", reflect.TypeOf((*MockLoginGuardService)(nil).Unlock), ctx, account, code)
+}
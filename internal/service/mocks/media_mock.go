@@ -0,0 +1,87 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: .\internal\service\media.go
+//
+// Generated by this command:
+//
+//	mockgen -source .\internal\service\media.go -destination .\internal\service\mocks\media_mock.go -package svcmocks
+//
+
+// Package svcmocks is a generated GoMock package.
+package svcmocks
+
+import (
+	context "context"
+	reflect "reflect"
+	domain "webook/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockMediaService is a mock of MediaService interface.
+type MockMediaService struct {
+	ctrl     *gomock.Controller
+	recorder *MockMediaServiceMockRecorder
+}
+
+// MockMediaServiceMockRecorder is the mock recorder for MockMediaService.
+type MockMediaServiceMockRecorder struct {
+	mock *MockMediaService
+}
+
+// NewMockMediaService creates a new mock instance.
+func NewMockMediaService(ctrl *gomock.Controller) *MockMediaService {
+	mock := &MockMediaService{ctrl: ctrl}
+	mock.recorder = &MockMediaServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMediaService) EXPECT() *MockMediaServiceMockRecorder {
+	return m.recorder
+}
+
+// Confirm mocks base method.
+func (m *MockMediaService) Confirm(ctx context.Context, uid, id int64) (domain.Media, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Confirm", ctx, uid, id)
+	ret0, _ := ret[0].(domain.Media)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Confirm indicates an expected call of Confirm.
+func (mr *MockMediaServiceMockRecorder) Confirm(ctx, uid, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Confirm", reflect.TypeOf((*MockMediaService)(nil).Confirm), ctx, uid, id)
+}
+
+// SetAvatar mocks base method.
+func (m *MockMediaService) SetAvatar(ctx context.Context, uid, mediaId int64) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAvatar", ctx, uid, mediaId)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetAvatar indicates an expected call of SetAvatar.
+func (mr *MockMediaServiceMockRecorder) SetAvatar(ctx, uid, mediaId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAvatar", reflect.TypeOf((*MockMediaService)(nil).SetAvatar), ctx, uid, mediaId)
+}
+
+// Upload mocks base method.
+func (m_2 *MockMediaService) Upload(ctx context.Context, uid int64, biz domain.MediaBiz, m domain.Media) (domain.Media, string, error) {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "Upload", ctx, uid, biz, m)
+	ret0, _ := ret[0].(domain.Media)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Upload indicates an expected call of Upload.
+func (mr *MockMediaServiceMockRecorder) Upload(ctx, uid, biz, m any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Upload", reflect.TypeOf((*MockMediaService)(nil).Upload), ctx, uid, biz, m)
+}
@@ -0,0 +1,99 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: .\internal\service\totp.go
+//
+// Generated by this command:
+//
+//	mockgen -source .\internal\service\totp.go -destination .\internal\service\mocks\totp_mock.go -package svcmocks
+//
+
+// Package svcmocks is a generated GoMock package.
+package svcmocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockTotpService is a mock of TotpService interface.
+type MockTotpService struct {
+	ctrl     *gomock.Controller
+	recorder *MockTotpServiceMockRecorder
+}
+
+// MockTotpServiceMockRecorder is the mock recorder for MockTotpService.
+type MockTotpServiceMockRecorder struct {
+	mock *MockTotpService
+}
+
+// NewMockTotpService creates a new mock instance.
+func NewMockTotpService(ctrl *gomock.Controller) *MockTotpService {
+	mock := &MockTotpService{ctrl: ctrl}
+	mock.recorder = &MockTotpServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTotpService) EXPECT() *MockTotpServiceMockRecorder {
+	return m.recorder
+}
+
+// Confirm mocks base method.
+func (m *MockTotpService) Confirm(ctx context.Context, uid int64, code string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Confirm", ctx, uid, code)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Confirm indicates an expected call of Confirm.
+func (mr *MockTotpServiceMockRecorder) Confirm(ctx, uid, code any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Confirm", reflect.TypeOf((*MockTotpService)(nil).Confirm), ctx, uid, code)
+}
+
+// Disable mocks base method.
+func (m *MockTotpService) Disable(ctx context.Context, uid int64, code string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Disable", ctx, uid, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Disable indicates an expected call of Disable.
+func (mr *MockTotpServiceMockRecorder) Disable(ctx, uid, code any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Disable", reflect.TypeOf((*MockTotpService)(nil).Disable), ctx, uid, code)
+}
+
+// Enroll mocks base method.
+func (m *MockTotpService) Enroll(ctx context.Context, uid int64) (string, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Enroll", ctx, uid)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Enroll indicates an expected call of Enroll.
+func (mr *MockTotpServiceMockRecorder) Enroll(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Enroll", reflect.TypeOf((*MockTotpService)(nil).Enroll), ctx, uid)
+}
+
+// Verify mocks base method.
+func (m *MockTotpService) Verify(ctx context.Context, uid int64, code string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Verify", ctx, uid, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Verify indicates an expected call of Verify.
+func (mr *MockTotpServiceMockRecorder) Verify(ctx, uid, code any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verify", reflect.TypeOf((*MockTotpService)(nil).Verify), ctx, uid, code)
+}
@@ -69,19 +69,19 @@ func (mr *MockUserServiceMockRecorder) FindOrCreate(ctx, phone any) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOrCreate", reflect.TypeOf((*MockUserService)(nil).FindOrCreate), ctx, phone)
 }
 
-// FindOrCreateByWechat mocks base method.
-func (m *MockUserService) FindOrCreateByWechat(ctx context.Context, info domain.WechatInfo) (domain.User, error) {
+// FindOrCreateByIdentity mocks base method.
+func (m *MockUserService) FindOrCreateByIdentity(ctx context.Context, identity domain.Identity) (domain.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindOrCreateByWechat", ctx, info)
+	ret := m.ctrl.Call(m, "FindOrCreateByIdentity", ctx, identity)
 	ret0, _ := ret[0].(domain.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// FindOrCreateByWechat indicates an expected call of FindOrCreateByWechat.
-func (mr *MockUserServiceMockRecorder) FindOrCreateByWechat(ctx, info any) *gomock.Call {
+// FindOrCreateByIdentity indicates an expected call of FindOrCreateByIdentity.
+func (mr *MockUserServiceMockRecorder) FindOrCreateByIdentity(ctx, identity any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOrCreateByWechat", reflect.TypeOf((*MockUserService)(nil).FindOrCreateByWechat), ctx, info)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOrCreateByIdentity", reflect.TypeOf((*MockUserService)(nil).FindOrCreateByIdentity), ctx, identity)
 }
 
 // GetUserInfo mocks base method.
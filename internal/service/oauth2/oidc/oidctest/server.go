@@ -0,0 +1,171 @@
+// Package oidctest 本地的 OIDC 服务器，测试用
+// 授权的时候不需要用户确认，直接跳回 redirect_uri，登录的用户由 SetUser 指定
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const (
+	ClientID     = "webook"
+	ClientSecret = "webook-secret"
+)
+
+type User struct {
+	Subject string `json:"sub"`
+	Email   string `json:"email,omitempty"`
+	Name    string `json:"name,omitempty"`
+}
+
+type authRequest struct {
+	challenge   string
+	redirectURI string
+	user        User
+}
+
+type Server struct {
+	*httptest.Server
+	mu     sync.Mutex
+	user   User
+	codes  map[string]authRequest
+	tokens map[string]User
+}
+
+func NewServer() *Server {
+	s := &Server{
+		user:   User{Subject: "10001", Email: "oidc@webook.com", Name: "oidc"},
+		codes:  make(map[string]authRequest),
+		tokens: make(map[string]User),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+	mux.HandleFunc("/userinfo", s.userinfo)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+func (s *Server) Issuer() string {
+	return s.URL
+}
+
+// SetUser 之后授权的都是这个用户
+func (s *Server) SetUser(u User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user = u
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	s.json(w, http.StatusOK, map[string]any{
+		"issuer":                           s.URL,
+		"authorization_endpoint":           s.URL + "/authorize",
+		"token_endpoint":                   s.URL + "/token",
+		"userinfo_endpoint":                s.URL + "/userinfo",
+		"code_challenge_methods_supported": []string{"S256"},
+	})
+}
+
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != ClientID || q.Get("response_type") != "code" ||
+		q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || q.Get("redirect_uri") == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	code := s.random()
+	s.mu.Lock()
+	s.codes[code] = authRequest{
+		challenge:   q.Get("code_challenge"),
+		redirectURI: q.Get("redirect_uri"),
+		user:        s.user,
+	}
+	s.mu.Unlock()
+	params := redirect.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		s.oauthErr(w, "invalid_request")
+		return
+	}
+	// 两种客户端认证方式都支持
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if id != ClientID || secret != ClientSecret {
+		s.json(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	s.mu.Lock()
+	req, ok := s.codes[r.PostForm.Get("code")]
+	// 授权码只能用一次
+	delete(s.codes, r.PostForm.Get("code"))
+	s.mu.Unlock()
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" ||
+		r.PostForm.Get("redirect_uri") != req.redirectURI {
+		s.oauthErr(w, "invalid_grant")
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != req.challenge {
+		s.oauthErr(w, "invalid_grant")
+		return
+	}
+	token := s.random()
+	s.mu.Lock()
+	s.tokens[token] = req.user
+	s.mu.Unlock()
+	s.json(w, http.StatusOK, map[string]any{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+	})
+}
+
+func (s *Server) userinfo(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	s.mu.Lock()
+	u, found := s.tokens[token]
+	s.mu.Unlock()
+	if !ok || !found {
+		s.json(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+		return
+	}
+	s.json(w, http.StatusOK, u)
+}
+
+func (s *Server) oauthErr(w http.ResponseWriter, code string) {
+	s.json(w, http.StatusBadRequest, map[string]string{"error": code})
+}
+
+func (s *Server) json(w http.ResponseWriter, status int, val any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(val)
+}
+
+func (s *Server) random() string {
+	data := make([]byte, 16)
+	_, _ = rand.Read(data)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,173 @@
+// Package oidc 通过配置接入的第三方登录，兼容标准的 OIDC（例如 Google）
+// 和只提供用户信息接口的 OAuth2（例如 GitHub）
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	xoauth2 "golang.org/x/oauth2"
+	"net/http"
+	"strings"
+	"webook/internal/domain"
+	"webook/internal/service/oauth2"
+)
+
+type Config struct {
+	// Name 路由里面的名字，例如 github
+	Name         string `yaml:"name"`
+	ClientID     string `yaml:"clientId"`
+	ClientSecret string `yaml:"clientSecret"`
+	RedirectURL  string `yaml:"redirectURL"`
+	// Issuer 配置了的话，从 Issuer/.well-known/openid-configuration 读取没有配置的地址
+	Issuer      string   `yaml:"issuer"`
+	AuthURL     string   `yaml:"authURL"`
+	TokenURL    string   `yaml:"tokenURL"`
+	UserInfoURL string   `yaml:"userInfoURL"`
+	Scopes      []string `yaml:"scopes"`
+	// SubjectField 用户信息里面的唯一标识，默认是 OIDC 的 sub，GitHub 是 id
+	SubjectField string `yaml:"subjectField"`
+}
+
+type provider struct {
+	name         string
+	cfg          xoauth2.Config
+	userInfoURL  string
+	subjectField string
+	client       *http.Client
+}
+
+// NewProvider 配置了 Issuer 的时候会发起一次 HTTP 请求
+func NewProvider(ctx context.Context, cfg Config, client *http.Client) (oauth2.Provider, error) {
+	if cfg.Name == "" {
+		return nil, errors.New("第三方登录没有配置 name")
+	}
+	if cfg.Issuer != "" {
+		err := discover(ctx, client, &cfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s 读取 OIDC 配置失败 %w", cfg.Name, err)
+		}
+	}
+	if cfg.AuthURL == "" || cfg.TokenURL == "" || cfg.UserInfoURL == "" {
+		return nil, fmt.Errorf("%s 缺少授权、token 或者用户信息的地址", cfg.Name)
+	}
+	if cfg.SubjectField == "" {
+		cfg.SubjectField = "sub"
+	}
+	return &provider{
+		name: cfg.Name,
+		cfg: xoauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       cfg.Scopes,
+			Endpoint: xoauth2.Endpoint{
+				AuthURL:  cfg.AuthURL,
+				TokenURL: cfg.TokenURL,
+			},
+		},
+		userInfoURL:  cfg.UserInfoURL,
+		subjectField: cfg.SubjectField,
+		client:       client,
+	}, nil
+}
+
+func (p *provider) Name() string {
+	return p.name
+}
+
+func (p *provider) AuthURL(ctx context.Context, state string, codeChallenge string) (string, error) {
+	return p.cfg.AuthCodeURL(state,
+		xoauth2.SetAuthURLParam("code_challenge", codeChallenge),
+		xoauth2.SetAuthURLParam("code_challenge_method", "S256")), nil
+}
+
+func (p *provider) VerifyCode(ctx context.Context, code string, codeVerifier string) (domain.Identity, error) {
+	ctx = context.WithValue(ctx, xoauth2.HTTPClient, p.client)
+	token, err := p.cfg.Exchange(ctx, code, xoauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		return domain.Identity{}, fmt.Errorf("通过 code 获取 access_token 失败 %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.userInfoURL, nil)
+	if err != nil {
+		return domain.Identity{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	token.SetAuthHeader(req)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return domain.Identity{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return domain.Identity{}, fmt.Errorf("获取用户信息失败 %s", resp.Status)
+	}
+	var info map[string]any
+	dec := json.NewDecoder(resp.Body)
+	// GitHub 的 id 是数字，避免变成科学计数法
+	dec.UseNumber()
+	err = dec.Decode(&info)
+	if err != nil {
+		return domain.Identity{}, err
+	}
+	subject := p.field(info, p.subjectField)
+	if subject == "" {
+		return domain.Identity{}, fmt.Errorf("用户信息里面没有 %s", p.subjectField)
+	}
+	name := p.field(info, "name")
+	if name == "" {
+		// GitHub 没有设置名字的用户
+		name = p.field(info, "login")
+	}
+	return domain.Identity{
+		Provider: p.name,
+		Subject:  subject,
+		Email:    p.field(info, "email"),
+		Name:     name,
+	}, nil
+}
+
+func (p *provider) field(info map[string]any, key string) string {
+	val, ok := info[key]
+	if !ok || val == nil {
+		return ""
+	}
+	return fmt.Sprint(val)
+}
+
+// discover 只补全没有配置的地址，配置了的优先
+func discover(ctx context.Context, client *http.Client, cfg *Config) error {
+	url := strings.TrimSuffix(cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s 返回 %s", url, resp.Status)
+	}
+	var doc struct {
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&doc)
+	if err != nil {
+		return err
+	}
+	if cfg.AuthURL == "" {
+		cfg.AuthURL = doc.AuthorizationEndpoint
+	}
+	if cfg.TokenURL == "" {
+		cfg.TokenURL = doc.TokenEndpoint
+	}
+	if cfg.UserInfoURL == "" {
+		cfg.UserInfoURL = doc.UserInfoEndpoint
+	}
+	return nil
+}
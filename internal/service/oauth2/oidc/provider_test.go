@@ -0,0 +1,86 @@
+package oidc
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/url"
+	"testing"
+	"webook/internal/domain"
+	"webook/internal/service/oauth2"
+	"webook/internal/service/oauth2/oidc/oidctest"
+)
+
+func TestProvider(t *testing.T) {
+	server := oidctest.NewServer()
+	defer server.Close()
+	server.SetUser(oidctest.User{Subject: "123", Email: "123@qq.com", Name: "daming"})
+
+	p, err := NewProvider(context.Background(), Config{
+		Name:         "fake",
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  "http://localhost:8080/oauth2/fake/callback",
+		Issuer:       server.Issuer(),
+		Scopes:       []string{"openid", "email", "profile"},
+	}, http.DefaultClient)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name string
+		// 回调的时候用的 verifier，和跳转的时候不一样就是被拦截了授权码
+		verifier func(verifier string) string
+
+		wantIdentity domain.Identity
+		wantErr      bool
+	}{
+		{
+			name: "登录成功",
+			verifier: func(verifier string) string {
+				return verifier
+			},
+			wantIdentity: domain.Identity{
+				Provider: "fake",
+				Subject:  "123",
+				Email:    "123@qq.com",
+				Name:     "daming",
+			},
+		},
+		{
+			name: "PKCE 校验失败",
+			verifier: func(verifier string) string {
+				return oauth2.NewVerifier()
+			},
+			wantErr: true,
+		},
+	}
+	// 不跟随跳转，从 Location 里面拿授权码
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			verifier := oauth2.NewVerifier()
+			authURL, err := p.AuthURL(context.Background(), "my-state", oauth2.Challenge(verifier))
+			require.NoError(t, err)
+			resp, err := client.Get(authURL)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, http.StatusFound, resp.StatusCode)
+			location, err := url.Parse(resp.Header.Get("Location"))
+			require.NoError(t, err)
+			assert.Equal(t, "my-state", location.Query().Get("state"))
+
+			identity, err := p.VerifyCode(context.Background(), location.Query().Get("code"), tc.verifier(verifier))
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantIdentity, identity)
+		})
+	}
+}
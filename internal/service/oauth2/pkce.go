@@ -0,0 +1,27 @@
+package oauth2
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	xoauth2 "golang.org/x/oauth2"
+)
+
+// NewState 防 CSRF 的 state
+func NewState() (string, error) {
+	data := make([]byte, 16)
+	_, err := rand.Read(data)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// NewVerifier PKCE 的 code_verifier，回调的时候用来换取 access token
+func NewVerifier() string {
+	return xoauth2.GenerateVerifier()
+}
+
+// Challenge S256 的 code_challenge，放在跳转地址里面
+func Challenge(verifier string) string {
+	return xoauth2.S256ChallengeFromVerifier(verifier)
+}
@@ -0,0 +1,48 @@
+// Package oauth2 第三方登录，每个平台实现一个 Provider，注册到 Registry 里面
+package oauth2
+
+import (
+	"context"
+	"sort"
+	"webook/internal/domain"
+)
+
+// Provider 一个第三方登录平台
+// state 和 PKCE 由调用方统一生成和校验，Provider 只负责拼接地址和换取用户信息
+type Provider interface {
+	// Name 出现在路由里面，也是 domain.Identity 的 Provider
+	Name() string
+	// AuthURL codeChallenge 是 S256 的 PKCE challenge，不支持 PKCE 的平台忽略
+	AuthURL(ctx context.Context, state string, codeChallenge string) (string, error)
+	// VerifyCode 用授权码换取用户的身份
+	VerifyCode(ctx context.Context, code string, codeVerifier string) (domain.Identity, error)
+}
+
+type Registry struct {
+	providers map[string]Provider
+}
+
+func NewRegistry(providers ...Provider) *Registry {
+	r := &Registry{
+		providers: make(map[string]Provider, len(providers)),
+	}
+	for _, p := range providers {
+		r.providers[p.Name()] = p
+	}
+	return r
+}
+
+func (r *Registry) Get(name string) (Provider, bool) {
+	p, ok := r.providers[name]
+	return p, ok
+}
+
+// Names 排好序的，方便前端展示
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
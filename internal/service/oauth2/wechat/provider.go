@@ -0,0 +1,41 @@
+package wechat
+
+import (
+	"context"
+	"webook/internal/domain"
+	"webook/internal/service/oauth2"
+)
+
+const ProviderName = "wechat"
+
+// provider 把 Service 适配成 oauth2.Provider
+type provider struct {
+	svc Service
+}
+
+func NewProvider(svc Service) oauth2.Provider {
+	return &provider{
+		svc: svc,
+	}
+}
+
+func (p *provider) Name() string {
+	return ProviderName
+}
+
+// AuthURL 微信不支持 PKCE，忽略 codeChallenge
+func (p *provider) AuthURL(ctx context.Context, state string, codeChallenge string) (string, error) {
+	return p.svc.AuthURL(ctx, state)
+}
+
+func (p *provider) VerifyCode(ctx context.Context, code string, codeVerifier string) (domain.Identity, error) {
+	info, err := p.svc.VerifyCode(ctx, code)
+	if err != nil {
+		return domain.Identity{}, err
+	}
+	return domain.Identity{
+		Provider: ProviderName,
+		Subject:  info.OpenId,
+		UnionId:  info.UnionId,
+	}, nil
+}
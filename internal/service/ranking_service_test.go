@@ -6,8 +6,8 @@ import (
 	"go.uber.org/mock/gomock"
 	"testing"
 	"time"
-	domain2 "webook/interactive/domain"
-	"webook/interactive/service"
+	intrv1 "webook/api/proto/gen/intr/v1"
+	intrv1mocks "webook/api/proto/gen/intr/v1/mocks"
 	"webook/internal/domain"
 	svcmocks "webook/internal/service/mocks"
 )
@@ -18,15 +18,15 @@ func TestBatchRankingService_TopN(t *testing.T) {
 	testCases := []struct {
 		name string
 
-		mock func(ctrl *gomock.Controller) (service.InteractiveService, ArticleService)
+		mock func(ctrl *gomock.Controller) (intrv1.InteractiveServiceClient, ArticleService)
 
 		wantArts []domain.Article
 		wantErr  error
 	}{
 		{
 			name: "成功获取",
-			mock: func(ctrl *gomock.Controller) (service.InteractiveService, ArticleService) {
-				intrSvc := svcmocks.NewMockInteractiveService(ctrl)
+			mock: func(ctrl *gomock.Controller) (intrv1.InteractiveServiceClient, ArticleService) {
+				intrSvc := intrv1mocks.NewMockInteractiveServiceClient(ctrl)
 				artSvc := svcmocks.NewMockArticleService(ctrl)
 				// 模拟数据库的分批查询
 				artSvc.EXPECT().ListPub(gomock.Any(), gomock.Any(), 0, 2).
@@ -44,17 +44,23 @@ func TestBatchRankingService_TopN(t *testing.T) {
 					Return([]domain.Article{}, nil)
 
 				// 第一批点赞数据
-				intrSvc.EXPECT().GetByIds(gomock.Any(), "article", []int64{1, 2}).
-					Return(map[int64]domain2.Interactive{
+				intrSvc.EXPECT().GetByIds(gomock.Any(), &intrv1.GetByIdsRequest{
+					Biz: "article", Ids: []int64{1, 2},
+				}).Return(&intrv1.GetByIdsResponse{
+					Intrs: map[int64]*intrv1.Interactive{
 						1: {LikeCnt: 12},
 						2: {LikeCnt: 23},
-					}, nil)
+					},
+				}, nil)
 				// 第二批点赞数据
-				intrSvc.EXPECT().GetByIds(gomock.Any(), "article", []int64{3, 4}).
-					Return(map[int64]domain2.Interactive{
+				intrSvc.EXPECT().GetByIds(gomock.Any(), &intrv1.GetByIdsRequest{
+					Biz: "article", Ids: []int64{3, 4},
+				}).Return(&intrv1.GetByIdsResponse{
+					Intrs: map[int64]*intrv1.Interactive{
 						3: {LikeCnt: 34},
 						4: {LikeCnt: 45},
-					}, nil)
+					},
+				}, nil)
 				return intrSvc, artSvc
 			},
 			wantErr: nil,
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/pkg/totp"
+)
+
+const (
+	totpIssuer = "webook"
+	// recoveryCodeCnt 开启两步验证的时候生成多少个恢复码
+	recoveryCodeCnt = 10
+	// recoveryCodeAlphabet 去掉了容易看错的 0 O 1 I L
+	recoveryCodeAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+)
+
+var (
+	ErrTotpInvalidCode    = errors.New("动态码或者恢复码不对")
+	ErrTotpNotEnrolled    = errors.New("还没有生成两步验证的密钥")
+	ErrTotpNotEnabled     = errors.New("没有开启两步验证")
+	ErrTotpAlreadyEnabled = errors.New("已经开启了两步验证")
+)
+
+// TotpService 两步验证，只有邮箱密码登录的时候需要
+type TotpService interface {
+	// Enroll 生成新的密钥和二维码的内容，Confirm 之前不生效
+	Enroll(ctx context.Context, uid int64) (secret string, uri string, err error)
+	// Confirm 用户第一次输入正确的动态码之后开启，返回的恢复码只展示这一次
+	Confirm(ctx context.Context, uid int64, code string) ([]string, error)
+	// Verify code 可以是动态码，也可以是恢复码，恢复码用一次就失效
+	Verify(ctx context.Context, uid int64, code string) error
+	// Disable 需要再验证一次
+	Disable(ctx context.Context, uid int64, code string) error
+}
+
+type totpService struct {
+	repo repository.UserRepository
+}
+
+func NewTotpService(repo repository.UserRepository) TotpService {
+	return &totpService{
+		repo: repo,
+	}
+}
+
+func (svc *totpService) Enroll(ctx context.Context, uid int64) (string, string, error) {
+	u, err := svc.repo.FindById(ctx, uid)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Totp.Enabled {
+		return "", "", ErrTotpAlreadyEnabled
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+	err = svc.repo.UpdateTotp(ctx, uid, domain.Totp{Secret: secret})
+	if err != nil {
+		return "", "", err
+	}
+	return secret, totp.URI(totpIssuer, u.Email, secret), nil
+}
+
+func (svc *totpService) Confirm(ctx context.Context, uid int64, code string) ([]string, error) {
+	u, err := svc.repo.FindById(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if u.Totp.Enabled {
+		return nil, ErrTotpAlreadyEnabled
+	}
+	if u.Totp.Secret == "" {
+		return nil, ErrTotpNotEnrolled
+	}
+	step, ok := totp.Validate(u.Totp.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrTotpInvalidCode
+	}
+	codes, hashes, err := svc.generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = svc.repo.UpdateTotp(ctx, uid, domain.Totp{
+		Secret:        u.Totp.Secret,
+		Enabled:       true,
+		RecoveryCodes: hashes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// 确认用的动态码，登录的时候不能再用
+	_, err = svc.repo.UseTotpStep(ctx, uid, step)
+	return codes, err
+}
+
+func (svc *totpService) Verify(ctx context.Context, uid int64, code string) error {
+	u, err := svc.repo.FindById(ctx, uid)
+	if err != nil {
+		return err
+	}
+	return svc.verify(ctx, u, code)
+}
+
+func (svc *totpService) Disable(ctx context.Context, uid int64, code string) error {
+	u, err := svc.repo.FindById(ctx, uid)
+	if err != nil {
+		return err
+	}
+	err = svc.verify(ctx, u, code)
+	if err != nil {
+		return err
+	}
+	return svc.repo.UpdateTotp(ctx, uid, domain.Totp{})
+}
+
+func (svc *totpService) verify(ctx context.Context, u domain.User, code string) error {
+	if !u.Totp.Enabled {
+		return ErrTotpNotEnabled
+	}
+	if step, ok := totp.Validate(u.Totp.Secret, code, time.Now()); ok {
+		ok, err := svc.repo.UseTotpStep(ctx, u.Id, step)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			// 这个动态码已经用过了，可能是被人偷看了
+			return ErrTotpInvalidCode
+		}
+		return nil
+	}
+	hash := svc.hashRecoveryCode(code)
+	idx := slices.Index(u.Totp.RecoveryCodes, hash)
+	if idx < 0 {
+		return ErrTotpInvalidCode
+	}
+	remaining := slices.Delete(slices.Clone(u.Totp.RecoveryCodes), idx, idx+1)
+	ok, err := svc.repo.UseRecoveryCode(ctx, u.Id, u.Totp.RecoveryCodes, remaining)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		// 并发使用了同一批恢复码，让用户重试
+		return ErrTotpInvalidCode
+	}
+	return nil
+}
+
+// generateRecoveryCodes 返回恢复码和它们的摘要，数据库里面只保存摘要
+func (svc *totpService) generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCnt)
+	hashes := make([]string, 0, recoveryCodeCnt)
+	buf := make([]byte, 10)
+	for i := 0; i < recoveryCodeCnt; i++ {
+		_, err := rand.Read(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+		code := make([]byte, len(buf))
+		for j, b := range buf {
+			code[j] = recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)]
+		}
+		codes = append(codes, string(code))
+		hashes = append(hashes, svc.hashRecoveryCode(string(code)))
+	}
+	return codes, hashes, nil
+}
+
+func (svc *totpService) hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
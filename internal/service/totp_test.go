@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/repository"
+	"webook/internal/repository/cache"
+	"webook/internal/repository/dao"
+	daomocks "webook/internal/repository/dao/mocks"
+	repomocks "webook/internal/repository/mocks"
+	"webook/pkg/totp"
+)
+
+func Test_totpService_Verify(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	step := totp.Step(time.Now())
+	code, err := totp.Code(secret, step)
+	require.NoError(t, err)
+	recovery := sha256.Sum256([]byte("ABCDEFGHJK"))
+	recoveryHash := hex.EncodeToString(recovery[:])
+	enabled := domain.User{
+		Id: 123,
+		Totp: domain.Totp{
+			Secret:        secret,
+			Enabled:       true,
+			RecoveryCodes: []string{"other", recoveryHash},
+		},
+	}
+
+	testCases := []struct {
+		name string
+
+		mock func(ctrl *gomock.Controller) repository.UserRepository
+
+		code string
+
+		wantErr error
+	}{
+		{
+			name: "动态码正确",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).Return(enabled, nil)
+				repo.EXPECT().UseTotpStep(gomock.Any(), int64(123), gomock.Any()).Return(true, nil)
+				return repo
+			},
+			code: code,
+		},
+		{
+			name: "动态码已经用过了",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).Return(enabled, nil)
+				repo.EXPECT().UseTotpStep(gomock.Any(), int64(123), gomock.Any()).Return(false, nil)
+				return repo
+			},
+			code:    code,
+			wantErr: ErrTotpInvalidCode,
+		},
+		{
+			name: "恢复码正确，用掉之后删除",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).Return(enabled, nil)
+				repo.EXPECT().UseRecoveryCode(gomock.Any(), int64(123),
+					[]string{"other", recoveryHash}, []string{"other"}).Return(true, nil)
+				return repo
+			},
+			code: "ABCDEFGHJK",
+		},
+		{
+			name: "恢复码不对",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).Return(enabled, nil)
+				return repo
+			},
+			code:    "ABCDEFGHJM",
+			wantErr: ErrTotpInvalidCode,
+		},
+		{
+			name: "没有开启两步验证",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).
+					Return(domain.User{Id: 123, Totp: domain.Totp{Secret: secret}}, nil)
+				return repo
+			},
+			code:    code,
+			wantErr: ErrTotpNotEnabled,
+		},
+		{
+			name: "系统错误",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).
+					Return(domain.User{}, errors.New("db Err"))
+				return repo
+			},
+			code:    code,
+			wantErr: errors.New("db Err"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewTotpService(tc.mock(ctrl))
+			err := svc.Verify(context.Background(), 123, tc.code)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func Test_totpService_Confirm(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	code, err := totp.Code(secret, totp.Step(time.Now()))
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := repomocks.NewMockUserRepository(ctrl)
+	repo.EXPECT().FindById(gomock.Any(), int64(123)).
+		Return(domain.User{Id: 123, Totp: domain.Totp{Secret: secret}}, nil)
+	var saved domain.Totp
+	repo.EXPECT().UpdateTotp(gomock.Any(), int64(123), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, uid int64, t domain.Totp) error {
+			saved = t
+			return nil
+		})
+	repo.EXPECT().UseTotpStep(gomock.Any(), int64(123), gomock.Any()).Return(true, nil)
+
+	codes, err := NewTotpService(repo).Confirm(context.Background(), 123, code)
+	require.NoError(t, err)
+	assert.Len(t, codes, recoveryCodeCnt)
+	assert.True(t, saved.Enabled)
+	assert.Equal(t, secret, saved.Secret)
+	// 数据库里面只有摘要
+	require.Len(t, saved.RecoveryCodes, recoveryCodeCnt)
+	sum := sha256.Sum256([]byte(codes[0]))
+	assert.Equal(t, hex.EncodeToString(sum[:]), saved.RecoveryCodes[0])
+}
+
+// 改了个人资料之后缓存里面不能只剩下改了的字段，两步验证也要照常用
+func Test_totpService_AfterEditUserInfo(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	code, err := totp.Code(secret, totp.Step(time.Now()))
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	d := daomocks.NewMockUserDAO(ctrl)
+	d.EXPECT().FindUserInfoById(gomock.Any(), int64(123)).Return(dao.User{
+		Id:          123,
+		Email:       sql.NullString{String: "123@qq.com", Valid: true},
+		Password:    "hash",
+		TotpSecret:  secret,
+		TotpEnabled: true,
+	}, nil).AnyTimes()
+	d.EXPECT().Update(gomock.Any(), gomock.Any()).Return(nil)
+	d.EXPECT().UpdateTotpLastStep(gomock.Any(), int64(123), gomock.Any()).Return(true, nil)
+	c := &memoryUserCache{data: map[int64]domain.User{}}
+	repo := repository.NewCachedUserRepository(d, c)
+	svc := NewTotpService(repo)
+	ctx := context.Background()
+
+	_, err = repo.FindUserInfoById(ctx, 123)
+	require.NoError(t, err)
+	err = repo.EditUserInfo(ctx, 123, "新昵称", "2000-01-01", "关于我")
+	require.NoError(t, err)
+
+	// 不能绕过动态码重新绑定
+	_, _, err = svc.Enroll(ctx, 123)
+	assert.Equal(t, ErrTotpAlreadyEnabled, err)
+	err = svc.Verify(ctx, 123, code)
+	assert.NoError(t, err)
+
+	u, err := repo.FindUserInfoById(ctx, 123)
+	require.NoError(t, err)
+	assert.Equal(t, "123@qq.com", u.Email)
+	assert.True(t, u.Totp.Enabled)
+}
+
+type memoryUserCache struct {
+	data map[int64]domain.User
+}
+
+func (c *memoryUserCache) Get(ctx context.Context, uid int64) (domain.User, error) {
+	u, ok := c.data[uid]
+	if !ok {
+		return domain.User{}, cache.ErrKeyNotExist
+	}
+	return u, nil
+}
+
+func (c *memoryUserCache) Set(ctx context.Context, du domain.User) error {
+	c.data[du.Id] = du
+	return nil
+}
+
+func (c *memoryUserCache) Del(ctx context.Context, uid int64) error {
+	delete(c.data, uid)
+	return nil
+}
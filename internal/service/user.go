@@ -20,16 +20,19 @@ type UserService interface {
 	EditUserInfo(ctx context.Context, userID int64, name string, birthday string, me string) error
 	GetUserInfo(ctx context.Context, userID int64) (domain.User, error)
 	FindOrCreate(ctx context.Context, phone string) (domain.User, error)
-	FindOrCreateByWechat(ctx context.Context, info domain.WechatInfo) (domain.User, error)
+	// FindOrCreateByIdentity 第三方登录，第一次登录的时候自动注册
+	FindOrCreateByIdentity(ctx context.Context, identity domain.Identity) (domain.User, error)
 }
 
 type userService struct {
-	repo repository.UserRepository
+	repo         repository.UserRepository
+	identityRepo repository.UserIdentityRepository
 }
 
-func NewUserService(repo repository.UserRepository) UserService {
+func NewUserService(repo repository.UserRepository, identityRepo repository.UserIdentityRepository) UserService {
 	return &userService{
-		repo: repo,
+		repo:         repo,
+		identityRepo: identityRepo,
 	}
 }
 
@@ -101,22 +104,30 @@ func (svc *userService) FindOrCreate(ctx context.Context, phone string) (domain.
 	return svc.repo.FindByPhone(ctx, phone)
 }
 
-func (svc *userService) FindOrCreateByWechat(ctx context.Context, wechatInfo domain.WechatInfo) (domain.User, error) {
+func (svc *userService) FindOrCreateByIdentity(ctx context.Context, identity domain.Identity) (domain.User, error) {
 	// 先去查找一下，因为我们认为大部分用户是已存在的用户
-	u, err := svc.repo.FindByWechat(ctx, wechatInfo.OpenId)
-	if err != repository.ErrUserNotFound {
-		return u, err
+	found, err := svc.identityRepo.FindByProvider(ctx, identity.Provider, identity.Subject)
+	switch {
+	case err == nil:
+		return svc.repo.FindUserInfoById(ctx, found.Uid)
+	case !errors.Is(err, repository.ErrIdentityNotFound):
+		return domain.User{}, err
 	}
 	// 日志打印 Info
 	// 记录一下发生了某件事
 	// 意味着这是一个新用户， zap.Any 输出 JSON 格式
-	zap.L().Info("新用户", zap.Any("wechatInfo", wechatInfo))
-	err = svc.repo.Create(ctx, domain.User{
-		WechatInfo: wechatInfo,
-	})
-
-	if err != nil && err != repository.ErrDuplicateUser {
+	zap.L().Info("新用户", zap.String("provider", identity.Provider), zap.String("subject", identity.Subject))
+	uid, err := svc.identityRepo.CreateWithUser(ctx, identity)
+	if err == nil {
+		return svc.repo.FindUserInfoById(ctx, uid)
+	}
+	if !errors.Is(err, repository.ErrDuplicateUser) {
+		return domain.User{}, err
+	}
+	// 并发登录，别人已经创建了（不过会有主从延迟的问题）
+	found, err = svc.identityRepo.FindByProvider(ctx, identity.Provider, identity.Subject)
+	if err != nil {
 		return domain.User{}, err
 	}
-	return svc.repo.FindByWechat(ctx, wechatInfo.OpenId)
+	return svc.repo.FindUserInfoById(ctx, found.Uid)
 }
@@ -118,7 +118,7 @@ func Test_userService_Login(t *testing.T) {
 			defer ctrl.Finish()
 
 			userRepo := tc.mock(ctrl)
-			svc := NewUserService(userRepo)
+			svc := NewUserService(userRepo, nil)
 
 			user, err := svc.Login(tc.ctx, tc.email, tc.password)
 
@@ -11,6 +11,7 @@ import (
 	"webook/internal/domain"
 	"webook/internal/service"
 	"webook/internal/web/jwt"
+	"webook/pkg/ginx"
 	"webook/pkg/logger"
 )
 
@@ -31,8 +32,9 @@ func NewArticleHandler(l logger.LoggerV1, svc service.ArticleService, interSvc i
 	}
 }
 
-func (h *ArticleHandler) RegisterRoutes(server *gin.Engine) {
-	g := server.Group("/articles")
+func (h *ArticleHandler) RegisterRoutes(server *gin.Engine, registry *ginx.Registry) {
+	ag := server.Group("/articles")
+	g := registry.Group(ag, ginx.Authenticated())
 	// 这里演示了基于集成测试的TDD
 	g.POST("/edit", h.Edit)
 	// 这里演示了基于单元测试的TDD
@@ -44,7 +46,7 @@ func (h *ArticleHandler) RegisterRoutes(server *gin.Engine) {
 	g.POST("/list", h.List)
 
 	// 读者接口
-	pub := g.Group("/pub")
+	pub := registry.Group(ag.Group("/pub"), ginx.Authenticated())
 	pub.GET("/:id", h.PubDetail)
 	// 传入一个参数，true 点赞 false 不点赞
 	pub.POST("/like", h.Like)
@@ -14,6 +14,7 @@ import (
 	"webook/internal/service"
 	svcmocks "webook/internal/service/mocks"
 	ijwt "webook/internal/web/jwt"
+	"webook/pkg/ginx"
 	"webook/pkg/logger"
 )
 
@@ -135,7 +136,7 @@ func TestArticleHandler_Publish(t *testing.T) {
 					UserId: 123,
 				})
 			})
-			hdl.RegisterRoutes(server)
+			hdl.RegisterRoutes(server, ginx.NewRegistry())
 
 			req, err := http.NewRequest(http.MethodPost, "/article/publish", bytes.NewBufferString(tc.reqBody))
 			assert.NoError(t, err)
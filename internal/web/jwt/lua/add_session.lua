@@ -0,0 +1,39 @@
+-- 会话详情
+local sessions = KEYS[1]
+-- 按照登录时间排序的 ssid
+local order = KEYS[2]
+local ssid = ARGV[1]
+local val = ARGV[2]
+local loginTime = tonumber(ARGV[3])
+-- 最多同时登录几个设备，0 表示不限制
+local max = tonumber(ARGV[4])
+-- 过期时间，秒
+local expiration = tonumber(ARGV[5])
+
+local evicted = {}
+
+-- 先清掉长 token 已经过期的会话
+local expired = redis.call("zrangebyscore", order, "-inf", loginTime - expiration * 1000)
+for _, s in ipairs(expired) do
+    redis.call("hdel", sessions, s)
+    redis.call("zrem", order, s)
+end
+
+redis.call("hset", sessions, ssid, val)
+redis.call("zadd", order, loginTime, ssid)
+
+if max > 0 then
+    local cnt = redis.call("zcard", order)
+    if cnt > max then
+        -- 踢掉最早登录的
+        evicted = redis.call("zrange", order, 0, cnt - max - 1)
+        for _, s in ipairs(evicted) do
+            redis.call("hdel", sessions, s)
+            redis.call("zrem", order, s)
+        end
+    end
+end
+
+redis.call("expire", sessions, expiration)
+redis.call("expire", order, expiration)
+return evicted
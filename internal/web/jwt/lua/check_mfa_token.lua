@@ -0,0 +1,18 @@
+-- 两步验证的临时 token，uid 和已经尝试的次数
+local key = KEYS[1]
+-- 最多可以尝试几次
+local maxAttempts = tonumber(ARGV[1])
+
+local uid = redis.call("hget", key, "uid")
+if not uid then
+    -- 不存在或者已经过期
+    return -1
+end
+
+local attempts = redis.call("hincrby", key, "attempts", 1)
+if attempts > maxAttempts then
+    -- 尝试太多次了，只能重新输入密码
+    redis.call("del", key)
+    return -1
+end
+return tonumber(uid)
@@ -0,0 +1,36 @@
+-- 会话详情
+local sessions = KEYS[1]
+-- 按照登录时间排序的 ssid
+local order = KEYS[2]
+-- 这个会话签发过的长 token，jti => active 或者 used
+local refresh = KEYS[3]
+local ssid = ARGV[1]
+-- 本次提交的长 token
+local jti = ARGV[2]
+-- 新签发的长 token
+local newJti = ARGV[3]
+-- 过期时间，秒
+local expiration = tonumber(ARGV[4])
+
+if redis.call("hexists", sessions, ssid) == 0 then
+    -- 会话已经退出登录或者被踢掉了
+    return -1
+end
+
+local status = redis.call("hget", refresh, jti)
+if status == "active" then
+    redis.call("hset", refresh, jti, "used", newJti, "active")
+    redis.call("expire", refresh, expiration)
+    return 0
+end
+
+if status == "used" then
+    -- 用过的长 token 又被提交了，说明长 token 泄露了，整个会话作废
+    redis.call("hdel", sessions, ssid)
+    redis.call("zrem", order, ssid)
+    redis.call("del", refresh)
+    return 1
+end
+
+-- 不是这个会话签发的
+return -1
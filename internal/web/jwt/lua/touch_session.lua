@@ -0,0 +1,12 @@
+-- 会话详情
+local sessions = KEYS[1]
+local ssid = ARGV[1]
+-- 更新了刷新时间之后的会话详情
+local val = ARGV[2]
+
+if redis.call("hexists", sessions, ssid) == 0 then
+    -- 读出来之后会话被踢掉或者退出登录了，不能再写回去
+    return 0
+end
+redis.call("hset", sessions, ssid, val)
+return 1
@@ -1,33 +1,72 @@
 package jwt
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
+	"sort"
 	"strings"
 	"time"
 	"webook/pkg/ginx"
+	"webook/pkg/jwtx"
+)
+
+var (
+	//go:embed lua/add_session.lua
+	luaAddSession string
+	//go:embed lua/rotate_refresh.lua
+	luaRotateRefresh string
+	//go:embed lua/check_mfa_token.lua
+	luaCheckMfaToken string
+	//go:embed lua/touch_session.lua
+	luaTouchSession string
+
+	ErrSessionNotFound = errors.New("会话不存在")
+	// ErrRefreshTokenReused 用过的长 token 又被提交了，整个会话已经作废
+	ErrRefreshTokenReused = errors.New("长 token 被重复使用")
+	// ErrMfaTokenInvalid 两步验证的临时 token 不存在、过期了或者尝试次数太多
+	ErrMfaTokenInvalid = errors.New("两步验证已经过期，请重新登录")
+)
+
+const (
+	// mfaTokenExpiration 输完密码之后，要在五分钟之内输入动态码
+	mfaTokenExpiration = time.Minute * 5
+	mfaMaxAttempts     = 5
 )
 
 type RedisJWTHandler struct {
-	cmd           redis.Cmdable
-	signingMethod jwt.SigningMethod
-	rcExpiration  time.Duration
+	cmd redis.Cmdable
+	// 短 token 和长 token 用不同的密钥，gRPC 服务只需要短 token 的公钥
+	keys         *jwtx.KeySet
+	rcKeys       *jwtx.KeySet
+	rcExpiration time.Duration
+	// 最多同时登录几个设备，超过了踢掉最早登录的，0 表示不限制
+	maxSessions int
 }
 
-func NewRedisJWTHandler(cmd redis.Cmdable) Handler {
+func NewRedisJWTHandler(cmd redis.Cmdable, keys *jwtx.KeySet, rcKeys *jwtx.KeySet) *RedisJWTHandler {
 	return &RedisJWTHandler{
-		cmd:           cmd,
-		signingMethod: jwt.SigningMethodHS512,
-		rcExpiration:  time.Hour * 24 * 7,
+		cmd:          cmd,
+		keys:         keys,
+		rcKeys:       rcKeys,
+		rcExpiration: time.Hour * 24 * 7,
 	}
 }
 
-var JWTKey = []byte("pBnSDaa0oCypBlPSpSoATWB4VZIS9niS")
-var RCJWTKey = []byte("pBnSDaa0oCypBlPSpSoATWB4VZIS9niB")
+// MaxSessions 每个用户最多同时登录 n 个设备
+func (rh *RedisJWTHandler) MaxSessions(n int) *RedisJWTHandler {
+	rh.maxSessions = n
+	return rh
+}
 
 // ExtractToken 根据约定，token 在 Authorization 头部
 // Bearer XXX
@@ -49,31 +88,76 @@ func (rh *RedisJWTHandler) ClearToken(ctx *gin.Context) error {
 	ctx.Header("x-jwt-token", "")
 	ctx.Header("x-refresh-token", "")
 	uc := ctx.MustGet("user").(UserClaims)
-	// 长 token 过期时间
-	return rh.cmd.Set(ctx, fmt.Sprintf("users:ssid:%s", uc.Ssid), "", rh.rcExpiration).Err()
+	err := rh.RevokeSession(ctx, uc.UserId, uc.Ssid)
+	if errors.Is(err, ErrSessionNotFound) {
+		// 已经被别的设备踢掉了
+		return nil
+	}
+	return err
 }
 
-func (rh *RedisJWTHandler) SetLoginToken(ctx *gin.Context, uid int64) error {
+// SetLoginToken 登录成功之后，登记一个新的会话，角色记录在会话里面，刷新短 token 的时候沿用
+func (rh *RedisJWTHandler) SetLoginToken(ctx *gin.Context, uid int64, roles []string) error {
 	ssid := uuid.New().String()
-	err := rh.SetRefreshToken(ctx, uid, ssid)
+	err := rh.addSession(ctx, uid, ssid, roles)
+	if err != nil {
+		return err
+	}
+	err = rh.SetRefreshToken(ctx, uid, ssid)
+	if err != nil {
+		return err
+	}
+	return rh.setJWTToken(ctx, uid, ssid, roles)
+}
+
+// RotateRefreshToken 每次刷新都换一个新的长 token，旧的长 token 标记为已使用
+// 已使用的长 token 再次出现，说明长 token 被偷了，而且不知道哪一边是真正的用户，所以整个会话都作废
+// 新的长 token 沿用原来的过期时间，会话从登录开始最长也只有 rcExpiration
+func (rh *RedisJWTHandler) RotateRefreshToken(ctx *gin.Context, rc RefreshClaims) error {
+	jti := uuid.New().String()
+	res, err := rh.cmd.Eval(ctx, luaRotateRefresh,
+		[]string{rh.sessionsKey(rc.UserId), rh.orderKey(rc.UserId), rh.refreshKey(rc.UserId, rc.Ssid)},
+		rc.Ssid, rc.ID, jti, int64(rh.rcExpiration/time.Second)).Int()
 	if err != nil {
 		return err
 	}
-	return rh.SetJWTToken(ctx, uid, ssid)
+	switch res {
+	case 0:
+	case 1:
+		return ErrRefreshTokenReused
+	default:
+		// 包括升级之前签发的、没有 jti 的长 token，需要重新登录
+		return ErrSessionNotFound
+	}
+	err = rh.signRefreshToken(ctx, rc.UserId, rc.Ssid, jti, rc.ExpiresAt.Time)
+	if err != nil {
+		return err
+	}
+	return rh.SetJWTToken(ctx, rc.UserId, rc.Ssid)
 }
 
+// SetJWTToken 用长 token 换短 token 的时候调用，顺便记录会话最近一次刷新的时间
 func (rh *RedisJWTHandler) SetJWTToken(ctx *gin.Context, uid int64, ssid string) error {
+	sess, err := rh.touchSession(ctx, uid, ssid)
+	if err != nil && !errors.Is(err, ErrSessionNotFound) {
+		return err
+	}
+	return rh.setJWTToken(ctx, uid, ssid, sess.Roles)
+}
+
+func (rh *RedisJWTHandler) setJWTToken(ctx *gin.Context, uid int64, ssid string, roles []string) error {
 	uc := UserClaims{
+		Typ:       jwtx.TokenTypeAccess,
 		UserId:    uid,
 		Ssid:      ssid,
 		UserAgent: ctx.GetHeader("User-Agent"),
+		Roles:     roles,
 		RegisteredClaims: jwt.RegisteredClaims{
 			// 30分钟后过期
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * 30)),
 		},
 	}
-	token := jwt.NewWithClaims(rh.signingMethod, uc)
-	tokenStr, err := token.SignedString(JWTKey)
+	tokenStr, err := rh.keys.Sign(uc)
 	if err != nil {
 		return err
 	}
@@ -82,17 +166,33 @@ func (rh *RedisJWTHandler) SetJWTToken(ctx *gin.Context, uid int64, ssid string)
 	return nil
 }
 
+// SetRefreshToken 会话的第一个长 token
 func (rh *RedisJWTHandler) SetRefreshToken(ctx *gin.Context, uid int64, ssid string) error {
+	jti := uuid.New().String()
+	key := rh.refreshKey(uid, ssid)
+	pipe := rh.cmd.TxPipeline()
+	pipe.HSet(ctx, key, jti, "active")
+	pipe.Expire(ctx, key, rh.rcExpiration)
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return err
+	}
+	// 长 token 设置 7 天后过期
+	return rh.signRefreshToken(ctx, uid, ssid, jti, time.Now().Add(rh.rcExpiration))
+}
+
+func (rh *RedisJWTHandler) signRefreshToken(ctx *gin.Context, uid int64, ssid string,
+	jti string, expiresAt time.Time) error {
 	rc := RefreshClaims{
+		Typ:    jwtx.TokenTypeRefresh,
 		UserId: uid,
 		Ssid:   ssid,
 		RegisteredClaims: jwt.RegisteredClaims{
-			// 长 token 设置 7 天后过期
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(rh.rcExpiration)),
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
 		},
 	}
-	token := jwt.NewWithClaims(rh.signingMethod, rc)
-	tokenStr, err := token.SignedString(RCJWTKey)
+	tokenStr, err := rh.rcKeys.Sign(rc)
 	if err != nil {
 		return err
 	}
@@ -100,32 +200,250 @@ func (rh *RedisJWTHandler) SetRefreshToken(ctx *gin.Context, uid int64, ssid str
 	return nil
 }
 
-func (rh *RedisJWTHandler) CheckSession(ctx *gin.Context, ssid string) error {
-	// 是否已经退出登录的校验
-	cnt, err := rh.cmd.Exists(ctx, fmt.Sprintf("users:ssid:%s", ssid)).Result()
-	if err == nil && cnt > 0 {
-		// cnt > 0 说明这个 token 在 redis 中，意味它是无效的
-		// || 代表一种降级策略，err == nil 表示 redis 没问题，那么就执行严格的 ssid 校验，如果 redis 出了问题，err != nil，就不需要严格校验 ssid
+func (rh *RedisJWTHandler) VerifyToken(tokenStr string) (UserClaims, error) {
+	var uc UserClaims
+	err := rh.keys.Parse(tokenStr, &uc)
+	if err == nil && uc.Typ != jwtx.TokenTypeAccess {
+		return uc, jwtx.ErrTokenType
+	}
+	return uc, err
+}
+
+func (rh *RedisJWTHandler) VerifyRefreshToken(tokenStr string) (RefreshClaims, error) {
+	var rc RefreshClaims
+	err := rh.rcKeys.Parse(tokenStr, &rc)
+	if err == nil && rc.Typ != jwtx.TokenTypeRefresh {
+		return rc, jwtx.ErrTokenType
+	}
+	return rc, err
+}
+
+// JWKS 短 token 的公钥，给 gRPC 服务验证用户身份
+func (rh *RedisJWTHandler) JWKS() jwtx.JWKS {
+	return rh.keys.JWKS()
+}
+
+func (rh *RedisJWTHandler) CheckSession(ctx *gin.Context, uid int64, ssid string) error {
+	// 会话还在登记表里面才有效，退出登录和被踢掉的会话都已经删掉了
+	ok, err := rh.cmd.HExists(ctx, rh.sessionsKey(uid), ssid).Result()
+	if err == nil && !ok {
+		// 降级策略，err == nil 表示 redis 没问题，那么就执行严格的 ssid 校验，如果 redis 出了问题，err != nil，就不需要严格校验 ssid
 		return errors.New("token 无效")
 	}
 	return nil
 
 	// 严格的方式，redis 一旦出问题就不再能够通过登录校验
-	//cnt, err := rh.cmd.Exists(ctx, fmt.Sprintf("users:ssid:%s", ssid)).Result()
+	//ok, err := rh.cmd.HExists(ctx, rh.sessionsKey(uid), ssid).Result()
 	//if err != nil {
 	//	return err
 	//}
-	//if cnt > 0 {
+	//if !ok {
 	//	return errors.New("token 无效")
 	//}
 	//return nil
 
 }
 
+// ListSessions 按照登录时间倒序
+func (rh *RedisJWTHandler) ListSessions(ctx *gin.Context, uid int64) ([]Session, error) {
+	vals, err := rh.cmd.HGetAll(ctx, rh.sessionsKey(uid)).Result()
+	if err != nil {
+		return nil, err
+	}
+	expired := time.Now().Add(-rh.rcExpiration).UnixMilli()
+	res := make([]Session, 0, len(vals))
+	for _, val := range vals {
+		var sess Session
+		err = json.Unmarshal([]byte(val), &sess)
+		if err != nil {
+			return nil, err
+		}
+		// 长 token 已经过期了，下一次登录的时候会被清掉
+		if sess.LoginTime < expired {
+			continue
+		}
+		res = append(res, sess)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].LoginTime > res[j].LoginTime
+	})
+	return res, nil
+}
+
+func (rh *RedisJWTHandler) RevokeSession(ctx *gin.Context, uid int64, ssid string) error {
+	cnt, err := rh.cmd.HDel(ctx, rh.sessionsKey(uid), ssid).Result()
+	if err != nil {
+		return err
+	}
+	if cnt == 0 {
+		return ErrSessionNotFound
+	}
+	pipe := rh.cmd.Pipeline()
+	pipe.ZRem(ctx, rh.orderKey(uid), ssid)
+	pipe.Del(ctx, rh.refreshKey(uid, ssid))
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// RevokeOtherSessions 除了 ssid 之外的会话都退出登录
+func (rh *RedisJWTHandler) RevokeOtherSessions(ctx *gin.Context, uid int64, ssid string) error {
+	ssids, err := rh.cmd.HKeys(ctx, rh.sessionsKey(uid)).Result()
+	if err != nil {
+		return err
+	}
+	others := make([]string, 0, len(ssids))
+	for _, s := range ssids {
+		if s != ssid {
+			others = append(others, s)
+		}
+	}
+	if len(others) == 0 {
+		return nil
+	}
+	pipe := rh.cmd.TxPipeline()
+	pipe.HDel(ctx, rh.sessionsKey(uid), others...)
+	members := make([]any, 0, len(others))
+	for _, s := range others {
+		members = append(members, s)
+		pipe.Del(ctx, rh.refreshKey(uid, s))
+	}
+	pipe.ZRem(ctx, rh.orderKey(uid), members...)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (rh *RedisJWTHandler) RevokeAllSessions(ctx *gin.Context, uid int64) error {
+	// ssid 不会是空字符串
+	return rh.RevokeOtherSessions(ctx, uid, "")
+}
+
+func (rh *RedisJWTHandler) addSession(ctx *gin.Context, uid int64, ssid string, roles []string) error {
+	ua := ctx.GetHeader("User-Agent")
+	now := time.Now().UnixMilli()
+	val, err := json.Marshal(Session{
+		Ssid:        ssid,
+		Device:      device(ctx.GetHeader("X-Device"), ua),
+		UserAgent:   ua,
+		IP:          ctx.ClientIP(),
+		LoginTime:   now,
+		RefreshTime: now,
+		Roles:       roles,
+	})
+	if err != nil {
+		return err
+	}
+	return rh.cmd.Eval(ctx, luaAddSession, []string{rh.sessionsKey(uid), rh.orderKey(uid)},
+		ssid, val, now, rh.maxSessions, int64(rh.rcExpiration/time.Second)).Err()
+}
+
+func (rh *RedisJWTHandler) touchSession(ctx *gin.Context, uid int64, ssid string) (Session, error) {
+	key := rh.sessionsKey(uid)
+	val, err := rh.cmd.HGet(ctx, key, ssid).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return Session{}, ErrSessionNotFound
+	}
+	if err != nil {
+		return Session{}, err
+	}
+	var sess Session
+	err = json.Unmarshal(val, &sess)
+	if err != nil {
+		return Session{}, err
+	}
+	sess.RefreshTime = time.Now().UnixMilli()
+	val, err = json.Marshal(sess)
+	if err != nil {
+		return Session{}, err
+	}
+	// 只有会话还在的时候才写回去，否则会把刚刚被踢掉的会话又加回来
+	ok, err := rh.cmd.Eval(ctx, luaTouchSession, []string{key}, ssid, val).Int()
+	if err != nil {
+		return Session{}, err
+	}
+	if ok == 0 {
+		return Session{}, ErrSessionNotFound
+	}
+	return sess, nil
+}
+
+// SetMfaPendingToken 密码验证通过了，但是还要输入动态码
+// 这时候不签发 JWT，只给一个随机的临时 token，放在 x-mfa-token 头部
+func (rh *RedisJWTHandler) SetMfaPendingToken(ctx *gin.Context, uid int64) error {
+	data := make([]byte, 32)
+	_, err := rand.Read(data)
+	if err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(data)
+	key := rh.mfaKey(token)
+	pipe := rh.cmd.TxPipeline()
+	pipe.HSet(ctx, key, "uid", uid, "attempts", 0)
+	pipe.Expire(ctx, key, mfaTokenExpiration)
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		return err
+	}
+	ctx.Header("x-mfa-token", token)
+	return nil
+}
+
+// CheckMfaPendingToken 每调用一次算一次尝试，超过 mfaMaxAttempts 次之后 token 作废
+func (rh *RedisJWTHandler) CheckMfaPendingToken(ctx *gin.Context, token string) (int64, error) {
+	if token == "" {
+		return 0, ErrMfaTokenInvalid
+	}
+	uid, err := rh.cmd.Eval(ctx, luaCheckMfaToken, []string{rh.mfaKey(token)}, mfaMaxAttempts).Int64()
+	if err != nil {
+		return 0, err
+	}
+	if uid <= 0 {
+		return 0, ErrMfaTokenInvalid
+	}
+	return uid, nil
+}
+
+func (rh *RedisJWTHandler) ClearMfaPendingToken(ctx *gin.Context, token string) error {
+	return rh.cmd.Del(ctx, rh.mfaKey(token)).Err()
+}
+
+func (rh *RedisJWTHandler) sessionsKey(uid int64) string {
+	return fmt.Sprintf("users:sessions:%d", uid)
+}
+
+func (rh *RedisJWTHandler) orderKey(uid int64) string {
+	return fmt.Sprintf("users:sessions:%d:order", uid)
+}
+
+// refreshKey 会话签发过的长 token，被踢掉的会话没有清理这个 key，等它自己过期
+func (rh *RedisJWTHandler) refreshKey(uid int64, ssid string) string {
+	return fmt.Sprintf("users:sessions:%d:refresh:%s", uid, ssid)
+}
+
+// mfaKey redis 里面只保存 token 的摘要
+func (rh *RedisJWTHandler) mfaKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "users:mfa:" + hex.EncodeToString(sum[:])
+}
+
+// device 客户端可以通过 X-Device 头告诉我们设备名，没有的话从 User-Agent 里面猜
+func device(name, ua string) string {
+	if name != "" {
+		return name
+	}
+	for _, d := range []string{"iPhone", "iPad", "Android", "Windows", "Macintosh", "Linux"} {
+		if strings.Contains(ua, d) {
+			return d
+		}
+	}
+	return "unknown"
+}
+
 type UserClaims = ginx.UserClaims
 
 type RefreshClaims struct {
 	jwt.RegisteredClaims
+	// Typ 固定是 jwtx.TokenTypeRefresh，防止和短 token 混用
+	Typ    string `json:"typ"`
 	UserId int64
 	Ssid   string
 }
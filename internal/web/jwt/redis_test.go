@@ -0,0 +1,327 @@
+package jwt
+
+import (
+	"encoding/json"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"webook/pkg/jwtx"
+)
+
+// 会话的增删都在 lua 脚本里面，用 miniredis 执行真正的脚本
+
+const testUid int64 = 123
+
+func TestRedisJWTHandler_SetLoginToken(t *testing.T) {
+	testCases := []struct {
+		name        string
+		maxSessions int
+		// before 已经登录的会话，值是登录时间距离现在多久
+		before map[string]time.Duration
+
+		// wantSsids 按照登录时间排序，新登录的会话用 new 表示
+		wantSsids []string
+	}{
+		{
+			name:        "不限制设备数量",
+			maxSessions: 0,
+			before: map[string]time.Duration{
+				"ssid-1": time.Second * 3,
+				"ssid-2": time.Second * 2,
+			},
+			wantSsids: []string{"ssid-1", "ssid-2", "new"},
+		},
+		{
+			name:        "超过设备上限踢掉最早登录的",
+			maxSessions: 2,
+			before: map[string]time.Duration{
+				"ssid-1": time.Second * 2,
+				"ssid-2": time.Second * 3,
+			},
+			wantSsids: []string{"ssid-1", "new"},
+		},
+		{
+			name:        "一次踢掉多个",
+			maxSessions: 1,
+			before: map[string]time.Duration{
+				"ssid-1": time.Second * 2,
+				"ssid-2": time.Second * 3,
+			},
+			wantSsids: []string{"new"},
+		},
+		{
+			name:        "清掉长 token 过期的会话",
+			maxSessions: 0,
+			before: map[string]time.Duration{
+				"ssid-1": time.Hour * 24 * 8,
+				"ssid-2": time.Second * 2,
+			},
+			wantSsids: []string{"ssid-2", "new"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mr, rh := newTestHandler(t)
+			rh.MaxSessions(tc.maxSessions)
+			for ssid, ago := range tc.before {
+				addTestSession(t, mr, rh, ssid, time.Now().Add(-ago))
+			}
+
+			ctx, _ := newTestContext()
+			err := rh.SetLoginToken(ctx, testUid, []string{"user"})
+			require.NoError(t, err)
+			ssid := ctx.MustGet("user").(UserClaims).Ssid
+
+			order, err := mr.ZMembers(rh.orderKey(testUid))
+			require.NoError(t, err)
+			for i, s := range order {
+				if s == ssid {
+					order[i] = "new"
+				}
+			}
+			assert.Equal(t, tc.wantSsids, order)
+			// 登记表和排序用的 zset 要一致
+			ssids, err := mr.HKeys(rh.sessionsKey(testUid))
+			require.NoError(t, err)
+			assert.Equal(t, len(tc.wantSsids), len(ssids))
+			assert.Equal(t, "user", mustSession(t, mr, rh, ssid).Roles[0])
+		})
+	}
+}
+
+// 被踢掉的设备，短 token 和长 token 都不能再用了
+func TestRedisJWTHandler_MaxSessions(t *testing.T) {
+	mr, rh := newTestHandler(t)
+	rh.MaxSessions(1)
+
+	oldCtx, oldRecorder := newTestContext()
+	err := rh.SetLoginToken(oldCtx, testUid, nil)
+	require.NoError(t, err)
+	oldSsid := oldCtx.MustGet("user").(UserClaims).Ssid
+	oldRc, err := rh.VerifyRefreshToken(oldRecorder.Header().Get("x-refresh-token"))
+	require.NoError(t, err)
+	// 同一毫秒登录的话顺序不确定，把第一次登录往前挪
+	_, err = mr.ZAdd(rh.orderKey(testUid), float64(time.Now().Add(-time.Second).UnixMilli()), oldSsid)
+	require.NoError(t, err)
+
+	newCtx, _ := newTestContext()
+	err = rh.SetLoginToken(newCtx, testUid, nil)
+	require.NoError(t, err)
+	newSsid := newCtx.MustGet("user").(UserClaims).Ssid
+
+	ctx, _ := newTestContext()
+	assert.Error(t, rh.CheckSession(ctx, testUid, oldSsid))
+	assert.Equal(t, ErrSessionNotFound, rh.RotateRefreshToken(ctx, oldRc))
+	assert.NoError(t, rh.CheckSession(ctx, testUid, newSsid))
+}
+
+func TestRedisJWTHandler_RotateRefreshToken(t *testing.T) {
+	mr, rh := newTestHandler(t)
+	loginCtx, loginRecorder := newTestContext()
+	err := rh.SetLoginToken(loginCtx, testUid, nil)
+	require.NoError(t, err)
+	ssid := loginCtx.MustGet("user").(UserClaims).Ssid
+	rc, err := rh.VerifyRefreshToken(loginRecorder.Header().Get("x-refresh-token"))
+	require.NoError(t, err)
+
+	ctx, recorder := newTestContext()
+	err = rh.RotateRefreshToken(ctx, rc)
+	require.NoError(t, err)
+	newRc, err := rh.VerifyRefreshToken(recorder.Header().Get("x-refresh-token"))
+	require.NoError(t, err)
+	assert.NotEqual(t, rc.ID, newRc.ID)
+	// 沿用登录时的过期时间
+	assert.Equal(t, rc.ExpiresAt.Unix(), newRc.ExpiresAt.Unix())
+
+	// 旧的长 token 又被提交了，整个会话作废
+	ctx, _ = newTestContext()
+	assert.Equal(t, ErrRefreshTokenReused, rh.RotateRefreshToken(ctx, rc))
+	assert.Error(t, rh.CheckSession(ctx, testUid, ssid))
+	assert.False(t, mr.Exists(rh.refreshKey(testUid, ssid)))
+	assert.Equal(t, ErrSessionNotFound, rh.RotateRefreshToken(ctx, newRc))
+}
+
+func TestRedisJWTHandler_RevokeSession(t *testing.T) {
+	testCases := []struct {
+		name string
+		ssid string
+
+		wantErr   error
+		wantSsids []string
+	}{
+		{
+			name:      "退出一个设备",
+			ssid:      "ssid-1",
+			wantSsids: []string{"ssid-2"},
+		},
+		{
+			name:      "会话不存在",
+			ssid:      "ssid-3",
+			wantErr:   ErrSessionNotFound,
+			wantSsids: []string{"ssid-1", "ssid-2"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mr, rh := newTestHandler(t)
+			addTestSession(t, mr, rh, "ssid-1", time.Now().Add(-time.Second*2))
+			addTestSession(t, mr, rh, "ssid-2", time.Now().Add(-time.Second))
+
+			ctx, _ := newTestContext()
+			err := rh.RevokeSession(ctx, testUid, tc.ssid)
+			assert.Equal(t, tc.wantErr, err)
+			assertSessions(t, mr, rh, tc.wantSsids)
+			assert.False(t, mr.Exists(rh.refreshKey(testUid, tc.ssid)))
+		})
+	}
+}
+
+func TestRedisJWTHandler_RevokeOtherSessions(t *testing.T) {
+	testCases := []struct {
+		name string
+		ssid string
+
+		wantSsids []string
+	}{
+		{
+			name:      "只保留当前设备",
+			ssid:      "ssid-2",
+			wantSsids: []string{"ssid-2"},
+		},
+		{
+			// 修改密码的时候所有设备都退出登录
+			name:      "全部退出",
+			ssid:      "",
+			wantSsids: []string{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mr, rh := newTestHandler(t)
+			for i, ssid := range []string{"ssid-1", "ssid-2", "ssid-3"} {
+				addTestSession(t, mr, rh, ssid, time.Now().Add(-time.Second*time.Duration(3-i)))
+			}
+
+			ctx, _ := newTestContext()
+			err := rh.RevokeOtherSessions(ctx, testUid, tc.ssid)
+			require.NoError(t, err)
+			assertSessions(t, mr, rh, tc.wantSsids)
+			for _, ssid := range []string{"ssid-1", "ssid-2", "ssid-3"} {
+				assert.Equal(t, ssid == tc.ssid, mr.Exists(rh.refreshKey(testUid, ssid)))
+			}
+		})
+	}
+}
+
+func TestRedisJWTHandler_touchSession(t *testing.T) {
+	testCases := []struct {
+		name string
+		// revoke 读出会话之后、写回去之前被踢掉
+		revoke bool
+
+		wantOk int
+	}{
+		{
+			name:   "更新刷新时间",
+			wantOk: 1,
+		},
+		{
+			name:   "写回去之前被踢掉了",
+			revoke: true,
+			wantOk: 0,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mr, rh := newTestHandler(t)
+			addTestSession(t, mr, rh, "ssid-1", time.Now().Add(-time.Minute))
+			sess := mustSession(t, mr, rh, "ssid-1")
+
+			ctx, _ := newTestContext()
+			if tc.revoke {
+				err := rh.RevokeSession(ctx, testUid, "ssid-1")
+				require.NoError(t, err)
+			}
+			sess.RefreshTime = time.Now().UnixMilli()
+			val, err := json.Marshal(sess)
+			require.NoError(t, err)
+			ok, err := rh.cmd.Eval(ctx, luaTouchSession, []string{rh.sessionsKey(testUid)}, "ssid-1", val).Int()
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantOk, ok)
+			if tc.revoke {
+				// 不能把被踢掉的会话又加回来
+				assert.Error(t, rh.CheckSession(ctx, testUid, "ssid-1"))
+				return
+			}
+			assert.Equal(t, sess.RefreshTime, mustSession(t, mr, rh, "ssid-1").RefreshTime)
+		})
+	}
+}
+
+// 被踢掉之后再刷新短 token，也不会把会话加回来
+func TestRedisJWTHandler_SetJWTToken(t *testing.T) {
+	mr, rh := newTestHandler(t)
+	addTestSession(t, mr, rh, "ssid-1", time.Now().Add(-time.Minute))
+	ctx, _ := newTestContext()
+	err := rh.RevokeSession(ctx, testUid, "ssid-1")
+	require.NoError(t, err)
+
+	err = rh.SetJWTToken(ctx, testUid, "ssid-1")
+	require.NoError(t, err)
+	assert.Error(t, rh.CheckSession(ctx, testUid, "ssid-1"))
+	assertSessions(t, mr, rh, []string{})
+}
+
+func newTestHandler(t *testing.T) (*miniredis.Miniredis, *RedisJWTHandler) {
+	mr := miniredis.RunT(t)
+	key, err := jwtx.GenerateKey("test")
+	require.NoError(t, err)
+	rcKey, err := jwtx.GenerateKey("test-rc")
+	require.NoError(t, err)
+	cmd := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	return mr, NewRedisJWTHandler(cmd, jwtx.NewKeySet(key), jwtx.NewKeySet(rcKey))
+}
+
+func newTestContext() (*gin.Context, *httptest.ResponseRecorder) {
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/users/login", nil)
+	ctx.Request.Header.Set("User-Agent", "Mozilla/5.0 (iPhone)")
+	return ctx, recorder
+}
+
+// addTestSession 在 loginTime 登录的会话，带一个长 token
+func addTestSession(t *testing.T, mr *miniredis.Miniredis, rh *RedisJWTHandler, ssid string, loginTime time.Time) {
+	val, err := json.Marshal(Session{
+		Ssid:        ssid,
+		Device:      "iPhone",
+		LoginTime:   loginTime.UnixMilli(),
+		RefreshTime: loginTime.UnixMilli(),
+	})
+	require.NoError(t, err)
+	mr.HSet(rh.sessionsKey(testUid), ssid, string(val))
+	_, err = mr.ZAdd(rh.orderKey(testUid), float64(loginTime.UnixMilli()), ssid)
+	require.NoError(t, err)
+	mr.HSet(rh.refreshKey(testUid, ssid), "jti-"+ssid, "active")
+}
+
+func mustSession(t *testing.T, mr *miniredis.Miniredis, rh *RedisJWTHandler, ssid string) Session {
+	var sess Session
+	err := json.Unmarshal([]byte(mr.HGet(rh.sessionsKey(testUid), ssid)), &sess)
+	require.NoError(t, err)
+	return sess
+}
+
+// assertSessions 登记表和排序用的 zset 里面都只剩下 ssids
+func assertSessions(t *testing.T, mr *miniredis.Miniredis, rh *RedisJWTHandler, ssids []string) {
+	keys, _ := mr.HKeys(rh.sessionsKey(testUid))
+	assert.ElementsMatch(t, ssids, keys)
+	order, _ := mr.ZMembers(rh.orderKey(testUid))
+	assert.ElementsMatch(t, ssids, order)
+}
@@ -1,11 +1,48 @@
 package jwt
 
-import "github.com/gin-gonic/gin"
+import (
+	"github.com/gin-gonic/gin"
+	"webook/pkg/jwtx"
+)
 
 type Handler interface {
 	ClearToken(ctx *gin.Context) error
 	ExtractToken(ctx *gin.Context) string
-	SetLoginToken(ctx *gin.Context, uid int64) error
+	SetLoginToken(ctx *gin.Context, uid int64, roles []string) error
 	SetJWTToken(ctx *gin.Context, uid int64, ssid string) error
-	CheckSession(ctx *gin.Context, ssid string) error
+	// RotateRefreshToken 换一个新的长 token，同时签发短 token
+	// 长 token 被重复使用的时候返回 ErrRefreshTokenReused，会话已经退出登录的时候返回 ErrSessionNotFound
+	RotateRefreshToken(ctx *gin.Context, rc RefreshClaims) error
+	CheckSession(ctx *gin.Context, uid int64, ssid string) error
+	// VerifyToken 验证短 token 的签名和有效期
+	VerifyToken(tokenStr string) (UserClaims, error)
+	VerifyRefreshToken(tokenStr string) (RefreshClaims, error)
+	// JWKS 短 token 的公钥
+	JWKS() jwtx.JWKS
+
+	// ListSessions 用户当前登录的所有设备
+	ListSessions(ctx *gin.Context, uid int64) ([]Session, error)
+	// RevokeSession 让某一个设备退出登录，会话不存在的时候返回 ErrSessionNotFound
+	RevokeSession(ctx *gin.Context, uid int64, ssid string) error
+	RevokeOtherSessions(ctx *gin.Context, uid int64, ssid string) error
+	// RevokeAllSessions 所有设备都退出登录，例如修改了密码
+	RevokeAllSessions(ctx *gin.Context, uid int64) error
+
+	// SetMfaPendingToken 开启了两步验证的用户输完密码之后，拿到的是临时 token 而不是 JWT
+	SetMfaPendingToken(ctx *gin.Context, uid int64) error
+	// CheckMfaPendingToken 临时 token 无效的时候返回 ErrMfaTokenInvalid
+	CheckMfaPendingToken(ctx *gin.Context, token string) (int64, error)
+	ClearMfaPendingToken(ctx *gin.Context, token string) error
+}
+
+// Session 一次登录，时间都是毫秒数
+type Session struct {
+	Ssid        string `json:"ssid"`
+	Device      string `json:"device"`
+	UserAgent   string `json:"userAgent"`
+	IP          string `json:"ip"`
+	LoginTime   int64  `json:"loginTime"`
+	RefreshTime int64  `json:"refreshTime"`
+	// Roles 登录时的角色
+	Roles []string `json:"roles,omitempty"`
 }
@@ -0,0 +1,85 @@
+package web
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"webook/internal/domain"
+	"webook/internal/errs"
+	"webook/internal/service"
+	ijwt "webook/internal/web/jwt"
+	"webook/pkg/ginx"
+)
+
+// MediaHandler 上传头像和文章图片，内容由客户端直接传到对象存储
+type MediaHandler struct {
+	svc service.MediaService
+}
+
+func NewMediaHandler(svc service.MediaService) *MediaHandler {
+	return &MediaHandler{
+		svc: svc,
+	}
+}
+
+func (h *MediaHandler) RegisterRoutes(server *gin.Engine, registry *ginx.Registry) {
+	g := registry.Group(server.Group("/media"), ginx.Authenticated())
+	g.POST("/upload", ginx.WrapClaimsAndReq[UploadMediaReq](h.Upload))
+	g.POST("/confirm", ginx.WrapClaimsAndReq[ConfirmMediaReq](h.Confirm))
+}
+
+func (h *MediaHandler) Upload(ctx *gin.Context, req UploadMediaReq, uc ijwt.UserClaims) (ginx.Result, error) {
+	m, uploadURL, err := h.svc.Upload(ctx, uc.UserId, domain.MediaBiz(req.Biz), domain.Media{
+		Hash:        req.Hash,
+		Size:        req.Size,
+		ContentType: req.ContentType,
+	})
+	if err != nil {
+		return h.mediaResult(err)
+	}
+	return ginx.Result{
+		Data: MediaVo{
+			Id:        m.Id,
+			URL:       m.URL,
+			UploadURL: uploadURL,
+		},
+	}, nil
+}
+
+func (h *MediaHandler) Confirm(ctx *gin.Context, req ConfirmMediaReq, uc ijwt.UserClaims) (ginx.Result, error) {
+	m, err := h.svc.Confirm(ctx, uc.UserId, req.Id)
+	if err != nil {
+		return h.mediaResult(err)
+	}
+	return ginx.Result{
+		Msg: "上传成功",
+		Data: MediaVo{
+			Id:  m.Id,
+			URL: m.URL,
+		},
+	}, nil
+}
+
+func (h *MediaHandler) mediaResult(err error) (ginx.Result, error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidMedia):
+		return ginx.Result{
+			Code: errs.MediaInvalidInput,
+			Msg:  "不支持的文件类型，或者文件太大",
+		}, nil
+	case errors.Is(err, service.ErrMediaNotUploaded):
+		return ginx.Result{
+			Code: errs.MediaNotUploaded,
+			Msg:  "文件还没有上传",
+		}, nil
+	case errors.Is(err, service.ErrMediaMismatch):
+		return ginx.Result{
+			Code: errs.MediaNotUploaded,
+			Msg:  "上传的内容和声明的不一致，请重新上传",
+		}, nil
+	default:
+		return ginx.Result{
+			Code: errs.MediaInternalServerError,
+			Msg:  "系统错误",
+		}, err
+	}
+}
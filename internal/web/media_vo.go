@@ -0,0 +1,22 @@
+package web
+
+type UploadMediaReq struct {
+	// Biz avatar 或者 article
+	Biz string `json:"biz"`
+	// Hash 内容的 SHA-256，十六进制
+	Hash        string `json:"hash"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"contentType"`
+}
+
+type ConfirmMediaReq struct {
+	Id int64 `json:"id"`
+}
+
+type MediaVo struct {
+	Id  int64  `json:"id"`
+	URL string `json:"url"`
+	// UploadURL 为空说明同样的内容已经上传过了，直接用 URL
+	// 否则 PUT 到这个地址，Content-Type 和声明的一样，然后调用 /media/confirm
+	UploadURL string `json:"uploadURL,omitempty"`
+}
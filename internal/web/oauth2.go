@@ -0,0 +1,235 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"time"
+	"webook/internal/domain"
+	"webook/internal/errs"
+	"webook/internal/service"
+	"webook/internal/service/oauth2"
+	ijwt "webook/internal/web/jwt"
+	"webook/pkg/ginx"
+)
+
+// OAuth2Handler 所有第三方登录共用，平台由路径里面的 :provider 决定
+// state 和 PKCE 的 code_verifier 放在签名的 cookie 里面，回调的时候校验
+type OAuth2Handler struct {
+	ijwt.Handler
+	providers       *oauth2.Registry
+	uSvc            service.UserService
+	bindingSvc      service.BindingService
+	key             []byte
+	stateCookieName string
+}
+
+func NewOAuth2Handler(providers *oauth2.Registry,
+	hdl ijwt.Handler,
+	uSvc service.UserService,
+	bindingSvc service.BindingService) *OAuth2Handler {
+	return &OAuth2Handler{
+		providers:       providers,
+		uSvc:            uSvc,
+		bindingSvc:      bindingSvc,
+		key:             []byte("pBnSDaa0oCypBlPSpSoATWB4VZIS9niB"),
+		stateCookieName: "jwt-state",
+		Handler:         hdl,
+	}
+}
+
+func (o *OAuth2Handler) RegisterRoutes(server *gin.Engine, registry *ginx.Registry) {
+	g := server.Group("/oauth2")
+	pub := registry.Group(g, ginx.Public())
+	pub.GET("/providers", o.Providers)
+	pub.GET("/:provider/authurl", o.Auth2Url)
+	pub.Any("/:provider/callback", o.CallBack)
+	// 已经登录的用户绑定第三方账号，回调还是 /callback
+	registry.Group(g, ginx.Authenticated()).
+		GET("/:provider/bind/authurl", ginx.WrapClaims(o.BindAuth2Url))
+}
+
+// Providers 前端根据这个展示登录按钮
+func (o *OAuth2Handler) Providers(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, Result{
+		Data: o.providers.Names(),
+	})
+}
+
+func (o *OAuth2Handler) Auth2Url(ctx *gin.Context) {
+	res, err := o.authURL(ctx, 0)
+	if err != nil {
+		ctx.Error(err)
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// BindAuth2Url 和 Auth2Url 一样，只是 state 里面带上了当前用户，回调的时候绑定而不是登录
+func (o *OAuth2Handler) BindAuth2Url(ctx *gin.Context, uc ijwt.UserClaims) (ginx.Result, error) {
+	return o.authURL(ctx, uc.UserId)
+}
+
+func (o *OAuth2Handler) authURL(ctx *gin.Context, uid int64) (ginx.Result, error) {
+	p, ok := o.providers.Get(ctx.Param("provider"))
+	if !ok {
+		return ginx.Result{
+			Msg:  "不支持的登录方式",
+			Code: errs.UserInvalidInput,
+		}, nil
+	}
+	state, err := oauth2.NewState()
+	if err != nil {
+		return ginx.Result{
+			Msg:  "服务器异常",
+			Code: errs.UserInternalServerError,
+		}, err
+	}
+	verifier := oauth2.NewVerifier()
+	url, err := p.AuthURL(ctx, state, oauth2.Challenge(verifier))
+	if err != nil {
+		return ginx.Result{
+			Msg:  "构造跳转URL失败",
+			Code: errs.UserInternalServerError,
+		}, err
+	}
+	err = o.setStateCookie(ctx, StateClaims{
+		State:    state,
+		Provider: p.Name(),
+		Verifier: verifier,
+		Uid:      uid,
+	})
+	if err != nil {
+		return ginx.Result{
+			Msg:  "服务器异常",
+			Code: errs.UserInternalServerError,
+		}, err
+	}
+	return ginx.Result{
+		Data: url,
+	}, nil
+}
+
+func (o *OAuth2Handler) CallBack(ctx *gin.Context) {
+	p, ok := o.providers.Get(ctx.Param("provider"))
+	if !ok {
+		ctx.JSON(http.StatusOK, Result{
+			Msg:  "不支持的登录方式",
+			Code: 4,
+		})
+		return
+	}
+	sc, err := o.verifyStateCookie(ctx, p.Name())
+	if err != nil {
+		ctx.Error(err)
+		ctx.JSON(http.StatusOK, Result{
+			Msg:  "非法请求",
+			Code: 4,
+		})
+		return
+	}
+	// state 只能用一次
+	ctx.SetCookie(o.stateCookieName, "", -1, o.cookiePath(p.Name()), "", false, true)
+	code := ctx.Query("code")
+	identity, err := p.VerifyCode(ctx, code, sc.Verifier)
+	if err != nil {
+		ctx.Error(err)
+		ctx.JSON(http.StatusOK, Result{
+			Msg:  "通过 code 获取 access_token 失败",
+			Code: 4,
+		})
+		return
+	}
+	if sc.Uid > 0 {
+		o.bind(ctx, sc.Uid, identity)
+		return
+	}
+	u, err := o.uSvc.FindOrCreateByIdentity(ctx, identity)
+	if err != nil {
+		ctx.Error(err)
+		ctx.JSON(http.StatusOK, Result{
+			Msg:  "系统错误",
+			Code: 5,
+		})
+		return
+	}
+	err = o.SetLoginToken(ctx, u.Id, u.Roles)
+	if err != nil {
+		ctx.String(http.StatusOK, "系统错误")
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Msg: "登录成功",
+	})
+}
+
+func (o *OAuth2Handler) bind(ctx *gin.Context, uid int64, identity domain.Identity) {
+	err := o.bindingSvc.BindIdentity(ctx, uid, identity)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "绑定成功",
+		})
+	case errors.Is(err, service.ErrBindingConflict):
+		ctx.JSON(http.StatusOK, Result{
+			Msg:  "这个账号已经绑定了其它用户，或者你已经绑定过了",
+			Code: errs.UserBindingConflict,
+		})
+	default:
+		ctx.Error(err)
+		ctx.JSON(http.StatusOK, Result{
+			Msg:  "系统错误",
+			Code: 5,
+		})
+	}
+}
+
+func (o *OAuth2Handler) verifyStateCookie(ctx *gin.Context, provider string) (StateClaims, error) {
+	state := ctx.Query("state")
+	ck, err := ctx.Cookie(o.stateCookieName)
+	if err != nil {
+		return StateClaims{}, fmt.Errorf("无法获得 cookie %w", err)
+	}
+	var sc StateClaims
+	_, err = jwt.ParseWithClaims(ck, &sc, func(token *jwt.Token) (interface{}, error) {
+		return o.key, nil
+	})
+
+	if err != nil {
+		return StateClaims{}, fmt.Errorf("解析 token 失败 %w", err)
+	}
+	if state != sc.State {
+		return StateClaims{}, errors.New("state 不匹配")
+	}
+	if provider != sc.Provider {
+		return StateClaims{}, errors.New("provider 不匹配")
+	}
+	return sc, nil
+}
+
+func (o *OAuth2Handler) setStateCookie(ctx *gin.Context, claims StateClaims) error {
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Minute * 10))
+	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
+
+	tokenStr, err := token.SignedString(o.key)
+	if err != nil {
+		return err
+	}
+	ctx.SetCookie(o.stateCookieName, tokenStr, 600, o.cookiePath(claims.Provider), "", false, true)
+	return nil
+}
+
+func (o *OAuth2Handler) cookiePath(provider string) string {
+	return "/oauth2/" + provider + "/callback"
+}
+
+type StateClaims struct {
+	jwt.RegisteredClaims
+	State    string
+	Provider string
+	// Verifier PKCE 的 code_verifier
+	Verifier string
+	// Uid 绑定第三方账号的用户，登录的时候是 0
+	Uid int64
+}
@@ -1,9 +1,13 @@
 package web
 
-import "github.com/gin-gonic/gin"
+import (
+	"github.com/gin-gonic/gin"
+	"webook/pkg/ginx"
+)
 
 type Handler interface {
-	RegisterRoutes(server *gin.Engine)
+	// RegisterRoutes 注册路由的时候通过 registry 声明每个路由的访问策略
+	RegisterRoutes(server *gin.Engine, registry *ginx.Registry)
 }
 
 type Page struct {
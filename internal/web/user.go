@@ -3,11 +3,11 @@
 package web
 
 import (
+	"errors"
 	"fmt"
 	regexp "github.com/dlclark/regexp2"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"net/http"
 	"strings"
 	"webook/internal/domain"
@@ -31,45 +31,100 @@ type UserHandler struct {
 	passwordRegExp *regexp.Regexp
 	svc            service.UserService
 	codeSvc        service.CodeService
+	accountSvc     service.AccountService
+	totpSvc        service.TotpService
+	bindingSvc     service.BindingService
+	guardSvc       service.LoginGuardService
+	mediaSvc       service.MediaService
 
 	//l logger.LoggerV1
 }
 
 func NewUserHandler(svc service.UserService,
 	hdl ijwt.Handler,
-	codeSvc service.CodeService) *UserHandler {
+	codeSvc service.CodeService,
+	accountSvc service.AccountService,
+	totpSvc service.TotpService,
+	bindingSvc service.BindingService,
+	guardSvc service.LoginGuardService,
+	mediaSvc service.MediaService) *UserHandler {
 	return &UserHandler{
 		emailRegExp:    regexp.MustCompile(emailRegexPattern, regexp.None),
 		passwordRegExp: regexp.MustCompile(passwordRegexPattern, regexp.None),
 		svc:            svc,
 		codeSvc:        codeSvc,
+		accountSvc:     accountSvc,
+		totpSvc:        totpSvc,
+		bindingSvc:     bindingSvc,
+		guardSvc:       guardSvc,
+		mediaSvc:       mediaSvc,
 		Handler:        hdl,
 
 		//l: l,
 	}
 }
 
-func (h *UserHandler) RegisterRoutes(server *gin.Engine) {
+func (h *UserHandler) RegisterRoutes(server *gin.Engine, registry *ginx.Registry) {
 	//server.POST("/users/login", h.Login)
 	//server.POST("/users/signup", h.SignUp)
 	//server.POST("/users/edit", h.Edit)
 	//server.GET("/users/profile", h.Profile)
 
+	// gRPC 服务从这里拿短 token 的公钥
+	registry.Group(server, ginx.Public()).GET("/.well-known/jwks.json", h.JWKS)
+
 	ug := server.Group("/users")
+	// 不需要登录的
+	pub := registry.Group(ug, ginx.Public())
 	//ug.POST("/login", h.Login)
-	ug.POST("/login", h.LoginJWT)
-	ug.POST("/logout", h.LogoutJWT)
-	ug.POST("/signup", ginx.WrapReq[SignUpReq](h.SignUp))
+	pub.POST("/login", h.LoginJWT)
+	// 开启了两步验证的，拿登录返回的临时 token 和动态码换 JWT
+	pub.POST("/login/mfa", ginx.WrapReq[LoginMfaReq](h.LoginMfa))
+	pub.POST("/signup", ginx.WrapReq[SignUpReq](h.SignUp))
+	// 带的是长 token，由 RefreshToken 自己校验
+	pub.GET("/refresh_token", h.RefreshToken)
+	// 手机验证码登录相关
+	pub.POST("/login_sms/code/send", h.SendSMSLoginCode)
+	pub.POST("/login_sms", h.LoginSMS)
+	// 登录失败太多被锁定的账号，用绑定的手机号解锁
+	pub.POST("/login/unlock/code/send", ginx.WrapReq[SendUnlockCodeReq](h.SendUnlockCode))
+	pub.POST("/login/unlock", ginx.WrapReq[UnlockReq](h.Unlock))
+	// 邮件里面的链接跳到前端页面，前端再调用这两个接口
+	pub.POST("/email/verify", ginx.WrapReq[VerifyEmailReq](h.VerifyEmail))
+	pub.POST("/password/reset/send", ginx.WrapReq[SendResetPasswordReq](h.SendResetPasswordEmail))
+	pub.POST("/password/reset", ginx.WrapReq[ResetPasswordReq](h.ResetPassword))
+
+	authed := registry.Group(ug, ginx.Authenticated())
+	authed.POST("/logout", h.LogoutJWT)
 	//ug.POST("/edit", h.Edit)
-	ug.POST("/edit", h.EditJWT)
+	authed.POST("/edit", h.EditJWT)
 	//ug.GET("/profile", h.Profile)
-	ug.GET("/profile", h.ProfileJWT)
-	ug.GET("/refresh_token", h.RefreshToken)
-
-	// 手机验证码登录相关
-	ug.POST("/login_sms/code/send", h.SendSMSLoginCode)
-	ug.POST("/login_sms", h.LoginSMS)
-
+	authed.GET("/profile", h.ProfileJWT)
+	// 图片先通过 /media/upload 上传
+	authed.POST("/avatar", ginx.WrapClaimsAndReq[SetAvatarReq](h.SetAvatar))
+
+	// 多设备登录管理
+	authed.GET("/sessions", ginx.WrapClaims(h.ListSessions))
+	authed.POST("/sessions/revoke", ginx.WrapClaimsAndReq[RevokeSessionReq](h.RevokeSession))
+	authed.POST("/sessions/revoke_others", ginx.WrapClaims(h.RevokeOtherSessions))
+
+	// 邮箱和密码
+	authed.POST("/email/verify/send", ginx.WrapClaims(h.SendVerifyEmail))
+	authed.POST("/password/change", ginx.WrapClaimsAndReq[ChangePasswordReq](h.ChangePassword))
+
+	// 两步验证
+	authed.POST("/totp/enroll", ginx.WrapClaims(h.EnrollTotp))
+	authed.POST("/totp/confirm", ginx.WrapClaimsAndReq[TotpCodeReq](h.ConfirmTotp))
+	authed.POST("/totp/disable", ginx.WrapClaimsAndReq[TotpCodeReq](h.DisableTotp))
+
+	// 绑定和解绑登录方式，绑定第三方登录在 OAuth2Handler 里面
+	authed.GET("/bindings", ginx.WrapClaims(h.Bindings))
+	authed.POST("/bind/email/send", ginx.WrapClaimsAndReq[SendBindEmailReq](h.SendBindEmail))
+	authed.POST("/bind/email", ginx.WrapClaimsAndReq[BindEmailReq](h.BindEmail))
+	authed.POST("/email/change", ginx.WrapClaimsAndReq[ChangeEmailReq](h.ChangeEmail))
+	authed.POST("/bind/phone/code/send", ginx.WrapClaimsAndReq[SendBindPhoneCodeReq](h.SendBindPhoneCode))
+	authed.POST("/bind/phone", ginx.WrapClaimsAndReq[BindPhoneReq](h.BindPhone))
+	authed.POST("/unbind", ginx.WrapClaimsAndReq[UnbindReq](h.Unbind))
 }
 
 func (h *UserHandler) SendSMSLoginCode(ctx *gin.Context) {
@@ -121,6 +176,9 @@ func (h *UserHandler) LoginSMS(ctx *gin.Context) {
 		ctx.Error(err)
 		return
 	}
+	if !h.checkLoginGuard(ctx, req.Phone) {
+		return
+	}
 	ok, err := h.codeSvc.Verify(ctx, bizLogin, req.Phone, req.Code)
 	if err != nil {
 		ctx.Error(err)
@@ -135,12 +193,14 @@ func (h *UserHandler) LoginSMS(ctx *gin.Context) {
 		return
 	}
 	if !ok {
+		h.loginFailed(ctx, req.Phone)
 		ctx.JSON(http.StatusOK, Result{
 			Code: 4,
 			Msg:  "验证码不对，请重新输入",
 		})
 		return
 	}
+	h.loginSucceeded(ctx, req.Phone)
 	// 因为需求：手机号第一次登录需要自动注册，所以需要一个新的方法
 	u, err := h.svc.FindOrCreate(ctx, req.Phone)
 	if err != nil {
@@ -151,7 +211,7 @@ func (h *UserHandler) LoginSMS(ctx *gin.Context) {
 		})
 		return
 	}
-	err = h.SetLoginToken(ctx, u.Id)
+	err = h.SetLoginToken(ctx, u.Id, u.Roles)
 	if err != nil {
 		ctx.Error(err)
 		ctx.String(http.StatusOK, "系统错误")
@@ -211,12 +271,21 @@ func (h *UserHandler) LoginJWT(ctx *gin.Context) {
 		ctx.Error(err)
 		return
 	}
+	if !h.checkLoginGuard(ctx, req.Email) {
+		return
+	}
 
 	u, err := h.svc.Login(ctx, req.Email, req.Password)
 
 	switch err {
 	case nil:
-		err = h.SetLoginToken(ctx, u.Id)
+		if u.Totp.Enabled {
+			// 动态码也通过之后才算登录成功，在 LoginMfa 里面清掉失败次数
+			h.requireMfa(ctx, u.Id)
+			return
+		}
+		h.loginSucceeded(ctx, req.Email)
+		err = h.SetLoginToken(ctx, u.Id, u.Roles)
 		if err != nil {
 			ctx.Error(err)
 			ctx.String(http.StatusOK, "系统错误")
@@ -225,6 +294,7 @@ func (h *UserHandler) LoginJWT(ctx *gin.Context) {
 		ctx.String(http.StatusOK, "登录成功")
 	case service.ErrInvalidUserOrPassword:
 		ctx.Error(err)
+		h.loginFailed(ctx, req.Email)
 		ctx.String(http.StatusOK, "用户不存在或密码错误")
 	default:
 		ctx.Error(err)
@@ -257,6 +327,7 @@ func (h *UserHandler) ProfileJWT(ctx *gin.Context) {
 		Phone    string
 		Birthday string
 		AboutMe  string
+		Avatar   string
 	}
 	uc := ctx.MustGet("user").(ijwt.UserClaims)
 	u, err := h.svc.GetUserInfo(ctx, uc.UserId)
@@ -267,12 +338,39 @@ func (h *UserHandler) ProfileJWT(ctx *gin.Context) {
 			Email:    u.Email,
 			Birthday: u.Birthday,
 			AboutMe:  u.AboutMe,
+			Avatar:   u.Avatar,
 		})
 	default:
 		ctx.String(http.StatusOK, "系统错误")
 	}
 }
 
+func (h *UserHandler) SetAvatar(ctx *gin.Context, req SetAvatarReq, uc ijwt.UserClaims) (ginx.Result, error) {
+	avatar, err := h.mediaSvc.SetAvatar(ctx, uc.UserId, req.MediaId)
+	switch {
+	case err == nil:
+		return ginx.Result{
+			Msg:  "修改成功",
+			Data: avatar,
+		}, nil
+	case errors.Is(err, service.ErrMediaNotUploaded):
+		return ginx.Result{
+			Code: errs.UserInvalidInput,
+			Msg:  "图片还没有上传",
+		}, nil
+	case errors.Is(err, service.ErrInvalidMedia):
+		return ginx.Result{
+			Code: errs.UserInvalidInput,
+			Msg:  "头像只能是 2MB 以内的 JPEG、PNG 或者 WebP 图片",
+		}, nil
+	default:
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误",
+		}, err
+	}
+}
+
 func (h *UserHandler) Profile(ctx *gin.Context) {
 	type UserInfoResp struct {
 		Nickname string
@@ -457,36 +555,398 @@ func (h *UserHandler) EditJWT(ctx *gin.Context) {
 
 func (h *UserHandler) RefreshToken(ctx *gin.Context) {
 	tokenStr := h.ExtractToken(ctx)
-	var rc ijwt.RefreshClaims
-	token, err := jwt.ParseWithClaims(tokenStr, &rc, func(token *jwt.Token) (interface{}, error) {
-		return ijwt.RCJWTKey, nil
-	})
+	rc, err := h.VerifyRefreshToken(tokenStr)
 	if err != nil {
 		ctx.Error(err)
 		ctx.AbortWithStatus(http.StatusUnauthorized)
 		return
 	}
-	if token == nil || token.Valid {
-		ctx.AbortWithStatus(http.StatusUnauthorized)
-		return
-	}
 
-	// 是否已经退出登录的校验
-	err = h.CheckSession(ctx, rc.Ssid)
+	// 顺便校验了是否已经退出登录，以及长 token 是否被重复使用
+	err = h.RotateRefreshToken(ctx, rc)
 	if err != nil {
 		ctx.Error(err)
 		ctx.AbortWithStatus(http.StatusUnauthorized)
 		return
 	}
+	ctx.JSON(http.StatusOK, Result{
+		Msg: "OK",
+	})
+}
+
+func (h *UserHandler) JWKS(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, h.Handler.JWKS())
+}
+
+// ListSessions 当前登录的所有设备，Current 标记出发起请求的这一个
+func (h *UserHandler) ListSessions(ctx *gin.Context, uc ijwt.UserClaims) (ginx.Result, error) {
+	sessions, err := h.Handler.ListSessions(ctx, uc.UserId)
+	if err != nil {
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误",
+		}, err
+	}
+	res := make([]SessionVo, 0, len(sessions))
+	for _, sess := range sessions {
+		res = append(res, SessionVo{
+			Session: sess,
+			Current: sess.Ssid == uc.Ssid,
+		})
+	}
+	return ginx.Result{
+		Data: res,
+	}, nil
+}
+
+func (h *UserHandler) RevokeSession(ctx *gin.Context, req RevokeSessionReq, uc ijwt.UserClaims) (ginx.Result, error) {
+	if req.Ssid == uc.Ssid {
+		return ginx.Result{
+			Code: errs.UserInvalidInput,
+			Msg:  "当前设备请直接退出登录",
+		}, nil
+	}
+	err := h.Handler.RevokeSession(ctx, uc.UserId, req.Ssid)
+	switch {
+	case err == nil:
+		return ginx.Result{
+			Msg: "OK",
+		}, nil
+	case errors.Is(err, ijwt.ErrSessionNotFound):
+		return ginx.Result{
+			Code: errs.UserInvalidInput,
+			Msg:  "该设备已经退出登录",
+		}, nil
+	default:
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误",
+		}, err
+	}
+}
+
+// RevokeOtherSessions 除了当前设备，其它设备都退出登录
+func (h *UserHandler) RevokeOtherSessions(ctx *gin.Context, uc ijwt.UserClaims) (ginx.Result, error) {
+	err := h.Handler.RevokeOtherSessions(ctx, uc.UserId, uc.Ssid)
+	if err != nil {
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误",
+		}, err
+	}
+	return ginx.Result{
+		Msg: "OK",
+	}, nil
+}
+
+func (h *UserHandler) SendVerifyEmail(ctx *gin.Context, uc ijwt.UserClaims) (ginx.Result, error) {
+	err := h.accountSvc.SendVerifyEmail(ctx, uc.UserId)
+	switch {
+	case err == nil:
+		return ginx.Result{
+			Msg: "发送成功",
+		}, nil
+	case errors.Is(err, service.ErrNoEmail):
+		return ginx.Result{
+			Code: errs.UserInvalidInput,
+			Msg:  "还没有绑定邮箱",
+		}, nil
+	case errors.Is(err, service.ErrTokenSendTooMany):
+		return ginx.Result{
+			Code: errs.UserTooManyRequests,
+			Msg:  "发送太频繁，请稍后再试",
+		}, nil
+	default:
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误",
+		}, err
+	}
+}
+
+func (h *UserHandler) VerifyEmail(ctx *gin.Context, req VerifyEmailReq) (ginx.Result, error) {
+	err := h.accountSvc.VerifyEmail(ctx, req.Token)
+	switch {
+	case err == nil:
+		return ginx.Result{
+			Msg: "邮箱验证成功",
+		}, nil
+	case errors.Is(err, service.ErrInvalidToken):
+		return ginx.Result{
+			Code: errs.UserInvalidToken,
+			Msg:  "链接无效或者已经过期",
+		}, nil
+	default:
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误",
+		}, err
+	}
+}
+
+// SendResetPasswordEmail 不管邮箱有没有注册，都提示发送成功
+func (h *UserHandler) SendResetPasswordEmail(ctx *gin.Context, req SendResetPasswordReq) (ginx.Result, error) {
+	isEmail, err := h.emailRegExp.MatchString(req.Email)
+	if err != nil {
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误，邮箱匹配超时",
+		}, err
+	}
+	if !isEmail {
+		return ginx.Result{
+			Code: errs.UserInvalidInput,
+			Msg:  "非法邮箱格式",
+		}, nil
+	}
+	err = h.accountSvc.SendResetPasswordEmail(ctx, req.Email)
+	switch {
+	// 只有注册过的邮箱才会触发频率限制，所以发送太频繁也返回一样的结果，不然就暴露了邮箱是否注册过
+	case err == nil, errors.Is(err, service.ErrTokenSendTooMany):
+		return ginx.Result{
+			Msg: "如果该邮箱已经注册，你会收到一封重置密码的邮件",
+		}, nil
+	default:
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误",
+		}, err
+	}
+}
+
+// ResetPassword 重置成功之后所有设备都要重新登录
+func (h *UserHandler) ResetPassword(ctx *gin.Context, req ResetPasswordReq) (ginx.Result, error) {
+	res, err := h.checkPassword(req.Password, req.ConfirmPassword)
+	if res.Code != 0 {
+		return res, err
+	}
+	uid, err := h.accountSvc.ResetPassword(ctx, req.Token, req.Password)
+	switch {
+	case err == nil:
+		return h.revokeAllSessions(ctx, uid, "密码已重置，请重新登录")
+	case errors.Is(err, service.ErrInvalidToken):
+		return ginx.Result{
+			Code: errs.UserInvalidToken,
+			Msg:  "链接无效或者已经过期",
+		}, nil
+	default:
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误",
+		}, err
+	}
+}
+
+// ChangePassword 修改成功之后所有设备都要重新登录，包括当前设备
+func (h *UserHandler) ChangePassword(ctx *gin.Context, req ChangePasswordReq, uc ijwt.UserClaims) (ginx.Result, error) {
+	res, err := h.checkPassword(req.Password, req.ConfirmPassword)
+	if res.Code != 0 {
+		return res, err
+	}
+	err = h.accountSvc.ChangePassword(ctx, uc.UserId, req.OldPassword, req.Password)
+	switch {
+	case err == nil:
+		return h.revokeAllSessions(ctx, uc.UserId, "密码已修改，请重新登录")
+	case errors.Is(err, service.ErrInvalidUserOrPassword):
+		return ginx.Result{
+			Code: errs.UserInvalidOrPassword,
+			Msg:  "原密码不对",
+		}, nil
+	default:
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误",
+		}, err
+	}
+}
 
-	err = h.SetJWTToken(ctx, rc.UserId, rc.Ssid)
+func (h *UserHandler) revokeAllSessions(ctx *gin.Context, uid int64, msg string) (ginx.Result, error) {
+	err := h.Handler.RevokeAllSessions(ctx, uid)
+	if err != nil {
+		// 密码已经改了，但是旧的会话还在，需要用户自己去退出其它设备
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "密码已修改，但是其它设备退出登录失败，请稍后在设备管理里面操作",
+		}, err
+	}
+	ctx.Header("x-jwt-token", "")
+	ctx.Header("x-refresh-token", "")
+	return ginx.Result{
+		Msg: msg,
+	}, nil
+}
 
+// checkPassword 密码不合法的时候，返回的 Result.Code 不为 0
+func (h *UserHandler) checkPassword(password, confirmPassword string) (ginx.Result, error) {
+	isPassword, err := h.passwordRegExp.MatchString(password)
+	if err != nil {
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误：密码匹配超时",
+		}, err
+	}
+	if !isPassword {
+		return ginx.Result{
+			Code: errs.UserInvalidInput,
+			Msg:  "密码格式不对：密码必须包含字母、数字、特殊字符，并且长度不能小于 8 位",
+		}, nil
+	}
+	if password != confirmPassword {
+		return ginx.Result{
+			Code: errs.UserInvalidInput,
+			Msg:  "两次输入密码不同",
+		}, nil
+	}
+	return ginx.Result{}, nil
+}
+
+// requireMfa 密码正确，但是还不能签发 JWT，要先输入动态码
+func (h *UserHandler) requireMfa(ctx *gin.Context, uid int64) {
+	err := h.SetMfaPendingToken(ctx, uid)
 	if err != nil {
 		ctx.Error(err)
-		ctx.AbortWithStatus(http.StatusUnauthorized)
+		ctx.JSON(http.StatusOK, Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误",
+		})
 		return
 	}
 	ctx.JSON(http.StatusOK, Result{
-		Msg: "OK",
+		Code: errs.UserMfaRequired,
+		Msg:  "需要两步验证",
 	})
 }
+
+func (h *UserHandler) LoginMfa(ctx *gin.Context, req LoginMfaReq) (ginx.Result, error) {
+	uid, err := h.CheckMfaPendingToken(ctx, req.Token)
+	switch {
+	case err == nil:
+	case errors.Is(err, ijwt.ErrMfaTokenInvalid):
+		return ginx.Result{
+			Code: errs.UserInvalidToken,
+			Msg:  "两步验证已经过期，请重新登录",
+		}, nil
+	default:
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误",
+		}, err
+	}
+	// 角色要从最新的用户信息里面拿，邮箱用来计数动态码输错的次数
+	u, err := h.svc.GetUserInfo(ctx, uid)
+	if err != nil {
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误",
+		}, err
+	}
+	// 动态码输错和密码输错一样计入登录失败的次数
+	res, err := h.loginGuard(ctx, u.Email)
+	if res.Code != 0 {
+		return res, err
+	}
+	err = h.totpSvc.Verify(ctx, uid, req.Code)
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrTotpInvalidCode):
+		h.loginFailed(ctx, u.Email)
+		return ginx.Result{
+			Code: errs.UserInvalidMfaCode,
+			Msg:  "动态码或者恢复码不对",
+		}, nil
+	default:
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误",
+		}, err
+	}
+	h.loginSucceeded(ctx, u.Email)
+	err = h.SetLoginToken(ctx, uid, u.Roles)
+	if err != nil {
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误",
+		}, err
+	}
+	// 已经登录成功了，临时 token 没删掉也会自己过期
+	_ = h.ClearMfaPendingToken(ctx, req.Token)
+	return ginx.Result{
+		Msg: "登录成功",
+	}, nil
+}
+
+// EnrollTotp 返回密钥和二维码内容，用户扫码之后调用 ConfirmTotp 才算开启
+func (h *UserHandler) EnrollTotp(ctx *gin.Context, uc ijwt.UserClaims) (ginx.Result, error) {
+	secret, uri, err := h.totpSvc.Enroll(ctx, uc.UserId)
+	switch {
+	case err == nil:
+		return ginx.Result{
+			Data: TotpEnrollVo{
+				Secret: secret,
+				URI:    uri,
+			},
+		}, nil
+	case errors.Is(err, service.ErrTotpAlreadyEnabled):
+		return ginx.Result{
+			Code: errs.UserInvalidInput,
+			Msg:  "已经开启了两步验证",
+		}, nil
+	default:
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误",
+		}, err
+	}
+}
+
+// ConfirmTotp 返回的恢复码只有这一次机会看到
+func (h *UserHandler) ConfirmTotp(ctx *gin.Context, req TotpCodeReq, uc ijwt.UserClaims) (ginx.Result, error) {
+	codes, err := h.totpSvc.Confirm(ctx, uc.UserId, req.Code)
+	switch {
+	case err == nil:
+		return ginx.Result{
+			Msg:  "两步验证已开启，请保存好恢复码",
+			Data: codes,
+		}, nil
+	case errors.Is(err, service.ErrTotpInvalidCode):
+		return ginx.Result{
+			Code: errs.UserInvalidMfaCode,
+			Msg:  "动态码不对",
+		}, nil
+	case errors.Is(err, service.ErrTotpAlreadyEnabled), errors.Is(err, service.ErrTotpNotEnrolled):
+		return ginx.Result{
+			Code: errs.UserInvalidInput,
+			Msg:  err.Error(),
+		}, nil
+	default:
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误",
+		}, err
+	}
+}
+
+func (h *UserHandler) DisableTotp(ctx *gin.Context, req TotpCodeReq, uc ijwt.UserClaims) (ginx.Result, error) {
+	err := h.totpSvc.Disable(ctx, uc.UserId, req.Code)
+	switch {
+	case err == nil:
+		return ginx.Result{
+			Msg: "两步验证已关闭",
+		}, nil
+	case errors.Is(err, service.ErrTotpInvalidCode):
+		return ginx.Result{
+			Code: errs.UserInvalidMfaCode,
+			Msg:  "动态码或者恢复码不对",
+		}, nil
+	case errors.Is(err, service.ErrTotpNotEnabled):
+		return ginx.Result{
+			Code: errs.UserInvalidInput,
+			Msg:  "没有开启两步验证",
+		}, nil
+	default:
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误",
+		}, err
+	}
+}
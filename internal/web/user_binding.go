@@ -0,0 +1,234 @@
+package web
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"webook/internal/domain"
+	"webook/internal/errs"
+	"webook/internal/service"
+	ijwt "webook/internal/web/jwt"
+	"webook/pkg/ginx"
+)
+
+func (h *UserHandler) Bindings(ctx *gin.Context, uc ijwt.UserClaims) (ginx.Result, error) {
+	u, err := h.svc.GetUserInfo(ctx, uc.UserId)
+	if err != nil {
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误",
+		}, err
+	}
+	identities, err := h.bindingSvc.Identities(ctx, uc.UserId)
+	if err != nil {
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误",
+		}, err
+	}
+	providers := make([]string, 0, len(identities))
+	for _, identity := range identities {
+		providers = append(providers, identity.Provider)
+	}
+	return ginx.Result{
+		Data: BindingsVo{
+			Email:         u.Email,
+			EmailVerified: u.EmailVerified,
+			Phone:         u.Phone,
+			Providers:     providers,
+		},
+	}, nil
+}
+
+// SendBindEmail 先往要绑定的邮箱发确认链接，已经绑定过的要走 ChangeEmail
+func (h *UserHandler) SendBindEmail(ctx *gin.Context, req SendBindEmailReq, uc ijwt.UserClaims) (ginx.Result, error) {
+	isEmail, err := h.emailRegExp.MatchString(req.Email)
+	if err != nil {
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误，邮箱匹配超时",
+		}, err
+	}
+	if !isEmail {
+		return ginx.Result{
+			Code: errs.UserInvalidInput,
+			Msg:  "非法邮箱格式",
+		}, nil
+	}
+	err = h.bindingSvc.SendBindEmail(ctx, uc.UserId, req.Email)
+	switch {
+	case err == nil:
+		return ginx.Result{
+			Msg: "发送成功，请查收邮件",
+		}, nil
+	case errors.Is(err, service.ErrTokenSendTooMany):
+		return ginx.Result{
+			Code: errs.UserTooManyRequests,
+			Msg:  "邮件发送太频繁，请稍后再试",
+		}, nil
+	default:
+		return h.bindingResult(err)
+	}
+}
+
+// BindEmail 点了确认邮件里面的链接之后才绑定，绑定之后就可以用邮箱和密码登录
+func (h *UserHandler) BindEmail(ctx *gin.Context, req BindEmailReq, uc ijwt.UserClaims) (ginx.Result, error) {
+	if req.Email == "" || req.Token == "" {
+		return ginx.Result{
+			Code: errs.UserInvalidToken,
+			Msg:  "链接无效或者已经过期",
+		}, nil
+	}
+	res, err := h.checkPassword(req.Password, req.ConfirmPassword)
+	if res.Code != 0 {
+		return res, err
+	}
+	err = h.bindingSvc.BindEmail(ctx, uc.UserId, req.Email, req.Password, req.Token)
+	if err != nil {
+		return h.bindingResult(err)
+	}
+	return ginx.Result{
+		Msg: "绑定成功",
+	}, nil
+}
+
+// ChangeEmail 换邮箱之后，除了当前设备，其它设备都要重新登录
+func (h *UserHandler) ChangeEmail(ctx *gin.Context, req ChangeEmailReq, uc ijwt.UserClaims) (ginx.Result, error) {
+	isEmail, err := h.emailRegExp.MatchString(req.Email)
+	if err != nil {
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误，邮箱匹配超时",
+		}, err
+	}
+	if !isEmail {
+		return ginx.Result{
+			Code: errs.UserInvalidInput,
+			Msg:  "非法邮箱格式",
+		}, nil
+	}
+	err = h.bindingSvc.ChangeEmail(ctx, uc.UserId, req.Email, req.Password, req.TotpCode)
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrInvalidUserOrPassword):
+		return ginx.Result{
+			Code: errs.UserInvalidOrPassword,
+			Msg:  "原密码不对",
+		}, nil
+	case errors.Is(err, service.ErrTotpInvalidCode):
+		return ginx.Result{
+			Code: errs.UserInvalidMfaCode,
+			Msg:  "动态码或者恢复码不对",
+		}, nil
+	case errors.Is(err, service.ErrTotpNotEnabled):
+		return ginx.Result{
+			Code: errs.UserInvalidInput,
+			Msg:  "没有开启两步验证",
+		}, nil
+	default:
+		return h.bindingResult(err)
+	}
+	err = h.Handler.RevokeOtherSessions(ctx, uc.UserId, uc.Ssid)
+	if err != nil {
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "邮箱已修改，但是其它设备退出登录失败，请稍后在设备管理里面操作",
+		}, err
+	}
+	return ginx.Result{
+		Msg: "修改成功，请验证新邮箱",
+	}, nil
+}
+
+func (h *UserHandler) SendBindPhoneCode(ctx *gin.Context, req SendBindPhoneCodeReq, uc ijwt.UserClaims) (ginx.Result, error) {
+	if req.Phone == "" {
+		return ginx.Result{
+			Code: errs.UserInvalidInput,
+			Msg:  "请输入手机号码",
+		}, nil
+	}
+	err := h.bindingSvc.SendBindPhoneCode(ctx, req.Phone)
+	switch {
+	case err == nil:
+		return ginx.Result{
+			Msg: "发送成功",
+		}, nil
+	case errors.Is(err, service.ErrCodeSendTooMany):
+		return ginx.Result{
+			Code: errs.UserTooManyRequests,
+			Msg:  "短信发送太频繁，请稍后再试",
+		}, nil
+	default:
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误",
+		}, err
+	}
+}
+
+func (h *UserHandler) BindPhone(ctx *gin.Context, req BindPhoneReq, uc ijwt.UserClaims) (ginx.Result, error) {
+	if req.Phone == "" {
+		return ginx.Result{
+			Code: errs.UserInvalidInput,
+			Msg:  "请输入手机号码",
+		}, nil
+	}
+	err := h.bindingSvc.BindPhone(ctx, uc.UserId, req.Phone, req.Code)
+	if err != nil {
+		return h.bindingResult(err)
+	}
+	return ginx.Result{
+		Msg: "绑定成功",
+	}, nil
+}
+
+func (h *UserHandler) Unbind(ctx *gin.Context, req UnbindReq, uc ijwt.UserClaims) (ginx.Result, error) {
+	if req.Method == "" {
+		return ginx.Result{
+			Code: errs.UserInvalidInput,
+			Msg:  "未知的登录方式",
+		}, nil
+	}
+	err := h.bindingSvc.Unbind(ctx, uc.UserId, domain.LoginMethod(req.Method))
+	if err != nil {
+		return h.bindingResult(err)
+	}
+	return ginx.Result{
+		Msg: "解绑成功",
+	}, nil
+}
+
+// bindingResult 绑定和解绑共用的错误处理
+func (h *UserHandler) bindingResult(err error) (ginx.Result, error) {
+	switch {
+	case errors.Is(err, service.ErrBindingConflict):
+		return ginx.Result{
+			Code: errs.UserBindingConflict,
+			Msg:  "已经绑定了其它账号",
+		}, nil
+	case errors.Is(err, service.ErrInvalidCode):
+		return ginx.Result{
+			Code: errs.UserInvalidInput,
+			Msg:  "验证码不对，请重新输入",
+		}, nil
+	case errors.Is(err, service.ErrInvalidToken):
+		return ginx.Result{
+			Code: errs.UserInvalidToken,
+			Msg:  "链接无效或者已经过期",
+		}, nil
+	case errors.Is(err, service.ErrNotBound):
+		return ginx.Result{
+			Code: errs.UserInvalidInput,
+			Msg:  "没有绑定这种登录方式",
+		}, nil
+	case errors.Is(err, service.ErrLastLoginMethod):
+		return ginx.Result{
+			Code: errs.UserInvalidInput,
+			Msg:  "至少要保留一种登录方式",
+		}, nil
+	default:
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误",
+		}, err
+	}
+}
@@ -0,0 +1,120 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+	"webook/internal/errs"
+	"webook/internal/service"
+	"webook/pkg/ginx"
+)
+
+// checkLoginGuard 账号或者 IP 被限制的时候直接返回 false，不再校验密码或者验证码
+func (h *UserHandler) checkLoginGuard(ctx *gin.Context, account string) bool {
+	res, err := h.loginGuard(ctx, account)
+	if res.Code == 0 {
+		return true
+	}
+	if err != nil {
+		ctx.Error(err)
+	}
+	ctx.JSON(http.StatusOK, res)
+	return false
+}
+
+// loginGuard 被限制的时候返回的 Result.Code 不为 0，给用 ginx.Wrap 包装的登录接口用
+func (h *UserHandler) loginGuard(ctx *gin.Context, account string) (ginx.Result, error) {
+	wait, err := h.guardSvc.Check(ctx, account, ctx.ClientIP())
+	switch {
+	case err == nil:
+		return ginx.Result{}, nil
+	case errors.Is(err, service.ErrLoginLocked):
+		return ginx.Result{
+			Code: errs.UserLoginLocked,
+			Msg:  fmt.Sprintf("登录失败太多，账号已经被锁定，请 %d 分钟后再试，或者用短信验证码解锁", ceil(wait, time.Minute)),
+		}, nil
+	case errors.Is(err, service.ErrLoginTooFrequent):
+		return ginx.Result{
+			Code: errs.UserTooManyRequests,
+			Msg:  fmt.Sprintf("登录失败太多，请 %d 秒后再试", ceil(wait, time.Second)),
+		}, nil
+	default:
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误",
+		}, err
+	}
+}
+
+// loginFailed 计数失败不影响这次的响应，只记录错误
+func (h *UserHandler) loginFailed(ctx *gin.Context, account string) {
+	err := h.guardSvc.Fail(ctx, account, ctx.ClientIP(), ctx.GetHeader("User-Agent"))
+	if err != nil {
+		ctx.Error(err)
+	}
+}
+
+func (h *UserHandler) loginSucceeded(ctx *gin.Context, account string) {
+	err := h.guardSvc.Succeed(ctx, account)
+	if err != nil {
+		ctx.Error(err)
+	}
+}
+
+func (h *UserHandler) SendUnlockCode(ctx *gin.Context, req SendUnlockCodeReq) (ginx.Result, error) {
+	if req.Account == "" {
+		return ginx.Result{
+			Code: errs.UserInvalidInput,
+			Msg:  "请输入邮箱或者手机号",
+		}, nil
+	}
+	err := h.guardSvc.SendUnlockCode(ctx, req.Account)
+	switch {
+	case err == nil:
+		return ginx.Result{
+			Msg: "如果账号绑定了手机号，会收到解锁的验证码",
+		}, nil
+	case errors.Is(err, service.ErrCodeSendTooMany):
+		return ginx.Result{
+			Code: errs.UserTooManyRequests,
+			Msg:  "短信发送太频繁，请稍后再试",
+		}, nil
+	default:
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误",
+		}, err
+	}
+}
+
+func (h *UserHandler) Unlock(ctx *gin.Context, req UnlockReq) (ginx.Result, error) {
+	err := h.guardSvc.Unlock(ctx, req.Account, req.Code)
+	switch {
+	case err == nil:
+		return ginx.Result{
+			Msg: "解锁成功，请重新登录",
+		}, nil
+	case errors.Is(err, service.ErrInvalidCode):
+		return ginx.Result{
+			Code: errs.UserInvalidInput,
+			Msg:  "验证码不对，请重新输入",
+		}, nil
+	case errors.Is(err, service.ErrCodeVerifyTooMany):
+		return ginx.Result{
+			Code: errs.UserTooManyRequests,
+			Msg:  "验证太频繁，请重新发送验证码",
+		}, nil
+	default:
+		return ginx.Result{
+			Code: errs.UserInternalServerError,
+			Msg:  "系统错误",
+		}, err
+	}
+}
+
+// ceil 至少是 1，避免提示等 0 秒
+func ceil(d time.Duration, unit time.Duration) int64 {
+	return int64((d + unit - 1) / unit)
+}
@@ -12,6 +12,7 @@ import (
 	"webook/internal/domain"
 	"webook/internal/service"
 	svcmocks "webook/internal/service/mocks"
+	"webook/pkg/ginx"
 )
 
 // mock 生成：mockgen -source .\internal\service\user.go -destination .\internal\service\mocks\user_mock.go -package svcmocks
@@ -200,11 +201,11 @@ func TestUserHandler_SignUp(t *testing.T) {
 			userSvc, codeSvc := tc.mock(ctrl)
 
 			// 初始化 hdl
-			hdl := NewUserHandler(userSvc, nil, codeSvc)
+			hdl := NewUserHandler(userSvc, nil, codeSvc, nil, nil, nil, nil, nil)
 
 			// 注册路由
 			server := gin.Default()
-			hdl.RegisterRoutes(server)
+			hdl.RegisterRoutes(server, ginx.NewRegistry())
 
 			// 拿到 req 和 recorder
 			req := tc.reqBuilder(t)
@@ -0,0 +1,107 @@
+package web
+
+import ijwt "webook/internal/web/jwt"
+
+type SessionVo struct {
+	ijwt.Session
+	// Current 是不是发起请求的设备
+	Current bool `json:"current"`
+}
+
+type RevokeSessionReq struct {
+	Ssid string `json:"ssid"`
+}
+
+type VerifyEmailReq struct {
+	Token string `json:"token"`
+}
+
+type SendResetPasswordReq struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordReq struct {
+	Token           string `json:"token"`
+	Password        string `json:"password"`
+	ConfirmPassword string `json:"confirmPassword"`
+}
+
+type ChangePasswordReq struct {
+	OldPassword     string `json:"oldPassword"`
+	Password        string `json:"password"`
+	ConfirmPassword string `json:"confirmPassword"`
+}
+
+type LoginMfaReq struct {
+	// Token 登录的时候 x-mfa-token 头部返回的临时 token
+	Token string `json:"token"`
+	// Code 动态码或者恢复码
+	Code string `json:"code"`
+}
+
+type TotpCodeReq struct {
+	Code string `json:"code"`
+}
+
+type TotpEnrollVo struct {
+	Secret string `json:"secret"`
+	// URI 前端用来生成二维码
+	URI string `json:"uri"`
+}
+
+type SendBindEmailReq struct {
+	Email string `json:"email"`
+}
+
+// BindEmailReq Token 来自确认邮件里面的链接
+type BindEmailReq struct {
+	Email           string `json:"email"`
+	Token           string `json:"token"`
+	Password        string `json:"password"`
+	ConfirmPassword string `json:"confirmPassword"`
+}
+
+// ChangeEmailReq Password 和 TotpCode 二选一，TotpCode 不为空的时候用动态码验证
+type ChangeEmailReq struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	TotpCode string `json:"totpCode"`
+}
+
+type SendBindPhoneCodeReq struct {
+	Phone string `json:"phone"`
+}
+
+type BindPhoneReq struct {
+	Phone string `json:"phone"`
+	Code  string `json:"code"`
+}
+
+type UnbindReq struct {
+	// Method email、phone 或者第三方登录的名字，例如 wechat、github
+	Method string `json:"method"`
+}
+
+// BindingsVo 当前绑定的登录方式
+type BindingsVo struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"emailVerified"`
+	Phone         string `json:"phone"`
+	// Providers 绑定的第三方登录
+	Providers []string `json:"providers"`
+}
+
+type SendUnlockCodeReq struct {
+	// Account 被锁定的邮箱或者手机号
+	Account string `json:"account"`
+}
+
+type UnlockReq struct {
+	Account string `json:"account"`
+	Code    string `json:"code"`
+}
+
+type SetAvatarReq struct {
+	// MediaId 先通过 /media/upload 上传
+	MediaId int64 `json:"mediaId"`
+}
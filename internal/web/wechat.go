@@ -1,134 +0,0 @@
-package web
-
-import (
-	"fmt"
-	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-	uuid "github.com/lithammer/shortuuid/v4"
-	"net/http"
-	"webook/internal/service"
-	"webook/internal/service/oauth2/wechat"
-	ijwt "webook/internal/web/jwt"
-)
-
-type OAuth2WechatHandler struct {
-	ijwt.Handler
-	svc             wechat.Service
-	uSvc            service.UserService
-	key             []byte
-	stateCookieName string
-}
-
-func NewOAuth2WechatHandler(svc wechat.Service,
-	hdl ijwt.Handler,
-	uSvc service.UserService) *OAuth2WechatHandler {
-	return &OAuth2WechatHandler{
-		svc:             svc,
-		uSvc:            uSvc,
-		key:             []byte("pBnSDaa0oCypBlPSpSoATWB4VZIS9niB"),
-		stateCookieName: "jwt-state",
-		Handler:         hdl,
-	}
-}
-
-func (o *OAuth2WechatHandler) RegisterRoutes(server *gin.Engine) {
-	g := server.Group("/oauth2/wechat")
-	g.GET("/authurl", o.Auth2Url)
-	g.Any("/callback", o.CallBack)
-}
-
-func (o *OAuth2WechatHandler) Auth2Url(ctx *gin.Context) {
-	state := uuid.New()
-	url, err := o.svc.AuthURL(ctx, state)
-	if err != nil {
-		ctx.JSON(http.StatusOK, Result{
-			Msg:  "构造跳转URL失败",
-			Code: 5,
-		})
-	}
-	err = o.setStateCookie(ctx, state)
-	if err != nil {
-		ctx.JSON(http.StatusOK, Result{
-			Msg:  "服务器异常",
-			Code: 5,
-		})
-	}
-	ctx.JSON(http.StatusOK, Result{
-		Data: url,
-	})
-}
-
-func (o *OAuth2WechatHandler) CallBack(ctx *gin.Context) {
-	err := o.verifyStateCookie(ctx)
-	if err != nil {
-		ctx.JSON(http.StatusOK, Result{
-			Msg:  "非法请求",
-			Code: 4,
-		})
-	}
-	code := ctx.Query("code")
-	wechatInfo, err := o.svc.VerifyCode(ctx, code)
-	if err != nil {
-		ctx.JSON(http.StatusOK, Result{
-			Msg:  "通过 code 获取 access_token 失败",
-			Code: 4,
-		})
-		return
-	}
-	u, err := o.uSvc.FindOrCreateByWechat(ctx, wechatInfo)
-	if err != nil {
-		ctx.JSON(http.StatusOK, Result{
-			Msg:  "系统错误",
-			Code: 5,
-		})
-		return
-	}
-	err = o.SetLoginToken(ctx, u.Id)
-	if err != nil {
-		ctx.String(http.StatusOK, "系统错误")
-		return
-	}
-	ctx.JSON(http.StatusOK, Result{
-		Msg: "登录成功",
-	})
-}
-
-func (o *OAuth2WechatHandler) verifyStateCookie(ctx *gin.Context) error {
-	state := ctx.Query("state")
-	ck, err := ctx.Cookie(o.stateCookieName)
-	if err != nil {
-		return fmt.Errorf("无法获得 cookie %w", err)
-	}
-	var sc StateClaims
-	_, err = jwt.ParseWithClaims(ck, &sc, func(token *jwt.Token) (interface{}, error) {
-		return o.key, nil
-	})
-
-	if err != nil {
-		return fmt.Errorf("解析 token 失败 %w", err)
-	}
-	if state != sc.State {
-		return fmt.Errorf("state 不匹配 %w", err)
-	}
-	return nil
-}
-
-func (o *OAuth2WechatHandler) setStateCookie(ctx *gin.Context, state string) error {
-	claims := StateClaims{
-		State: state,
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
-
-	tokenStr, err := token.SignedString(o.key)
-	if err != nil {
-		return err
-	}
-	ctx.SetCookie(o.stateCookieName, tokenStr, 600, "/oauth2/wechat/callback", "", false, true)
-	return nil
-}
-
-type StateClaims struct {
-	jwt.RegisteredClaims
-	State string
-}
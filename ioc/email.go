@@ -0,0 +1,26 @@
+package ioc
+
+import (
+	"github.com/spf13/viper"
+	"webook/internal/repository"
+	"webook/internal/service"
+	"webook/internal/service/email"
+	"webook/internal/service/email/localemail"
+)
+
+// InitEmailService 还没有接入邮件服务商，先打印到日志里面
+func InitEmailService() email.Service {
+	return localemail.NewService()
+}
+
+func InitAccountService(repo repository.UserRepository, tokenRepo repository.UserTokenRepository,
+	emailSvc email.Service) service.AccountService {
+	// 邮件里面的链接指向前端页面
+	return service.NewAccountService(repo, tokenRepo, emailSvc, viper.GetString("email.baseURL"))
+}
+
+func InitBindingService(repo repository.UserRepository, identityRepo repository.UserIdentityRepository,
+	codeSvc service.CodeService, totpSvc service.TotpService, tokenRepo repository.UserTokenRepository,
+	emailSvc email.Service) service.BindingService {
+	return service.NewBindingService(repo, identityRepo, codeSvc, totpSvc, tokenRepo, emailSvc, viper.GetString("email.baseURL"))
+}
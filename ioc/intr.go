@@ -1,6 +1,7 @@
 package ioc
 
 import (
+	"fmt"
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 	etcdv3 "go.etcd.io/etcd/client/v3"
@@ -12,6 +13,9 @@ import (
 	"webook/interactive/repository"
 	"webook/interactive/service"
 	"webook/internal/client"
+	"webook/pkg/grpcx/balancer/p2c"
+	"webook/pkg/grpcx/interceptor/auth"
+	"webook/pkg/grpcx/interceptor/circuitbreaker"
 )
 
 // InitIntrClientV1 只发起远程调用，且从注册中心读 Interactive 服务的地址
@@ -30,7 +34,11 @@ func InitIntrClientV1(client *etcdv3.Client) intrv1.InteractiveServiceClient {
 	if err != nil {
 		panic(err)
 	}
-	opts := []grpc.DialOption{grpc.WithResolvers(etcdResolver)}
+	opts := []grpc.DialOption{
+		grpc.WithResolvers(etcdResolver),
+		// 把用户的 token 带给 Interactive 服务
+		grpc.WithChainUnaryInterceptor(auth.ClientUnaryInterceptor()),
+	}
 
 	if !cfg.Secure {
 		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
@@ -43,6 +51,41 @@ func InitIntrClientV1(client *etcdv3.Client) intrv1.InteractiveServiceClient {
 	return remote
 }
 
+// InitIntrClientV2 从注册中心读 Interactive 服务的地址，并接入客户端熔断
+// 熔断之后降级到 local，只读缓存里面的计数
+func InitIntrClientV2(etcdClient *etcdv3.Client, local *client.LocalInteractiveReader) intrv1.InteractiveServiceClient {
+	type Config struct {
+		Addr   string `yaml:"addr"`
+		Secure bool   `yaml:"secure"`
+	}
+	var cfg Config
+	err := viper.UnmarshalKey("grpc.client.intr", &cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	etcdResolver, err := resolver.NewBuilder(etcdClient)
+	if err != nil {
+		panic(err)
+	}
+	cb := circuitbreaker.NewClientInterceptorBuilder().DefaultFallback(local.Fallback)
+	opts := []grpc.DialOption{
+		grpc.WithResolvers(etcdResolver),
+		// Interactive 服务端会上报负载，挑负载低的节点
+		grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"loadBalancingConfig": [{"%s":{}}]}`, p2c.Name)),
+		grpc.WithChainUnaryInterceptor(auth.ClientUnaryInterceptor(), cb.BuildUnaryClientInterceptor()),
+	}
+
+	if !cfg.Secure {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	cc, err := grpc.Dial(cfg.Addr, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return intrv1.NewInteractiveServiceClient(cc)
+}
+
 func InitIntrClient(svc service.InteractiveService) intrv1.InteractiveServiceClient {
 	type Config struct {
 		Addr      string `yaml:"addr"`
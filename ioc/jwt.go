@@ -0,0 +1,40 @@
+package ioc
+
+import (
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	ijwt "webook/internal/web/jwt"
+	"webook/pkg/jwtx"
+	"webook/pkg/logger"
+)
+
+func InitJWTHandler(cmd redis.Cmdable, l logger.LoggerV1) ijwt.Handler {
+	keys := initKeySet("jwt.access", l)
+	rcKeys := initKeySet("jwt.refresh", l)
+	// 每个用户最多同时登录几个设备，不配置就不限制
+	return ijwt.NewRedisJWTHandler(cmd, keys, rcKeys).MaxSessions(viper.GetInt("jwt.maxSessions"))
+}
+
+// initKeySet 轮换密钥的时候，把新密钥加到 keys 里面，等 gRPC 服务刷新 JWKS 之后再改 active，
+// 旧密钥去掉私钥，并且设置 verifyUntil
+func initKeySet(key string, l logger.LoggerV1) *jwtx.KeySet {
+	var cfg jwtx.KeySetConfig
+	err := viper.UnmarshalKey(key, &cfg)
+	if err != nil {
+		panic(err)
+	}
+	if len(cfg.Keys) == 0 {
+		// 开发环境没有配置密钥，临时生成一个，重启之后需要重新登录
+		l.Warn("没有配置 JWT 密钥，使用临时生成的密钥", logger.String("key", key))
+		k, err := jwtx.GenerateKey("dev")
+		if err != nil {
+			panic(err)
+		}
+		return jwtx.NewKeySet(k)
+	}
+	ks, err := jwtx.LoadKeySet(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return ks
+}
@@ -4,6 +4,8 @@ import (
 	"github.com/IBM/sarama"
 	"github.com/spf13/viper"
 	"webook/internal/events"
+	"webook/internal/repository/dao"
+	"webook/pkg/migrator/events/fixer"
 )
 
 func InitSaramaClient() sarama.Client {
@@ -32,6 +34,6 @@ func InitSyncProducer(c sarama.Client) sarama.SyncProducer {
 	return p
 }
 
-func InitConsumers() []events.Consumer {
-	return []events.Consumer{}
+func InitConsumers(articleFixer *fixer.FixConsumer[dao.PublishedArticle]) []events.Consumer {
+	return []events.Consumer{articleFixer}
 }
@@ -0,0 +1,19 @@
+package ioc
+
+import (
+	"github.com/redis/go-redis/v9"
+	"time"
+	"webook/internal/events/user"
+	"webook/internal/repository"
+	"webook/internal/service"
+	"webook/pkg/limiter"
+	"webook/pkg/logger"
+)
+
+func InitLoginGuardService(cmd redis.Cmdable, repo repository.LoginGuardRepository,
+	userRepo repository.UserRepository, codeSvc service.CodeService,
+	producer user.Producer, l logger.LoggerV1) service.LoginGuardService {
+	// 同一个 IP 十分钟之内失败 50 次，就暂时不让它登录
+	ipLimiter := limiter.NewRedisSlidingWindowLimiter(cmd, time.Minute*10, 50)
+	return service.NewLoginGuardService(repo, userRepo, codeSvc, ipLimiter, producer, l)
+}
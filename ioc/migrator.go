@@ -0,0 +1,85 @@
+package ioc
+
+import (
+	"context"
+	"github.com/IBM/sarama"
+	"github.com/bwmarrin/snowflake"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gorm.io/gorm"
+	"time"
+	"webook/internal/repository/dao"
+	"webook/pkg/ginx"
+	"webook/pkg/logger"
+	"webook/pkg/migrator"
+	"webook/pkg/migrator/events"
+	"webook/pkg/migrator/events/fixer"
+	"webook/pkg/migrator/scheduler"
+	"webook/pkg/migrator/validator"
+)
+
+// 线上库 published_articles 从 MySQL 迁移到 MongoDB
+
+const topicInconsistentPublishedArticle = "inconsistent_published_article"
+
+func InitMongoDB() *mongo.Database {
+	type Config struct {
+		URI      string `yaml:"uri"`
+		Database string `yaml:"database"`
+	}
+	var cfg Config
+	err := viper.UnmarshalKey("mongo", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.URI))
+	if err != nil {
+		panic(err)
+	}
+	return client.Database(cfg.Database)
+}
+
+// InitArticleDAO 迁移期间线上库同步写到 MongoDB，从哪一边读由 Scheduler 切换
+func InitArticleDAO(db *gorm.DB, mdb *mongo.Database, l logger.LoggerV1) *dao.DoubleWriteArticleDAO {
+	// 制作库还在 MySQL 里面，MongoDB 这边用不到生成的 id
+	node, err := snowflake.NewNode(1)
+	if err != nil {
+		panic(err)
+	}
+	return dao.NewDoubleWriteArticleDAO(dao.NewArticleGORMDAO(db), dao.NewMongoDBArticleDAO(mdb, node),
+		dao.NewPublishedArticleMongoStore(mdb), l)
+}
+
+func InitArticleMigratorProducer(producer sarama.SyncProducer) events.Producer {
+	return events.NewSaramaProducer(producer, topicInconsistentPublishedArticle)
+}
+
+// InitArticleScheduler 迁移的接口只有管理员可以访问，路由注册在 web 服务器上
+func InitArticleScheduler(l logger.LoggerV1, db *gorm.DB, mdb *mongo.Database,
+	doubleWrite *dao.DoubleWriteArticleDAO, producer events.Producer, redisClient redis.Cmdable,
+	server *gin.Engine, registry *ginx.Registry) *scheduler.Scheduler[dao.PublishedArticle] {
+	// 每个实例都有自己的 DoubleWriteArticleDAO，pattern 要保存下来一起切换
+	err := db.AutoMigrate(&scheduler.MigratorState{}, &scheduler.MigratorAudit{})
+	if err != nil {
+		panic(err)
+	}
+	sch := scheduler.NewStoreScheduler[dao.PublishedArticle](l, "published_articles",
+		migrator.NewGORMStore[dao.PublishedArticle](db), dao.NewPublishedArticleMongoStore(mdb),
+		doubleWrite, producer).
+		Checkpoint(validator.NewRedisCheckpointStore(redisClient)).
+		State(scheduler.NewGORMStateStore(db))
+	sch.RegisterRoutes(server, registry)
+	return sch
+}
+
+func InitArticleFixConsumer(client sarama.Client, l logger.LoggerV1, db *gorm.DB,
+	mdb *mongo.Database) *fixer.FixConsumer[dao.PublishedArticle] {
+	return fixer.NewStoreFixConsumer[dao.PublishedArticle](client, l,
+		migrator.NewGORMStore[dao.PublishedArticle](db), dao.NewPublishedArticleMongoStore(mdb),
+		topicInconsistentPublishedArticle)
+}
@@ -0,0 +1,35 @@
+package ioc
+
+import (
+	"context"
+	"github.com/spf13/viper"
+	"net/http"
+	"time"
+	"webook/internal/service/oauth2"
+	"webook/internal/service/oauth2/oidc"
+	"webook/internal/service/oauth2/wechat"
+	"webook/pkg/logger"
+)
+
+// InitOAuth2Registry 微信之外的第三方登录都在 oauth2.providers 里面配置
+func InitOAuth2Registry(wechatSvc wechat.Service, l logger.LoggerV1) *oauth2.Registry {
+	var cfgs []oidc.Config
+	err := viper.UnmarshalKey("oauth2.providers", &cfgs)
+	if err != nil {
+		panic(err)
+	}
+	providers := []oauth2.Provider{wechat.NewProvider(wechatSvc)}
+	client := &http.Client{Timeout: time.Second * 10}
+	for _, cfg := range cfgs {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		p, err := oidc.NewProvider(ctx, cfg, client)
+		cancel()
+		if err != nil {
+			// 某一个平台连不上，不影响其它的登录方式
+			l.Error("初始化第三方登录失败", logger.String("name", cfg.Name), logger.Error(err))
+			continue
+		}
+		providers = append(providers, p)
+	}
+	return oauth2.NewRegistry(providers...)
+}
@@ -0,0 +1,39 @@
+package ioc
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/ecodeclub/ekit"
+	"github.com/spf13/viper"
+	"os"
+	"webook/internal/repository/dao"
+)
+
+// InitObjectDAO 腾讯云 COS 兼容 S3 的接口，密钥从环境变量里面读
+func InitObjectDAO() dao.ObjectDAO {
+	type Config struct {
+		Region   string `yaml:"region"`
+		Endpoint string `yaml:"endpoint"`
+		Bucket   string `yaml:"bucket"`
+		// BaseURL 公开访问的地址，一般是 CDN
+		BaseURL string `yaml:"baseURL"`
+	}
+	var cfg Config
+	err := viper.UnmarshalKey("oss", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	sess, err := session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentials(os.Getenv("COS_APP_ID"), os.Getenv("COS_APP_SECRET"), ""),
+		Region:      ekit.ToPtr[string](cfg.Region),
+		Endpoint:    ekit.ToPtr[string](cfg.Endpoint),
+		// 强制使用 /bucket/key 的形态
+		S3ForcePathStyle: ekit.ToPtr[bool](true),
+	})
+	if err != nil {
+		panic(err)
+	}
+	return dao.NewS3ObjectDAO(s3.New(sess), cfg.Bucket, cfg.BaseURL)
+}
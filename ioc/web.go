@@ -18,18 +18,25 @@ import (
 	"webook/pkg/logger"
 )
 
-func InitWebServer(mdls []gin.HandlerFunc,
-	userHdl *web.UserHandler, wechatHdl *web.OAuth2WechatHandler, artHdl *web.ArticleHandler, l logger.LoggerV1) *gin.Engine {
+func InitWebServer(mdls []gin.HandlerFunc, registry *ginx.Registry,
+	userHdl *web.UserHandler, oauth2Hdl *web.OAuth2Handler, artHdl *web.ArticleHandler,
+	mediaHdl *web.MediaHandler, l logger.LoggerV1) *gin.Engine {
 	ginx.SetLogger(l)
 	server := gin.Default()
 	server.Use(mdls...)
-	userHdl.RegisterRoutes(server)
-	wechatHdl.RegisterRoutes(server)
-	artHdl.RegisterRoutes(server)
+	userHdl.RegisterRoutes(server, registry)
+	oauth2Hdl.RegisterRoutes(server, registry)
+	artHdl.RegisterRoutes(server, registry)
+	mediaHdl.RegisterRoutes(server, registry)
 	return server
 }
 
-func InitGinMiddlewares(redisClient redis.Cmdable, hdl ijwt.Handler, l logger.LoggerV1) []gin.HandlerFunc {
+// InitRouteRegistry 注册路由和登录校验共用同一个
+func InitRouteRegistry() *ginx.Registry {
+	return ginx.NewRegistry()
+}
+
+func InitGinMiddlewares(redisClient redis.Cmdable, hdl ijwt.Handler, registry *ginx.Registry, l logger.LoggerV1) []gin.HandlerFunc {
 	pb := &prometheus2.Builder{
 		Namespace: "geektime_daming",
 		Subsystem: "webook",
@@ -53,7 +60,7 @@ func InitGinMiddlewares(redisClient redis.Cmdable, hdl ijwt.Handler, l logger.Lo
 			AllowCredentials: true,
 			AllowHeaders:     []string{"authorization", "content-type"},
 
-			ExposeHeaders: []string{"x-jwt-token", "x-refresh-token"}, // 允许前端访问后端响应中带的头部
+			ExposeHeaders: []string{"x-jwt-token", "x-refresh-token", "x-mfa-token"}, // 允许前端访问后端响应中带的头部
 			AllowOriginFunc: func(origin string) bool {
 				return true
 			},
@@ -75,7 +82,7 @@ func InitGinMiddlewares(redisClient redis.Cmdable, hdl ijwt.Handler, l logger.Lo
 		}).AllowReqBody().AllowRespBody().Builder(),
 		// handler日志
 		middleware.NewLogHandlerBuilder(l).Builder(),
-		// 登录校验
-		middleware.NewLoginJWTMiddlewareBuilder(hdl).CheckLogin(),
+		// 登录校验和角色校验
+		middleware.NewLoginJWTMiddlewareBuilder(hdl, registry).CheckLogin(),
 	}
 }
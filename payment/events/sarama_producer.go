@@ -2,8 +2,8 @@ package events
 
 import (
 	"context"
-	"encoding/json"
 	"github.com/IBM/sarama"
+	"webook/pkg/saramax"
 )
 
 type SaramaProducer struct {
@@ -21,14 +21,11 @@ func NewSaramaProducer(client sarama.Client) (Producer, error) {
 }
 
 func (s *SaramaProducer) ProducePaymentEvent(ctx context.Context, evt PaymentEvent) error {
-	data, err := json.Marshal(evt)
+	msg, err := saramax.DefaultRegistry.NewMessage(ctx, evt.Topic(), evt)
 	if err != nil {
 		return err
 	}
-	_, _, err = s.producer.SendMessage(&sarama.ProducerMessage{
-		Key:   sarama.StringEncoder(evt.BizTradeNO),
-		Topic: evt.Topic(),
-		Value: sarama.ByteEncoder(data),
-	})
+	msg.Key = sarama.StringEncoder(evt.BizTradeNO)
+	_, _, err = s.producer.SendMessage(msg)
 	return err
 }
@@ -1,5 +1,12 @@
 package events
 
+import "webook/pkg/saramax"
+
+func init() {
+	saramax.DefaultRegistry.Register(PaymentEvent{}.Topic(), "payment.status_changed",
+		saramax.Version{Version: 1, Sample: PaymentEvent{}})
+}
+
 // PaymentEvent 最简设计
 type PaymentEvent struct {
 	BizTradeNO string
@@ -8,22 +8,11 @@ import (
 func main() {
 	initViperWatch()
 	app := InitApp()
-
-	// 启动 grpc 服务
-	go func() {
-		err := app.GRPCServer.Serve()
+	// 启动 grpc 服务、web 服务和定时任务，收到退出信号后统一关闭
+	err := app.Run()
+	if err != nil {
 		panic(err)
-	}()
-
-	app.Cron.Start()
-	defer func() {
-		ctx := app.Cron.Stop()
-		<-ctx.Done()
-	}()
-
-	// 启动 web 服务
-	err := app.WebServer.Start()
-	panic(err)
+	}
 }
 
 func initViperWatch() {
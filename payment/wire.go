@@ -42,7 +42,7 @@ func InitApp() *wego.App {
 		ioc.InitSyncWechatOrderJob,
 		ioc.InitJobs,
 
-		wire.Struct(new(wego.App), "WebServer", "GRPCServer", "Cron"),
+		wire.Struct(new(wego.App), "WebServer", "GRPCServer", "Cron", "L"),
 	)
 	return new(wego.App)
 }
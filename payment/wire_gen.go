@@ -40,6 +40,7 @@ func InitApp() *wego.App {
 		WebServer:  server,
 		GRPCServer: grpcxServer,
 		Cron:       cron,
+		L:          loggerV1,
 	}
 	return app
 }
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"webook/pkg/ginx"
+	"webook/pkg/jwtx"
+)
+
+// Authenticate 从请求里面解析出用户，失败返回 error
+type Authenticate func(ctx *gin.Context) (ginx.UserClaims, error)
+
+// Builder 按照 ginx.Registry 里面登记的策略鉴权
+// 公开的路由直接放行；其它的路由先用 authenticate 识别用户，再检查角色
+// 通过之后用户放在 ctx 的 "user" 里面
+type Builder struct {
+	registry     *ginx.Registry
+	authenticate Authenticate
+}
+
+func NewBuilder(registry *ginx.Registry, authenticate Authenticate) *Builder {
+	return &Builder{registry: registry, authenticate: authenticate}
+}
+
+func (b *Builder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		policy := b.registry.Policy(ctx.Request.Method, ctx.FullPath())
+		if policy.Public {
+			return
+		}
+		uc, err := b.authenticate(ctx)
+		if err != nil {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		if !policy.Allow(uc.Roles) {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		ctx.Set("user", uc)
+	}
+}
+
+// BearerAuthenticate 只验证 Authorization 里面的短 token，不检查会话是否已经退出登录
+// 给没有会话信息的服务用，例如 interactive 的后台接口
+func BearerAuthenticate(verifier *jwtx.Verifier) Authenticate {
+	return func(ctx *gin.Context) (ginx.UserClaims, error) {
+		segs := strings.SplitN(ctx.GetHeader("Authorization"), " ", 2)
+		if len(segs) != 2 || !strings.EqualFold(segs[0], "Bearer") {
+			return ginx.UserClaims{}, errors.New("缺少 token")
+		}
+		if verifier == nil {
+			return ginx.UserClaims{}, errors.New("没有配置 JWKS，无法验证 token")
+		}
+		return verifier.Verify(segs[1])
+	}
+}
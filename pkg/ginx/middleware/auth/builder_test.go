@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"webook/pkg/ginx"
+)
+
+func TestBuilder_Build(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	registry := ginx.NewRegistry()
+	server := gin.New()
+	// 测试里面用 X-Uid 和 X-Role 头部模拟登录的用户
+	server.Use(NewBuilder(registry, func(ctx *gin.Context) (ginx.UserClaims, error) {
+		if ctx.GetHeader("X-Uid") == "" {
+			return ginx.UserClaims{}, errors.New("未登录")
+		}
+		var roles []string
+		if role := ctx.GetHeader("X-Role"); role != "" {
+			roles = append(roles, role)
+		}
+		return ginx.UserClaims{UserId: 123, Roles: roles}, nil
+	}).Build())
+	ok := func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	}
+	registry.Group(server.Group("/users"), ginx.Public()).POST("/login", ok)
+	registry.Group(server.Group("/users"), ginx.Authenticated()).GET("/profile", ok)
+	registry.Group(server.Group("/articles"), ginx.Authenticated()).GET("/detail/:id", ok)
+	registry.Group(server.Group("/migrator"), ginx.RequireRoles(ginx.RoleAdmin)).POST("/src_first", ok)
+	// 没有登记的
+	server.GET("/unknown", ok)
+
+	testCases := []struct {
+		name   string
+		method string
+		path   string
+		uid    string
+		role   string
+
+		wantCode int
+	}{
+		{
+			name:     "公开接口",
+			method:   http.MethodPost,
+			path:     "/users/login",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "未登录",
+			method:   http.MethodGet,
+			path:     "/users/profile",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "已登录",
+			method:   http.MethodGet,
+			path:     "/users/profile",
+			uid:      "123",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "路径参数",
+			method:   http.MethodGet,
+			path:     "/articles/detail/1",
+			uid:      "123",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "不是管理员",
+			method:   http.MethodPost,
+			path:     "/migrator/src_first",
+			uid:      "123",
+			role:     "author",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "管理员",
+			method:   http.MethodPost,
+			path:     "/migrator/src_first",
+			uid:      "123",
+			role:     ginx.RoleAdmin,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "没有登记的路由默认需要登录",
+			method:   http.MethodGet,
+			path:     "/unknown",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "同一个路径的其它方法没有登记",
+			method:   http.MethodGet,
+			path:     "/users/login",
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			req.Header.Set("X-Uid", tc.uid)
+			req.Header.Set("X-Role", tc.role)
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+		})
+	}
+}
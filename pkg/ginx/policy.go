@@ -0,0 +1,126 @@
+package ginx
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"path"
+	"slices"
+	"sync"
+)
+
+// RoleAdmin 管理员，可以操作数据迁移之类的后台接口
+const RoleAdmin = "admin"
+
+// Policy 一个路由的访问策略，零值表示登录了就可以访问
+type Policy struct {
+	// Public 不需要登录
+	Public bool
+	// Roles 有其中任意一个角色就可以访问，为空表示不限制角色
+	Roles []string
+}
+
+func Public() Policy {
+	return Policy{Public: true}
+}
+
+func Authenticated() Policy {
+	return Policy{}
+}
+
+func RequireRoles(roles ...string) Policy {
+	return Policy{Roles: roles}
+}
+
+// Allow 用户的角色是否满足要求
+func (p Policy) Allow(roles []string) bool {
+	if p.Public || len(p.Roles) == 0 {
+		return true
+	}
+	for _, r := range roles {
+		if slices.Contains(p.Roles, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Registry 登记每个路由的访问策略，由鉴权的 middleware 统一执行
+// 路由用 gin 匹配出来的 FullPath，所以 /articles/detail/:id 只需要登记一次
+type Registry struct {
+	lock     sync.RWMutex
+	policies map[string]Policy
+}
+
+func NewRegistry() *Registry {
+	return &Registry{policies: make(map[string]Policy)}
+}
+
+// Policy 没有登记过的路由，包括 404，都要求登录
+func (r *Registry) Policy(method, fullPath string) Policy {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	p, ok := r.policies[method+" "+fullPath]
+	if !ok {
+		return Authenticated()
+	}
+	return p
+}
+
+func (r *Registry) set(method, fullPath string, p Policy) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.policies[method+" "+fullPath] = p
+}
+
+// Group 在 group 上注册的路由都使用 p 这个策略
+// group 可以是 *gin.Engine，也可以是 *gin.RouterGroup
+func (r *Registry) Group(group Router, p Policy) *Routes {
+	return &Routes{group: group, registry: r, policy: p}
+}
+
+type Router interface {
+	gin.IRoutes
+	BasePath() string
+}
+
+// Routes 注册路由的同时登记访问策略
+type Routes struct {
+	group    Router
+	registry *Registry
+	policy   Policy
+}
+
+func (rs *Routes) Handle(method, relativePath string, handlers ...gin.HandlerFunc) *Routes {
+	rs.group.Handle(method, relativePath, handlers...)
+	rs.registry.set(method, joinPaths(rs.group.BasePath(), relativePath), rs.policy)
+	return rs
+}
+
+func (rs *Routes) GET(relativePath string, handlers ...gin.HandlerFunc) *Routes {
+	return rs.Handle(http.MethodGet, relativePath, handlers...)
+}
+
+func (rs *Routes) POST(relativePath string, handlers ...gin.HandlerFunc) *Routes {
+	return rs.Handle(http.MethodPost, relativePath, handlers...)
+}
+
+// Any 和 gin 的 Any 一样，所有方法都用同一个策略
+func (rs *Routes) Any(relativePath string, handlers ...gin.HandlerFunc) *Routes {
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodHead, http.MethodOptions, http.MethodDelete, http.MethodConnect, http.MethodTrace} {
+		rs.Handle(method, relativePath, handlers...)
+	}
+	return rs
+}
+
+// joinPaths 和 gin 拼接 FullPath 的方式保持一致
+func joinPaths(absolutePath, relativePath string) string {
+	if relativePath == "" {
+		return absolutePath
+	}
+	finalPath := path.Join(absolutePath, relativePath)
+	if relativePath[len(relativePath)-1] == '/' && finalPath[len(finalPath)-1] != '/' {
+		return finalPath + "/"
+	}
+	return finalPath
+}
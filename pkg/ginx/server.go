@@ -1,12 +1,43 @@
 package ginx
 
-import "github.com/gin-gonic/gin"
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sync"
+)
 
 type Server struct {
 	*gin.Engine
 	Addr string
+
+	// Start 一般在单独的 goroutine 里面调用，和 Shutdown 可能并发
+	// 所以 http.Server 只创建一次，谁先用到谁创建
+	initOnce sync.Once
+	server   *http.Server
 }
 
 func (s *Server) Start() error {
-	return s.Engine.Run(s.Addr)
+	return s.httpServer().ListenAndServe()
+}
+
+// Shutdown 不再接收新请求，等待正在处理的请求结束
+// 在 Start 之前调用的话，之后的 Start 会直接返回 http.ErrServerClosed
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer().Shutdown(ctx)
+}
+
+// RegisterOnShutdown 注册 Shutdown 的时候要执行的清理逻辑，例如停掉后台任务
+func (s *Server) RegisterOnShutdown(f func()) {
+	s.httpServer().RegisterOnShutdown(f)
+}
+
+func (s *Server) httpServer() *http.Server {
+	s.initOnce.Do(func() {
+		s.server = &http.Server{
+			Addr:    s.Addr,
+			Handler: s.Engine,
+		}
+	})
+	return s.server
 }
@@ -1,6 +1,6 @@
 package ginx
 
-import "github.com/golang-jwt/jwt/v5"
+import "webook/pkg/jwtx"
 
 // Result 你可以通过在 Result 里面定义更加多的字段，来配合 Wrap 方法
 type Result struct {
@@ -9,9 +9,5 @@ type Result struct {
 	Data any    `json:"data"`
 }
 
-type UserClaims struct {
-	jwt.RegisteredClaims
-	UserId    int64
-	Ssid      string
-	UserAgent string
-}
+// UserClaims 和 gRPC 服务共用 jwtx 里面的定义
+type UserClaims = jwtx.UserClaims
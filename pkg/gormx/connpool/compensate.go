@@ -0,0 +1,120 @@
+package connpool
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"regexp"
+	"strings"
+)
+
+// FailedWrite 主库写成功了，但是次要库上没有生效的一条 SQL
+type FailedWrite struct {
+	// Pattern 写入时的双写模式，SrcFirst 的时候失败的是目标库，DstFirst 的时候失败的是源库
+	Pattern string
+	// Table 从 SQL 里面解析出来的表名，解析不出来的时候为空
+	Table string
+	Query string
+	Args  []any
+	// IDs 受影响的 id，从主库的执行结果或者 WHERE 条件里面解析，解析不出来的时候为空
+	IDs []int64
+	Err error
+}
+
+// Base 以哪一边为准，也就是主库，取值为 SRC 或者 DST
+func (f FailedWrite) Base() string {
+	if f.Pattern == PatternDstFirst {
+		return "DST"
+	}
+	return "SRC"
+}
+
+// Target 写入失败的一边，取值为 SRC 或者 DST
+func (f FailedWrite) Target() string {
+	if f.Pattern == PatternDstFirst {
+		return "SRC"
+	}
+	return "DST"
+}
+
+// Compensator 补偿次要库上失败的写，例如通知修复程序，或者把 SQL 记下来之后重放
+// 在业务的写入路径上同步调用，实现不能阻塞太久
+type Compensator interface {
+	Compensate(ctx context.Context, fw FailedWrite)
+}
+
+// write 主库上执行成功的一条 SQL，res 是主库的执行结果
+type write struct {
+	query string
+	args  []any
+	res   sql.Result
+	err   error
+}
+
+func (w write) failed(pattern string, err error) FailedWrite {
+	return FailedWrite{
+		Pattern: pattern,
+		Table:   parseTable(w.query),
+		Query:   w.query,
+		Args:    w.args,
+		IDs:     parseIDs(w.query, w.args, w.res),
+		Err:     err,
+	}
+}
+
+var (
+	tableRegexp = regexp.MustCompile("(?i)^\\s*(?:insert\\s+(?:ignore\\s+)?into|replace\\s+into|update|delete\\s+from)\\s+`?(\\w+)`?")
+	// 只认 WHERE 后面第一个条件是 id 的情况，例如 WHERE `interactives`.`id` = ? 或者 WHERE id IN (?,?)
+	idRegexp = regexp.MustCompile("(?i)\\bwhere\\s+(?:`?\\w+`?\\.)?`?id`?\\s*(?:=\\s*\\?|in\\s*\\(\\s*\\?(?:\\s*,\\s*\\?)*\\s*\\))")
+)
+
+func parseTable(query string) string {
+	matches := tableRegexp.FindStringSubmatch(query)
+	if matches == nil {
+		return ""
+	}
+	return matches[1]
+}
+
+// parseIDs INSERT 只有一行的时候用主库的 LastInsertId，UPDATE 和 DELETE 从 WHERE 条件里面取
+// 解析不出来，或者有 OR 这种没办法确定范围的条件，都返回 nil
+func parseIDs(query string, args []any, res sql.Result) []int64 {
+	if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(query)), "INSERT") {
+		if res == nil {
+			return nil
+		}
+		// ON DUPLICATE KEY UPDATE 更新的时候影响行数是 2，这时候 LastInsertId 不是被更新的那一行
+		n, err := res.RowsAffected()
+		if err != nil || n != 1 {
+			return nil
+		}
+		id, err := res.LastInsertId()
+		if err != nil || id <= 0 {
+			return nil
+		}
+		return []int64{id}
+	}
+	loc := idRegexp.FindStringIndex(query)
+	if loc == nil || strings.Contains(strings.ToUpper(query[loc[0]:]), " OR ") {
+		return nil
+	}
+	// 前面的占位符，例如 UPDATE 里面 SET 的值
+	start := strings.Count(query[:loc[0]], "?")
+	cnt := strings.Count(query[loc[0]:loc[1]], "?")
+	if start+cnt > len(args) {
+		return nil
+	}
+	ids := make([]int64, 0, cnt)
+	for _, arg := range args[start : start+cnt] {
+		val, err := driver.DefaultParameterConverter.ConvertValue(arg)
+		if err != nil {
+			return nil
+		}
+		id, ok := val.(int64)
+		if !ok {
+			return nil
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
@@ -0,0 +1,72 @@
+package connpool
+
+import (
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseIDs(t *testing.T) {
+	testCases := []struct {
+		name  string
+		query string
+		args  []any
+		res   sql.Result
+		want  []int64
+	}{
+		{
+			name:  "单行插入",
+			query: "INSERT INTO `interactives` (`biz_id`,`biz`) VALUES (?,?)",
+			args:  []any{int64(1), "test"},
+			res:   result{id: 10, affected: 1},
+			want:  []int64{10},
+		},
+		{
+			name:  "插入冲突之后更新",
+			query: "INSERT INTO `interactives` (`biz_id`,`biz`) VALUES (?,?) ON DUPLICATE KEY UPDATE `read_cnt`=`read_cnt`+1",
+			args:  []any{int64(1), "test"},
+			res:   result{id: 10, affected: 2},
+		},
+		{
+			name:  "按照 id 更新",
+			query: "UPDATE `interactives` SET `read_cnt`=?,`utime`=? WHERE `interactives`.`id` = ?",
+			args:  []any{int64(3), int64(123), int64(7)},
+			want:  []int64{7},
+		},
+		{
+			name:  "按照 id 删除多行",
+			query: "DELETE FROM `interactives` WHERE id IN (?,?) AND biz = ?",
+			args:  []any{int64(7), 8, "test"},
+			want:  []int64{7, 8},
+		},
+		{
+			name:  "有 OR 条件",
+			query: "UPDATE `interactives` SET `read_cnt`=? WHERE id = ? OR biz = ?",
+			args:  []any{int64(3), int64(7), "test"},
+		},
+		{
+			name:  "不是按照 id 更新",
+			query: "UPDATE `interactives` SET `read_cnt`=? WHERE biz = ? AND biz_id = ?",
+			args:  []any{int64(3), "test", int64(7)},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, parseIDs(tc.query, tc.args, tc.res))
+			assert.Equal(t, "interactives", parseTable(tc.query))
+		})
+	}
+}
+
+type result struct {
+	id       int64
+	affected int64
+}
+
+func (r result) LastInsertId() (int64, error) {
+	return r.id, nil
+}
+
+func (r result) RowsAffected() (int64, error) {
+	return r.affected, nil
+}
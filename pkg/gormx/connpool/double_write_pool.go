@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"github.com/ecodeclub/ekit/syncx/atomicx"
+	"github.com/prometheus/client_golang/prometheus"
 	"gorm.io/gorm"
 	"webook/pkg/logger"
 )
@@ -16,6 +17,11 @@ type DoubleWritePool struct {
 	pattern *atomicx.Value[string]
 
 	l logger.LoggerV1
+
+	// 次要库写入失败的时候调用，不设置的话只记录日志
+	compensator Compensator
+	// 次要库的写入结果，用来计算失败率
+	vector *prometheus.CounterVec
 }
 
 func NewDoubleWritePool(src *gorm.DB, dst *gorm.DB, l logger.LoggerV1) *DoubleWritePool {
@@ -26,6 +32,19 @@ func NewDoubleWritePool(src *gorm.DB, dst *gorm.DB, l logger.LoggerV1) *DoubleWr
 	}
 }
 
+// Compensator 次要库写入失败的时候交给 c 补偿，包括次要库开启事务和提交事务失败
+func (d *DoubleWritePool) Compensator(c Compensator) *DoubleWritePool {
+	d.compensator = c
+	return d
+}
+
+// Metrics 统计次要库的写入结果，label 是 pattern、op（begin、exec、commit）和 result（success、fail）
+func (d *DoubleWritePool) Metrics(opts prometheus.CounterOpts) *DoubleWritePool {
+	d.vector = prometheus.NewCounterVec(opts, []string{"pattern", "op", "result"})
+	prometheus.MustRegister(d.vector)
+	return d
+}
+
 func (d *DoubleWritePool) UpdatePattern(pattern string) error {
 	switch pattern {
 	case PatternSrcOnly, PatternSrcFirst, PatternDstFirst, PatternDstOnly:
@@ -48,24 +67,26 @@ func (d *DoubleWritePool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gor
 			return nil, err
 		}
 		dst, err := d.dst.(gorm.TxBeginner).BeginTx(ctx, opts)
+		d.observe(pattern, "begin", err)
 		if err != nil {
-			// 同样的，只需要源库事务开成功即可，这里只记录日志
+			// 同样的，只需要源库事务开成功即可，这里只记录日志，提交的时候补偿这个事务里面所有的写
 			// 当然，此时也可以考虑回滚掉 src 并返回 error
 			d.l.Error("双写阶段，开启目标表事务失败", logger.Error(err))
 		}
-		return &DoubleWriteTx{src: src, dst: dst, l: d.l, pattern: pattern}, nil
+		return &DoubleWriteTx{src: src, dst: dst, l: d.l, pattern: pattern, pool: d, beginErr: err}, nil
 	case PatternDstFirst:
 		dst, err := d.dst.(gorm.TxBeginner).BeginTx(ctx, opts)
 		if err != nil {
 			return nil, err
 		}
 		src, err := d.src.(gorm.TxBeginner).BeginTx(ctx, opts)
+		d.observe(pattern, "begin", err)
 		if err != nil {
-			// 同样的，只需要源库事务开成功即可，这里只记录日志
+			// 同样的，只需要源库事务开成功即可，这里只记录日志，提交的时候补偿这个事务里面所有的写
 			// 当然，此时也可以考虑回滚掉 dst 并返回 error
 			d.l.Error("双写阶段，开启源表事务失败", logger.Error(err))
 		}
-		return &DoubleWriteTx{src: src, dst: dst, l: d.l, pattern: pattern}, nil
+		return &DoubleWriteTx{src: src, dst: dst, l: d.l, pattern: pattern, pool: d, beginErr: err}, nil
 	case PatternDstOnly:
 		dst, err := d.dst.(gorm.TxBeginner).BeginTx(ctx, opts)
 		return &DoubleWriteTx{dst: dst, l: d.l, pattern: pattern}, err
@@ -86,8 +107,10 @@ func (d *DoubleWritePool) ExecContext(ctx context.Context, query string, args ..
 		res, err := d.src.ExecContext(ctx, query, args...)
 		if err == nil {
 			_, er := d.dst.ExecContext(ctx, query, args...)
+			d.observe(PatternSrcFirst, "exec", er)
 			if er != nil {
 				d.l.Error("SrcFirst阶段，写入 dst 失败", logger.Error(er), logger.String("sql", query))
+				d.compensate(ctx, write{query: query, args: args, res: res}.failed(PatternSrcFirst, er))
 			}
 		}
 		return res, err
@@ -95,8 +118,10 @@ func (d *DoubleWritePool) ExecContext(ctx context.Context, query string, args ..
 		res, err := d.dst.ExecContext(ctx, query, args...)
 		if err == nil {
 			_, er := d.src.ExecContext(ctx, query, args...)
+			d.observe(PatternDstFirst, "exec", er)
 			if er != nil {
 				d.l.Error("DstFirst阶段，写入 src 失败", logger.Error(er), logger.String("sql", query))
+				d.compensate(ctx, write{query: query, args: args, res: res}.failed(PatternDstFirst, er))
 			}
 		}
 		return res, err
@@ -143,6 +168,23 @@ func (d *DoubleWritePool) QueryRowContext(ctx context.Context, query string, arg
 	}
 }
 
+func (d *DoubleWritePool) compensate(ctx context.Context, fw FailedWrite) {
+	if d.compensator != nil {
+		d.compensator.Compensate(ctx, fw)
+	}
+}
+
+func (d *DoubleWritePool) observe(pattern, op string, err error) {
+	if d.vector == nil {
+		return
+	}
+	result := "success"
+	if err != nil {
+		result = "fail"
+	}
+	d.vector.WithLabelValues(pattern, op, result).Inc()
+}
+
 type DoubleWriteTx struct {
 	src *sql.Tx
 	dst *sql.Tx
@@ -150,7 +192,15 @@ type DoubleWriteTx struct {
 	// 事务一定是稳定不变的
 	pattern string
 
-	l logger.LoggerV1
+	l    logger.LoggerV1
+	pool *DoubleWritePool
+
+	// 开启次要库事务失败的原因，这时候主库上所有的写都要补偿
+	beginErr error
+	// 主库上执行成功的写，次要库提交失败的时候全部都要补偿
+	writes []write
+	// 次要库上执行失败的写，主库提交成功之后补偿
+	failed []write
 }
 
 func (d *DoubleWriteTx) Commit() error {
@@ -162,24 +212,14 @@ func (d *DoubleWriteTx) Commit() error {
 		if err != nil {
 			return err
 		}
-		if d.dst != nil {
-			er := d.dst.Commit()
-			if er != nil {
-				d.l.Error("SrcFirst阶段，目标表提交事务失败")
-			}
-		}
+		d.commitSecondary(d.dst)
 		return nil
 	case PatternDstFirst:
 		err := d.dst.Commit()
 		if err != nil {
 			return err
 		}
-		if d.src != nil {
-			er := d.src.Commit()
-			if er != nil {
-				d.l.Error("DstFirst阶段，源表提交事务失败")
-			}
-		}
+		d.commitSecondary(d.src)
 		return nil
 	case PatternDstOnly:
 		return d.dst.Commit()
@@ -188,6 +228,30 @@ func (d *DoubleWriteTx) Commit() error {
 	}
 }
 
+// commitSecondary 主库提交成功之后提交次要库，然后补偿次要库上没有生效的写
+func (d *DoubleWriteTx) commitSecondary(secondary *sql.Tx) {
+	// 事务已经结束了，补偿不能用事务里面的 ctx
+	ctx := context.Background()
+	if secondary == nil {
+		for _, w := range d.writes {
+			d.pool.compensate(ctx, w.failed(d.pattern, d.beginErr))
+		}
+		return
+	}
+	err := secondary.Commit()
+	d.pool.observe(d.pattern, "commit", err)
+	if err != nil {
+		d.l.Error("双写阶段，次要库提交事务失败", logger.String("pattern", d.pattern), logger.Error(err))
+		for _, w := range d.writes {
+			d.pool.compensate(ctx, w.failed(d.pattern, err))
+		}
+		return
+	}
+	for _, w := range d.failed {
+		d.pool.compensate(ctx, w.failed(d.pattern, w.err))
+	}
+}
+
 func (d *DoubleWriteTx) Rollback() error {
 	switch d.pattern {
 	case PatternSrcOnly:
@@ -233,22 +297,14 @@ func (d *DoubleWriteTx) ExecContext(ctx context.Context, query string, args ...i
 		return d.src.ExecContext(ctx, query, args...)
 	case PatternSrcFirst:
 		res, err := d.src.ExecContext(ctx, query, args...)
-		// d.dst != nil: 防止开启事务阶段 (BeginTx)，一个开启另一个失败的情况
-		if err == nil && d.dst != nil {
-			_, er := d.dst.ExecContext(ctx, query, args...)
-			if er != nil {
-				d.l.Error("SrcFirst阶段，写入 dst 失败", logger.Error(er), logger.String("sql", query))
-			}
+		if err == nil {
+			d.execSecondary(ctx, d.dst, write{query: query, args: args, res: res})
 		}
 		return res, err
 	case PatternDstFirst:
 		res, err := d.dst.ExecContext(ctx, query, args...)
-		// d.src != nil: 防止开启事务阶段 (BeginTx)，一个开启另一个失败的情况
-		if err == nil && d.src != nil {
-			_, er := d.src.ExecContext(ctx, query, args...)
-			if er != nil {
-				d.l.Error("DstFirst阶段，写入 src 失败", logger.Error(er), logger.String("sql", query))
-			}
+		if err == nil {
+			d.execSecondary(ctx, d.src, write{query: query, args: args, res: res})
 		}
 		return res, err
 	case PatternDstOnly:
@@ -258,6 +314,23 @@ func (d *DoubleWriteTx) ExecContext(ctx context.Context, query string, args ...i
 	}
 }
 
+// execSecondary 主库写成功之后写次要库，失败的写在提交之后补偿
+func (d *DoubleWriteTx) execSecondary(ctx context.Context, secondary *sql.Tx, w write) {
+	d.writes = append(d.writes, w)
+	// 防止开启事务阶段 (BeginTx)，一个开启另一个失败的情况，提交的时候一起补偿
+	if secondary == nil {
+		return
+	}
+	_, err := secondary.ExecContext(ctx, w.query, w.args...)
+	d.pool.observe(d.pattern, "exec", err)
+	if err != nil {
+		d.l.Error("双写阶段，写入次要库失败", logger.String("pattern", d.pattern),
+			logger.Error(err), logger.String("sql", w.query))
+		w.err = err
+		d.failed = append(d.failed, w)
+	}
+}
+
 func (d *DoubleWriteTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
 	switch d.pattern {
 	case PatternSrcOnly, PatternSrcFirst:
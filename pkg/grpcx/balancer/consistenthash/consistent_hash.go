@@ -0,0 +1,99 @@
+package consistenthash
+
+import (
+	"context"
+	"hash/crc32"
+	"math/rand"
+	"sort"
+	"strconv"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	Name = "custom_consistent_hash"
+	// DefaultKey 默认按照 biz_id 做哈希，同一个业务的请求总是落到同一个节点上，提高本地缓存的命中率
+	DefaultKey = "biz_id"
+	// replicas 每个节点对应的虚拟节点个数，虚拟节点越多，分布越均匀
+	replicas = 160
+)
+
+func newBuilder(name, key string) balancer.Builder {
+	return base.NewBalancerBuilder(name, &PickerBuilder{key: key}, base.Config{HealthCheck: true})
+}
+
+func init() {
+	balancer.Register(newBuilder(Name, DefaultKey))
+}
+
+// Register 注册一个按照其它 metadata 字段做哈希的负载均衡器
+// 需要在 init 阶段调用
+func Register(name, key string) {
+	balancer.Register(newBuilder(name, key))
+}
+
+// WithKey 把哈希使用的 key 放到请求的 metadata 里面
+func WithKey(ctx context.Context, key string, val int64) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, key, strconv.FormatInt(val, 10))
+}
+
+type PickerBuilder struct {
+	key string
+}
+
+func (p *PickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	conns := make([]balancer.SubConn, 0, len(info.ReadySCs))
+	nodes := make([]node, 0, len(info.ReadySCs)*replicas)
+	for sc, sci := range info.ReadySCs {
+		conns = append(conns, sc)
+		for i := 0; i < replicas; i++ {
+			nodes = append(nodes, node{
+				hash: crc32.ChecksumIEEE([]byte(sci.Address.Addr + "#" + strconv.Itoa(i))),
+				sc:   sc,
+			})
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].hash < nodes[j].hash
+	})
+	return &Picker{
+		key:   p.key,
+		nodes: nodes,
+		conns: conns,
+	}
+}
+
+// Picker 在哈希环上顺时针找到第一个虚拟节点
+// 节点上下线的时候，只有相邻区间的 key 会被重新分配
+type Picker struct {
+	key   string
+	nodes []node
+	conns []balancer.SubConn
+}
+
+type node struct {
+	hash uint32
+	sc   balancer.SubConn
+}
+
+func (p *Picker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	if len(p.nodes) == 0 {
+		return balancer.PickResult{}, balancer.ErrNoSubConnAvailable
+	}
+	md, _ := metadata.FromOutgoingContext(info.Ctx)
+	vals := md.Get(p.key)
+	if len(vals) == 0 {
+		// 没有带 key 的请求，随机挑一个
+		return balancer.PickResult{SubConn: p.conns[rand.Intn(len(p.conns))]}, nil
+	}
+	hash := crc32.ChecksumIEEE([]byte(vals[0]))
+	idx := sort.Search(len(p.nodes), func(i int) bool {
+		return p.nodes[i].hash >= hash
+	})
+	if idx == len(p.nodes) {
+		idx = 0
+	}
+	return balancer.PickResult{SubConn: p.nodes[idx].sc}, nil
+}
@@ -0,0 +1,104 @@
+package consistenthash
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConsistentHashPicker(t *testing.T) {
+	const serverCnt = 3
+	lises := make(map[string]*bufconn.Listener, serverCnt)
+	addrs := make([]resolver.Address, 0, serverCnt)
+	// 记录每个 biz_id 落到了哪些节点上
+	var lock sync.Mutex
+	hits := make(map[string]map[string]struct{})
+	for i := 0; i < serverCnt; i++ {
+		addr := fmt.Sprintf("server-%d", i)
+		lis := bufconn.Listen(1024 * 1024)
+		lises[addr] = lis
+		addrs = append(addrs, resolver.Address{Addr: addr})
+		server := grpc.NewServer(grpc.ChainUnaryInterceptor(
+			func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				bizId := bizIdFromCtx(ctx)
+				lock.Lock()
+				if hits[bizId] == nil {
+					hits[bizId] = make(map[string]struct{})
+				}
+				hits[bizId][addr] = struct{}{}
+				lock.Unlock()
+				return handler(ctx, req)
+			}))
+		healthpb.RegisterHealthServer(server, health.NewServer())
+		go func() {
+			_ = server.Serve(lis)
+		}()
+		defer server.Stop()
+	}
+
+	r := manual.NewBuilderWithScheme("hash")
+	r.InitialState(resolver.State{Addresses: addrs})
+	cc, err := grpc.Dial(r.Scheme()+":///test",
+		grpc.WithResolvers(r),
+		grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"loadBalancingConfig": [{"%s":{}}]}`, Name)),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return lises[addr].DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer cc.Close()
+
+	client := healthpb.NewHealthClient(cc)
+	// 不带 key 的请求是随机的，等到所有节点都收到过请求，说明 picker 里面已经有全部节点了
+	require.Eventually(t, func() bool {
+		_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+		lock.Lock()
+		defer lock.Unlock()
+		return len(hits[""]) == serverCnt
+	}, time.Second*5, time.Millisecond)
+	delete(hits, "")
+
+	const bizIdCnt = 50
+	for round := 0; round < 5; round++ {
+		for bizId := int64(1); bizId <= bizIdCnt; bizId++ {
+			ctx := WithKey(context.Background(), DefaultKey, bizId)
+			_, err = client.Check(ctx, &healthpb.HealthCheckRequest{})
+			require.NoError(t, err)
+		}
+	}
+
+	assert.Equal(t, bizIdCnt, len(hits))
+	servers := make(map[string]struct{})
+	for bizId, addrs := range hits {
+		// 同一个 biz_id 只会落到同一个节点上
+		assert.Equal(t, 1, len(addrs), bizId)
+		for addr := range addrs {
+			servers[addr] = struct{}{}
+		}
+	}
+	// 不同的 biz_id 会分散到不同的节点上
+	assert.Greater(t, len(servers), 1)
+}
+
+func bizIdFromCtx(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	vals := md.Get(DefaultKey)
+	if len(vals) == 0 {
+		return ""
+	}
+	return vals[0]
+}
@@ -0,0 +1,183 @@
+package p2c
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	Name = "custom_p2c_least_loaded"
+	// LoadKey 服务端通过 trailer 上报自身负载使用的 key
+	LoadKey = "x-server-load"
+	// decay 负载的指数加权平均系数，越大越看重历史数据
+	decay = 0.8
+)
+
+func newBuilder() balancer.Builder {
+	return builder{}
+}
+
+func init() {
+	balancer.Register(newBuilder())
+}
+
+// builder 每个 ClientConn 用自己的 PickerBuilder，负载统计不会串到别的 ClientConn 上
+type builder struct {
+}
+
+func (b builder) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	return base.NewBalancerBuilder(Name, NewPickerBuilder(), base.Config{HealthCheck: true}).Build(cc, opts)
+}
+
+func (b builder) Name() string {
+	return Name
+}
+
+// PickerBuilder 任何一个节点的状态变化都会重新 Build
+// 还在的节点沿用原来的 loadConn，不然负载和正在处理中的请求数每次都会清零
+type PickerBuilder struct {
+	lock  sync.Mutex
+	conns map[balancer.SubConn]*loadConn
+}
+
+func NewPickerBuilder() *PickerBuilder {
+	return &PickerBuilder{
+		conns: make(map[balancer.SubConn]*loadConn),
+	}
+}
+
+func (p *PickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	conns := make([]*loadConn, 0, len(info.ReadySCs))
+	ready := make(map[balancer.SubConn]*loadConn, len(info.ReadySCs))
+	for sc, sci := range info.ReadySCs {
+		c, ok := p.conns[sc]
+		if !ok {
+			c = &loadConn{
+				SubConn: sc,
+				addr:    sci.Address.Addr,
+			}
+		}
+		ready[sc] = c
+		conns = append(conns, c)
+	}
+	// 不可用的节点重新连上之后从头统计
+	p.conns = ready
+	return &Picker{
+		conns: conns,
+		r:     rand.New(rand.NewSource(rand.Int63())),
+	}
+}
+
+// Picker power of two choices：随机挑两个节点，选负载低的那个
+// 负载由两部分组成：服务端在 trailer 里面上报的负载，以及客户端自己统计的正在处理中的请求数
+type Picker struct {
+	conns []*loadConn
+	// rand.Rand 不是并发安全的
+	lock sync.Mutex
+	r    *rand.Rand
+}
+
+func (p *Picker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	if len(p.conns) == 0 {
+		return balancer.PickResult{}, balancer.ErrNoSubConnAvailable
+	}
+	var c *loadConn
+	if len(p.conns) == 1 {
+		c = p.conns[0]
+	} else {
+		p.lock.Lock()
+		i := p.r.Intn(len(p.conns))
+		j := p.r.Intn(len(p.conns) - 1)
+		p.lock.Unlock()
+		// 保证 i 和 j 不相等
+		if j >= i {
+			j++
+		}
+		c = p.conns[i]
+		if p.conns[j].score() < c.score() {
+			c = p.conns[j]
+		}
+	}
+
+	atomic.AddInt64(&c.inflight, 1)
+	return balancer.PickResult{
+		SubConn: c.SubConn,
+		Done: func(info balancer.DoneInfo) {
+			atomic.AddInt64(&c.inflight, -1)
+			vals := info.Trailer.Get(LoadKey)
+			if len(vals) == 0 {
+				return
+			}
+			load, err := strconv.ParseInt(vals[0], 10, 64)
+			if err != nil {
+				return
+			}
+			c.updateLoad(load)
+		},
+	}, nil
+}
+
+type loadConn struct {
+	balancer.SubConn
+	addr string
+
+	// 客户端正在等待响应的请求数
+	inflight int64
+	lock     sync.RWMutex
+	// 服务端上报的负载，经过了指数加权平均
+	load float64
+}
+
+func (c *loadConn) score() float64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return (c.load + 1) * float64(atomic.LoadInt64(&c.inflight)+1)
+}
+
+func (c *loadConn) updateLoad(load int64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.load = c.load*decay + float64(load)*(1-decay)
+}
+
+// LoadReporter 服务端拦截器，把负载放到 trailer 里面返回给客户端
+type LoadReporter struct {
+	inflight int64
+	// loadFunc 计算当前的负载，默认是正在处理中的请求数
+	// 也可以换成 CPU 使用率之类的指标
+	loadFunc func() int64
+}
+
+func NewLoadReporter() *LoadReporter {
+	r := &LoadReporter{}
+	r.loadFunc = func() int64 {
+		return atomic.LoadInt64(&r.inflight)
+	}
+	return r
+}
+
+func (r *LoadReporter) LoadFunc(fn func() int64) *LoadReporter {
+	r.loadFunc = fn
+	return r
+}
+
+func (r *LoadReporter) BuildServerUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		atomic.AddInt64(&r.inflight, 1)
+		defer func() {
+			_ = grpc.SetTrailer(ctx, metadata.Pairs(LoadKey, strconv.FormatInt(r.loadFunc(), 10)))
+			atomic.AddInt64(&r.inflight, -1)
+		}()
+		return handler(ctx, req)
+	}
+}
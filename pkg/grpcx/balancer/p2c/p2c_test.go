@@ -0,0 +1,113 @@
+package p2c
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"sync/atomic"
+	"testing"
+)
+
+func TestP2CPicker(t *testing.T) {
+	// 三个节点，负载分别是 100, 100, 1
+	loads := []int64{100, 100, 1}
+	lises := make(map[string]*bufconn.Listener, len(loads))
+	counts := make([]int64, len(loads))
+	addrs := make([]resolver.Address, 0, len(loads))
+	for i, load := range loads {
+		i, load := i, load
+		addr := fmt.Sprintf("server-%d", i)
+		lis := bufconn.Listen(1024 * 1024)
+		lises[addr] = lis
+		addrs = append(addrs, resolver.Address{Addr: addr})
+		reporter := NewLoadReporter().LoadFunc(func() int64 {
+			return load
+		})
+		server := grpc.NewServer(grpc.ChainUnaryInterceptor(
+			reporter.BuildServerUnaryInterceptor(),
+			func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				atomic.AddInt64(&counts[i], 1)
+				return handler(ctx, req)
+			}))
+		healthpb.RegisterHealthServer(server, health.NewServer())
+		go func() {
+			_ = server.Serve(lis)
+		}()
+		defer server.Stop()
+	}
+
+	r := manual.NewBuilderWithScheme("p2c")
+	r.InitialState(resolver.State{Addresses: addrs})
+	cc, err := grpc.Dial(r.Scheme()+":///test",
+		grpc.WithResolvers(r),
+		grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"loadBalancingConfig": [{"%s":{}}]}`, Name)),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return lises[addr].DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer cc.Close()
+
+	client := healthpb.NewHealthClient(cc)
+	const total = 300
+	for i := 0; i < total; i++ {
+		_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+	}
+	// 负载最低的节点只要被挑中就一定胜出，所以应该承担绝大部分请求
+	assert.Greater(t, atomic.LoadInt64(&counts[2]), int64(total/2))
+}
+
+func TestPickerBuilder_Build(t *testing.T) {
+	b := NewPickerBuilder()
+	sc1, sc2 := &testSubConn{}, &testSubConn{}
+	picker := b.Build(base.PickerBuildInfo{ReadySCs: map[balancer.SubConn]base.SubConnInfo{
+		sc1: {Address: resolver.Address{Addr: "server-1"}},
+	}}).(*Picker)
+	res, err := picker.Pick(balancer.PickInfo{})
+	require.NoError(t, err)
+	res.Done(balancer.DoneInfo{Trailer: metadata.Pairs(LoadKey, "100")})
+	// 还没有返回的请求
+	_, err = picker.Pick(balancer.PickInfo{})
+	require.NoError(t, err)
+	old := picker.conns[0]
+
+	// 新的节点上线，原来的节点沿用之前的统计
+	picker = b.Build(base.PickerBuildInfo{ReadySCs: map[balancer.SubConn]base.SubConnInfo{
+		sc1: {Address: resolver.Address{Addr: "server-1"}},
+		sc2: {Address: resolver.Address{Addr: "server-2"}},
+	}}).(*Picker)
+	require.Len(t, picker.conns, 2)
+	for _, c := range picker.conns {
+		if c.SubConn == sc1 {
+			assert.Same(t, old, c)
+			assert.Equal(t, int64(1), atomic.LoadInt64(&c.inflight))
+			assert.InDelta(t, 20, c.load, 0.001)
+			continue
+		}
+		assert.Equal(t, float64(0), c.load)
+	}
+
+	// 节点下线之后就不再保留
+	b.Build(base.PickerBuildInfo{ReadySCs: map[balancer.SubConn]base.SubConnInfo{
+		sc2: {Address: resolver.Address{Addr: "server-2"}},
+	}})
+	_, ok := b.conns[sc1]
+	assert.False(t, ok)
+}
+
+type testSubConn struct {
+	balancer.SubConn
+}
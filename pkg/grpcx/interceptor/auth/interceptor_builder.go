@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strings"
+	"webook/pkg/jwtx"
+)
+
+// InterceptorBuilder 验证终端用户的 token，token 放在 metadata 的 authorization 里面，格式是 Bearer XXX
+// 验证通过之后，可以用 jwtx.ClaimsFromContext 拿到用户信息
+type InterceptorBuilder struct {
+	verifier *jwtx.Verifier
+	// 没有带 token 的请求是否拒绝，服务之间的调用（例如定时任务）是没有用户的
+	required bool
+	skip     map[string]struct{}
+}
+
+func NewInterceptorBuilder(verifier *jwtx.Verifier) *InterceptorBuilder {
+	return &InterceptorBuilder{verifier: verifier, skip: make(map[string]struct{})}
+}
+
+// Required 没有带 token 的请求也拒绝
+func (b *InterceptorBuilder) Required() *InterceptorBuilder {
+	b.required = true
+	return b
+}
+
+// Skip 这些方法不验证，例如 /grpc.health.v1.Health/Check
+func (b *InterceptorBuilder) Skip(methods ...string) *InterceptorBuilder {
+	for _, m := range methods {
+		b.skip[m] = struct{}{}
+	}
+	return b
+}
+
+func (b *InterceptorBuilder) BuildServerUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		if _, ok := b.skip[info.FullMethod]; ok {
+			return handler(ctx, req)
+		}
+		tokenStr := b.token(ctx)
+		if tokenStr == "" {
+			if b.required {
+				return nil, status.Error(codes.Unauthenticated, "缺少 token")
+			}
+			return handler(ctx, req)
+		}
+		// 带了 token 就一定要是合法的
+		claims, err := b.verifier.Verify(tokenStr)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, "token 无效")
+		}
+		return handler(jwtx.ContextWithClaims(ctx, claims), req)
+	}
+}
+
+// ClientUnaryInterceptor 把 ctx 里面的 token 带给下游服务，见 jwtx.ContextWithToken
+func ClientUnaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if tokenStr := jwtx.TokenFromContext(ctx); tokenStr != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+tokenStr)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func (b *InterceptorBuilder) token(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	vals := md.Get("authorization")
+	if len(vals) == 0 {
+		return ""
+	}
+	segs := strings.SplitN(vals[0], " ", 2)
+	if len(segs) != 2 || !strings.EqualFold(segs[0], "Bearer") {
+		return ""
+	}
+	return segs[1]
+}
@@ -0,0 +1,200 @@
+package circuitbreaker
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/aegis/circuitbreaker"
+)
+
+type State int32
+
+const (
+	// StateClosed 正常放行
+	StateClosed State = iota
+	// StateOpen 后端接受的请求数跟不上客户端发起的请求数，开始按概率丢弃请求
+	StateOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+var _ circuitbreaker.CircuitBreaker = (*AdaptiveBreaker)(nil)
+
+// AdaptiveBreaker 基于 Google SRE 自适应限流算法的熔断器
+// 和 aegis 的 sre.Breaker 算法一致，区别在于这里维护了显式的状态，并且在状态变化的时候会回调 onStateChange
+// 这样才能把状态变迁上报到 Prometheus
+type AdaptiveBreaker struct {
+	lock  sync.Mutex
+	stat  *rollingCounter
+	r     *rand.Rand
+	state State
+
+	// k 越小越激进，越大越保守
+	k float64
+	// 统计窗口内的请求数小于 request 的时候，不会触发熔断
+	request int64
+
+	onStateChange func(from, to State)
+}
+
+type AdaptiveOption func(b *AdaptiveBreaker)
+
+// WithSuccess K = 1 / success，默认 0.6
+func WithSuccess(success float64) AdaptiveOption {
+	return func(b *AdaptiveBreaker) {
+		b.k = 1 / success
+	}
+}
+
+// WithRequest 触发熔断的最小请求数，默认 100
+func WithRequest(request int64) AdaptiveOption {
+	return func(b *AdaptiveBreaker) {
+		b.request = request
+	}
+}
+
+// WithWindow 统计窗口大小和桶的数量，默认 3s 10 个桶
+func WithWindow(window time.Duration, buckets int) AdaptiveOption {
+	return func(b *AdaptiveBreaker) {
+		b.stat = newRollingCounter(buckets, window/time.Duration(buckets))
+	}
+}
+
+// WithStateChange 状态变化的时候回调，回调在持有锁的情况下执行，不要在里面做耗时操作
+func WithStateChange(fn func(from, to State)) AdaptiveOption {
+	return func(b *AdaptiveBreaker) {
+		b.onStateChange = fn
+	}
+}
+
+func NewAdaptiveBreaker(opts ...AdaptiveOption) *AdaptiveBreaker {
+	b := &AdaptiveBreaker{
+		stat:          newRollingCounter(10, 300*time.Millisecond),
+		r:             rand.New(rand.NewSource(time.Now().UnixNano())),
+		state:         StateClosed,
+		k:             1 / 0.6,
+		request:       100,
+		onStateChange: func(from, to State) {},
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+func (b *AdaptiveBreaker) Allow() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	accepts, total := b.stat.summary(time.Now())
+	requests := b.k * float64(accepts)
+	if total < b.request || float64(total) < requests {
+		b.transit(StateClosed)
+		return nil
+	}
+	b.transit(StateOpen)
+	// 丢弃概率 max(0, (total - K * accepts) / (total + 1))
+	dr := math.Max(0, (float64(total)-requests)/float64(total+1))
+	if b.r.Float64() < dr {
+		return circuitbreaker.ErrNotAllowed
+	}
+	return nil
+}
+
+func (b *AdaptiveBreaker) MarkSuccess() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.stat.add(time.Now(), true)
+}
+
+// MarkFailed 被本地拒绝的请求同样要计数，这样丢弃概率才会继续上升
+func (b *AdaptiveBreaker) MarkFailed() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.stat.add(time.Now(), false)
+}
+
+func (b *AdaptiveBreaker) State() State {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.state
+}
+
+func (b *AdaptiveBreaker) transit(to State) {
+	if b.state == to {
+		return
+	}
+	from := b.state
+	b.state = to
+	b.onStateChange(from, to)
+}
+
+// rollingCounter 滑动窗口计数，不是并发安全的，由 AdaptiveBreaker 加锁保护
+type rollingCounter struct {
+	buckets        []bucket
+	bucketDuration time.Duration
+	// 最后一次写入的桶的下标和对应的时间
+	offset     int
+	lastAppend time.Time
+}
+
+type bucket struct {
+	success int64
+	total   int64
+}
+
+func newRollingCounter(size int, bucketDuration time.Duration) *rollingCounter {
+	return &rollingCounter{
+		buckets:        make([]bucket, size),
+		bucketDuration: bucketDuration,
+		lastAppend:     time.Now(),
+	}
+}
+
+func (r *rollingCounter) add(now time.Time, success bool) {
+	r.rotate(now)
+	b := &r.buckets[r.offset]
+	b.total++
+	if success {
+		b.success++
+	}
+}
+
+func (r *rollingCounter) summary(now time.Time) (success int64, total int64) {
+	r.rotate(now)
+	for _, b := range r.buckets {
+		success += b.success
+		total += b.total
+	}
+	return
+}
+
+// rotate 把过期的桶清零
+func (r *rollingCounter) rotate(now time.Time) {
+	span := int(now.Sub(r.lastAppend) / r.bucketDuration)
+	if span <= 0 {
+		return
+	}
+	if span > len(r.buckets) {
+		span = len(r.buckets)
+	}
+	for i := 0; i < span; i++ {
+		r.offset = (r.offset + 1) % len(r.buckets)
+		r.buckets[r.offset] = bucket{}
+	}
+	r.lastAppend = r.lastAppend.Add(time.Duration(span) * r.bucketDuration)
+	if now.Sub(r.lastAppend) >= r.bucketDuration {
+		// 长时间没有请求，直接对齐到当前时间
+		r.lastAppend = now
+	}
+}
@@ -0,0 +1,60 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestAdaptiveBreaker_StateChange(t *testing.T) {
+	var transitions []string
+	b := NewAdaptiveBreaker(WithRequest(10), WithStateChange(func(from, to State) {
+		transitions = append(transitions, from.String()+"->"+to.String())
+	}))
+
+	// 请求数不够，不会熔断
+	for i := 0; i < 9; i++ {
+		b.MarkFailed()
+	}
+	assert.NoError(t, b.Allow())
+	assert.Equal(t, StateClosed, b.State())
+
+	// 全部失败，进入 open
+	for i := 0; i < 100; i++ {
+		b.MarkFailed()
+	}
+	_ = b.Allow()
+	assert.Equal(t, StateOpen, b.State())
+
+	// 后端恢复，成功的请求足够多就回到 closed
+	for i := 0; i < 1000; i++ {
+		b.MarkSuccess()
+	}
+	assert.NoError(t, b.Allow())
+	assert.Equal(t, StateClosed, b.State())
+	assert.Equal(t, []string{"closed->open", "open->closed"}, transitions)
+}
+
+func TestIsFailure(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "成功", err: nil, want: false},
+		{name: "服务不可用", err: status.Error(codes.Unavailable, "unavailable"), want: true},
+		{name: "超时", err: status.Error(codes.DeadlineExceeded, "timeout"), want: true},
+		{name: "限流", err: status.Error(codes.ResourceExhausted, "limited"), want: true},
+		{name: "业务错误", err: status.Error(codes.InvalidArgument, "bad request"), want: false},
+		{name: "非 grpc 错误", err: errors.New("mock error"), want: false},
+		{name: "ctx 超时", err: status.FromContextError(context.DeadlineExceeded).Err(), want: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, IsFailure(tc.err))
+		})
+	}
+}
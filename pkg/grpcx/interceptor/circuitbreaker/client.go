@@ -0,0 +1,180 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/go-kratos/aegis/circuitbreaker"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Fallback 熔断之后的降级逻辑，需要把结果写到 reply 里面
+type Fallback func(ctx context.Context, method string, req, reply any) error
+
+// ClientInterceptorBuilder 客户端熔断
+// 每一个目标服务的每一个方法，都有自己独立的熔断器，某个方法出问题不会影响其它方法
+type ClientInterceptorBuilder struct {
+	Namespace  string
+	Subsystem  string
+	Name       string
+	InstanceId string
+	Help       string
+	// Registerer 默认是 prometheus.DefaultRegisterer
+	// 同样的指标已经注册过的话，直接复用，所以同一个 Builder 可以多次 Build
+	Registerer prometheus.Registerer
+
+	// NewBreaker 创建熔断器，默认是 AdaptiveBreaker
+	// onStateChange 用来上报状态变迁，自定义的熔断器如果不支持可以忽略
+	NewBreaker func(onStateChange func(from, to State)) circuitbreaker.CircuitBreaker
+
+	breakers  sync.Map
+	lock      sync.RWMutex
+	fallbacks map[string]Fallback
+	// 没有给具体方法注册降级逻辑的时候，使用这个
+	defaultFallback Fallback
+}
+
+func NewClientInterceptorBuilder() *ClientInterceptorBuilder {
+	return &ClientInterceptorBuilder{
+		Namespace:  "harmonic",
+		Subsystem:  "webook",
+		Name:       "grpc_client_breaker",
+		Help:       "统计 gRPC 客户端熔断器",
+		Registerer: prometheus.DefaultRegisterer,
+		NewBreaker: func(onStateChange func(from, to State)) circuitbreaker.CircuitBreaker {
+			return NewAdaptiveBreaker(WithStateChange(onStateChange))
+		},
+		fallbacks: make(map[string]Fallback),
+	}
+}
+
+// RegisterFallback 给某个方法注册降级逻辑，method 是完整的方法名，例如 /intr.v1.InteractiveService/Get
+func (b *ClientInterceptorBuilder) RegisterFallback(method string, fallback Fallback) *ClientInterceptorBuilder {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.fallbacks[method] = fallback
+	return b
+}
+
+// DefaultFallback 所有方法共用的降级逻辑
+func (b *ClientInterceptorBuilder) DefaultFallback(fallback Fallback) *ClientInterceptorBuilder {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.defaultFallback = fallback
+	return b
+}
+
+func (b *ClientInterceptorBuilder) BuildUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	labels := []string{"target", "method"}
+	stateGauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: b.Namespace,
+		Subsystem: b.Subsystem,
+		Name:      b.Name + "_state",
+		Help:      b.Help,
+		ConstLabels: map[string]string{
+			"instance_id": b.InstanceId,
+		},
+	}, labels)
+	transitions := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: b.Namespace,
+		Subsystem: b.Subsystem,
+		Name:      b.Name + "_transitions",
+		Help:      b.Help,
+		ConstLabels: map[string]string{
+			"instance_id": b.InstanceId,
+		},
+	}, append(labels, "from", "to"))
+	rejected := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: b.Namespace,
+		Subsystem: b.Subsystem,
+		Name:      b.Name + "_rejected",
+		Help:      b.Help,
+		ConstLabels: map[string]string{
+			"instance_id": b.InstanceId,
+		},
+	}, append(labels, "fallback"))
+	stateGauge = register(b.Registerer, stateGauge)
+	transitions = register(b.Registerer, transitions)
+	rejected = register(b.Registerer, rejected)
+
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		target := cc.Target()
+		breaker := b.breaker(target, method, stateGauge, transitions)
+		if err := breaker.Allow(); err != nil {
+			breaker.MarkFailed()
+			fallback := b.fallback(method)
+			if fallback == nil {
+				rejected.WithLabelValues(target, method, "false").Inc()
+				return status.Errorf(codes.Unavailable, "熔断")
+			}
+			rejected.WithLabelValues(target, method, "true").Inc()
+			return fallback(ctx, method, req, reply)
+		}
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if IsFailure(err) {
+			breaker.MarkFailed()
+		} else {
+			breaker.MarkSuccess()
+		}
+		return err
+	}
+}
+
+// register 注册 c，已经注册过的话返回之前注册的那个
+func register[T prometheus.Collector](r prometheus.Registerer, c T) T {
+	err := r.Register(c)
+	if err == nil {
+		return c
+	}
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		if existing, ok := are.ExistingCollector.(T); ok {
+			return existing
+		}
+	}
+	panic(err)
+}
+
+// breaker 绝大部分请求都能直接 Load 到，只有第一次调用某个方法的时候才创建熔断器
+// 并发创建的时候多出来的那个直接丢掉，它还没有被用过，不会上报状态
+func (b *ClientInterceptorBuilder) breaker(target, method string,
+	stateGauge *prometheus.GaugeVec, transitions *prometheus.CounterVec) circuitbreaker.CircuitBreaker {
+	key := target + method
+	val, ok := b.breakers.Load(key)
+	if ok {
+		return val.(circuitbreaker.CircuitBreaker)
+	}
+	val, _ = b.breakers.LoadOrStore(key, b.NewBreaker(func(from, to State) {
+		stateGauge.WithLabelValues(target, method).Set(float64(to))
+		transitions.WithLabelValues(target, method, from.String(), to.String()).Inc()
+	}))
+	return val.(circuitbreaker.CircuitBreaker)
+}
+
+func (b *ClientInterceptorBuilder) fallback(method string) Fallback {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	fallback, ok := b.fallbacks[method]
+	if ok {
+		return fallback
+	}
+	return b.defaultFallback
+}
+
+// IsFailure 判断 err 是否应该计入熔断器的失败次数
+// 业务错误（参数错误、找不到数据等）说明服务端是正常的，不应该触发熔断
+func IsFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
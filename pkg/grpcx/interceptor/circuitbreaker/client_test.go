@@ -0,0 +1,80 @@
+package circuitbreaker
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/go-kratos/aegis/circuitbreaker"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// unavailableHealthServer 模拟一个已经不可用的服务端
+type unavailableHealthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+}
+
+func (s *unavailableHealthServer) Check(ctx context.Context,
+	req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	return nil, status.Error(codes.Unavailable, "unavailable")
+}
+
+func TestClientInterceptorBuilder_Fallback(t *testing.T) {
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	grpc_health_v1.RegisterHealthServer(server, &unavailableHealthServer{})
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	defer server.Stop()
+
+	var fallbackCnt, newCnt int
+	var breaker *AdaptiveBreaker
+	b := NewClientInterceptorBuilder()
+	b.Registerer = prometheus.NewRegistry()
+	b.NewBreaker = func(onStateChange func(from, to State)) circuitbreaker.CircuitBreaker {
+		newCnt++
+		breaker = NewAdaptiveBreaker(WithRequest(10), WithStateChange(onStateChange))
+		return breaker
+	}
+	b.RegisterFallback(grpc_health_v1.Health_Check_FullMethodName,
+		func(ctx context.Context, method string, req, reply any) error {
+			fallbackCnt++
+			reply.(*grpc_health_v1.HealthCheckResponse).Status = grpc_health_v1.HealthCheckResponse_SERVING
+			return nil
+		})
+	// 同一个 Builder 多次 Build 不会因为重复注册指标而 panic
+	_ = b.BuildUnaryClientInterceptor()
+
+	cc, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(b.BuildUnaryClientInterceptor()))
+	require.NoError(t, err)
+	defer cc.Close()
+	client := grpc_health_v1.NewHealthClient(cc)
+
+	// 服务端一直返回 Unavailable，熔断器打开之后会有越来越多的请求走降级逻辑
+	for i := 0; i < 200; i++ {
+		resp, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		if err != nil {
+			assert.Equal(t, codes.Unavailable, status.Code(err))
+			continue
+		}
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.Status)
+	}
+	assert.Equal(t, StateOpen, breaker.State())
+	assert.True(t, fallbackCnt > 0)
+	// 同一个方法只创建一次熔断器
+	assert.Equal(t, 1, newCnt)
+}
@@ -5,94 +5,231 @@ import (
 	etcdv3 "go.etcd.io/etcd/client/v3"
 	"go.etcd.io/etcd/client/v3/naming/endpoints"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"net"
 	"strconv"
+	"sync"
 	"time"
 	"webook/pkg/logger"
 	"webook/pkg/netx"
 )
 
 // Server 给 grpc.Server 做了一个封装
+// 生命周期：
+// 启动：注册健康检查服务 -> 监听端口并开始服务 -> 注册到 etcd -> 健康检查置为 SERVING
+// 关闭：健康检查置为 NOT_SERVING -> 从 etcd 删除 -> 等待 DrainTimeout 让客户端感知 -> GracefulStop
 type Server struct {
 	*grpc.Server
 	Port     int
 	EtcdAddr string
 	Name     string
+	// 如果没有注入，就用 EtcdAddr 自己创建一个，并且在 Close 的时候关掉
 	Client   *etcdv3.Client
 	KaCancel context.CancelFunc
 
 	L logger.LoggerV1
 
-	// ETCD 服务注册租约 TTL
+	// ETCD 服务注册租约 TTL，单位是秒
 	EtcdTTL int64
+	// DrainTimeout 从注册中心摘除之后，等待客户端更新节点列表的时间
+	DrainTimeout time.Duration
+	// DisableHealth 不注册健康检查服务，例如业务方已经自己注册了
+	DisableHealth bool
+
+	// lock 保护下面的字段，Serve 和 Close 一般在不同的 goroutine 里面调用
+	lock      sync.Mutex
+	closed    bool
+	health    *health.Server
+	em        endpoints.Manager
+	key       string
+	ownClient bool
+	closeOnce sync.Once
 }
 
+const (
+	defaultEtcdTTL      = 5
+	defaultDrainTimeout = time.Second * 3
+)
+
 func (s *Server) Serve() error {
 	addr := ":" + strconv.Itoa(s.Port)
 	l, err := net.Listen("tcp", addr)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	// 完成服务注册
-	err = s.registerEtcd()
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		_ = l.Close()
+		return grpc.ErrServerStopped
+	}
+	// 健康检查服务必须在 Serve 之前注册
+	s.registerHealth()
+	s.lock.Unlock()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.Server.Serve(l)
+	}()
+
+	// 已经开始服务了，再完成服务注册，避免客户端拿到地址但是连不上
+	s.lock.Lock()
+	// 注册期间被关闭了，就不要再注册上去了
+	if !s.closed {
+		err = s.register()
+		if err == nil && s.health != nil {
+			s.health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+		}
+	}
+	s.lock.Unlock()
 	if err != nil {
+		s.Server.Stop()
 		return err
 	}
 
-	return s.Server.Serve(l)
+	return <-errCh
+}
+
+// registerHealth 同一个 grpc.Server 上重复注册会 panic，已经注册过的就跳过
+func (s *Server) registerHealth() {
+	if s.DisableHealth {
+		return
+	}
+	if _, ok := s.Server.GetServiceInfo()[healthpb.Health_ServiceDesc.ServiceName]; ok {
+		s.L.Warn("健康检查服务已经注册过了，跳过", logger.String("name", s.Name))
+		return
+	}
+	s.health = health.NewServer()
+	s.health.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(s.Server, s.health)
 }
 
-func (s *Server) registerEtcd() error {
-	etcdClient, err := etcdv3.NewFromURL(s.EtcdAddr)
+// register 调用方要持有 lock
+func (s *Server) register() error {
+	if s.Client == nil {
+		etcdClient, err := etcdv3.NewFromURL(s.EtcdAddr)
+		if err != nil {
+			return err
+		}
+		s.Client = etcdClient
+		s.ownClient = true
+	}
+
+	em, err := endpoints.NewManager(s.Client, "service/"+s.Name)
 	if err != nil {
 		return err
 	}
-	s.Client = etcdClient
+	s.em = em
+	addr := netx.GetOutboundIP() + ":" + strconv.Itoa(s.Port)
+	s.key = "service/" + s.Name + "/" + addr
 
-	em, err := endpoints.NewManager(s.Client, "service/"+s.Name)
+	leaseID, err := s.addEndpoint(addr)
+	if err != nil {
+		return err
+	}
+
+	// 续约
+	kaCtx, kaCancel := context.WithCancel(context.Background())
+	s.KaCancel = kaCancel
+	go s.keepAlive(kaCtx, addr, leaseID)
+	return nil
+}
 
+func (s *Server) addEndpoint(addr string) (etcdv3.LeaseID, error) {
+	ttl := s.EtcdTTL
+	if ttl <= 0 {
+		ttl = defaultEtcdTTL
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
-	var ttl int64 = 5 // 单位是秒
 	leaseResp, err := s.Client.Grant(ctx, ttl)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
-	addr := netx.GetOutboundIP() + ":" + strconv.Itoa(s.Port)
-	key := "service/" + s.Name + "/" + addr
-	err = em.AddEndpoint(ctx, key, endpoints.Endpoint{
+	err = s.em.AddEndpoint(ctx, s.key, endpoints.Endpoint{
 		// 定位信息
 		Addr: addr,
 	}, etcdv3.WithLease(leaseResp.ID))
-	if err != nil {
-		return err
-	}
+	return leaseResp.ID, err
+}
 
-	// 续约
-	kaCtx, kaCancel := context.WithCancel(context.Background())
-	s.KaCancel = kaCancel
-	ch, err := s.Client.KeepAlive(kaCtx, leaseResp.ID)
-	go func() {
-		for kaResp := range ch {
-			s.L.Debug(kaResp.String())
+// keepAlive 续约，如果租约丢了（例如 etcd 重启、网络长时间不通），就重新注册
+func (s *Server) keepAlive(ctx context.Context, addr string, leaseID etcdv3.LeaseID) {
+	for {
+		ch, err := s.Client.KeepAlive(ctx, leaseID)
+		if err == nil {
+			for kaResp := range ch {
+				s.L.Debug(kaResp.String())
+			}
 		}
-	}()
+		// channel 被关闭，要么是主动关闭，要么是租约丢失
+		if ctx.Err() != nil {
+			return
+		}
+		s.L.Warn("服务注册租约丢失，准备重新注册",
+			logger.String("key", s.key), logger.Error(err))
 
-	return err
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			leaseID, err = s.addEndpoint(addr)
+			if err == nil {
+				break
+			}
+			s.L.Error("重新注册失败", logger.String("key", s.key), logger.Error(err))
+		}
+	}
 }
 
 func (s *Server) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		err = s.close()
+	})
+	return err
+}
+
+func (s *Server) close() error {
+	s.lock.Lock()
+	s.closed = true
+	if s.health != nil {
+		s.health.Shutdown()
+	}
 	if s.KaCancel != nil {
 		s.KaCancel()
 	}
-	if s.Client != nil {
-		// 如果采用依赖注入的形式初始化 etcd 客户端，就不需要我去关了
-		return s.Client.Close()
+	em, key := s.em, s.key
+	client, ownClient := s.Client, s.ownClient
+	s.lock.Unlock()
+
+	var err error
+	if em != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		err = em.DeleteEndpoint(ctx, key)
+		cancel()
+		if err != nil {
+			s.L.Error("删除服务注册信息失败", logger.String("key", key), logger.Error(err))
+		}
+
+		// 等待客户端感知到节点下线，这期间还能正常处理请求
+		drain := s.DrainTimeout
+		if drain <= 0 {
+			drain = defaultDrainTimeout
+		}
+		time.Sleep(drain)
 	}
 	s.GracefulStop()
-	return nil
+	if ownClient {
+		// 如果采用依赖注入的形式初始化 etcd 客户端，就不需要我去关了
+		return client.Close()
+	}
+	return err
 }
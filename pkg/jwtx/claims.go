@@ -0,0 +1,54 @@
+package jwtx
+
+import (
+	"context"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// TokenTypeAccess 短 token，用来访问接口
+	TokenTypeAccess = "access"
+	// TokenTypeRefresh 长 token，只能用来换短 token
+	TokenTypeRefresh = "refresh"
+)
+
+// ErrTokenType 例如拿长 token 访问接口
+var ErrTokenType = errors.New("jwtx: token 类型不对")
+
+// UserClaims 短 token 里面的用户信息，web 和各个 gRPC 服务共用
+type UserClaims struct {
+	jwt.RegisteredClaims
+	// Typ 固定是 TokenTypeAccess，升级之前签发的没有这个字段，需要重新刷新
+	Typ       string `json:"typ"`
+	UserId    int64
+	Ssid      string
+	UserAgent string
+	// Roles 登录的时候用户的角色，改了角色需要重新登录
+	Roles []string
+}
+
+// TokenKey 登录校验通过之后，原始的短 token 放在 ctx 里面的 key
+// 用字符串是因为 gin.Context 只认字符串的 key，这样 *gin.Context 直接当 context.Context 用也能拿到
+const TokenKey = "jwtx-token"
+
+type claimsKey struct{}
+
+func ContextWithClaims(ctx context.Context, claims UserClaims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+func ClaimsFromContext(ctx context.Context) (UserClaims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(UserClaims)
+	return claims, ok
+}
+
+// ContextWithToken 调用下游 gRPC 服务的时候，由客户端拦截器带上
+func ContextWithToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, TokenKey, token)
+}
+
+func TokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(TokenKey).(string)
+	return token
+}
@@ -0,0 +1,298 @@
+package jwtx
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"os"
+	"time"
+)
+
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+var (
+	ErrUnknownKey = errors.New("jwtx: 未知的 kid")
+	ErrKeyRetired = errors.New("jwtx: 密钥已经停用")
+)
+
+// Key 一把密钥，只有公钥的时候只能用来验证
+type Key struct {
+	Kid     string
+	Alg     string
+	Private crypto.Signer
+	Public  crypto.PublicKey
+	// VerifyUntil 轮换下来的旧密钥只用来验证，过了这个时间之后用它签发的 token 全部失效
+	// 零值表示不限制
+	VerifyUntil time.Time
+}
+
+func (k *Key) method() jwt.SigningMethod {
+	if k.Alg == AlgEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// KeyConfig 配置文件里面的一把密钥，密钥都是 PEM 文件的路径
+type KeyConfig struct {
+	Kid string `yaml:"kid"`
+	// Alg RS256 或者 EdDSA
+	Alg string `yaml:"alg"`
+	// PrivateKey PKCS8 格式的私钥，轮换下来的旧密钥可以不配置
+	PrivateKey string `yaml:"privateKey"`
+	// PublicKey PKIX 格式的公钥，配置了私钥的话可以不配置
+	PublicKey string `yaml:"publicKey"`
+	// VerifyUntil RFC3339 格式，轮换下来的旧密钥一般设置成轮换时间加上 token 的有效期
+	VerifyUntil string `yaml:"verifyUntil"`
+}
+
+// KeySetConfig Active 是用来签发的密钥，其它的只用来验证
+type KeySetConfig struct {
+	Active string      `yaml:"active"`
+	Keys   []KeyConfig `yaml:"keys"`
+}
+
+// KeySet 用 active 签发，用 kid 找到对应的密钥验证
+type KeySet struct {
+	active *Key
+	keys   map[string]*Key
+}
+
+func NewKeySet(active *Key, others ...*Key) *KeySet {
+	ks := &KeySet{active: active, keys: make(map[string]*Key, len(others)+1)}
+	if active != nil {
+		ks.keys[active.Kid] = active
+	}
+	for _, k := range others {
+		ks.keys[k.Kid] = k
+	}
+	return ks
+}
+
+// LoadKeySet 从配置加载，Active 必须有私钥
+func LoadKeySet(cfg KeySetConfig) (*KeySet, error) {
+	ks := NewKeySet(nil)
+	for _, kc := range cfg.Keys {
+		k, err := loadKey(kc)
+		if err != nil {
+			return nil, fmt.Errorf("jwtx: 加载密钥 %s 失败 %w", kc.Kid, err)
+		}
+		ks.keys[k.Kid] = k
+	}
+	active, ok := ks.keys[cfg.Active]
+	if !ok || active.Private == nil {
+		return nil, fmt.Errorf("jwtx: 没有找到用来签发的密钥 %s", cfg.Active)
+	}
+	ks.active = active
+	return ks, nil
+}
+
+// GenerateKey 生成一把 Ed25519 密钥，只适合开发环境，重启之后签发的 token 全部失效
+func GenerateKey(kid string) (*Key, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{Kid: kid, Alg: AlgEdDSA, Private: priv, Public: pub}, nil
+}
+
+// Sign 用 active 签发，header 里面带上 kid
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if ks.active == nil || ks.active.Private == nil {
+		return "", errors.New("jwtx: 没有用来签发的密钥")
+	}
+	token := jwt.NewWithClaims(ks.active.method(), claims)
+	token.Header["kid"] = ks.active.Kid
+	return token.SignedString(ks.active.Private)
+}
+
+// Keyfunc 给 jwt.ParseWithClaims 用，算法必须和 kid 对应的密钥一致
+func (ks *KeySet) Keyfunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	k, ok := ks.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != k.method().Alg() {
+		return nil, fmt.Errorf("jwtx: kid %s 不能使用算法 %s", kid, token.Method.Alg())
+	}
+	if !k.VerifyUntil.IsZero() && time.Now().After(k.VerifyUntil) {
+		return nil, ErrKeyRetired
+	}
+	return k.Public, nil
+}
+
+// Parse 验证签名和有效期
+func (ks *KeySet) Parse(tokenStr string, claims jwt.Claims, opts ...jwt.ParserOption) error {
+	opts = append(opts, jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA}))
+	token, err := jwt.ParseWithClaims(tokenStr, claims, ks.Keyfunc, opts...)
+	if err != nil {
+		return err
+	}
+	if !token.Valid {
+		return errors.New("jwtx: token 无效")
+	}
+	return nil
+}
+
+// JWKS 所有还能用来验证的公钥
+func (ks *KeySet) JWKS() JWKS {
+	res := JWKS{Keys: make([]JWK, 0, len(ks.keys))}
+	now := time.Now()
+	for _, k := range ks.keys {
+		if !k.VerifyUntil.IsZero() && now.After(k.VerifyUntil) {
+			continue
+		}
+		jwk, err := newJWK(k)
+		if err != nil {
+			continue
+		}
+		res.Keys = append(res.Keys, jwk)
+	}
+	return res
+}
+
+// JWKS RFC 7517
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+func newJWK(k *Key) (JWK, error) {
+	jwk := JWK{Kid: k.Kid, Alg: k.Alg, Use: "sig"}
+	switch pub := k.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	default:
+		return JWK{}, fmt.Errorf("jwtx: 不支持的公钥类型 %T", pub)
+	}
+	return jwk, nil
+}
+
+// Key 从 JWK 还原出只能验证的密钥
+func (j JWK) Key() (*Key, error) {
+	k := &Key{Kid: j.Kid, Alg: j.Alg}
+	switch j.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil {
+			return nil, err
+		}
+		k.Public = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if k.Alg == "" {
+			k.Alg = AlgRS256
+		}
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		if j.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("jwtx: 不支持的曲线 %s", j.Crv)
+		}
+		k.Public = ed25519.PublicKey(x)
+		if k.Alg == "" {
+			k.Alg = AlgEdDSA
+		}
+	default:
+		return nil, fmt.Errorf("jwtx: 不支持的密钥类型 %s", j.Kty)
+	}
+	return k, nil
+}
+
+func loadKey(kc KeyConfig) (*Key, error) {
+	if kc.Alg != AlgRS256 && kc.Alg != AlgEdDSA {
+		return nil, fmt.Errorf("不支持的算法 %s", kc.Alg)
+	}
+	k := &Key{Kid: kc.Kid, Alg: kc.Alg}
+	if kc.VerifyUntil != "" {
+		t, err := time.Parse(time.RFC3339, kc.VerifyUntil)
+		if err != nil {
+			return nil, err
+		}
+		k.VerifyUntil = t
+	}
+	if kc.PrivateKey != "" {
+		block, err := readPEM(kc.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		priv, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := priv.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("不支持的私钥类型 %T", priv)
+		}
+		k.Private = signer
+		k.Public = signer.Public()
+	} else {
+		block, err := readPEM(kc.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		k.Public, err = x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+	switch k.Public.(type) {
+	case *rsa.PublicKey:
+		if k.Alg != AlgRS256 {
+			return nil, errors.New("RSA 密钥只能用于 RS256")
+		}
+	case ed25519.PublicKey:
+		if k.Alg != AlgEdDSA {
+			return nil, errors.New("Ed25519 密钥只能用于 EdDSA")
+		}
+	default:
+		return nil, fmt.Errorf("不支持的公钥类型 %T", k.Public)
+	}
+	return k, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s 不是 PEM 格式", path)
+	}
+	return block, nil
+}
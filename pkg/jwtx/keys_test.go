@@ -0,0 +1,115 @@
+package jwtx
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestKeySet(t *testing.T) {
+	edKey, err := GenerateKey("ed")
+	require.NoError(t, err)
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaKey := &Key{Kid: "rsa", Alg: AlgRS256, Private: rsaPriv, Public: rsaPriv.Public()}
+	oldKey, err := GenerateKey("old")
+	require.NoError(t, err)
+	oldKey.VerifyUntil = time.Now().Add(-time.Minute)
+
+	claims := func() UserClaims {
+		return UserClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+			UserId: 123,
+			Ssid:   "ssid",
+		}
+	}
+
+	testCases := []struct {
+		name string
+		// 签发用的
+		signer *KeySet
+		// 验证用的
+		verifier *KeySet
+		wantErr  error
+	}{
+		{
+			name:     "EdDSA",
+			signer:   NewKeySet(edKey),
+			verifier: NewKeySet(edKey),
+		},
+		{
+			name:     "RS256",
+			signer:   NewKeySet(rsaKey),
+			verifier: NewKeySet(rsaKey),
+		},
+		{
+			name:     "轮换之后旧的 token 还能验证",
+			signer:   NewKeySet(rsaKey),
+			verifier: NewKeySet(edKey, rsaKey),
+		},
+		{
+			name:     "只有 JWKS 里面的公钥",
+			signer:   NewKeySet(edKey, rsaKey),
+			verifier: fromJWKS(t, NewKeySet(edKey, rsaKey).JWKS()),
+		},
+		{
+			name:     "未知的 kid",
+			signer:   NewKeySet(rsaKey),
+			verifier: NewKeySet(edKey),
+			wantErr:  ErrUnknownKey,
+		},
+		{
+			name:     "旧密钥已经停用",
+			signer:   NewKeySet(oldKey),
+			verifier: NewKeySet(edKey, oldKey),
+			wantErr:  ErrKeyRetired,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tokenStr, err := tc.signer.Sign(claims())
+			require.NoError(t, err)
+			var res UserClaims
+			err = tc.verifier.Parse(tokenStr, &res)
+			assert.ErrorIs(t, err, tc.wantErr)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, int64(123), res.UserId)
+			assert.Equal(t, "ssid", res.Ssid)
+		})
+	}
+}
+
+func TestKeySet_JWKS(t *testing.T) {
+	edKey, err := GenerateKey("ed")
+	require.NoError(t, err)
+	oldKey, err := GenerateKey("old")
+	require.NoError(t, err)
+	oldKey.VerifyUntil = time.Now().Add(-time.Minute)
+	jwks := NewKeySet(edKey, oldKey).JWKS()
+	// 停用的密钥不再公开
+	require.Len(t, jwks.Keys, 1)
+	k, err := jwks.Keys[0].Key()
+	require.NoError(t, err)
+	assert.Equal(t, "ed", k.Kid)
+	assert.Equal(t, AlgEdDSA, k.Alg)
+	assert.Equal(t, edKey.Public, k.Public)
+	assert.Nil(t, k.Private)
+}
+
+func fromJWKS(t *testing.T, jwks JWKS) *KeySet {
+	keys := make([]*Key, 0, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		k, err := jwk.Key()
+		require.NoError(t, err)
+		keys = append(keys, k)
+	}
+	return NewKeySet(nil, keys...)
+}
@@ -0,0 +1,177 @@
+package jwtx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+	"webook/pkg/logger"
+)
+
+// ErrNotReady 还没有拉取到 JWKS
+var ErrNotReady = errors.New("jwtx: 还没有拉取到 JWKS")
+
+// Verifier 验证短 token，解析出 UserClaims
+// gRPC 服务用 NewRemoteVerifier 从 web 的 JWKS 接口拿公钥，不需要和 web 共享密钥
+type Verifier struct {
+	lock sync.RWMutex
+	keys *KeySet
+
+	// 下面是远程 JWKS，本地密钥的时候不用
+	url      string
+	client   *http.Client
+	interval time.Duration
+	// 遇到未知的 kid 立刻刷新，但是两次刷新至少间隔 minInterval，防止被伪造的 kid 打爆
+	minInterval time.Duration
+	refreshing  sync.Mutex
+	utime       time.Time
+	l           logger.LoggerV1
+}
+
+func NewVerifier(keys *KeySet) *Verifier {
+	return &Verifier{keys: keys}
+}
+
+// NewRemoteVerifier 在后台拉取 url 上的 JWKS，第一次拉取成功之前所有的 token 都验证不通过
+// 这样 web 暂时不可用的时候，服务也能正常启动
+// 之后超过 interval 就在后台刷新，密钥轮换的时候，新的公钥会先出现在 JWKS 里面，所以刷新间隔只要小于轮换的提前量就行
+func NewRemoteVerifier(url string, interval time.Duration, l logger.LoggerV1) *Verifier {
+	v := &Verifier{
+		keys:        NewKeySet(nil),
+		url:         url,
+		client:      &http.Client{Timeout: time.Second * 3},
+		interval:    interval,
+		minInterval: time.Second * 10,
+		l:           l,
+	}
+	go v.init()
+	return v
+}
+
+// init 一直重试，直到第一次拉取成功
+func (v *Verifier) init() {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+		err := v.Refresh(ctx)
+		cancel()
+		if err == nil {
+			return
+		}
+		v.l.Error("拉取 JWKS 失败，稍后重试", logger.String("url", v.url), logger.Error(err))
+		time.Sleep(v.minInterval)
+	}
+}
+
+// Verify 验证签名、有效期和 token 类型
+func (v *Verifier) Verify(tokenStr string) (UserClaims, error) {
+	claims, err := v.parse(tokenStr)
+	if err != nil {
+		return claims, err
+	}
+	if claims.Typ != TokenTypeAccess {
+		return claims, ErrTokenType
+	}
+	return claims, nil
+}
+
+func (v *Verifier) parse(tokenStr string) (UserClaims, error) {
+	var claims UserClaims
+	if v.url != "" && !v.ready() {
+		return claims, ErrNotReady
+	}
+	err := v.keySet().Parse(tokenStr, &claims)
+	if v.url == "" {
+		return claims, err
+	}
+	if errors.Is(err, ErrUnknownKey) && v.stale(v.minInterval) {
+		// 可能是刚轮换的新密钥
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+		defer cancel()
+		v.refreshing.Lock()
+		var er error
+		// 拿到锁之前可能别的请求已经刷新过了
+		if v.stale(v.minInterval) {
+			er = v.refresh(ctx)
+		}
+		v.refreshing.Unlock()
+		if er != nil {
+			return claims, err
+		}
+		claims = UserClaims{}
+		err = v.keySet().Parse(tokenStr, &claims)
+	} else if v.stale(v.interval) && v.refreshing.TryLock() {
+		// 已经有别的请求在刷新了就不用管
+		go func() {
+			defer v.refreshing.Unlock()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+			defer cancel()
+			if er := v.refresh(ctx); er != nil {
+				v.l.Error("刷新 JWKS 失败", logger.String("url", v.url), logger.Error(er))
+			}
+		}()
+	}
+	return claims, err
+}
+
+// Refresh 重新拉取 JWKS，同一时间只有一个刷新
+func (v *Verifier) Refresh(ctx context.Context) error {
+	v.refreshing.Lock()
+	defer v.refreshing.Unlock()
+	return v.refresh(ctx)
+}
+
+func (v *Verifier) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("jwtx: 拉取 JWKS 失败，状态码 %d", resp.StatusCode)
+	}
+	var jwks JWKS
+	err = json.NewDecoder(resp.Body).Decode(&jwks)
+	if err != nil {
+		return err
+	}
+	keys := make([]*Key, 0, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		k, er := jwk.Key()
+		if er != nil {
+			// 不认识的密钥跳过，不影响其它的
+			continue
+		}
+		keys = append(keys, k)
+	}
+	v.lock.Lock()
+	v.keys = NewKeySet(nil, keys...)
+	v.utime = time.Now()
+	v.lock.Unlock()
+	return nil
+}
+
+func (v *Verifier) keySet() *KeySet {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+	return v.keys
+}
+
+// ready 是否已经拉取成功过
+func (v *Verifier) ready() bool {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+	return !v.utime.IsZero()
+}
+
+func (v *Verifier) stale(interval time.Duration) bool {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+	return time.Since(v.utime) > interval
+}
@@ -0,0 +1,81 @@
+package jwtx
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+	"webook/pkg/logger"
+)
+
+func TestRemoteVerifier(t *testing.T) {
+	key, err := GenerateKey("ed")
+	require.NoError(t, err)
+	ks := NewKeySet(key)
+	// 一开始 web 还没有启动
+	var up atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(ks.JWKS())
+	}))
+	defer server.Close()
+
+	sign := func(typ string) string {
+		tokenStr, err := ks.Sign(UserClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+			Typ:    typ,
+			UserId: 123,
+		})
+		require.NoError(t, err)
+		return tokenStr
+	}
+
+	v := NewRemoteVerifier(server.URL, time.Minute, logger.NewNoOpLogger())
+	_, err = v.Verify(sign(TokenTypeAccess))
+	assert.ErrorIs(t, err, ErrNotReady)
+
+	up.Store(true)
+	require.NoError(t, v.Refresh(context.Background()))
+
+	testCases := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{
+			name:  "短 token",
+			token: sign(TokenTypeAccess),
+		},
+		{
+			name:    "长 token 不能用来访问接口",
+			token:   sign(TokenTypeRefresh),
+			wantErr: ErrTokenType,
+		},
+		{
+			name:    "升级之前签发的没有类型",
+			token:   sign(""),
+			wantErr: ErrTokenType,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims, err := v.Verify(tc.token)
+			assert.ErrorIs(t, err, tc.wantErr)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, int64(123), claims.UserId)
+		})
+	}
+}
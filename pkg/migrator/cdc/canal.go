@@ -0,0 +1,58 @@
+package cdc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// CanalMessage canal 的 flatMessage 格式，也就是投递到 kafka 的 JSON
+type CanalMessage struct {
+	Database string `json:"database"`
+	Table    string `json:"table"`
+	Type     string `json:"type"`
+	IsDdl    bool   `json:"isDdl"`
+	// Data 变更之后的数据，DELETE 的时候是被删除的数据，列的值都是字符串
+	Data []map[string]any `json:"data"`
+	// Ts 投递的时间，毫秒
+	Ts int64 `json:"ts"`
+}
+
+// RowEvents 拆成一行一个事件，DDL 和不认识的类型直接忽略
+func (m CanalMessage) RowEvents() ([]RowEvent, error) {
+	if m.IsDdl {
+		return nil, nil
+	}
+	switch m.Type {
+	case TypeInsert, TypeUpdate, TypeDelete:
+	default:
+		return nil, nil
+	}
+	res := make([]RowEvent, 0, len(m.Data))
+	for _, row := range m.Data {
+		id, err := parseID(row["id"])
+		if err != nil {
+			return nil, fmt.Errorf("cdc: %s.%s 的 id 不合法 %w", m.Database, m.Table, err)
+		}
+		res = append(res, RowEvent{
+			Database: m.Database,
+			Table:    m.Table,
+			Type:     m.Type,
+			ID:       id,
+		})
+	}
+	return res, nil
+}
+
+func parseID(val any) (int64, error) {
+	switch v := val.(type) {
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	case float64:
+		return int64(v), nil
+	case json.Number:
+		return v.Int64()
+	default:
+		return 0, fmt.Errorf("不支持的类型 %T", val)
+	}
+}
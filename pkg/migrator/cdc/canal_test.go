@@ -0,0 +1,55 @@
+package cdc
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCanalMessage_RowEvents(t *testing.T) {
+	testCases := []struct {
+		name    string
+		msg     string
+		want    []RowEvent
+		wantErr bool
+	}{
+		{
+			name: "更新多行",
+			msg:  `{"database":"webook","table":"interactives","type":"UPDATE","isDdl":false,"data":[{"id":"1","read_cnt":"2"},{"id":"3","read_cnt":"4"}]}`,
+			want: []RowEvent{
+				{Database: "webook", Table: "interactives", Type: TypeUpdate, ID: 1},
+				{Database: "webook", Table: "interactives", Type: TypeUpdate, ID: 3},
+			},
+		},
+		{
+			name: "删除",
+			msg:  `{"database":"webook","table":"interactives","type":"DELETE","isDdl":false,"data":[{"id":"5"}]}`,
+			want: []RowEvent{
+				{Database: "webook", Table: "interactives", Type: TypeDelete, ID: 5},
+			},
+		},
+		{
+			name: "DDL 忽略",
+			msg:  `{"database":"webook","table":"interactives","type":"ALTER","isDdl":true,"data":null}`,
+		},
+		{
+			name:    "id 不合法",
+			msg:     `{"database":"webook","table":"interactives","type":"INSERT","isDdl":false,"data":[{"id":"abc"}]}`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var m CanalMessage
+			require.NoError(t, json.Unmarshal([]byte(tc.msg), &m))
+			evts, err := m.RowEvents()
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, evts)
+		})
+	}
+}
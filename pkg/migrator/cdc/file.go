@@ -0,0 +1,55 @@
+package cdc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+)
+
+// FileSource 从文件里面读取 canal 的消息，一行一条，读完就结束
+// 主要用于测试，也可以用来手动重放导出的 binlog
+type FileSource struct {
+	path string
+}
+
+func NewFileSource(path string) *FileSource {
+	return &FileSource{path: path}
+}
+
+func (f *FileSource) Consume(ctx context.Context, fn func(ctx context.Context, evt RowEvent) error) error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	// binlog 里面可能有比较大的行
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var m CanalMessage
+		err = json.Unmarshal(line, &m)
+		if err != nil {
+			return err
+		}
+		evts, err := m.RowEvents()
+		if err != nil {
+			return err
+		}
+		for _, evt := range evts {
+			err = fn(ctx, evt)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
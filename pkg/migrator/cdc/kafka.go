@@ -0,0 +1,66 @@
+package cdc
+
+import (
+	"context"
+	"errors"
+	"github.com/IBM/sarama"
+	"time"
+	"webook/pkg/logger"
+	"webook/pkg/saramax"
+)
+
+// KafkaSource 消费 canal 投递到 kafka 的 binlog
+type KafkaSource struct {
+	client  sarama.Client
+	topic   string
+	groupId string
+	l       logger.LoggerV1
+}
+
+func NewKafkaSource(client sarama.Client, topic string, groupId string, l logger.LoggerV1) *KafkaSource {
+	return &KafkaSource{
+		client:  client,
+		topic:   topic,
+		groupId: groupId,
+		l:       l,
+	}
+}
+
+func (k *KafkaSource) Consume(ctx context.Context, fn func(ctx context.Context, evt RowEvent) error) error {
+	cg, err := sarama.NewConsumerGroupFromClient(k.groupId, k.client)
+	if err != nil {
+		return err
+	}
+	defer cg.Close()
+	handler := saramax.NewHandler[CanalMessage](func(msg *sarama.ConsumerMessage, m CanalMessage) error {
+		evts, err := m.RowEvents()
+		if err != nil {
+			// 重试也没用
+			k.l.Error("解析 binlog 失败", logger.String("topic", msg.Topic),
+				logger.Int64("offset", msg.Offset), logger.Error(err))
+			return nil
+		}
+		for _, evt := range evts {
+			err = fn(ctx, evt)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}, k.l, saramax.WithRetry(3, saramax.ExponentialBackoff(time.Millisecond*100, time.Second)))
+	for {
+		// 发生 rebalance 的时候 Consume 会返回，所以要放在循环里面
+		err = cg.Consume(ctx, []string{k.topic}, handler)
+		if ctx.Err() != nil || errors.Is(err, sarama.ErrClosedConsumerGroup) {
+			return nil
+		}
+		if err != nil {
+			k.l.Error("消费 binlog 出错", logger.String("topic", k.topic), logger.Error(err))
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(time.Second):
+			}
+		}
+	}
+}
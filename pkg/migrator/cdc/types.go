@@ -0,0 +1,25 @@
+package cdc
+
+import "context"
+
+const (
+	TypeInsert = "INSERT"
+	TypeUpdate = "UPDATE"
+	TypeDelete = "DELETE"
+)
+
+// RowEvent 一行数据的变更
+type RowEvent struct {
+	Database string
+	Table    string
+	// Type 取值是 INSERT、UPDATE、DELETE
+	Type string
+	ID   int64
+}
+
+// Source 行变更事件的来源，例如 canal 投递到 kafka 的 binlog
+type Source interface {
+	// Consume 每一个行变更事件调用一次 fn，直到 ctx 被取消或者没有更多的事件
+	// fn 返回 error 的时候，Source 可以选择重试
+	Consume(ctx context.Context, fn func(ctx context.Context, evt RowEvent) error) error
+}
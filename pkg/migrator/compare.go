@@ -0,0 +1,85 @@
+package migrator
+
+import (
+	"fmt"
+	"gorm.io/gorm/schema"
+	"reflect"
+)
+
+var naming = schema.NamingStrategy{}
+
+// Diff 用反射逐个字段比较 src 和 dst，返回不相等的列名
+// 列名的规则和 gorm 一样，优先使用 gorm 标签里面的 column，否则转成 snake_case
+// 打了 migrator:"-" 标签的字段不参与比较，例如只在一边维护的字段
+func Diff(src, dst Entity) ([]string, error) {
+	sv, dv := indirect(reflect.ValueOf(src)), indirect(reflect.ValueOf(dst))
+	if sv.Type() != dv.Type() {
+		return nil, fmt.Errorf("migrator: 类型不一致 %T 和 %T", src, dst)
+	}
+	srcCols, dstCols := columns(sv), columns(dv)
+	var res []string
+	for i, col := range srcCols {
+		if !reflect.DeepEqual(col.val.Interface(), dstCols[i].val.Interface()) {
+			res = append(res, col.name)
+		}
+	}
+	return res, nil
+}
+
+// Equal 实现 CompareTo 的时候可以直接用它
+func Equal(src, dst Entity) bool {
+	cols, err := Diff(src, dst)
+	return err == nil && len(cols) == 0
+}
+
+// Columns 列名到值的映射，规则和 Diff 一样
+func Columns(e Entity) map[string]any {
+	cols := columns(indirect(reflect.ValueOf(e)))
+	res := make(map[string]any, len(cols))
+	for _, col := range cols {
+		res[col.name] = col.val.Interface()
+	}
+	return res
+}
+
+type column struct {
+	name string
+	val  reflect.Value
+}
+
+// columns 按照字段的顺序展开，匿名嵌套的结构体展开成它的字段
+func columns(v reflect.Value) []column {
+	typ := v.Type()
+	res := make([]column, 0, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Tag.Get("migrator") == "-" {
+			continue
+		}
+		tags := schema.ParseTagSetting(field.Tag.Get("gorm"), ";")
+		if _, ignored := tags["-"]; ignored {
+			continue
+		}
+		// 嵌套的结构体类型没有导出，它的字段也是可以访问的
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			res = append(res, columns(v.Field(i))...)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		name := tags["COLUMN"]
+		if name == "" {
+			name = naming.ColumnName("", field.Name)
+		}
+		res = append(res, column{name: name, val: v.Field(i)})
+	}
+	return res
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	return v
+}
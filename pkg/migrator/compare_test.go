@@ -0,0 +1,63 @@
+package migrator
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type compareBase struct {
+	Ctime int64
+}
+
+type compareEntity struct {
+	compareBase
+	Id      int64
+	Name    string `gorm:"column:nick_name"`
+	Tags    []string
+	Version int64  `migrator:"-"`
+	Temp    string `gorm:"-"`
+}
+
+func (c compareEntity) ID() int64 {
+	return c.Id
+}
+
+func (c compareEntity) CompareTo(dst Entity) bool {
+	return Equal(c, dst)
+}
+
+func TestDiff(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  compareEntity
+		dst  compareEntity
+		want []string
+	}{
+		{
+			name: "相等",
+			src:  compareEntity{Id: 1, Name: "a", Tags: []string{"x"}},
+			dst:  compareEntity{Id: 1, Name: "a", Tags: []string{"x"}},
+		},
+		{
+			name: "gorm 的列名和嵌套的字段",
+			src:  compareEntity{compareBase: compareBase{Ctime: 1}, Id: 1, Name: "a"},
+			dst:  compareEntity{compareBase: compareBase{Ctime: 2}, Id: 1, Name: "b"},
+			want: []string{"ctime", "nick_name"},
+		},
+		{
+			name: "忽略的字段",
+			src:  compareEntity{Id: 1, Version: 1, Temp: "a", Tags: []string{"x"}},
+			dst:  compareEntity{Id: 1, Version: 2, Temp: "b", Tags: []string{"y"}},
+			want: []string{"tags"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cols, err := Diff(tc.src, tc.dst)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, cols)
+			assert.Equal(t, len(tc.want) == 0, tc.src.CompareTo(tc.dst))
+		})
+	}
+}
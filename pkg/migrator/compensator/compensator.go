@@ -0,0 +1,232 @@
+package compensator
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+	"webook/pkg/gormx/connpool"
+	"webook/pkg/logger"
+	"webook/pkg/migrator/events"
+)
+
+// Compensator 实现 connpool.Compensator
+// 能解析出 id 的失败写，只发送 InconsistentEvent 交给修复程序，按照主库的数据修复
+// 解析不出 id，或者表没有注册 Producer 的，把 SQL 和参数记到主库的 MigratorCompensation 表里面，由 Replayer 重放
+type Compensator struct {
+	src       *gorm.DB
+	dst       *gorm.DB
+	producers map[string]events.Producer
+	l         logger.LoggerV1
+	timeout   time.Duration
+}
+
+// NewCompensator src 和 dst 不能是双写的 DB，两边都要提前建好 MigratorCompensation 对应的表
+// 补偿记录写在主库上，写失败的那一边这时候很可能也写不进去
+func NewCompensator(src *gorm.DB, dst *gorm.DB, l logger.LoggerV1) *Compensator {
+	return &Compensator{
+		src:       src,
+		dst:       dst,
+		producers: make(map[string]events.Producer),
+		l:         l,
+		timeout:   time.Second * 3,
+	}
+}
+
+// Producer table 上失败的写通过 producer 通知修复程序，producer 的 topic 要和修复程序的一样
+func (c *Compensator) Producer(table string, producer events.Producer) *Compensator {
+	c.producers[table] = producer
+	return c
+}
+
+// Compensate 异步补偿，不阻塞业务，而且业务的 ctx 可能很快就被取消了
+func (c *Compensator) Compensate(ctx context.Context, fw connpool.FailedWrite) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+		defer cancel()
+		err := c.compensate(ctx, fw)
+		if err != nil {
+			// 只能靠全量校验兜底了
+			c.l.Error("补偿双写失败", logger.String("sql", fw.Query), logger.Error(err))
+		}
+	}()
+}
+
+func (c *Compensator) compensate(ctx context.Context, fw connpool.FailedWrite) error {
+	producer, ok := c.producers[fw.Table]
+	if ok && len(fw.IDs) > 0 {
+		// 按照主库的数据修复是幂等的，重放 SQL 不是，例如 read_cnt = read_cnt + 1
+		// 所以发送失败了也不能改成重放 SQL，只能靠全量校验兜底
+		return c.produce(ctx, producer, fw)
+	}
+	return c.record(ctx, fw)
+}
+
+func (c *Compensator) produce(ctx context.Context, producer events.Producer, fw connpool.FailedWrite) error {
+	for _, id := range fw.IDs {
+		err := producer.ProduceInconsistentEvent(ctx, events.InconsistentEvent{
+			ID:        id,
+			Direction: fw.Base(),
+			Type:      events.InconsistentEventTypeWriteFailed,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Compensator) record(ctx context.Context, fw connpool.FailedWrite) error {
+	args, err := encodeArgs(fw.Args)
+	if err != nil {
+		return err
+	}
+	errMsg := ""
+	if fw.Err != nil {
+		errMsg = fw.Err.Error()
+	}
+	db := c.src
+	if fw.Base() == "DST" {
+		db = c.dst
+	}
+	now := time.Now().UnixMilli()
+	return db.WithContext(ctx).Create(&MigratorCompensation{
+		Table:  fw.Table,
+		Target: fw.Target(),
+		Query:  fw.Query,
+		Args:   args,
+		Err:    errMsg,
+		Status: StatusPending,
+		Ctime:  now,
+		Utime:  now,
+	}).Error
+}
+
+const (
+	StatusPending uint8 = iota
+	// StatusReplaying 被某个实例抢到了，正在重放
+	StatusReplaying
+	StatusDone
+	// StatusFailed 重试次数用完了，需要人工处理
+	StatusFailed
+)
+
+// MigratorCompensation 次要库上没有生效、又解析不出 id 的 SQL，保存在写入时的主库上
+type MigratorCompensation struct {
+	Id    int64  `gorm:"primaryKey,autoIncrement"`
+	Table string `gorm:"type:varchar(128)"`
+	// Target 要在哪一边重放，SRC 或者 DST
+	Target string `gorm:"type:varchar(8)"`
+	Query  string `gorm:"type:text"`
+	// Args encodeArgs 编码之后的参数
+	Args    string `gorm:"type:text"`
+	Err     string `gorm:"type:varchar(1024)"`
+	Status  uint8  `gorm:"index:idx_status_id"`
+	Retries int
+	Ctime   int64
+	Utime   int64
+}
+
+// MigratorCompensationApplied 重放过的补偿记录，保存在重放的那一边，和重放的 SQL 在同一个事务里面写入
+// 重放成功了但是没来得及更新 MigratorCompensation 的状态，再次重放的时候靠它跳过，不会重复执行
+type MigratorCompensationApplied struct {
+	// Id MigratorCompensation 的 id
+	Id    int64 `gorm:"primaryKey,autoIncrement:false"`
+	Ctime int64
+}
+
+// arg 直接用 JSON 的话，int64 会变成 float64，[]byte 和 time.Time 会变成 string，所以带上类型
+type arg struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+func encodeArgs(args []any) (string, error) {
+	res := make([]arg, 0, len(args))
+	for _, a := range args {
+		// 处理 driver.Valuer 和各种整数类型，转换之后只剩下 driver.Value 的那几种类型
+		val, err := driver.DefaultParameterConverter.ConvertValue(a)
+		if err != nil {
+			return "", err
+		}
+		var typ string
+		switch val.(type) {
+		case nil:
+			res = append(res, arg{Type: "nil"})
+			continue
+		case int64:
+			typ = "int64"
+		case float64:
+			typ = "float64"
+		case bool:
+			typ = "bool"
+		case []byte:
+			typ = "bytes"
+		case string:
+			typ = "string"
+		case time.Time:
+			typ = "time"
+		default:
+			return "", fmt.Errorf("不支持的参数类型 %T", val)
+		}
+		data, err := json.Marshal(val)
+		if err != nil {
+			return "", err
+		}
+		res = append(res, arg{Type: typ, Value: data})
+	}
+	data, err := json.Marshal(res)
+	return string(data), err
+}
+
+func decodeArgs(data string) ([]any, error) {
+	var args []arg
+	err := json.Unmarshal([]byte(data), &args)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]any, 0, len(args))
+	for _, a := range args {
+		var val any
+		switch a.Type {
+		case "nil":
+			res = append(res, nil)
+			continue
+		case "int64":
+			val = new(int64)
+		case "float64":
+			val = new(float64)
+		case "bool":
+			val = new(bool)
+		case "bytes":
+			val = new([]byte)
+		case "string":
+			val = new(string)
+		case "time":
+			val = new(time.Time)
+		default:
+			return nil, fmt.Errorf("未知的参数类型 %s", a.Type)
+		}
+		err = json.Unmarshal(a.Value, val)
+		if err != nil {
+			return nil, err
+		}
+		switch v := val.(type) {
+		case *int64:
+			res = append(res, *v)
+		case *float64:
+			res = append(res, *v)
+		case *bool:
+			res = append(res, *v)
+		case *[]byte:
+			res = append(res, *v)
+		case *string:
+			res = append(res, *v)
+		case *time.Time:
+			res = append(res, *v)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,109 @@
+package compensator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+	"webook/pkg/gormx/connpool"
+	"webook/pkg/logger"
+	"webook/pkg/migrator/events"
+)
+
+func TestCompensator_compensate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fw       connpool.FailedWrite
+		producer events.Producer
+		mock     func(src, dst sqlmock.Sqlmock)
+
+		wantErr error
+	}{
+		{
+			name: "解析不出 id，记录到主库",
+			fw: connpool.FailedWrite{
+				Pattern: connpool.PatternDstFirst,
+				Table:   "interactives",
+				Query:   "UPDATE interactives SET read_cnt = read_cnt + 1 WHERE biz = ?",
+				Args:    []any{"article"},
+			},
+			producer: &failedProducer{},
+			mock: func(src, dst sqlmock.Sqlmock) {
+				dst.ExpectExec("INSERT INTO `migrator_compensations`").
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+		},
+		{
+			// 重放 SQL 不是幂等的，不能因为发送失败就改成重放
+			name: "能解析出 id，发送失败也不记录 SQL",
+			fw: connpool.FailedWrite{
+				Pattern: connpool.PatternSrcFirst,
+				Table:   "interactives",
+				Query:   "UPDATE interactives SET read_cnt = read_cnt + 1 WHERE id = ?",
+				Args:    []any{int64(1)},
+				IDs:     []int64{1},
+			},
+			producer: &failedProducer{},
+			mock:     func(src, dst sqlmock.Sqlmock) {},
+			wantErr:  errProduce,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src, srcMock := newMockDB(t)
+			dst, dstMock := newMockDB(t)
+			tc.mock(srcMock, dstMock)
+			c := NewCompensator(src, dst, logger.NewNoOpLogger()).Producer("interactives", tc.producer)
+			err := c.compensate(context.Background(), tc.fw)
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.NoError(t, srcMock.ExpectationsWereMet())
+			assert.NoError(t, dstMock.ExpectationsWereMet())
+		})
+	}
+}
+
+var errProduce = errors.New("mock produce error")
+
+type failedProducer struct{}
+
+func (f *failedProducer) ProduceInconsistentEvent(ctx context.Context, evt events.InconsistentEvent) error {
+	return errProduce
+}
+
+func TestArgs(t *testing.T) {
+	now := time.UnixMilli(time.Now().UnixMilli()).UTC()
+	testCases := []struct {
+		name string
+		args []any
+		want []any
+	}{
+		{
+			name: "基本类型",
+			args: []any{int64(1), 2, "test", true, 1.5},
+			want: []any{int64(1), int64(2), "test", true, 1.5},
+		},
+		{
+			name: "nil、字节和时间",
+			args: []any{nil, []byte("abc"), now},
+			want: []any{nil, []byte("abc"), now},
+		},
+		{
+			name: "driver.Valuer",
+			args: []any{sql.NullString{String: "a", Valid: true}, sql.NullInt64{}},
+			want: []any{"a", nil},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := encodeArgs(tc.args)
+			require.NoError(t, err)
+			args, err := decodeArgs(data)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, args)
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package compensator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"time"
+	"webook/pkg/logger"
+)
+
+// ErrReplayBlocked 前面有一条重放失败了，需要人工处理之后才能继续
+var ErrReplayBlocked = errors.New("compensator: 有重放失败的补偿记录，需要人工处理")
+
+var errApplied = errors.New("compensator: 已经重放过了")
+
+// Replayer 按照记录的顺序，在写入失败的那一边重放 MigratorCompensation 里面的 SQL
+// 补偿记录保存在写入时的主库上，所以源库和目标库上的记录都要重放
+// 每一条先抢占再重放，所以可以多个实例一起跑；重放的时候实例崩溃了，这一条停在 StatusReplaying 超过 lease 之后会被重新抢占
+// 前面的记录没有完成（包括重放失败的），后面的都不会重放，保证同一行上的 SQL 按照顺序执行
+// 实现了 saramax.Consumer，可以和其它消费者一起启动和停止
+type Replayer struct {
+	src *gorm.DB
+	dst *gorm.DB
+	l   logger.LoggerV1
+
+	batchSize  int
+	interval   time.Duration
+	maxRetries int
+	// lease 要比执行一条 SQL 的时间长得多，否则别的实例会抢走还在重放的记录
+	lease time.Duration
+
+	cancel func()
+	done   chan struct{}
+}
+
+// NewReplayer src 和 dst 不能是双写的 DB，否则又会写到两边
+// 两边都要提前建好 MigratorCompensation 和 MigratorCompensationApplied 对应的表
+func NewReplayer(src *gorm.DB, dst *gorm.DB, l logger.LoggerV1) *Replayer {
+	return &Replayer{
+		src:        src,
+		dst:        dst,
+		l:          l,
+		batchSize:  100,
+		interval:   time.Second * 5,
+		maxRetries: 3,
+		lease:      time.Minute,
+	}
+}
+
+// Lease 停在 StatusReplaying 超过 lease 的记录，说明重放的实例崩溃了，可以重新抢占
+func (r *Replayer) Lease(lease time.Duration) *Replayer {
+	r.lease = lease
+	return r
+}
+
+func (r *Replayer) Start() error {
+	var ctx context.Context
+	ctx, r.cancel = context.WithCancel(context.Background())
+	r.done = make(chan struct{})
+	go r.run(ctx)
+	return nil
+}
+
+func (r *Replayer) Stop(ctx context.Context) error {
+	r.cancel()
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Replayer) run(ctx context.Context) {
+	defer close(r.done)
+	for {
+		cnt, err := r.Replay(ctx)
+		if err != nil {
+			r.l.Error("重放补偿 SQL 失败", logger.Error(err))
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if cnt > 0 && err == nil {
+			// 可能还有，继续
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.interval):
+		}
+	}
+}
+
+// Replay 源库和目标库上的记录各重放一批，返回重放成功的数量
+func (r *Replayer) Replay(ctx context.Context) (int, error) {
+	cnt, err := r.replayFrom(ctx, r.src)
+	n, er := r.replayFrom(ctx, r.dst)
+	return cnt + n, errors.Join(err, er)
+}
+
+// replayFrom 重放保存在 db 上的一批记录
+// 有一条失败了就停下来，下一次从它开始重试；重试次数用完了之后，后面的都不再重放
+func (r *Replayer) replayFrom(ctx context.Context, db *gorm.DB) (int, error) {
+	var cs []MigratorCompensation
+	err := db.WithContext(ctx).Where("status <> ?", StatusDone).
+		Order("id").Limit(r.batchSize).Find(&cs).Error
+	if err != nil {
+		return 0, err
+	}
+	cnt := 0
+	for _, c := range cs {
+		switch c.Status {
+		case StatusFailed:
+			return cnt, fmt.Errorf("%w: id %d", ErrReplayBlocked, c.Id)
+		case StatusReplaying:
+			if time.Since(time.UnixMilli(c.Utime)) < r.lease {
+				// 别的实例正在重放，等它完成
+				return cnt, nil
+			}
+			r.l.Warn("补偿记录重放超时，重新抢占", logger.Int64("id", c.Id))
+		}
+		ok, err := r.replay(ctx, db, c)
+		if err != nil || !ok {
+			return cnt, err
+		}
+		cnt++
+	}
+	return cnt, nil
+}
+
+// replay 被别的实例抢走了返回 false
+func (r *Replayer) replay(ctx context.Context, db *gorm.DB, c MigratorCompensation) (bool, error) {
+	ok, err := r.claim(ctx, db, c)
+	if err != nil || !ok {
+		return false, err
+	}
+	err = r.exec(ctx, c)
+	updates := map[string]any{
+		"status": StatusDone,
+		"utime":  time.Now().UnixMilli(),
+	}
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		// 被停掉了，不算重试，下一次重新抢占
+		updates["status"] = StatusPending
+	default:
+		c.Retries++
+		updates["status"] = StatusPending
+		if c.Retries >= r.maxRetries {
+			updates["status"] = StatusFailed
+		}
+		updates["retries"] = c.Retries
+		updates["err"] = err.Error()
+		r.l.Error("重放补偿 SQL 失败", logger.Int64("id", c.Id),
+			logger.Int("retries", c.Retries), logger.Error(err))
+	}
+	// ctx 被取消了也要写回状态，否则这一条要等 lease 过了才能重放
+	wctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second*3)
+	defer cancel()
+	er := db.WithContext(wctx).Model(&MigratorCompensation{}).
+		Where("id = ?", c.Id).
+		Updates(updates)
+	if er.Error != nil {
+		return false, er.Error
+	}
+	return err == nil, err
+}
+
+// claim 抢占 c，重新抢占超时的记录的时候，utime 没变才说明没有被别的实例抢走
+// 重新抢占之后再执行一遍也没关系，exec 会跳过已经执行过的
+func (r *Replayer) claim(ctx context.Context, db *gorm.DB, c MigratorCompensation) (bool, error) {
+	query := db.WithContext(ctx).Model(&MigratorCompensation{}).Where("id = ?", c.Id)
+	if c.Status == StatusReplaying {
+		query = query.Where("status = ? AND utime = ?", StatusReplaying, c.Utime)
+	} else {
+		query = query.Where("status = ?", StatusPending)
+	}
+	res := query.Updates(map[string]any{
+		"status": StatusReplaying,
+		"utime":  time.Now().UnixMilli(),
+	})
+	return res.RowsAffected > 0, res.Error
+}
+
+// exec 在写入失败的那一边执行记录下来的 SQL，已经执行过的直接返回
+func (r *Replayer) exec(ctx context.Context, c MigratorCompensation) error {
+	args, err := decodeArgs(c.Args)
+	if err != nil {
+		return err
+	}
+	target := r.src
+	if c.Target == "DST" {
+		target = r.dst
+	}
+	err = target.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(&MigratorCompensationApplied{
+			Id:    c.Id,
+			Ctime: time.Now().UnixMilli(),
+		}).Error
+		var me *mysqlDriver.MySQLError
+		if errors.As(err, &me) && me.Number == 1062 {
+			return errApplied
+		}
+		if err != nil {
+			return err
+		}
+		// 直接用 ConnPool，原样执行记录下来的 SQL
+		_, err = tx.Statement.ConnPool.ExecContext(ctx, c.Query, args...)
+		return err
+	})
+	if errors.Is(err, errApplied) {
+		// 上一次已经执行成功了，只是状态没有更新
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,173 @@
+package compensator
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	mysqlDriver "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+	"webook/pkg/logger"
+)
+
+func TestReplayer_Replay(t *testing.T) {
+	const query = "UPDATE interactives SET read_cnt = read_cnt + 1 WHERE biz = ? AND biz_id = ?"
+	args, err := encodeArgs([]any{"article", int64(1)})
+	require.NoError(t, err)
+	columns := []string{"id", "table", "target", "query", "args", "status", "retries", "utime"}
+
+	testCases := []struct {
+		name string
+		// 补偿记录都在源库上，在目标库上重放
+		mock func(src, dst sqlmock.Sqlmock)
+
+		wantCnt int
+		wantErr error
+	}{
+		{
+			name: "重放成功",
+			mock: func(src, dst sqlmock.Sqlmock) {
+				src.ExpectQuery("SELECT .* FROM `migrator_compensations`").
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, "interactives", "DST", query, args, StatusPending, 0, 0))
+				src.ExpectExec("UPDATE `migrator_compensations`").WillReturnResult(sqlmock.NewResult(0, 1))
+				dst.ExpectBegin()
+				dst.ExpectExec("INSERT INTO `migrator_compensation_applieds`").WillReturnResult(sqlmock.NewResult(1, 1))
+				dst.ExpectExec("UPDATE interactives SET read_cnt").
+					WithArgs("article", int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+				dst.ExpectCommit()
+				src.ExpectExec("UPDATE `migrator_compensations`").WillReturnResult(sqlmock.NewResult(0, 1))
+				dst.ExpectQuery("SELECT .* FROM `migrator_compensations`").
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			wantCnt: 1,
+		},
+		{
+			// 上一次执行成功了，但是没来得及更新状态，不能再加一次
+			name: "已经重放过",
+			mock: func(src, dst sqlmock.Sqlmock) {
+				src.ExpectQuery("SELECT .* FROM `migrator_compensations`").
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, "interactives", "DST", query, args, StatusPending, 0, 0))
+				src.ExpectExec("UPDATE `migrator_compensations`").WillReturnResult(sqlmock.NewResult(0, 1))
+				dst.ExpectBegin()
+				dst.ExpectExec("INSERT INTO `migrator_compensation_applieds`").
+					WillReturnError(&mysqlDriver.MySQLError{Number: 1062})
+				dst.ExpectRollback()
+				src.ExpectExec("UPDATE `migrator_compensations`").WillReturnResult(sqlmock.NewResult(0, 1))
+				dst.ExpectQuery("SELECT .* FROM `migrator_compensations`").
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			wantCnt: 1,
+		},
+		{
+			name: "前面有重放失败的，后面的不重放",
+			mock: func(src, dst sqlmock.Sqlmock) {
+				src.ExpectQuery("SELECT .* FROM `migrator_compensations`").
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, "interactives", "DST", query, args, StatusFailed, 3, 0).
+						AddRow(2, "interactives", "DST", query, args, StatusPending, 0, 0))
+				dst.ExpectQuery("SELECT .* FROM `migrator_compensations`").
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			wantErr: ErrReplayBlocked,
+		},
+		{
+			name: "别的实例正在重放",
+			mock: func(src, dst sqlmock.Sqlmock) {
+				src.ExpectQuery("SELECT .* FROM `migrator_compensations`").
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, "interactives", "DST", query, args, StatusReplaying, 0, time.Now().UnixMilli()))
+				dst.ExpectQuery("SELECT .* FROM `migrator_compensations`").
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+		},
+		{
+			// 重放的实例崩溃了，超过 lease 之后重新抢占
+			name: "重放超时",
+			mock: func(src, dst sqlmock.Sqlmock) {
+				utime := time.Now().Add(-time.Hour).UnixMilli()
+				src.ExpectQuery("SELECT .* FROM `migrator_compensations`").
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, "interactives", "DST", query, args, StatusReplaying, 0, utime))
+				src.ExpectExec("UPDATE `migrator_compensations` .* WHERE id = \\? AND \\(status = \\? AND utime = \\?\\)").
+					WithArgs(StatusReplaying, sqlmock.AnyArg(), 1, StatusReplaying, utime).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				dst.ExpectBegin()
+				dst.ExpectExec("INSERT INTO `migrator_compensation_applieds`").
+					WillReturnError(&mysqlDriver.MySQLError{Number: 1062})
+				dst.ExpectRollback()
+				src.ExpectExec("UPDATE `migrator_compensations`").WillReturnResult(sqlmock.NewResult(0, 1))
+				dst.ExpectQuery("SELECT .* FROM `migrator_compensations`").
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			wantCnt: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src, srcMock := newMockDB(t)
+			dst, dstMock := newMockDB(t)
+			tc.mock(srcMock, dstMock)
+			r := NewReplayer(src, dst, logger.NewNoOpLogger())
+			cnt, err := r.Replay(context.Background())
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.wantCnt, cnt)
+			assert.NoError(t, srcMock.ExpectationsWereMet())
+			assert.NoError(t, dstMock.ExpectationsWereMet())
+		})
+	}
+}
+
+// 重放到一半被停掉了，状态还是要写回去，而且不算一次重试
+func TestReplayer_Replay_Cancel(t *testing.T) {
+	const query = "UPDATE interactives SET read_cnt = read_cnt + 1 WHERE biz = ? AND biz_id = ?"
+	args, err := encodeArgs([]any{"article", int64(1)})
+	require.NoError(t, err)
+	src, srcMock := newMockDB(t)
+	dst, dstMock := newMockDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srcMock.ExpectQuery("SELECT .* FROM `migrator_compensations`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "table", "target", "query", "args", "status", "retries"}).
+			AddRow(1, "interactives", "DST", query, args, StatusPending, 0))
+	srcMock.ExpectExec("UPDATE `migrator_compensations`").WillReturnResult(sqlmock.NewResult(0, 1))
+	dstMock.ExpectBegin()
+	dstMock.ExpectExec("INSERT INTO `migrator_compensation_applieds`").
+		WillDelayFor(time.Second).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	dstMock.ExpectRollback()
+	// 只改回 StatusPending，没有 retries
+	srcMock.ExpectExec("UPDATE `migrator_compensations` SET `status`=\\?,`utime`=\\? WHERE id = \\?").
+		WithArgs(StatusPending, sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	go func() {
+		time.Sleep(time.Millisecond * 100)
+		cancel()
+	}()
+	r := NewReplayer(src, dst, logger.NewNoOpLogger())
+	cnt, err := r.Replay(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, cnt)
+	assert.NoError(t, srcMock.ExpectationsWereMet())
+}
+
+func newMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      sqlDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+	return db, mock
+}
@@ -2,9 +2,11 @@ package fixer
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"github.com/IBM/sarama"
 	"gorm.io/gorm"
+	"strings"
 	"time"
 	"webook/pkg/logger"
 	"webook/pkg/migrator"
@@ -19,6 +21,11 @@ type FixConsumer[T migrator.Entity] struct {
 	srcFirst *fixer.Fixer[T]
 	dstFirst *fixer.Fixer[T]
 	topic    string
+	cg       *saramax.GroupConsumer
+
+	// 设置了就是 dry run，只把修复计划写到 report 里面
+	report *gorm.DB
+	table  string
 }
 
 func NewFixConsumer[T migrator.Entity](client sarama.Client, l logger.LoggerV1, src *gorm.DB, dst *gorm.DB, topic string) (*FixConsumer[T], error) {
@@ -30,6 +37,7 @@ func NewFixConsumer[T migrator.Entity](client sarama.Client, l logger.LoggerV1,
 	if err != nil {
 		return nil, err
 	}
+	events.RegisterSchema(topic)
 	return &FixConsumer[T]{client: client,
 		l:        l,
 		srcFirst: srcFirst,
@@ -37,23 +45,43 @@ func NewFixConsumer[T migrator.Entity](client sarama.Client, l logger.LoggerV1,
 		topic:    topic}, nil
 }
 
-func (f *FixConsumer[T]) Start() error {
-	cg, err := sarama.NewConsumerGroupFromClient("fix", f.client)
-	if err != nil {
-		return err
+// NewStoreFixConsumer 两端可以是不同的存储，例如 MySQL 和 MongoDB
+func NewStoreFixConsumer[T migrator.Entity](client sarama.Client, l logger.LoggerV1, src migrator.Store[T], dst migrator.Store[T], topic string) *FixConsumer[T] {
+	events.RegisterSchema(topic)
+	return &FixConsumer[T]{client: client,
+		l:        l,
+		srcFirst: fixer.NewStoreFixer[T](src, dst),
+		dstFirst: fixer.NewStoreFixer[T](dst, src),
+		topic:    topic}
+}
+
+// DryRun 不修复数据，而是把修复计划写到 db 的 MigratorFixPlan 表里面，由 DBA 审核之后再修复
+// 需要提前建好 MigratorFixPlan 对应的表
+func (f *FixConsumer[T]) DryRun(db *gorm.DB) *FixConsumer[T] {
+	f.report = db
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err == nil {
+		f.table = stmt.Schema.Table
 	}
-	go func() {
-		er := cg.Consume(context.Background(), []string{f.topic}, saramax.NewHandler[events.InconsistentEvent](f.Consume, f.l))
-		if er != nil {
-			f.l.Error("数据修复消费者异常", logger.Error(er))
-		}
-	}()
-	return nil
+	return f
+}
+
+func (f *FixConsumer[T]) Start() error {
+	f.cg = saramax.NewGroupConsumer(f.client, "fix", []string{f.topic},
+		saramax.NewHandler[events.InconsistentEvent](f.Consume, f.l), f.l)
+	return f.cg.Start()
+}
+
+func (f *FixConsumer[T]) Stop(ctx context.Context) error {
+	return f.cg.Stop(ctx)
 }
 
 func (f *FixConsumer[T]) Consume(msg *sarama.ConsumerMessage, event events.InconsistentEvent) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
+	if f.report != nil {
+		return f.plan(ctx, event)
+	}
 	switch event.Direction {
 	case "SRC":
 		return f.srcFirst.Fix(ctx, event.ID)
@@ -62,3 +90,58 @@ func (f *FixConsumer[T]) Consume(msg *sarama.ConsumerMessage, event events.Incon
 	}
 	return errors.New("未知的校验方向")
 }
+
+func (f *FixConsumer[T]) plan(ctx context.Context, event events.InconsistentEvent) error {
+	var fx *fixer.Fixer[T]
+	switch event.Direction {
+	case "SRC":
+		fx = f.srcFirst
+	case "DST":
+		fx = f.dstFirst
+	default:
+		return errors.New("未知的校验方向")
+	}
+	plan, err := fx.Plan(ctx, event.ID)
+	if err != nil {
+		return err
+	}
+	if plan.Action == fixer.ActionNone {
+		// 已经一致了，可能是双写的时候还没写完
+		return nil
+	}
+	before, err := json.Marshal(plan.Before)
+	if err != nil {
+		return err
+	}
+	after, err := json.Marshal(plan.After)
+	if err != nil {
+		return err
+	}
+	return f.report.WithContext(ctx).Create(&MigratorFixPlan{
+		Table:     f.table,
+		EntityId:  plan.ID,
+		Direction: event.Direction,
+		Type:      event.Type,
+		Action:    plan.Action,
+		Columns:   strings.Join(plan.Columns, ","),
+		Before:    string(before),
+		After:     string(after),
+		Ctime:     time.Now().UnixMilli(),
+	}).Error
+}
+
+// MigratorFixPlan dry run 的时候生成的修复计划
+type MigratorFixPlan struct {
+	Id        int64  `gorm:"primaryKey,autoIncrement"`
+	Table     string `gorm:"type:varchar(128);index:idx_table_entity"`
+	EntityId  int64  `gorm:"index:idx_table_entity"`
+	Direction string `gorm:"type:varchar(8)"`
+	Type      string `gorm:"type:varchar(32)"`
+	Action    string `gorm:"type:varchar(16)"`
+	// Columns 逗号分隔
+	Columns string `gorm:"type:varchar(1024)"`
+	// Before 和 After 都是 JSON
+	Before string `gorm:"type:text"`
+	After  string `gorm:"type:text"`
+	Ctime  int64
+}
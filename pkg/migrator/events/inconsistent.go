@@ -1,11 +1,21 @@
 package events
 
+import "webook/pkg/saramax"
+
+// RegisterSchema 校验和修复用的 topic 是业务方自己指定的，所以生产者和消费者创建的时候各自注册
+func RegisterSchema(topic string) {
+	saramax.DefaultRegistry.Register(topic, "migrator.inconsistent",
+		saramax.Version{Version: 1, Sample: InconsistentEvent{}})
+}
+
 type InconsistentEvent struct {
 	ID int64
 	// 取值为 SRC 表示以源表为准，取值为 DST 表示以目标表为准
 	Direction string
 	// 标记什么原因引起的不一致
 	Type string
+	// Columns 不相等的列，只有 not_equal 才有
+	Columns []string `json:",omitempty"`
 }
 
 const (
@@ -15,4 +25,6 @@ const (
 	InconsistentEventTypeTargetMissing = "target_missing"
 	// InconsistentEventTypeNotEqual 不相等
 	InconsistentEventTypeNotEqual = "not_equal"
+	// InconsistentEventTypeWriteFailed 双写的时候，次要库写入失败
+	InconsistentEventTypeWriteFailed = "write_failed"
 )
@@ -2,8 +2,8 @@ package events
 
 import (
 	"context"
-	"encoding/json"
 	"github.com/IBM/sarama"
+	"webook/pkg/saramax"
 )
 
 type Producer interface {
@@ -16,17 +16,15 @@ type SaramaProducer struct {
 }
 
 func NewSaramaProducer(producer sarama.SyncProducer, topic string) Producer {
+	RegisterSchema(topic)
 	return &SaramaProducer{producer: producer, topic: topic}
 }
 
 func (s *SaramaProducer) ProduceInconsistentEvent(ctx context.Context, evt InconsistentEvent) error {
-	val, err := json.Marshal(evt)
+	msg, err := saramax.DefaultRegistry.NewMessage(ctx, s.topic, evt)
 	if err != nil {
 		return err
 	}
-	_, _, err = s.producer.SendMessage(&sarama.ProducerMessage{
-		Topic: s.topic,
-		Value: sarama.ByteEncoder(val),
-	})
+	_, _, err = s.producer.SendMessage(msg)
 	return err
 }
@@ -4,24 +4,36 @@ import (
 	"context"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"sort"
 	"webook/pkg/migrator"
 	"webook/pkg/migrator/events"
 )
 
 type Fixer[T migrator.Entity] struct {
+	// FixV1 和 FixV2 直接用 gorm，跨存储的 Fixer 没有这两个
 	base   *gorm.DB
 	target *gorm.DB
+	// Fix 和 Plan 都通过 Store 访问数据，所以两端可以是不同的存储
+	baseStore   migrator.Store[T]
+	targetStore migrator.Store[T]
 
 	columns []string
 }
 
 func NewFixerV1[T migrator.Entity](base *gorm.DB, target *gorm.DB, columns []string) *Fixer[T] {
 	return &Fixer[T]{base: base,
-		target:  target,
-		columns: columns,
+		target:      target,
+		baseStore:   migrator.NewGORMStore[T](base),
+		targetStore: migrator.NewGORMStore[T](target),
+		columns:     columns,
 	}
 }
 
+// NewStoreFixer 两端可以是不同的存储，例如 MySQL 和 MongoDB，只支持 Fix 和 Plan
+func NewStoreFixer[T migrator.Entity](base migrator.Store[T], target migrator.Store[T]) *Fixer[T] {
+	return &Fixer[T]{baseStore: base, targetStore: target}
+}
+
 func NewFixer[T migrator.Entity](base *gorm.DB, target *gorm.DB) (*Fixer[T], error) {
 	rows, err := base.Model(new(T)).Order("id").Rows()
 	if err != nil {
@@ -29,26 +41,91 @@ func NewFixer[T migrator.Entity](base *gorm.DB, target *gorm.DB) (*Fixer[T], err
 	}
 	columns, err := rows.Columns()
 	return &Fixer[T]{base: base,
-		target:  target,
-		columns: columns,
+		target:      target,
+		baseStore:   migrator.NewGORMStore[T](base),
+		targetStore: migrator.NewGORMStore[T](target),
+		columns:     columns,
 	}, nil
 }
 
 // Fix 最简单粗暴的写法，直接去 base 里面找，找到就 upsert，找不到就 delete
 func (f *Fixer[T]) Fix(ctx context.Context, id int64) error {
-	var t T
-	err := f.base.WithContext(ctx).Where("id=?", id).First(&t).Error
-	switch err {
-	case gorm.ErrRecordNotFound:
-		return f.target.WithContext(ctx).Model(&t).Delete("id = ?", id).Error
-	case nil:
-		// upsert
-		return f.target.WithContext(ctx).Clauses(clause.OnConflict{
-			DoUpdates: clause.AssignmentColumns(f.columns),
-		}).Create(&t).Error
-	default:
+	t, found, err := f.find(ctx, f.baseStore, id)
+	if err != nil {
 		return err
 	}
+	if !found {
+		return f.targetStore.Delete(ctx, id)
+	}
+	return f.targetStore.Upsert(ctx, t)
+}
+
+const (
+	ActionNone   = "none"
+	ActionUpsert = "upsert"
+	ActionDelete = "delete"
+)
+
+// FixPlan Fix 会对 target 做的修改
+type FixPlan struct {
+	ID int64
+	// Action 取值是 upsert、delete，none 表示两边已经一致了
+	Action string
+	// Columns 会被修改的列，delete 的时候为空
+	Columns []string
+	// Before 这些列在 target 里面的值，target 里面没有这一行的时候为 nil
+	Before map[string]any
+	// After 修复之后的值，delete 的时候为 nil
+	After map[string]any
+}
+
+// Plan 和 Fix 的逻辑一样，但是只计算要做的修改，不会真的修改 target
+func (f *Fixer[T]) Plan(ctx context.Context, id int64) (FixPlan, error) {
+	plan := FixPlan{ID: id, Action: ActionNone}
+	src, srcFound, err := f.find(ctx, f.baseStore, id)
+	if err != nil {
+		return plan, err
+	}
+	dst, dstFound, err := f.find(ctx, f.targetStore, id)
+	if err != nil {
+		return plan, err
+	}
+	switch {
+	case !srcFound && dstFound:
+		plan.Action = ActionDelete
+		plan.Before = migrator.Columns(dst)
+	case srcFound && !dstFound:
+		plan.Action = ActionUpsert
+		plan.After = migrator.Columns(src)
+		for col := range plan.After {
+			plan.Columns = append(plan.Columns, col)
+		}
+		sort.Strings(plan.Columns)
+	case srcFound && dstFound:
+		cols, err := migrator.Diff(src, dst)
+		if err != nil || len(cols) == 0 {
+			return plan, err
+		}
+		plan.Action = ActionUpsert
+		plan.Columns = cols
+		before, after := migrator.Columns(dst), migrator.Columns(src)
+		plan.Before = make(map[string]any, len(cols))
+		plan.After = make(map[string]any, len(cols))
+		for _, col := range cols {
+			plan.Before[col] = before[col]
+			plan.After[col] = after[col]
+		}
+	}
+	return plan, nil
+}
+
+func (f *Fixer[T]) find(ctx context.Context, store migrator.Store[T], id int64) (T, bool, error) {
+	res, err := store.FindByIds(ctx, []int64{id})
+	if err != nil || len(res) == 0 {
+		var t T
+		return t, false, err
+	}
+	return res[0], true, nil
 }
 
 // FixV1 使用 upsert 控制并发安全
@@ -2,26 +2,42 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	rlock "github.com/gotomicro/redis-lock"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 	"webook/pkg/ginx"
 	"webook/pkg/gormx/connpool"
 	"webook/pkg/logger"
 	"webook/pkg/migrator"
+	"webook/pkg/migrator/cdc"
 	"webook/pkg/migrator/events"
 	"webook/pkg/migrator/validator"
 )
 
+// PatternUpdater 切换双写模式，connpool.DoubleWritePool 和业务自己实现的双写 DAO 都可以
+type PatternUpdater interface {
+	UpdatePattern(pattern string) error
+}
+
 // Scheduler 用来统一管理整个迁移过程
 // 它不是必须的，可以理解为这是为了方便用户操作而引入的。
 type Scheduler[T migrator.Entity] struct {
-	lock       sync.Mutex
-	src        *gorm.DB
-	dst        *gorm.DB
-	pool       *connpool.DoubleWritePool
+	lock sync.Mutex
+	// 按照 utime 的增量校验直接用 gorm，跨存储的迁移没有这两个
+	src *gorm.DB
+	dst *gorm.DB
+	// 全量校验和 binlog 校验都通过 Store 访问数据
+	srcStore   migrator.Store[T]
+	dstStore   migrator.Store[T]
+	name       string
+	pool       PatternUpdater
 	l          logger.LoggerV1
 	pattern    string
 	cancelFull func()
@@ -30,6 +46,32 @@ type Scheduler[T migrator.Entity] struct {
 
 	// 如果你要允许多个全量校验同时运行
 	fulls map[string]func()
+
+	// 全量校验的进度，不设置的话每次都从头开始
+	checkpoint validator.CheckpointStore
+	// 当前（或者最近一次）的校验，用来查询进度
+	full *validator.Validator[T]
+	incr *validator.Validator[T]
+
+	// 设置了的话，增量校验消费 binlog，而不是按照 utime 轮询
+	source cdc.Source
+
+	// 下面是分片全量校验，不设置 Sharding 的话只在当前实例校验
+	redis       redis.Cmdable
+	lockClient  *rlock.Client
+	shardSize   int64
+	concurrency int
+	sharded     *validator.ShardedValidator[T]
+
+	// 设置了的话，pattern 和校验任务保存在 state 里面，所有实例一起切换
+	state StateStore
+	// 当前实例正在参与的校验，和 State 里面的值一样
+	fullRun string
+	incrRun string
+
+	// ctx 监听状态和执行校验都用它，Close 的时候取消
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 func NewScheduler[T migrator.Entity](
@@ -39,11 +81,31 @@ func NewScheduler[T migrator.Entity](
 	// 这个是业务用的 DoubleWritePool
 	pool *connpool.DoubleWritePool,
 	producer events.Producer) *Scheduler[T] {
+	s := NewStoreScheduler[T](l, "", migrator.NewGORMStore[T](src), migrator.NewGORMStore[T](dst), pool, producer)
+	s.src = src
+	s.dst = dst
+	return s
+}
+
+// NewStoreScheduler 两端可以是不同的存储，例如 MySQL 和 MongoDB，增量校验只支持 Binlog
+// name 用来区分保存的状态和校验进度，一般用表名
+func NewStoreScheduler[T migrator.Entity](
+	l logger.LoggerV1,
+	name string,
+	src migrator.Store[T],
+	dst migrator.Store[T],
+	// 业务用的双写，切换 pattern 的时候调用
+	pool PatternUpdater,
+	producer events.Producer) *Scheduler[T] {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Scheduler[T]{
-		l:       l,
-		src:     src,
-		dst:     dst,
-		pattern: connpool.PatternSrcOnly,
+		ctx:      ctx,
+		cancel:   cancel,
+		l:        l,
+		srcStore: src,
+		dstStore: dst,
+		name:     name,
+		pattern:  connpool.PatternSrcOnly,
 		cancelFull: func() {
 			// 初始的时候，啥也不用做
 		},
@@ -55,11 +117,62 @@ func NewScheduler[T migrator.Entity](
 	}
 }
 
+// Checkpoint 保存全量校验的进度，实例重启之后可以继续校验
+func (s *Scheduler[T]) Checkpoint(store validator.CheckpointStore) *Scheduler[T] {
+	s.checkpoint = store
+	return s
+}
+
+// Binlog 增量校验消费 source 里面的行变更事件
+func (s *Scheduler[T]) Binlog(source cdc.Source) *Scheduler[T] {
+	s.source = source
+	return s
+}
+
+// Sharding 全量校验按照 id 分成大小为 shardSize 的分片，所有的实例一起完成，每个实例同时校验 concurrency 个分片
+// 必须配合 State 使用，启动和停止都是通过 State 同步到所有实例的，否则停止只能停掉当前实例
+func (s *Scheduler[T]) Sharding(client redis.Cmdable, shardSize int64, concurrency int) *Scheduler[T] {
+	s.redis = client
+	s.lockClient = rlock.NewClient(client)
+	s.shardSize = shardSize
+	s.concurrency = concurrency
+	return s
+}
+
+// State pattern 和校验任务保存到 store 里面，所有实例都监听它的变化
+// 实例重启之后会恢复到保存的 pattern，所有实例的 DoubleWritePool 一起切换
+// 可以多个实例一起执行的校验（分片全量校验、消费 binlog 的增量校验）所有实例都会加入，其它的只在发起的实例上执行
+func (s *Scheduler[T]) State(store StateStore) *Scheduler[T] {
+	s.state = store
+	go func() {
+		err := store.Watch(s.ctx, s.table(), s.apply)
+		if err != nil && s.ctx.Err() == nil {
+			s.l.Error("监听迁移状态失败", logger.Error(err))
+		}
+	}()
+	return s
+}
+
+// Close 停止监听状态，停掉当前实例上的校验，一般在 HTTP 服务器关闭的时候调用
+// 保存的状态不会改，其它实例不受影响，重启之后会重新加入
+func (s *Scheduler[T]) Close() {
+	s.cancel()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.cancelFull()
+	s.cancelIncr()
+}
+
 // 这一个也不是必须的，就是你可以考虑利用配置中心，监听配置中心的变化
 // 把全量校验，增量校验做成分布式任务，利用分布式任务调度平台来调度
-func (s *Scheduler[T]) RegisterRoutes(server *gin.Engine) {
+// 所有接口都只有管理员可以访问，需要配合 ginx/middleware/auth 使用
+func (s *Scheduler[T]) RegisterRoutes(server *gin.Engine, registry *ginx.Registry) {
+	if s.redis != nil && s.state == nil {
+		// 其它实例不知道要停下来，在启动的时候就暴露出来
+		panic("scheduler: 分片全量校验必须设置 State")
+	}
 	// 将这个暴露为 HTTP 接口
-	group := server.Group("/migrator")
+	group := registry.Group(server.Group("/migrator"), ginx.RequireRoles(ginx.RoleAdmin))
 	group.POST("/src_only", ginx.Wrap(s.SrcOnly))
 	group.POST("/src_first", ginx.Wrap(s.SrcFirst))
 	group.POST("/dst_first", ginx.Wrap(s.DstFirst))
@@ -68,55 +181,126 @@ func (s *Scheduler[T]) RegisterRoutes(server *gin.Engine) {
 	group.POST("/full/stop", ginx.Wrap(s.StopFullValidation))
 	group.POST("/incr/stop", ginx.Wrap(s.StopIncrementValidation))
 	group.POST("/incr/start", ginx.WrapReq[StartIncrRequest](s.StartIncrementValidation))
+	group.GET("/status", ginx.Wrap(s.Status))
+	group.GET("/audits", ginx.Wrap(s.Audits))
 }
 
-// ---- 下面是四个阶段 ---- //
-
-// SrcOnly 只读写源表
-func (s *Scheduler[T]) SrcOnly(c *gin.Context) (ginx.Result, error) {
+// Status 当前的阶段和校验进度
+func (s *Scheduler[T]) Status(c *gin.Context) (ginx.Result, error) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
-	s.pattern = connpool.PatternSrcOnly
-	s.pool.UpdatePattern(connpool.PatternSrcOnly)
+	status := Status{Pattern: s.pattern}
+	if s.full != nil {
+		p := s.full.Progress()
+		status.Full = &p
+	}
+	if s.incr != nil {
+		p := s.incr.Progress()
+		status.Incr = &p
+	}
+	if s.sharded != nil {
+		p := s.sharded.Progress()
+		status.Shards = &p
+	}
 	return ginx.Result{
-		Msg: "OK",
+		Msg:  "OK",
+		Data: status,
 	}, nil
 }
 
-func (s *Scheduler[T]) SrcFirst(c *gin.Context) (ginx.Result, error) {
-	s.lock.Lock()
-	defer s.lock.Unlock()
-	s.pattern = connpool.PatternSrcFirst
-	s.pool.UpdatePattern(connpool.PatternSrcFirst)
+// Audits 审计日志，按照时间倒序，?offset=0&limit=20
+func (s *Scheduler[T]) Audits(c *gin.Context) (ginx.Result, error) {
+	if s.state == nil {
+		return ginx.Result{Code: 4, Msg: "没有保存迁移状态"}, nil
+	}
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	audits, err := s.state.ListAudits(c, s.table(), offset, limit)
+	if err != nil {
+		return ginx.Result{}, err
+	}
 	return ginx.Result{
-		Msg: "OK",
+		Msg:  "OK",
+		Data: audits,
 	}, nil
 }
 
+// ---- 下面是四个阶段 ---- //
+
+// SrcOnly 只读写源表
+func (s *Scheduler[T]) SrcOnly(c *gin.Context) (ginx.Result, error) {
+	return s.switchPattern(c, connpool.PatternSrcOnly)
+}
+
+func (s *Scheduler[T]) SrcFirst(c *gin.Context) (ginx.Result, error) {
+	return s.switchPattern(c, connpool.PatternSrcFirst)
+}
+
 func (s *Scheduler[T]) DstFirst(c *gin.Context) (ginx.Result, error) {
-	s.lock.Lock()
-	defer s.lock.Unlock()
-	s.pattern = connpool.PatternDstFirst
-	s.pool.UpdatePattern(connpool.PatternDstFirst)
-	return ginx.Result{
-		Msg: "OK",
-	}, nil
+	return s.switchPattern(c, connpool.PatternDstFirst)
 }
 
 func (s *Scheduler[T]) DstOnly(c *gin.Context) (ginx.Result, error) {
+	return s.switchPattern(c, connpool.PatternDstOnly)
+}
+
+// switchPattern 只能一步一步地切换，设置了 State 的时候以保存的 pattern 为准
+func (s *Scheduler[T]) switchPattern(c *gin.Context, pattern string) (ginx.Result, error) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
-	s.pattern = connpool.PatternDstOnly
-	s.pool.UpdatePattern(connpool.PatternDstOnly)
+	if s.state != nil {
+		err := s.update(c, "pattern", func(st *State) (string, string, error) {
+			before := st.Pattern
+			st.Pattern = pattern
+			return before, pattern, checkTransition(before, pattern)
+		})
+		if err != nil {
+			return s.updateFailed(err)
+		}
+	} else if err := checkTransition(s.pattern, pattern); err != nil {
+		return ginx.Result{Code: 4, Msg: err.Error()}, nil
+	}
+	s.applyPattern(pattern)
 	return ginx.Result{
 		Msg: "OK",
 	}, nil
 }
 
+// applyPattern 调用方要持有 s.lock
+func (s *Scheduler[T]) applyPattern(pattern string) {
+	if s.pattern == pattern {
+		return
+	}
+	err := s.pool.UpdatePattern(pattern)
+	if err != nil {
+		s.l.Error("切换双写模式失败", logger.String("pattern", pattern), logger.Error(err))
+		return
+	}
+	s.l.Info("切换双写模式", logger.String("from", s.pattern), logger.String("to", pattern))
+	s.pattern = pattern
+}
+
 func (s *Scheduler[T]) StopIncrementValidation(c *gin.Context) (ginx.Result, error) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
+	if s.state != nil {
+		err := s.update(c, "incr_stop", func(st *State) (string, string, error) {
+			before := ""
+			if st.Incr != nil {
+				before = st.Incr.Run
+			}
+			st.Incr = nil
+			return before, "", nil
+		})
+		if err != nil {
+			return s.updateFailed(err)
+		}
+	}
 	s.cancelIncr()
+	s.incrRun = ""
 	return ginx.Result{
 		Msg: "OK",
 	}, nil
@@ -127,75 +311,368 @@ func (s *Scheduler[T]) StartIncrementValidation(c *gin.Context,
 	// 开启增量校验
 	s.lock.Lock()
 	defer s.lock.Unlock()
-	// 取消上一次的
-	cancel := s.cancelIncr
-	v, err := s.newValidator()
+	if s.src == nil && s.source == nil {
+		return ginx.Result{
+			Code: 4,
+			Msg:  "跨存储的迁移只支持消费 binlog 的增量校验",
+		}, nil
+	}
+	direction, err := s.direction()
 	if err != nil {
 		return ginx.Result{
 			Code: 5,
 			Msg:  "系统异常",
 		}, nil
 	}
-	v.Incr().Utime(req.Utime).SleepInterval(time.Duration(req.Interval) * time.Millisecond)
+	job := IncrJob{
+		Run:      s.newRun(direction),
+		Utime:    req.Utime,
+		Interval: req.Interval,
+	}
+	if s.state != nil {
+		err = s.update(c, "incr_start", func(st *State) (string, string, error) {
+			before := ""
+			if st.Incr != nil {
+				before = st.Incr.Run
+			}
+			st.Incr = &job
+			return before, job.Run, nil
+		})
+		if err != nil {
+			return s.updateFailed(err)
+		}
+	}
+	s.startIncr(job)
+	return ginx.Result{
+		Msg: "启动增量校验成功",
+	}, nil
+}
 
+// startIncr 停掉上一次的，开始 job 这一次的增量校验，调用方要持有 s.lock
+func (s *Scheduler[T]) startIncr(job IncrJob) {
+	s.cancelIncr()
+	s.incrRun = job.Run
+	direction, _, _ := strings.Cut(job.Run, ":")
+	v := s.newValidator(direction)
+	if s.source != nil {
+		// 消费 binlog 的时候 utime 和 interval 都用不上
+		v.Binlog(s.source)
+	} else {
+		v.Incr().Utime(job.Utime).SleepInterval(time.Duration(job.Interval) * time.Millisecond)
+	}
+	s.incr = v
+	var ctx context.Context
+	ctx, s.cancelIncr = context.WithCancel(s.ctx)
 	go func() {
-		var ctx context.Context
-		ctx, s.cancelIncr = context.WithCancel(context.Background())
-		cancel()
 		err := v.Validate(ctx)
 		s.l.Warn("退出增量校验", logger.Error(err))
 	}()
-	return ginx.Result{
-		Msg: "启动增量校验成功",
-	}, nil
 }
 
+// StopFullValidation 设置了 State 的时候，其它实例监听到之后也会停下来
 func (s *Scheduler[T]) StopFullValidation(c *gin.Context) (ginx.Result, error) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
+	if s.state != nil {
+		err := s.update(c, "full_stop", func(st *State) (string, string, error) {
+			before := st.Full
+			st.Full = ""
+			return before, "", nil
+		})
+		if err != nil {
+			return s.updateFailed(err)
+		}
+	}
 	s.cancelFull()
+	s.fullRun = ""
 	return ginx.Result{
 		Msg: "OK",
 	}, nil
 }
 
 // StartFullValidation 全量校验
+// 设置了 Checkpoint 的话，会从上一次的进度继续，带上 ?reset=true 可以从头开始
 func (s *Scheduler[T]) StartFullValidation(c *gin.Context) (ginx.Result, error) {
 	// 可以考虑去重的问题
 	s.lock.Lock()
 	defer s.lock.Unlock()
-	// 取消上一次的
-	cancel := s.cancelFull
-	v, err := s.newValidator()
+	direction, err := s.direction()
 	if err != nil {
 		return ginx.Result{}, err
 	}
-	var ctx context.Context
-	ctx, s.cancelFull = context.WithCancel(context.Background())
-	v.Full()
+	if c.Query("reset") == "true" {
+		err = s.reset(c, direction)
+		if err != nil {
+			return ginx.Result{}, err
+		}
+	}
+	run := s.newRun(direction)
+	if s.state != nil {
+		err = s.update(c, "full_start", func(st *State) (string, string, error) {
+			before := st.Full
+			st.Full = run
+			return before, run, nil
+		})
+		if err != nil {
+			return s.updateFailed(err)
+		}
+	}
+	s.startFull(run)
+	return ginx.Result{
+		Msg: "OK",
+	}, nil
+}
 
+func (s *Scheduler[T]) reset(ctx context.Context, direction string) error {
+	if s.redis != nil {
+		return s.newShardedValidator(direction).Reset(ctx)
+	}
+	if s.checkpoint != nil {
+		return validator.ClearCheckpoint(ctx, s.checkpoint, s.checkpointKey(direction))
+	}
+	return nil
+}
+
+// startFull 停掉本地上一次的校验，开始 run 这一次的全量校验，调用方要持有 s.lock
+// run 的值是 <方向>:<启动时间>，每一次启动都不一样，所以重复启动也能被其它实例感知到
+func (s *Scheduler[T]) startFull(run string) {
+	s.cancelFull()
+	s.fullRun = run
+	direction, _, _ := strings.Cut(run, ":")
+	var ctx context.Context
+	ctx, s.cancelFull = context.WithCancel(s.ctx)
+	var validate func(ctx context.Context) error
+	if s.redis != nil {
+		v := s.newShardedValidator(direction)
+		s.sharded = v
+		validate = v.Validate
+	} else {
+		v := s.newValidator(direction)
+		if s.checkpoint != nil {
+			v.Checkpoint(s.checkpoint, s.checkpointKey(direction))
+		}
+		v.Full()
+		s.full = v
+		validate = v.Validate
+	}
 	go func() {
-		// 先取消上一次的
-		cancel()
-		err := v.Validate(ctx)
+		err := validate(ctx)
 		if err != nil {
 			s.l.Warn("退出全量校验", logger.Error(err))
+			return
 		}
+		if ctx.Err() != nil || s.state == nil {
+			return
+		}
+		// 校验完成了，清掉任务，其它实例也就停下来了
+		s.finishFull(run)
 	}()
-	return ginx.Result{
-		Msg: "OK",
-	}, nil
 }
 
-func (s *Scheduler[T]) newValidator() (*validator.Validator[T], error) {
+func (s *Scheduler[T]) finishFull(run string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	err := s.update(ctx, "full_finish", func(st *State) (string, string, error) {
+		// 已经有新的一次校验了，不能清掉
+		if st.Full != run {
+			return "", "", errNoChange
+		}
+		st.Full = ""
+		return run, "", nil
+	})
+	if err != nil {
+		if !errors.Is(err, errNoChange) {
+			s.l.Error("清除全量校验任务失败", logger.String("run", run), logger.Error(err))
+		}
+		return
+	}
+	// 只有清掉任务的那个实例来清分片的进度
+	direction, _, _ := strings.Cut(run, ":")
+	s.clearShards(ctx, direction)
+}
+
+// clearShards 分片的完成标记不清掉的话，下一次全量校验会直接跳过这些分片
+func (s *Scheduler[T]) clearShards(ctx context.Context, direction string) {
+	if s.redis == nil {
+		return
+	}
+	err := s.newShardedValidator(direction).Reset(ctx)
+	if err != nil {
+		s.l.Error("清除分片校验进度失败", logger.String("direction", direction), logger.Error(err))
+	}
+}
+
+// apply 把保存的状态应用到当前实例，启动的时候和状态变化的时候都会调用
+func (s *Scheduler[T]) apply(st State) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.applyPattern(st.Pattern)
+
+	if st.Full != s.fullRun {
+		switch {
+		case st.Full == "":
+			s.cancelFull()
+			s.fullRun = ""
+		case s.redis != nil:
+			// 只有分片全量校验可以多个实例一起执行
+			s.startFull(st.Full)
+		}
+	}
+
+	incrRun := ""
+	if st.Incr != nil {
+		incrRun = st.Incr.Run
+	}
+	if incrRun != s.incrRun {
+		switch {
+		case st.Incr == nil:
+			s.cancelIncr()
+			s.incrRun = ""
+		case s.source != nil:
+			// 消费 binlog 的时候靠消费者组分配分区，多个实例一起执行也不会重复
+			s.startIncr(*st.Incr)
+		}
+	}
+}
+
+var errNoChange = errors.New("状态不需要修改")
+
+// update 修改保存的状态并且记录审计日志，被别的实例抢先修改了就重试
+// fn 返回修改前后的值，记录到审计日志里面
+func (s *Scheduler[T]) update(ctx context.Context, action string,
+	fn func(st *State) (string, string, error)) error {
+	name := s.table()
+	for i := 0; i < 3; i++ {
+		old, err := s.state.Load(ctx, name)
+		if err != nil {
+			return err
+		}
+		st := old
+		before, after, err := fn(&st)
+		if err != nil {
+			return err
+		}
+		_, err = s.state.CompareAndSwap(ctx, name, old, st)
+		if errors.Is(err, ErrStateChanged) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = s.state.AddAudit(ctx, name, Audit{
+			Operator: operator(ctx),
+			Action:   action,
+			Before:   before,
+			After:    after,
+			Ctime:    time.Now().UnixMilli(),
+		})
+		if err != nil {
+			// 状态已经改了，审计日志失败不影响结果
+			s.l.Error("记录审计日志失败", logger.String("action", action), logger.Error(err))
+		}
+		return nil
+	}
+	return ErrStateChanged
+}
+
+func (s *Scheduler[T]) updateFailed(err error) (ginx.Result, error) {
+	switch {
+	case errors.Is(err, ErrIllegalTransition):
+		return ginx.Result{Code: 4, Msg: err.Error()}, nil
+	case errors.Is(err, ErrStateChanged):
+		return ginx.Result{Code: 4, Msg: "状态已经被修改，请刷新之后重试"}, nil
+	default:
+		return ginx.Result{}, err
+	}
+}
+
+// operator 操作人，用登录的管理员的 id，请求头这些客户端可以随便填，不能用
+// 不是 HTTP 请求的时候是 system
+func operator(ctx context.Context) string {
+	c, ok := ctx.(*gin.Context)
+	if !ok {
+		return "system"
+	}
+	val, _ := c.Get("user")
+	uc, ok := val.(ginx.UserClaims)
+	if !ok {
+		return "unknown"
+	}
+	return strconv.FormatInt(uc.UserId, 10)
+}
+
+func (s *Scheduler[T]) newRun(direction string) string {
+	return direction + ":" + strconv.FormatInt(time.Now().UnixMilli(), 10)
+}
+
+func (s *Scheduler[T]) newShardedValidator(direction string) *validator.ShardedValidator[T] {
+	base, target := s.stores(direction)
+	store := s.checkpoint
+	if store == nil {
+		store = validator.NewRedisCheckpointStore(s.redis)
+	}
+	return validator.NewShardedValidator[T](base, target, direction, s.l, s.producer,
+		s.lockClient, store, s.checkpointKey(direction)).
+		ShardSize(s.shardSize).
+		Concurrency(s.concurrency)
+}
+
+func (s *Scheduler[T]) direction() (string, error) {
 	switch s.pattern {
 	case connpool.PatternSrcOnly, connpool.PatternSrcFirst:
-		return validator.NewValidator[T](s.src, s.dst, "SRC", s.l, s.producer), nil
+		return "SRC", nil
 	case connpool.PatternDstFirst, connpool.PatternDstOnly:
-		return validator.NewValidator[T](s.dst, s.src, "DST", s.l, s.producer), nil
+		return "DST", nil
 	default:
-		return nil, fmt.Errorf("未知的 pattern %s", s.pattern)
+		return "", fmt.Errorf("未知的 pattern %s", s.pattern)
+	}
+}
+
+func (s *Scheduler[T]) newValidator(direction string) *validator.Validator[T] {
+	if s.src == nil {
+		base, target := s.stores(direction)
+		return validator.NewStoreValidator[T](base, target, direction, s.l, s.producer)
+	}
+	base, target := s.dbs(direction)
+	return validator.NewValidator[T](base, target, direction, s.l, s.producer)
+}
+
+func (s *Scheduler[T]) stores(direction string) (migrator.Store[T], migrator.Store[T]) {
+	if direction == "DST" {
+		return s.dstStore, s.srcStore
 	}
+	return s.srcStore, s.dstStore
+}
+
+// dbs 返回以哪一边为准，以及要校验的另一边
+func (s *Scheduler[T]) dbs(direction string) (*gorm.DB, *gorm.DB) {
+	if direction == "DST" {
+		return s.dst, s.src
+	}
+	return s.src, s.dst
+}
+
+func (s *Scheduler[T]) checkpointKey(direction string) string {
+	return s.table() + ":" + direction
+}
+
+func (s *Scheduler[T]) table() string {
+	if s.name != "" {
+		return s.name
+	}
+	stmt := &gorm.Statement{DB: s.src}
+	if err := stmt.Parse(new(T)); err == nil {
+		return stmt.Schema.Table
+	}
+	return "unknown"
+}
+
+type Status struct {
+	Pattern string              `json:"pattern"`
+	Full    *validator.Progress `json:"full,omitempty"`
+	Incr    *validator.Progress `json:"incr,omitempty"`
+	// Shards 分片全量校验的进度
+	Shards *validator.ShardProgress `json:"shards,omitempty"`
 }
 
 type StartIncrRequest struct {
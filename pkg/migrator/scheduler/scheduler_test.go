@@ -0,0 +1,197 @@
+package scheduler
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"webook/pkg/ginx"
+	"webook/pkg/gormx/connpool"
+	"webook/pkg/logger"
+	"webook/pkg/migrator"
+)
+
+func TestOperator(t *testing.T) {
+	testCases := []struct {
+		name string
+		ctx  func() context.Context
+		want string
+	}{
+		{
+			name: "不是 HTTP 请求",
+			ctx: func() context.Context {
+				return context.Background()
+			},
+			want: "system",
+		},
+		{
+			name: "登录的管理员",
+			ctx: func() context.Context {
+				c, _ := gin.CreateTestContext(httptest.NewRecorder())
+				c.Request = httptest.NewRequest(http.MethodPost, "/migrator/src_first", nil)
+				c.Set("user", ginx.UserClaims{UserId: 123})
+				return c
+			},
+			want: "123",
+		},
+		{
+			// 请求头是客户端随便填的，不能用
+			name: "忽略 X-Operator",
+			ctx: func() context.Context {
+				c, _ := gin.CreateTestContext(httptest.NewRecorder())
+				c.Request = httptest.NewRequest(http.MethodPost, "/migrator/src_first", nil)
+				c.Request.Header.Set("X-Operator", "admin")
+				return c
+			},
+			want: "unknown",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, operator(tc.ctx()))
+		})
+	}
+}
+
+func TestStoreScheduler(t *testing.T) {
+	updater := &patternRecorder{}
+	s := NewStoreScheduler[testEntity](logger.NewNoOpLogger(), "test", nil, nil, updater, nil)
+	assert.Equal(t, "test", s.table())
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/migrator/src_first", nil)
+	res, err := s.SrcFirst(c)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, res.Code)
+	assert.Equal(t, []string{connpool.PatternSrcFirst}, updater.patterns)
+
+	// 跨存储的时候没有 gorm，不能按照 utime 轮询
+	res, err = s.StartIncrementValidation(c, StartIncrRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, res.Code)
+}
+
+func TestScheduler_finishFull(t *testing.T) {
+	const run = "SRC:1700000000000"
+	testCases := []struct {
+		name string
+		// full 保存的全量校验任务
+		full string
+
+		wantFull string
+		// wantDone 还留着的分片完成标记
+		wantDone map[string]int64
+	}{
+		{
+			name:     "清掉任务和分片的完成标记",
+			full:     run,
+			wantFull: "",
+			wantDone: map[string]int64{},
+		},
+		{
+			// 新的一次校验已经开始了，它的进度不能清掉
+			name:     "已经有新的一次校验",
+			full:     "SRC:1700000001000",
+			wantFull: "SRC:1700000001000",
+			wantDone: map[string]int64{
+				"test:SRC:shard:0:done": 1,
+				"test:SRC:shard:1:done": 1,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			checkpoint := &memoryCheckpointStore{data: map[string]int64{
+				"test:SRC:shard:0:done": 1,
+				"test:SRC:shard:1:done": 1,
+			}}
+			state := &memoryStateStore{state: State{Pattern: connpool.PatternSrcOnly, Full: tc.full, Version: 1}}
+			store := maxIdStore{maxId: 15}
+			s := NewStoreScheduler[testEntity](logger.NewNoOpLogger(), "test", store, store, &patternRecorder{}, nil).
+				Sharding(redis.NewClient(&redis.Options{}), 10, 1).
+				Checkpoint(checkpoint)
+			// 不走 State，避免启动监听
+			s.state = state
+
+			s.finishFull(run)
+			st, err := state.Load(context.Background(), "test")
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantFull, st.Full)
+			assert.Equal(t, tc.wantDone, checkpoint.data)
+		})
+	}
+}
+
+type patternRecorder struct {
+	patterns []string
+}
+
+func (p *patternRecorder) UpdatePattern(pattern string) error {
+	p.patterns = append(p.patterns, pattern)
+	return nil
+}
+
+type testEntity struct {
+	Id int64
+}
+
+func (t testEntity) ID() int64 {
+	return t.Id
+}
+
+func (t testEntity) CompareTo(dst migrator.Entity) bool {
+	return migrator.Equal(t, dst)
+}
+
+type maxIdStore struct {
+	migrator.Store[testEntity]
+	maxId int64
+}
+
+func (m maxIdStore) MaxId(ctx context.Context) (int64, error) {
+	return m.maxId, nil
+}
+
+type memoryCheckpointStore struct {
+	data map[string]int64
+}
+
+func (m *memoryCheckpointStore) Load(ctx context.Context, key string) (int64, error) {
+	return m.data[key], nil
+}
+
+func (m *memoryCheckpointStore) Save(ctx context.Context, key string, lastId int64) error {
+	m.data[key] = lastId
+	return nil
+}
+
+func (m *memoryCheckpointStore) Clear(ctx context.Context, key string) error {
+	delete(m.data, key)
+	return nil
+}
+
+type memoryStateStore struct {
+	StateStore
+	state State
+}
+
+func (m *memoryStateStore) Load(ctx context.Context, name string) (State, error) {
+	return m.state, nil
+}
+
+func (m *memoryStateStore) CompareAndSwap(ctx context.Context, name string, old State, state State) (State, error) {
+	if m.state.Version != old.Version {
+		return State{}, ErrStateChanged
+	}
+	state.Version = old.Version + 1
+	m.state = state
+	return state, nil
+}
+
+func (m *memoryStateStore) AddAudit(ctx context.Context, name string, audit Audit) error {
+	return nil
+}
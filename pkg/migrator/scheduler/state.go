@@ -0,0 +1,230 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+	"webook/pkg/gormx/connpool"
+)
+
+var (
+	ErrStateChanged      = errors.New("scheduler: 状态已经被别人修改了")
+	ErrIllegalTransition = errors.New("scheduler: 非法的切换")
+)
+
+// State 所有实例共享的迁移状态，实例启动的时候加载，之后监听它的变化
+type State struct {
+	Pattern string `json:"pattern"`
+	// Full 正在进行的全量校验，空字符串表示没有
+	// 值是 <方向>:<启动时间>，每一次启动都不一样，所以重复启动也能被感知到
+	Full string `json:"full"`
+	// Incr 正在进行的增量校验，nil 表示没有
+	Incr *IncrJob `json:"incr,omitempty"`
+	// Version 每一次修改都加一，用来做并发控制，0 表示还没有保存过
+	Version int64 `json:"version"`
+}
+
+type IncrJob struct {
+	// Run 和 State.Full 一样，每一次启动都不一样
+	Run      string `json:"run"`
+	Utime    int64  `json:"utime"`
+	Interval int64  `json:"interval"`
+}
+
+// Audit 谁在什么时候改了什么
+type Audit struct {
+	Operator string `json:"operator"`
+	// Action 例如 pattern、full_start、full_stop
+	Action string `json:"action"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+	Ctime  int64  `json:"ctime"`
+}
+
+// StateStore 保存迁移状态和审计日志，name 用来区分不同的迁移，例如表名
+type StateStore interface {
+	// Load 没有保存过的时候返回 PatternSrcOnly
+	Load(ctx context.Context, name string) (State, error)
+	// CompareAndSwap 保存的版本号和 old.Version 一样的时候才会保存，否则返回 ErrStateChanged
+	// 返回保存之后的状态
+	CompareAndSwap(ctx context.Context, name string, old State, state State) (State, error)
+	// Watch 状态变化的时候调用 fn，第一次会用当前的状态调用，直到 ctx 被取消
+	Watch(ctx context.Context, name string, fn func(state State)) error
+	AddAudit(ctx context.Context, name string, audit Audit) error
+	// ListAudits 按照时间倒序
+	ListAudits(ctx context.Context, name string, offset, limit int) ([]Audit, error)
+}
+
+// patterns 四个阶段只能按照顺序一步一步切换，往前或者回退一步都可以
+var patterns = []string{
+	connpool.PatternSrcOnly,
+	connpool.PatternSrcFirst,
+	connpool.PatternDstFirst,
+	connpool.PatternDstOnly,
+}
+
+// checkTransition 例如 SrcOnly 不能直接切换到 DstOnly，中间没有双写，数据肯定不一致
+func checkTransition(from, to string) error {
+	fi, ti := -1, -1
+	for i, p := range patterns {
+		if p == from {
+			fi = i
+		}
+		if p == to {
+			ti = i
+		}
+	}
+	if ti < 0 {
+		return fmt.Errorf("%w: 未知的 pattern %s", ErrIllegalTransition, to)
+	}
+	if fi >= 0 && (ti-fi > 1 || fi-ti > 1) {
+		return fmt.Errorf("%w: 不能从 %s 直接切换到 %s", ErrIllegalTransition, from, to)
+	}
+	return nil
+}
+
+// GORMStateStore 基于 MySQL 的 StateStore，通过轮询监听变化
+// 需要提前建好 MigratorState 和 MigratorAudit 对应的表
+type GORMStateStore struct {
+	db       *gorm.DB
+	interval time.Duration
+}
+
+func NewGORMStateStore(db *gorm.DB) *GORMStateStore {
+	return &GORMStateStore{db: db, interval: time.Second * 3}
+}
+
+func (g *GORMStateStore) Load(ctx context.Context, name string) (State, error) {
+	var ms MigratorState
+	err := g.db.WithContext(ctx).Where("name = ?", name).First(&ms).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return State{Pattern: connpool.PatternSrcOnly}, nil
+	}
+	if err != nil {
+		return State{}, err
+	}
+	state := State{Pattern: ms.Pattern, Full: ms.Full, Version: ms.Version}
+	if ms.Incr != "" {
+		state.Incr = &IncrJob{}
+		err = json.Unmarshal([]byte(ms.Incr), state.Incr)
+	}
+	return state, err
+}
+
+func (g *GORMStateStore) CompareAndSwap(ctx context.Context, name string, old State, state State) (State, error) {
+	incr := ""
+	if state.Incr != nil {
+		val, err := json.Marshal(state.Incr)
+		if err != nil {
+			return State{}, err
+		}
+		incr = string(val)
+	}
+	state.Version = old.Version + 1
+	now := time.Now().UnixMilli()
+	if old.Version == 0 {
+		// 还没有保存过，两个实例同时插入的话，有一个会因为主键冲突失败
+		err := g.db.WithContext(ctx).Create(&MigratorState{
+			Name:    name,
+			Pattern: state.Pattern,
+			Full:    state.Full,
+			Incr:    incr,
+			Version: state.Version,
+			Utime:   now,
+		}).Error
+		if err != nil {
+			if cur, er := g.Load(ctx, name); er == nil && cur.Version != 0 {
+				return State{}, ErrStateChanged
+			}
+			return State{}, err
+		}
+		return state, nil
+	}
+	res := g.db.WithContext(ctx).Model(&MigratorState{}).
+		Where("name = ? AND version = ?", name, old.Version).
+		Updates(map[string]any{
+			"pattern": state.Pattern,
+			"full":    state.Full,
+			"incr":    incr,
+			"version": state.Version,
+			"utime":   now,
+		})
+	if res.Error != nil {
+		return State{}, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return State{}, ErrStateChanged
+	}
+	return state, nil
+}
+
+func (g *GORMStateStore) Watch(ctx context.Context, name string, fn func(state State)) error {
+	var version int64 = -1
+	for {
+		state, err := g.Load(ctx, name)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == nil && state.Version != version {
+			version = state.Version
+			fn(state)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(g.interval):
+		}
+	}
+}
+
+func (g *GORMStateStore) AddAudit(ctx context.Context, name string, audit Audit) error {
+	return g.db.WithContext(ctx).Create(&MigratorAudit{
+		Name:     name,
+		Operator: audit.Operator,
+		Action:   audit.Action,
+		Before:   audit.Before,
+		After:    audit.After,
+		Ctime:    audit.Ctime,
+	}).Error
+}
+
+func (g *GORMStateStore) ListAudits(ctx context.Context, name string, offset, limit int) ([]Audit, error) {
+	var audits []MigratorAudit
+	err := g.db.WithContext(ctx).Where("name = ?", name).
+		Order("id DESC").Offset(offset).Limit(limit).
+		Find(&audits).Error
+	res := make([]Audit, 0, len(audits))
+	for _, a := range audits {
+		res = append(res, Audit{
+			Operator: a.Operator,
+			Action:   a.Action,
+			Before:   a.Before,
+			After:    a.After,
+			Ctime:    a.Ctime,
+		})
+	}
+	return res, err
+}
+
+type MigratorState struct {
+	Name    string `gorm:"type:varchar(128);primaryKey"`
+	Pattern string `gorm:"type:varchar(32)"`
+	Full    string `gorm:"type:varchar(64)"`
+	// Incr IncrJob 的 JSON
+	Incr    string `gorm:"type:varchar(256)"`
+	Version int64
+	Utime   int64
+}
+
+type MigratorAudit struct {
+	Id       int64  `gorm:"primaryKey,autoIncrement"`
+	Name     string `gorm:"type:varchar(128);index"`
+	Operator string `gorm:"type:varchar(128)"`
+	Action   string `gorm:"type:varchar(32)"`
+	Before   string `gorm:"type:varchar(256)"`
+	After    string `gorm:"type:varchar(256)"`
+	Ctime    int64
+}
@@ -0,0 +1,60 @@
+package scheduler
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"webook/pkg/gormx/connpool"
+)
+
+func TestCheckTransition(t *testing.T) {
+	testCases := []struct {
+		name    string
+		from    string
+		to      string
+		wantErr bool
+	}{
+		{
+			name: "前进一步",
+			from: connpool.PatternSrcOnly,
+			to:   connpool.PatternSrcFirst,
+		},
+		{
+			name: "回退一步",
+			from: connpool.PatternDstFirst,
+			to:   connpool.PatternSrcFirst,
+		},
+		{
+			name: "不变",
+			from: connpool.PatternDstOnly,
+			to:   connpool.PatternDstOnly,
+		},
+		{
+			name:    "跳过双写",
+			from:    connpool.PatternSrcOnly,
+			to:      connpool.PatternDstOnly,
+			wantErr: true,
+		},
+		{
+			name:    "跳过一步",
+			from:    connpool.PatternSrcOnly,
+			to:      connpool.PatternDstFirst,
+			wantErr: true,
+		},
+		{
+			name:    "未知的 pattern",
+			from:    connpool.PatternSrcOnly,
+			to:      "unknown",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkTransition(tc.from, tc.to)
+			if tc.wantErr {
+				assert.ErrorIs(t, err, ErrIllegalTransition)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package migrator
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Store 迁移的一端，除了 MySQL 之外，也可以是 MongoDB、ES 这种不能用 gorm 访问的存储
+// 校验和修复都只依赖 Store，数据完全靠 id 定位
+type Store[T Entity] interface {
+	// FindByIds 不存在的 id 直接忽略
+	FindByIds(ctx context.Context, ids []int64) ([]T, error)
+	// Scan 按照 id 升序，返回 id 在 (after, until] 里面的最多 limit 条数据，until 为 0 表示不限制
+	Scan(ctx context.Context, after, until int64, limit int) ([]T, error)
+	// MaxId 没有数据的时候返回 0
+	MaxId(ctx context.Context) (int64, error)
+	// Upsert 按照 id 插入或者整行覆盖
+	Upsert(ctx context.Context, t T) error
+	Delete(ctx context.Context, id int64) error
+}
+
+// GORMStore 基于 gorm 的 Store
+type GORMStore[T Entity] struct {
+	db *gorm.DB
+}
+
+func NewGORMStore[T Entity](db *gorm.DB) *GORMStore[T] {
+	return &GORMStore[T]{db: db}
+}
+
+// Table T 对应的表名，消费 binlog 的时候用来过滤
+func (g *GORMStore[T]) Table() (string, error) {
+	stmt := &gorm.Statement{DB: g.db}
+	err := stmt.Parse(new(T))
+	if err != nil {
+		return "", err
+	}
+	return stmt.Schema.Table, nil
+}
+
+func (g *GORMStore[T]) FindByIds(ctx context.Context, ids []int64) ([]T, error) {
+	var res []T
+	err := g.db.WithContext(ctx).Where("id IN ?", ids).Find(&res).Error
+	return res, err
+}
+
+func (g *GORMStore[T]) Scan(ctx context.Context, after, until int64, limit int) ([]T, error) {
+	db := g.db.WithContext(ctx).Where("id > ?", after)
+	if until > 0 {
+		db = db.Where("id <= ?", until)
+	}
+	var res []T
+	err := db.Order("id").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+func (g *GORMStore[T]) MaxId(ctx context.Context) (int64, error) {
+	var maxId int64
+	err := g.db.WithContext(ctx).Model(new(T)).
+		Select("COALESCE(MAX(id), 0)").
+		Scan(&maxId).Error
+	return maxId, err
+}
+
+func (g *GORMStore[T]) Upsert(ctx context.Context, t T) error {
+	return g.db.WithContext(ctx).Clauses(clause.OnConflict{
+		UpdateAll: true,
+	}).Create(&t).Error
+}
+
+func (g *GORMStore[T]) Delete(ctx context.Context, id int64) error {
+	return g.db.WithContext(ctx).Where("id = ?", id).Delete(new(T)).Error
+}
@@ -0,0 +1,102 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// CheckpointStore 保存全量校验的进度，也就是已经校验过的最大 id
+// 实例重启之后，全量校验可以从这里继续，而不是从头开始
+type CheckpointStore interface {
+	// Load 没有保存过就返回 0
+	Load(ctx context.Context, key string) (int64, error)
+	Save(ctx context.Context, key string, lastId int64) error
+	// Clear 校验完成之后清掉，下一次全量校验从头开始
+	Clear(ctx context.Context, key string) error
+}
+
+// ClearCheckpoint 清掉两个方向的校验进度，下一次全量校验从头开始
+func ClearCheckpoint(ctx context.Context, store CheckpointStore, key string) error {
+	err := store.Clear(ctx, key+":"+phaseBaseToTarget)
+	if err != nil {
+		return err
+	}
+	return store.Clear(ctx, key+":"+phaseTargetToBase)
+}
+
+type RedisCheckpointStore struct {
+	client redis.Cmdable
+}
+
+func NewRedisCheckpointStore(client redis.Cmdable) CheckpointStore {
+	return &RedisCheckpointStore{client: client}
+}
+
+func (r *RedisCheckpointStore) Load(ctx context.Context, key string) (int64, error) {
+	res, err := r.client.Get(ctx, r.key(key)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return res, err
+}
+
+func (r *RedisCheckpointStore) Save(ctx context.Context, key string, lastId int64) error {
+	return r.client.Set(ctx, r.key(key), lastId, 0).Err()
+}
+
+func (r *RedisCheckpointStore) Clear(ctx context.Context, key string) error {
+	return r.client.Del(ctx, r.key(key)).Err()
+}
+
+func (r *RedisCheckpointStore) key(key string) string {
+	return "migrator:checkpoint:" + key
+}
+
+type GORMCheckpointStore struct {
+	db *gorm.DB
+}
+
+// NewGORMCheckpointStore 需要提前建好 MigratorCheckpoint 对应的表
+func NewGORMCheckpointStore(db *gorm.DB) CheckpointStore {
+	return &GORMCheckpointStore{db: db}
+}
+
+func (g *GORMCheckpointStore) Load(ctx context.Context, key string) (int64, error) {
+	var cp MigratorCheckpoint
+	err := g.db.WithContext(ctx).Where("`key` = ?", key).First(&cp).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	return cp.LastId, err
+}
+
+func (g *GORMCheckpointStore) Save(ctx context.Context, key string, lastId int64) error {
+	now := time.Now().UnixMilli()
+	return g.db.WithContext(ctx).Clauses(clause.OnConflict{
+		DoUpdates: clause.Assignments(map[string]any{
+			"last_id": lastId,
+			"utime":   now,
+		}),
+	}).Create(&MigratorCheckpoint{
+		Key:    key,
+		LastId: lastId,
+		Ctime:  now,
+		Utime:  now,
+	}).Error
+}
+
+func (g *GORMCheckpointStore) Clear(ctx context.Context, key string) error {
+	return g.db.WithContext(ctx).Where("`key` = ?", key).Delete(&MigratorCheckpoint{}).Error
+}
+
+type MigratorCheckpoint struct {
+	Id     int64  `gorm:"primaryKey,autoIncrement"`
+	Key    string `gorm:"type:varchar(256);uniqueIndex"`
+	LastId int64
+	Ctime  int64
+	Utime  int64
+}
@@ -0,0 +1,100 @@
+package validator
+
+import (
+	"sync"
+	"time"
+)
+
+// Progress 校验进度
+type Progress struct {
+	Direction    string        `json:"direction"`
+	BaseToTarget PhaseProgress `json:"base_to_target"`
+	TargetToBase PhaseProgress `json:"target_to_base"`
+	// Mismatches 按照不一致的类型统计
+	Mismatches map[string]int64 `json:"mismatches"`
+}
+
+// PhaseProgress 一个方向的校验进度
+type PhaseProgress struct {
+	// Scanned 这一次启动之后扫描过的行数
+	Scanned int64 `json:"scanned"`
+	// LastId 已经校验到的 id
+	LastId int64 `json:"last_id"`
+	// MaxId 开始校验的时候表里面最大的 id
+	MaxId int64 `json:"max_id"`
+	Done  bool  `json:"done"`
+	// ETA 预计还需要多少秒，按照 id 的推进速度估算，-1 表示还估算不出来
+	ETA int64 `json:"eta_seconds"`
+}
+
+type phase struct {
+	lock    sync.Mutex
+	startId int64
+	lastId  int64
+	maxId   int64
+	scanned int64
+	done    bool
+	start   time.Time
+}
+
+func (p *phase) begin(startId, maxId int64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.startId = startId
+	p.lastId = startId
+	p.maxId = maxId
+	p.start = time.Now()
+}
+
+func (p *phase) advance(lastId int64, scanned int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.lastId = lastId
+	p.scanned += int64(scanned)
+}
+
+func (p *phase) finish() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.done = true
+}
+
+func (p *phase) snapshot() PhaseProgress {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	res := PhaseProgress{
+		Scanned: p.scanned,
+		LastId:  p.lastId,
+		MaxId:   p.maxId,
+		Done:    p.done,
+		ETA:     -1,
+	}
+	if p.done {
+		res.ETA = 0
+		return res
+	}
+	finished := p.lastId - p.startId
+	if finished <= 0 || p.maxId <= p.startId {
+		return res
+	}
+	elapsed := time.Since(p.start)
+	remaining := p.maxId - p.lastId
+	if remaining < 0 {
+		remaining = 0
+	}
+	res.ETA = int64(elapsed.Seconds() * float64(remaining) / float64(finished))
+	return res
+}
+
+// ShardProgress 分片全量校验的进度，除了 Total 之外都是当前实例的数据
+type ShardProgress struct {
+	Direction string `json:"direction"`
+	// Total 分片总数
+	Total int `json:"total"`
+	// Finished 当前实例校验完成的分片数
+	Finished int `json:"finished"`
+	// Running 当前实例正在校验的分片
+	Running map[int]Progress `json:"running"`
+	// Mismatches 当前实例发现的不一致，按照类型统计
+	Mismatches map[string]int64 `json:"mismatches"`
+}
@@ -0,0 +1,303 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	rlock "github.com/gotomicro/redis-lock"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+	"webook/pkg/logger"
+	"webook/pkg/migrator"
+	"webook/pkg/migrator/events"
+)
+
+// ShardedValidator 多个实例一起完成全量校验
+// id 按照 shardSize 切成固定的分片，第 i 个分片是 (i*shardSize, (i+1)*shardSize]，
+// 分片的划分只和 shardSize 有关，所以每个分片的 checkpoint 在重启之后依旧有效
+// 每个实例开 concurrency 个 goroutine，通过分布式锁抢占分片，每个分片交给一个 Validator 校验
+// 开始的时候才计算分片的数量，之后插入的数据由增量校验负责
+type ShardedValidator[T migrator.Entity] struct {
+	base   migrator.Store[T]
+	target migrator.Store[T]
+
+	l          logger.LoggerV1
+	producer   events.Producer
+	direction  string
+	lockClient *rlock.Client
+	checkpoint CheckpointStore
+	key        string
+
+	shardSize   int64
+	concurrency int
+	batchSize   int
+	// 分片锁的过期时间，持有期间会自动续约
+	lockExpiration time.Duration
+	// 有分片被别的实例占着的时候，隔多久再扫一遍
+	passInterval time.Duration
+
+	lock       sync.Mutex
+	total      int
+	finished   int
+	running    map[int]*Validator[T]
+	mismatches map[string]int64
+}
+
+// NewShardedValidator key 要能区分不同的表和校验方向，分片的 checkpoint 和锁都以它为前缀
+func NewShardedValidator[T migrator.Entity](base migrator.Store[T], target migrator.Store[T], direction string,
+	l logger.LoggerV1, producer events.Producer,
+	lockClient *rlock.Client, checkpoint CheckpointStore, key string) *ShardedValidator[T] {
+	return &ShardedValidator[T]{
+		base:           base,
+		target:         target,
+		l:              l,
+		producer:       producer,
+		direction:      direction,
+		lockClient:     lockClient,
+		checkpoint:     checkpoint,
+		key:            key,
+		shardSize:      100000,
+		concurrency:    4,
+		batchSize:      100,
+		lockExpiration: time.Minute,
+		passInterval:   time.Second * 10,
+		running:        make(map[int]*Validator[T]),
+		mismatches:     make(map[string]int64),
+	}
+}
+
+func (s *ShardedValidator[T]) ShardSize(shardSize int64) *ShardedValidator[T] {
+	s.shardSize = shardSize
+	return s
+}
+
+// Concurrency 当前实例同时校验几个分片
+func (s *ShardedValidator[T]) Concurrency(concurrency int) *ShardedValidator[T] {
+	s.concurrency = concurrency
+	return s
+}
+
+func (s *ShardedValidator[T]) BatchSize(batchSize int) *ShardedValidator[T] {
+	s.batchSize = batchSize
+	return s
+}
+
+func (s *ShardedValidator[T]) Direction() string {
+	return s.direction
+}
+
+// Progress 当前实例的校验进度
+func (s *ShardedValidator[T]) Progress() ShardProgress {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	res := ShardProgress{
+		Direction:  s.direction,
+		Total:      s.total,
+		Finished:   s.finished,
+		Running:    make(map[int]Progress, len(s.running)),
+		Mismatches: make(map[string]int64, len(s.mismatches)),
+	}
+	for typ, cnt := range s.mismatches {
+		res.Mismatches[typ] = cnt
+	}
+	for idx, v := range s.running {
+		p := v.Progress()
+		res.Running[idx] = p
+		for typ, cnt := range p.Mismatches {
+			res.Mismatches[typ] += cnt
+		}
+	}
+	return res
+}
+
+// Validate 一轮一轮地扫描所有的分片，直到所有分片都校验完成，或者 ctx 被取消
+// 被别的实例占着的分片这一轮先跳过，如果那个实例崩溃了，锁过期之后分片会被重新抢占
+func (s *ShardedValidator[T]) Validate(ctx context.Context) error {
+	total, err := s.shards(ctx)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	s.total = total
+	s.lock.Unlock()
+	for {
+		pending := s.pass(ctx, total)
+		if ctx.Err() != nil || pending == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(s.passInterval):
+		}
+	}
+}
+
+// Reset 清掉所有分片的进度，下一次全量校验从头开始
+func (s *ShardedValidator[T]) Reset(ctx context.Context) error {
+	total, err := s.shards(ctx)
+	if err != nil {
+		return err
+	}
+	for idx := 0; idx < total; idx++ {
+		key := s.shardKey(idx)
+		err = ClearCheckpoint(ctx, s.checkpoint, key)
+		if err != nil {
+			return err
+		}
+		err = s.checkpoint.Clear(ctx, key+":done")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pass 把所有分片扫一遍，返回还没有完成的分片数
+func (s *ShardedValidator[T]) pass(ctx context.Context, total int) int {
+	concurrency := s.concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	var pending atomic.Int64
+	var wg sync.WaitGroup
+	shards := make(chan int)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range shards {
+				done, err := s.validateShard(ctx, idx)
+				if err != nil {
+					s.l.Error("分片校验失败", logger.String("key", s.key),
+						logger.Int("shard", idx), logger.Error(err))
+				}
+				if !done {
+					pending.Add(1)
+				}
+			}
+		}()
+	}
+loop:
+	for idx := 0; idx < total; idx++ {
+		select {
+		case <-ctx.Done():
+			break loop
+		case shards <- idx:
+		}
+	}
+	close(shards)
+	wg.Wait()
+	return int(pending.Load())
+}
+
+// validateShard 返回分片是否已经校验完成，被别的实例占着的话返回 false
+func (s *ShardedValidator[T]) validateShard(ctx context.Context, idx int) (bool, error) {
+	key := s.shardKey(idx)
+	done, err := s.isDone(ctx, key)
+	if err != nil || done {
+		return done, err
+	}
+	lock, err := s.lockClient.TryLock(ctx, "migrator:lock:"+key, s.lockExpiration)
+	if errors.Is(err, rlock.ErrFailedToPreemptLock) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if er := lock.Unlock(ctx); er != nil {
+			s.l.Warn("释放分片锁失败", logger.String("key", key), logger.Error(er))
+		}
+	}()
+	// 拿到锁之前，别的实例可能刚好校验完
+	done, err = s.isDone(ctx, key)
+	if err != nil || done {
+		return done, err
+	}
+
+	shardCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		er := lock.AutoRefresh(s.lockExpiration/3, time.Second)
+		if er != nil {
+			// 锁丢了，分片可能已经被别的实例抢走了，停下来等下一轮
+			s.l.Error("分片锁续约失败", logger.String("key", key), logger.Error(er))
+			cancel()
+		}
+	}()
+
+	start := int64(idx) * s.shardSize
+	v := NewStoreValidator[T](s.base, s.target, s.direction, s.l, s.producer).
+		BatchSize(s.batchSize).
+		Checkpoint(s.checkpoint, key).
+		Range(start, start+s.shardSize).
+		Full()
+	s.track(idx, v)
+	err = v.Validate(shardCtx)
+	s.untrack(idx, v)
+	if err != nil || shardCtx.Err() != nil {
+		return false, err
+	}
+	err = s.checkpoint.Save(ctx, key+":done", 1)
+	if err != nil {
+		// 下一轮会重新校验这个分片
+		return false, err
+	}
+	s.lock.Lock()
+	s.finished++
+	s.lock.Unlock()
+	return true, nil
+}
+
+// isDone 完成的分片在 CheckpointStore 里面记一个标记
+func (s *ShardedValidator[T]) isDone(ctx context.Context, key string) (bool, error) {
+	done, err := s.checkpoint.Load(ctx, key+":done")
+	return done > 0, err
+}
+
+func (s *ShardedValidator[T]) track(idx int, v *Validator[T]) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.running[idx] = v
+}
+
+func (s *ShardedValidator[T]) untrack(idx int, v *Validator[T]) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.running, idx)
+	for typ, cnt := range v.Progress().Mismatches {
+		s.mismatches[typ] += cnt
+	}
+}
+
+// shards 按照两边最大的 id 计算分片数量
+func (s *ShardedValidator[T]) shards(ctx context.Context) (int, error) {
+	var maxId int64
+	for _, store := range []migrator.Store[T]{s.base, s.target} {
+		dbCtx, cancel := context.WithTimeout(ctx, time.Second*3)
+		id, err := store.MaxId(dbCtx)
+		cancel()
+		if err != nil {
+			return 0, err
+		}
+		if id > maxId {
+			maxId = id
+		}
+	}
+	return shardCount(maxId, s.shardSize), nil
+}
+
+func shardCount(maxId, shardSize int64) int {
+	if shardSize <= 0 {
+		return 0
+	}
+	return int((maxId + shardSize - 1) / shardSize)
+}
+
+func (s *ShardedValidator[T]) shardKey(idx int) string {
+	return s.key + ":shard:" + strconv.Itoa(idx)
+}
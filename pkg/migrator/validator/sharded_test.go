@@ -0,0 +1,26 @@
+package validator
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestShardCount(t *testing.T) {
+	testCases := []struct {
+		name      string
+		maxId     int64
+		shardSize int64
+		want      int
+	}{
+		{name: "空表", maxId: 0, shardSize: 100, want: 0},
+		{name: "刚好整除", maxId: 200, shardSize: 100, want: 2},
+		{name: "不能整除", maxId: 201, shardSize: 100, want: 3},
+		{name: "分片比数据大", maxId: 5, shardSize: 100, want: 1},
+		{name: "非法的分片大小", maxId: 5, shardSize: 0, want: 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, shardCount(tc.maxId, tc.shardSize))
+		})
+	}
+}
@@ -2,18 +2,25 @@ package validator
 
 import (
 	"context"
+	"errors"
 	"github.com/ecodeclub/ekit/slice"
 	"golang.org/x/sync/errgroup"
 	"gorm.io/gorm"
+	"sync"
 	"time"
 	"webook/pkg/logger"
 	"webook/pkg/migrator"
+	"webook/pkg/migrator/cdc"
 	"webook/pkg/migrator/events"
 )
 
 type Validator[T migrator.Entity] struct {
+	// 按照 utime 的增量校验直接用 gorm，跨存储的校验没有这两个
 	base   *gorm.DB
 	target *gorm.DB
+	// 全量校验和 binlog 校验都通过 Store 访问数据，所以两端可以是不同的存储
+	baseStore   migrator.Store[T]
+	targetStore migrator.Store[T]
 
 	l         logger.LoggerV1
 	producer  events.Producer
@@ -29,15 +36,81 @@ type Validator[T migrator.Entity] struct {
 
 	// 借助该字段抽取全量校验和增量校验的逻辑
 	fromBase func(ctx context.Context, offset int) (T, error)
+	// 全量校验使用 id > lastId 的方式分页，并且可以从 checkpoint 恢复
+	full bool
+	// 全量校验的 id 范围 (start, end]，end 为 0 表示不限制
+	start int64
+	end   int64
+
+	checkpoint    CheckpointStore
+	checkpointKey string
+
+	// 设置了就是基于 binlog 的增量校验
+	source cdc.Source
+
+	baseToTarget phase
+	targetToBase phase
+	lock         sync.Mutex
+	mismatches   map[string]int64
 }
 
 func NewValidator[T migrator.Entity](base *gorm.DB, target *gorm.DB, direction string, l logger.LoggerV1, producer events.Producer) *Validator[T] {
+	v := NewStoreValidator[T](migrator.NewGORMStore[T](base), migrator.NewGORMStore[T](target), direction, l, producer)
+	v.base = base
+	v.target = target
+	return v
+}
+
+// NewStoreValidator 两端可以是不同的存储，例如 MySQL 和 MongoDB，只支持全量校验和 binlog 校验
+func NewStoreValidator[T migrator.Entity](base migrator.Store[T], target migrator.Store[T], direction string, l logger.LoggerV1, producer events.Producer) *Validator[T] {
 	return &Validator[T]{
-		base:      base,
-		target:    target,
-		l:         l,
-		producer:  producer,
-		direction: direction}
+		baseStore:   base,
+		targetStore: target,
+		l:           l,
+		producer:    producer,
+		direction:   direction,
+		batchSize:   100,
+		mismatches:  make(map[string]int64),
+	}
+}
+
+func (v *Validator[T]) BatchSize(batchSize int) *Validator[T] {
+	v.batchSize = batchSize
+	return v
+}
+
+// Checkpoint 全量校验的进度保存到 store 里面，key 要能区分不同的表和校验方向
+func (v *Validator[T]) Checkpoint(store CheckpointStore, key string) *Validator[T] {
+	v.checkpoint = store
+	v.checkpointKey = key
+	return v
+}
+
+// Range 全量校验只校验 id 在 (start, end] 里面的数据，end 为 0 表示不限制
+func (v *Validator[T]) Range(start, end int64) *Validator[T] {
+	v.start = start
+	v.end = end
+	return v
+}
+
+func (v *Validator[T]) Direction() string {
+	return v.direction
+}
+
+// Progress 当前的校验进度
+func (v *Validator[T]) Progress() Progress {
+	v.lock.Lock()
+	mismatches := make(map[string]int64, len(v.mismatches))
+	for typ, cnt := range v.mismatches {
+		mismatches[typ] = cnt
+	}
+	v.lock.Unlock()
+	return Progress{
+		Direction:    v.direction,
+		BaseToTarget: v.baseToTarget.snapshot(),
+		TargetToBase: v.targetToBase.snapshot(),
+		Mismatches:   mismatches,
+	}
 }
 
 func (v *Validator[T]) Utime(utime int64) *Validator[T] {
@@ -57,7 +130,22 @@ func (v *Validator[T]) Validate(ctx context.Context) error {
 	//}
 	//return v.validateTargetToBase(ctx)
 
+	if v.source != nil {
+		return v.validateBinlog(ctx)
+	}
 	var eg errgroup.Group
+	if v.full {
+		eg.Go(func() error {
+			return v.fullBaseToTarget(ctx)
+		})
+		eg.Go(func() error {
+			return v.fullTargetToBase(ctx)
+		})
+		return eg.Wait()
+	}
+	if v.base == nil || v.target == nil {
+		return errors.New("跨存储的校验不支持按照 utime 增量校验")
+	}
 	eg.Go(func() error {
 		return v.validateBaseToTarget(ctx)
 	})
@@ -67,6 +155,200 @@ func (v *Validator[T]) Validate(ctx context.Context) error {
 	return eg.Wait()
 }
 
+const (
+	phaseBaseToTarget = "base_to_target"
+	phaseTargetToBase = "target_to_base"
+	// 查询失败之后，等待多久重试同一批
+	retryInterval = time.Second
+)
+
+// fullBaseToTarget 按照 id 分批从 base 里面取数据，和 target 里面对应的数据比较
+// 查询失败会重试同一批，而不是跳过
+func (v *Validator[T]) fullBaseToTarget(ctx context.Context) error {
+	lastId, err := v.begin(ctx, v.baseStore, phaseBaseToTarget, &v.baseToTarget)
+	if err != nil {
+		return err
+	}
+	for {
+		var bases []T
+		err = v.queryWithTimeout(ctx, func(ctx context.Context) error {
+			var er error
+			bases, er = v.baseStore.Scan(ctx, lastId, v.end, v.batchSize)
+			return er
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			v.l.Error("base -> target 查询 base 失败，稍后重试",
+				logger.Int64("last_id", lastId), logger.Error(err))
+			v.sleep(ctx, retryInterval)
+			continue
+		}
+		if len(bases) == 0 {
+			return v.finish(ctx, phaseBaseToTarget, &v.baseToTarget)
+		}
+
+		ids := slice.Map(bases, func(idx int, src T) int64 {
+			return src.ID()
+		})
+		var tars []T
+		err = v.queryWithTimeout(ctx, func(ctx context.Context) error {
+			var er error
+			tars, er = v.targetStore.FindByIds(ctx, ids)
+			return er
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			v.l.Error("base -> target 查询 target 失败，稍后重试",
+				logger.Int64("last_id", lastId), logger.Error(err))
+			v.sleep(ctx, retryInterval)
+			continue
+		}
+
+		tarMap := make(map[int64]T, len(tars))
+		for _, tar := range tars {
+			tarMap[tar.ID()] = tar
+		}
+		for _, src := range bases {
+			tar, ok := tarMap[src.ID()]
+			if !ok {
+				v.notify(src.ID(), events.InconsistentEventTypeTargetMissing)
+				continue
+			}
+			if !src.CompareTo(tar) {
+				v.notify(src.ID(), events.InconsistentEventTypeNotEqual, v.diff(src, tar)...)
+			}
+		}
+		lastId = ids[len(ids)-1]
+		v.advance(ctx, phaseBaseToTarget, &v.baseToTarget, lastId, len(bases))
+	}
+}
+
+// fullTargetToBase 找出 target 里面有，但是 base 里面没有的数据
+func (v *Validator[T]) fullTargetToBase(ctx context.Context) error {
+	lastId, err := v.begin(ctx, v.targetStore, phaseTargetToBase, &v.targetToBase)
+	if err != nil {
+		return err
+	}
+	for {
+		var tars []T
+		err = v.queryWithTimeout(ctx, func(ctx context.Context) error {
+			var er error
+			tars, er = v.targetStore.Scan(ctx, lastId, v.end, v.batchSize)
+			return er
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			v.l.Error("target -> base 查询 target 失败，稍后重试",
+				logger.Int64("last_id", lastId), logger.Error(err))
+			v.sleep(ctx, retryInterval)
+			continue
+		}
+		if len(tars) == 0 {
+			return v.finish(ctx, phaseTargetToBase, &v.targetToBase)
+		}
+
+		ids := slice.Map(tars, func(idx int, t T) int64 {
+			return t.ID()
+		})
+		var bases []T
+		err = v.queryWithTimeout(ctx, func(ctx context.Context) error {
+			var er error
+			bases, er = v.baseStore.FindByIds(ctx, ids)
+			return er
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			v.l.Error("target -> base 查询 base 失败，稍后重试",
+				logger.Int64("last_id", lastId), logger.Error(err))
+			v.sleep(ctx, retryInterval)
+			continue
+		}
+		diff := slice.DiffSetFunc(tars, bases, func(src, dst T) bool {
+			return src.ID() == dst.ID()
+		})
+		v.notifyBaseMissing(diff)
+		lastId = ids[len(ids)-1]
+		v.advance(ctx, phaseTargetToBase, &v.targetToBase, lastId, len(tars))
+	}
+}
+
+// begin 从 checkpoint 里面恢复进度，并且记录当前最大的 id 用来估算剩余时间
+func (v *Validator[T]) begin(ctx context.Context, store migrator.Store[T], name string, p *phase) (int64, error) {
+	var lastId int64
+	if v.checkpoint != nil {
+		var err error
+		lastId, err = v.checkpoint.Load(ctx, v.checkpointKey+":"+name)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if lastId < v.start {
+		lastId = v.start
+	}
+	if v.end > 0 {
+		p.begin(lastId, v.end)
+		return lastId, nil
+	}
+	var maxId int64
+	err := v.queryWithTimeout(ctx, func(ctx context.Context) error {
+		var er error
+		maxId, er = store.MaxId(ctx)
+		return er
+	})
+	if err != nil {
+		// 只是影响进度的估算
+		v.l.Warn("查询最大 id 失败", logger.String("phase", name), logger.Error(err))
+	}
+	p.begin(lastId, maxId)
+	return lastId, nil
+}
+
+func (v *Validator[T]) advance(ctx context.Context, name string, p *phase, lastId int64, scanned int) {
+	p.advance(lastId, scanned)
+	if v.checkpoint == nil {
+		return
+	}
+	err := v.checkpoint.Save(ctx, v.checkpointKey+":"+name, lastId)
+	if err != nil {
+		// 下一次恢复的时候会重复校验一部分数据，不影响正确性
+		v.l.Error("保存校验进度失败", logger.String("phase", name),
+			logger.Int64("last_id", lastId), logger.Error(err))
+	}
+}
+
+func (v *Validator[T]) finish(ctx context.Context, name string, p *phase) error {
+	p.finish()
+	if v.checkpoint == nil {
+		return nil
+	}
+	return v.checkpoint.Clear(ctx, v.checkpointKey+":"+name)
+}
+
+func (v *Validator[T]) queryWithTimeout(ctx context.Context, fn func(ctx context.Context) error) error {
+	dbCtx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+	err := fn(dbCtx)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (v *Validator[T]) sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
+
 // week13 assignment 12
 func (v *Validator[T]) validateBaseToTargetBatch(ctx context.Context) error {
 	offset := 0
@@ -170,7 +452,7 @@ func (v *Validator[T]) validateBaseToTarget(ctx context.Context) error {
 			equal := src.CompareTo(dst)
 			if !equal {
 				// 需要同步，丢一条消息到 kafka
-				v.notify(src.ID(), events.InconsistentEventTypeNotEqual)
+				v.notify(src.ID(), events.InconsistentEventTypeNotEqual, v.diff(src, dst)...)
 			}
 		default:
 			v.l.Error("base -> target 查询 target 失败",
@@ -259,25 +541,88 @@ func (v *Validator[T]) validateTargetToBase(ctx context.Context) error {
 }
 
 func (v *Validator[T]) Full() *Validator[T] {
-	v.fromBase = v.fullFromBase
+	v.full = true
+	v.source = nil
 	return v
 }
 
 func (v *Validator[T]) Incr() *Validator[T] {
+	v.full = false
+	v.source = nil
 	v.fromBase = v.incrFromBase
 	return v
 }
 
-func (v *Validator[T]) fullFromBase(ctx context.Context, offset int) (T, error) {
-	dbCtx, cancel := context.WithTimeout(ctx, time.Second)
-	defer cancel()
-	var src T
-	err := v.base.WithContext(dbCtx).
-		Order("id").
-		Offset(offset).
-		First(&src).
-		Error
-	return src, err
+// Binlog 增量校验改成消费行变更事件，每一个变更的 id 都马上和另一边比较
+// 和按照 utime 轮询相比，硬删除和没有更新 utime 的修改也能发现
+// 两边的事件都可以投递进来，只处理 T 对应的表
+func (v *Validator[T]) Binlog(source cdc.Source) *Validator[T] {
+	v.full = false
+	v.source = source
+	return v
+}
+
+func (v *Validator[T]) validateBinlog(ctx context.Context) error {
+	table, err := v.binlogTable()
+	if err != nil {
+		return err
+	}
+	err = v.source.Consume(ctx, func(ctx context.Context, evt cdc.RowEvent) error {
+		if evt.Table != table {
+			return nil
+		}
+		return v.validateID(ctx, evt.ID)
+	})
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// binlogTable binlog 只有 MySQL 那一端有，表名也以它为准
+func (v *Validator[T]) binlogTable() (string, error) {
+	for _, store := range []migrator.Store[T]{v.baseStore, v.targetStore} {
+		if gs, ok := store.(*migrator.GORMStore[T]); ok {
+			return gs.Table()
+		}
+	}
+	return "", errors.New("binlog 校验至少要有一端是 MySQL")
+}
+
+// validateID 比较两边 id 对应的数据，查询失败返回 error，由 Source 决定要不要重试
+// 双写的时候第二个库可能还没写完，这时候发出去的不一致事件由修复的时候重新比较
+func (v *Validator[T]) validateID(ctx context.Context, id int64) error {
+	src, srcFound, err := v.findByID(ctx, v.baseStore, id)
+	if err != nil {
+		return err
+	}
+	dst, dstFound, err := v.findByID(ctx, v.targetStore, id)
+	if err != nil {
+		return err
+	}
+	switch {
+	case srcFound && !dstFound:
+		v.notify(id, events.InconsistentEventTypeTargetMissing)
+	case !srcFound && dstFound:
+		v.notify(id, events.InconsistentEventTypeBaseMissing)
+	case srcFound && dstFound && !src.CompareTo(dst):
+		v.notify(id, events.InconsistentEventTypeNotEqual, v.diff(src, dst)...)
+	}
+	return nil
+}
+
+func (v *Validator[T]) findByID(ctx context.Context, store migrator.Store[T], id int64) (T, bool, error) {
+	var res []T
+	err := v.queryWithTimeout(ctx, func(ctx context.Context) error {
+		var er error
+		res, er = store.FindByIds(ctx, []int64{id})
+		return er
+	})
+	if err != nil || len(res) == 0 {
+		var t T
+		return t, false, err
+	}
+	return res[0], true, nil
 }
 
 func (v *Validator[T]) incrFromBase(ctx context.Context, offset int) (T, error) {
@@ -305,16 +650,32 @@ func (v *Validator[T]) notifyTargetMissing(bases []T) {
 	}
 }
 
-func (v *Validator[T]) notify(id int64, typ string) {
+// diff 找出不相等的列，CompareTo 可能有自己的规则，所以只是用来辅助排查和修复
+func (v *Validator[T]) diff(src, dst T) []string {
+	cols, err := migrator.Diff(src, dst)
+	if err != nil {
+		v.l.Warn("比较列失败", logger.Int64("id", src.ID()), logger.Error(err))
+	}
+	return cols
+}
+
+func (v *Validator[T]) notify(id int64, typ string, columns ...string) {
+	v.lock.Lock()
+	v.mismatches[typ]++
+	v.lock.Unlock()
+
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
 	err := v.producer.ProduceInconsistentEvent(ctx, events.InconsistentEvent{
 		ID:        id,
 		Type:      typ,
 		Direction: v.direction,
+		Columns:   columns,
 	})
-	v.l.Error("发送不一致消息到 kafka 失败",
-		logger.Error(err),
-		logger.String("type", typ),
-		logger.Int64("id", id))
+	if err != nil {
+		v.l.Error("发送不一致消息到 kafka 失败",
+			logger.Error(err),
+			logger.String("type", typ),
+			logger.Int64("id", id))
+	}
 }
@@ -0,0 +1,217 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+	"webook/pkg/logger"
+	"webook/pkg/migrator"
+	"webook/pkg/migrator/cdc"
+	"webook/pkg/migrator/events"
+)
+
+func TestValidator_FullBaseToTarget(t *testing.T) {
+	baseDB, baseMock := newMockDB(t)
+	targetDB, targetMock := newMockDB(t)
+
+	baseMock.ExpectQuery("SELECT COALESCE\\(MAX\\(id\\), 0\\) FROM `test_entities`").
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(13))
+	// 查询失败之后要重试同一批，而不是跳过
+	baseMock.ExpectQuery("SELECT \\* FROM `test_entities` WHERE id > \\?").
+		WithArgs(10).
+		WillReturnError(errors.New("mock db error"))
+	baseMock.ExpectQuery("SELECT \\* FROM `test_entities` WHERE id > \\?").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).
+			AddRow(11, "a").AddRow(12, "b"))
+	targetMock.ExpectQuery("SELECT \\* FROM `test_entities` WHERE id IN").
+		WithArgs(11, 12).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(11, "c"))
+	baseMock.ExpectQuery("SELECT \\* FROM `test_entities` WHERE id > \\?").
+		WithArgs(12).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(13, "d"))
+	targetMock.ExpectQuery("SELECT \\* FROM `test_entities` WHERE id IN").
+		WithArgs(13).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(13, "d"))
+	baseMock.ExpectQuery("SELECT \\* FROM `test_entities` WHERE id > \\?").
+		WithArgs(13).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+
+	store := &memoryCheckpointStore{data: map[string]int64{"test:SRC:base_to_target": 10}}
+	producer := &memoryProducer{}
+	v := NewValidator[testEntity](baseDB, targetDB, "SRC", logger.NewNoOpLogger(), producer).
+		BatchSize(2).
+		Checkpoint(store, "test:SRC").
+		Full()
+	// 防止 mock 不匹配的时候一直重试
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	err := v.fullBaseToTarget(ctx)
+	require.NoError(t, err)
+	require.NoError(t, ctx.Err())
+
+	assert.Equal(t, []events.InconsistentEvent{
+		{ID: 11, Direction: "SRC", Type: events.InconsistentEventTypeNotEqual, Columns: []string{"name"}},
+		{ID: 12, Direction: "SRC", Type: events.InconsistentEventTypeTargetMissing},
+	}, producer.evts)
+	// 校验完成之后清掉进度
+	assert.Empty(t, store.data)
+
+	progress := v.Progress()
+	assert.Equal(t, PhaseProgress{Scanned: 3, LastId: 13, MaxId: 13, Done: true}, progress.BaseToTarget)
+	assert.Equal(t, map[string]int64{
+		events.InconsistentEventTypeNotEqual:      1,
+		events.InconsistentEventTypeTargetMissing: 1,
+	}, progress.Mismatches)
+	assert.NoError(t, baseMock.ExpectationsWereMet())
+	assert.NoError(t, targetMock.ExpectationsWereMet())
+}
+
+func TestValidator_FullRange(t *testing.T) {
+	baseDB, baseMock := newMockDB(t)
+	targetDB, targetMock := newMockDB(t)
+
+	// 设置了 Range 就不需要查询最大的 id
+	baseMock.ExpectQuery("SELECT \\* FROM `test_entities` WHERE id > \\? AND id <= \\?").
+		WithArgs(10, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(20, "a"))
+	targetMock.ExpectQuery("SELECT \\* FROM `test_entities` WHERE id IN").
+		WithArgs(20).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	baseMock.ExpectQuery("SELECT \\* FROM `test_entities` WHERE id > \\? AND id <= \\?").
+		WithArgs(20, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+
+	producer := &memoryProducer{}
+	v := NewValidator[testEntity](baseDB, targetDB, "SRC", logger.NewNoOpLogger(), producer).
+		Range(10, 20).
+		Full()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	err := v.fullBaseToTarget(ctx)
+	require.NoError(t, err)
+	require.NoError(t, ctx.Err())
+
+	assert.Equal(t, []events.InconsistentEvent{
+		{ID: 20, Direction: "SRC", Type: events.InconsistentEventTypeTargetMissing},
+	}, producer.evts)
+	assert.Equal(t, PhaseProgress{Scanned: 1, LastId: 20, MaxId: 20, Done: true}, v.Progress().BaseToTarget)
+	assert.NoError(t, baseMock.ExpectationsWereMet())
+	assert.NoError(t, targetMock.ExpectationsWereMet())
+}
+
+func TestValidator_Binlog(t *testing.T) {
+	baseDB, baseMock := newMockDB(t)
+	targetDB, targetMock := newMockDB(t)
+
+	path := filepath.Join(t.TempDir(), "binlog.json")
+	err := os.WriteFile(path, []byte(`
+{"database":"webook","table":"test_entities","type":"UPDATE","isDdl":false,"data":[{"id":"1"}]}
+{"database":"webook","table":"others","type":"UPDATE","isDdl":false,"data":[{"id":"2"}]}
+{"database":"webook","table":"test_entities","type":"DELETE","isDdl":false,"data":[{"id":"3"}]}
+`), 0644)
+	require.NoError(t, err)
+
+	// id 1 两边不相等
+	baseMock.ExpectQuery("SELECT \\* FROM `test_entities` WHERE id IN").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "a"))
+	targetMock.ExpectQuery("SELECT \\* FROM `test_entities` WHERE id IN").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "b"))
+	// id 3 在 base 里面被硬删除了，target 里面还有
+	baseMock.ExpectQuery("SELECT \\* FROM `test_entities` WHERE id IN").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	targetMock.ExpectQuery("SELECT \\* FROM `test_entities` WHERE id IN").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(3, "c"))
+
+	producer := &memoryProducer{}
+	v := NewValidator[testEntity](baseDB, targetDB, "SRC", logger.NewNoOpLogger(), producer).
+		Binlog(cdc.NewFileSource(path))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	err = v.Validate(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, []events.InconsistentEvent{
+		{ID: 1, Direction: "SRC", Type: events.InconsistentEventTypeNotEqual, Columns: []string{"name"}},
+		{ID: 3, Direction: "SRC", Type: events.InconsistentEventTypeBaseMissing},
+	}, producer.evts)
+	assert.NoError(t, baseMock.ExpectationsWereMet())
+	assert.NoError(t, targetMock.ExpectationsWereMet())
+}
+
+func newMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      sqlDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+	return db, mock
+}
+
+type testEntity struct {
+	Id   int64
+	Name string
+}
+
+func (e testEntity) ID() int64 {
+	return e.Id
+}
+
+func (e testEntity) CompareTo(dst migrator.Entity) bool {
+	return migrator.Equal(e, dst)
+}
+
+type memoryCheckpointStore struct {
+	lock sync.Mutex
+	data map[string]int64
+}
+
+func (m *memoryCheckpointStore) Load(ctx context.Context, key string) (int64, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.data[key], nil
+}
+
+func (m *memoryCheckpointStore) Save(ctx context.Context, key string, lastId int64) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.data[key] = lastId
+	return nil
+}
+
+func (m *memoryCheckpointStore) Clear(ctx context.Context, key string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.data, key)
+	return nil
+}
+
+type memoryProducer struct {
+	lock sync.Mutex
+	evts []events.InconsistentEvent
+}
+
+func (m *memoryProducer) ProduceInconsistentEvent(ctx context.Context, evt events.InconsistentEvent) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.evts = append(m.evts, evt)
+	return nil
+}
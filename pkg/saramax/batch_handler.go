@@ -2,20 +2,42 @@ package saramax
 
 import (
 	"context"
-	"encoding/json"
 	"github.com/IBM/sarama"
-	"log"
 	"time"
 	"webook/pkg/logger"
 )
 
+const (
+	defaultBatchSize     = 10
+	defaultFlushInterval = time.Second * 10
+)
+
+// WithBatchSize 攒够了 size 条消息就处理一批
+func WithBatchSize(size int) HandlerOption {
+	return func(o *handlerOptions) {
+		o.batchSize = size
+	}
+}
+
+// WithFlushInterval 从这一批的第一条消息开始，最多等待 interval 就处理，不管有没有攒够
+func WithFlushInterval(interval time.Duration) HandlerOption {
+	return func(o *handlerOptions) {
+		o.flushInterval = interval
+	}
+}
+
+// BatchHandler 批量消费，满足数量或者时间任何一个条件就处理一批
+// 只有处理成功（或者转发到 retry/死信 topic）之后才会标记这一批的 offset
+// 没有设置死信队列的时候，处理失败的批次记录日志之后直接标记，和 Handler 一样
 type BatchHandler[T any] struct {
-	fn func(msgs []*sarama.ConsumerMessage, ts []T) error
-	l  logger.LoggerV1
+	fn   func(msgs []*sarama.ConsumerMessage, ts []T) error
+	l    logger.LoggerV1
+	opts handlerOptions
 }
 
-func NewBatchHandler[T any](fn func(msgs []*sarama.ConsumerMessage, ts []T) error, l logger.LoggerV1) *BatchHandler[T] {
-	return &BatchHandler[T]{fn: fn, l: l}
+func NewBatchHandler[T any](fn func(msgs []*sarama.ConsumerMessage, ts []T) error, l logger.LoggerV1,
+	opts ...HandlerOption) *BatchHandler[T] {
+	return &BatchHandler[T]{fn: fn, l: l, opts: newHandlerOptions(opts)}
 }
 
 func (b *BatchHandler[T]) Setup(session sarama.ConsumerGroupSession) error {
@@ -23,58 +45,103 @@ func (b *BatchHandler[T]) Setup(session sarama.ConsumerGroupSession) error {
 }
 
 func (b *BatchHandler[T]) Cleanup(session sarama.ConsumerGroupSession) error {
+	// rebalance 或者退出的时候，把已经标记的 offset 提交掉
+	session.Commit()
 	return nil
 }
 
 func (b *BatchHandler[T]) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
 	msgs := claim.Messages()
-	const batchSize = 10
+	ctx := session.Context()
+	batchSize := b.opts.batchSize
 	for {
-		log.Println("开始一个新的批次")
+		// 整批的消息，包括反序列化失败的，处理完了一起标记
+		all := make([]*sarama.ConsumerMessage, 0, batchSize)
 		batch := make([]*sarama.ConsumerMessage, 0, batchSize)
 		ts := make([]T, 0, batchSize)
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		// 收到第一条消息才开始计时，没有消息的时候不会空转
+		var flush <-chan time.Time
 		var done = false
-		for i := 0; i < batchSize; i++ {
+		for len(all) < batchSize && !done {
 			select {
 			case msg, ok := <-msgs:
 				if !ok {
-					cancel()
+					// 没有处理的消息没有标记，下一次会重新消费
+					return nil
+				}
+				if !waitUntilDue(ctx, msg) {
 					return nil
 				}
+				if flush == nil {
+					flush = time.After(b.opts.flushInterval)
+				}
+				all = append(all, msg)
 				var t T
-				err := json.Unmarshal(msg.Value, &t)
+				err := b.opts.registry.Decode(msg, &t)
 				if err != nil {
 					b.l.Error("反序列化失败",
 						logger.String("topic", msg.Topic),
 						logger.Int32("partition", msg.Partition),
 						logger.Int64("offset", msg.Offset),
 						logger.Error(err))
+					err = b.opts.forward(msg, err, true)
+					if err != nil {
+						return b.forwardFailed(msg, err)
+					}
 					continue
 				}
 				batch = append(batch, msg)
 				ts = append(ts, t)
-
-			case <-ctx.Done():
+			case <-flush:
 				done = true
+			case <-ctx.Done():
+				return nil
 			}
+		}
 
-			if done {
-				break
+		if len(batch) > 0 {
+			err := b.flush(ctx, batch, ts)
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err != nil {
+				return err
 			}
 		}
-		cancel()
 
-		// 凑够了一批后，批量处理
-		err := b.fn(batch, ts)
-		if err != nil {
-			b.l.Error("处理消息失败",
-				logger.Error(err))
+		for _, msg := range all {
+			session.MarkMessage(msg, "")
 		}
+	}
+}
 
-		// 批量提交
-		for _, msg := range batch {
-			session.MarkMessage(msg, "")
+// flush 处理一批消息，返回 error 说明这一批既没有处理成功，也没有转发出去，不能标记
+func (b *BatchHandler[T]) flush(ctx context.Context, batch []*sarama.ConsumerMessage, ts []T) error {
+	err := b.opts.retry(ctx, func() error {
+		return b.fn(batch, ts)
+	})
+	if err == nil || ctx.Err() != nil {
+		return nil
+	}
+	b.l.Error("处理消息失败",
+		logger.Int("size", len(batch)),
+		logger.Error(err))
+	// 不知道是哪一条出了问题，整批转发
+	// 和 Handler 一样，没有设置死信队列的时候只记录日志，这一批就丢弃了
+	for _, msg := range batch {
+		if er := b.opts.forward(msg, err, false); er != nil {
+			return b.forwardFailed(msg, er)
 		}
 	}
+	return nil
+}
+
+func (b *BatchHandler[T]) forwardFailed(msg *sarama.ConsumerMessage, err error) error {
+	// 不标记，返回之后 session 会结束，从上一次提交的 offset 重新消费
+	b.l.Error("转发失败消息出错",
+		logger.String("topic", msg.Topic),
+		logger.Int32("partition", msg.Partition),
+		logger.Int64("offset", msg.Offset),
+		logger.Error(err))
+	return err
 }
@@ -0,0 +1,69 @@
+package saramax
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"webook/pkg/logger"
+)
+
+type batchEvent struct {
+	Id int64
+}
+
+func TestBatchHandler_ConsumeClaim(t *testing.T) {
+	const cnt = 4
+	testCases := []struct {
+		name string
+		fn   func(msgs []*sarama.ConsumerMessage, ts []batchEvent) error
+
+		wantBatches int
+		wantMarked  int64
+	}{
+		{
+			name: "处理成功",
+			fn: func(msgs []*sarama.ConsumerMessage, ts []batchEvent) error {
+				return nil
+			},
+			wantBatches: 2,
+			wantMarked:  cnt,
+		},
+		{
+			// 和 Handler 一样记录日志之后丢弃，不会一直重新消费同一批
+			name: "没有死信队列，处理失败",
+			fn: func(msgs []*sarama.ConsumerMessage, ts []batchEvent) error {
+				return errors.New("mock error")
+			},
+			wantBatches: 2,
+			wantMarked:  cnt,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			batches := 0
+			h := NewBatchHandler[batchEvent](func(msgs []*sarama.ConsumerMessage, ts []batchEvent) error {
+				batches++
+				return tc.fn(msgs, ts)
+			}, logger.NewNoOpLogger(), WithBatchSize(2), WithRegistry(NewRegistry()))
+
+			msgs := make(chan *sarama.ConsumerMessage, cnt)
+			for i := 0; i < cnt; i++ {
+				val, err := json.Marshal(batchEvent{Id: int64(i)})
+				require.NoError(t, err)
+				msgs <- &sarama.ConsumerMessage{Offset: int64(i), Value: val}
+			}
+			close(msgs)
+
+			sess := &fakeSession{ctx: context.Background()}
+			err := h.ConsumeClaim(sess, &fakeClaim{msgs: msgs})
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantBatches, batches)
+			assert.Equal(t, tc.wantMarked, sess.marked())
+		})
+	}
+}
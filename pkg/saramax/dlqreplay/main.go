@@ -0,0 +1,58 @@
+// dlqreplay 把死信队列里面的消息重放到原始 topic
+//
+//	go run ./pkg/saramax/dlqreplay -addrs localhost:9094 -topic payment_events_dlq -since 2024-01-01T00:00:00+08:00
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/IBM/sarama"
+	"go.uber.org/zap"
+	"log"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+	"webook/pkg/logger"
+	"webook/pkg/saramax"
+)
+
+func main() {
+	addrs := flag.String("addrs", "localhost:9094", "kafka 地址，多个用逗号分隔")
+	topic := flag.String("topic", "", "死信队列的 topic，例如 payment_events_dlq")
+	since := flag.String("since", "", "只重放这个时间之后进入死信队列的消息，RFC3339 格式，不填就从头开始")
+	flag.Parse()
+	if *topic == "" {
+		log.Fatal("必须指定 topic")
+	}
+	var sinceTime time.Time
+	if *since != "" {
+		var err error
+		sinceTime, err = time.Parse(time.RFC3339, *since)
+		if err != nil {
+			log.Fatalf("since 格式不对 %v", err)
+		}
+	}
+
+	cfg := sarama.NewConfig()
+	cfg.Producer.Return.Successes = true
+	client, err := sarama.NewClient(strings.Split(*addrs, ","), cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer producer.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	r := saramax.NewReplayer(client, producer, logger.NewZapLogger(zap.NewExample()))
+	cnt, err := r.Replay(ctx, *topic, sinceTime)
+	log.Printf("重放了 %d 条消息", cnt)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
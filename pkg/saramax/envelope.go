@@ -0,0 +1,238 @@
+package saramax
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HeaderEnvelope 带了这个 header 的消息，value 是 Envelope，否则就是老的裸 JSON 消息
+const HeaderEnvelope = "x-envelope"
+
+const envelopeFormat = "1"
+
+var ErrUnknownVersion = errors.New("saramax: 未知的事件版本")
+
+// Envelope 所有事件统一的外层结构
+type Envelope struct {
+	// Type 事件类型，例如 article.read
+	Type string `json:"type"`
+	// Version Data 的 schema 版本
+	Version int `json:"version"`
+	// Producer 发送方
+	Producer string `json:"producer"`
+	// Trace 链路信息，例如 traceparent
+	Trace map[string]string `json:"trace,omitempty"`
+	// Timestamp 毫秒
+	Timestamp int64           `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Context 把 Envelope 里面的链路信息放到 ctx 里面
+func (e Envelope) Context(ctx context.Context) context.Context {
+	if len(e.Trace) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(e.Trace))
+}
+
+// DecodeEnvelope 解析消息的 Envelope，老的消息没有 Envelope，返回 false
+func DecodeEnvelope(msg *sarama.ConsumerMessage) (Envelope, bool, error) {
+	if _, ok := header(msg, HeaderEnvelope); !ok {
+		return Envelope{}, false, nil
+	}
+	var env Envelope
+	err := json.Unmarshal(msg.Value, &env)
+	return env, true, err
+}
+
+// Upcaster 把一个版本的事件升级成下一个版本，evt 是上一个版本注册的类型的值
+type Upcaster func(evt any) (any, error)
+
+// Version 某个 schema 版本对应的 Go 类型
+// 生产者和消费者往往在不同的服务里面，各自定义自己的 Go 类型，所以只按照事件类型和版本号匹配
+type Version struct {
+	Version int
+	// Sample 这个版本的 Go 类型的零值，例如 ReadEvent{}，只用来执行 Upcast
+	Sample any
+	// Upcast 升级到下一个版本，最新的版本不需要
+	Upcast Upcaster
+}
+
+type schema struct {
+	eventType string
+	// 按照版本号从小到大排列
+	versions []Version
+}
+
+func (s *schema) latest() Version {
+	return s.versions[len(s.versions)-1]
+}
+
+// Registry 记录每一个 topic 的事件类型、各个版本的 Go 类型和升级逻辑
+// 生产者用它包装 Envelope，消费者用它把任意版本的消息（包括没有 Envelope 的老消息）升级成最新版本
+// 同一个进程里面生产者和消费者可能都注册了同一个 topic，只要事件类型一致就合并它们的版本
+type Registry struct {
+	// Producer 写到 Envelope 里面的发送方，默认是可执行文件的名字
+	Producer string
+
+	lock    sync.RWMutex
+	schemas map[string]*schema
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		Producer: filepath.Base(os.Args[0]),
+		schemas:  make(map[string]*schema),
+	}
+}
+
+// DefaultRegistry 各个业务在 init 里面注册自己的 topic，Handler 默认用它解析消息
+var DefaultRegistry = NewRegistry()
+
+// Register 注册 topic 的 schema，没有 Envelope 的老消息按照最小的版本解析
+// 同一个 topic 重复注册的时候按照版本号合并，已经有的版本以先注册的为准
+// 事件类型不一致说明两个业务用错了 topic，直接 panic
+func (r *Registry) Register(topic, eventType string, versions ...Version) {
+	if len(versions) == 0 {
+		panic("saramax: 至少需要一个版本")
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var vs []Version
+	if old, ok := r.schemas[topic]; ok {
+		if old.eventType != eventType {
+			panic(fmt.Sprintf("saramax: topic %s 已经注册为 %s，不能再注册为 %s", topic, old.eventType, eventType))
+		}
+		vs = slices.Clone(old.versions)
+	}
+	for _, v := range versions {
+		idx := slices.IndexFunc(vs, func(e Version) bool {
+			return e.Version == v.Version
+		})
+		switch {
+		case idx < 0:
+			vs = append(vs, v)
+		case vs[idx].Upcast == nil:
+			// 先注册的没有升级逻辑，例如它当时还是最新版本
+			vs[idx] = v
+		}
+	}
+	sort.Slice(vs, func(i, j int) bool {
+		return vs[i].Version < vs[j].Version
+	})
+	r.schemas[topic] = &schema{eventType: eventType, versions: vs}
+}
+
+func (r *Registry) schema(topic string) (*schema, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	s, ok := r.schemas[topic]
+	return s, ok
+}
+
+// NewMessage 用 Envelope 包装事件，evt 序列化之后必须是 topic 最新版本的结构
+func (r *Registry) NewMessage(ctx context.Context, topic string, evt any) (*sarama.ProducerMessage, error) {
+	s, ok := r.schema(topic)
+	if !ok {
+		return nil, fmt.Errorf("saramax: topic %s 没有注册", topic)
+	}
+	latest := s.latest()
+	data, err := json.Marshal(evt)
+	if err != nil {
+		return nil, err
+	}
+	trace := make(map[string]string)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(trace))
+	val, err := json.Marshal(Envelope{
+		Type:      s.eventType,
+		Version:   latest.Version,
+		Producer:  r.Producer,
+		Trace:     trace,
+		Timestamp: time.Now().UnixMilli(),
+		Data:      data,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sarama.ProducerMessage{
+		Topic: topic,
+		Value: sarama.ByteEncoder(val),
+		Headers: []sarama.RecordHeader{
+			recordHeader(HeaderEnvelope, envelopeFormat),
+		},
+	}, nil
+}
+
+// Decode 把消息解析到 dst 里面，dst 必须是指针
+// 注册过的 topic 会升级到最新的版本，dst 按照最新版本的结构解析，不要求是注册的 Go 类型
+// 没有注册的 topic 直接用 JSON 解析
+func (r *Registry) Decode(msg *sarama.ConsumerMessage, dst any) error {
+	env, ok, err := DecodeEnvelope(msg)
+	if err != nil {
+		return err
+	}
+	data := json.RawMessage(msg.Value)
+	if ok {
+		data = env.Data
+	}
+
+	// retry topic 和死信队列里面的消息，要按照原始 topic 解析
+	topic := msg.Topic
+	if origin, has := header(msg, HeaderOriginTopic); has {
+		topic = origin
+	}
+	s, registered := r.schema(topic)
+	if !registered {
+		return json.Unmarshal(data, dst)
+	}
+
+	idx := 0
+	if ok {
+		idx = slices.IndexFunc(s.versions, func(v Version) bool {
+			return v.Version == env.Version
+		})
+		if idx < 0 {
+			return fmt.Errorf("%w: topic %s 版本 %d", ErrUnknownVersion, topic, env.Version)
+		}
+	}
+	if idx == len(s.versions)-1 {
+		// 已经是最新版本了
+		return json.Unmarshal(data, dst)
+	}
+
+	ptr := reflect.New(reflect.TypeOf(s.versions[idx].Sample))
+	err = json.Unmarshal(data, ptr.Interface())
+	if err != nil {
+		return err
+	}
+	evt := ptr.Elem().Interface()
+	for ; idx < len(s.versions)-1; idx++ {
+		up := s.versions[idx].Upcast
+		if up == nil {
+			return fmt.Errorf("saramax: topic %s 版本 %d 没有升级逻辑", topic, s.versions[idx].Version)
+		}
+		evt, err = up(evt)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 升级之后的类型是注册方的，dst 可能是另一个包里面定义的，所以再转一次 JSON
+	data, err = json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
@@ -0,0 +1,158 @@
+package saramax
+
+import (
+	"context"
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type readEventV1 struct {
+	Aid int64
+	Uid int64
+}
+
+type readEventV2 struct {
+	Biz   string
+	BizId int64
+	Uid   int64
+}
+
+func newTestRegistry() *Registry {
+	r := NewRegistry()
+	r.Register("article_read", "article.read",
+		Version{Version: 1, Sample: readEventV1{}, Upcast: func(evt any) (any, error) {
+			v1 := evt.(readEventV1)
+			return readEventV2{Biz: "article", BizId: v1.Aid, Uid: v1.Uid}, nil
+		}},
+		Version{Version: 2, Sample: readEventV2{}})
+	return r
+}
+
+func TestRegistry_Decode(t *testing.T) {
+	r := newTestRegistry()
+	old := NewRegistry()
+	old.Register("article_read", "article.read", Version{Version: 1, Sample: readEventV1{}})
+	v1Msg, err := old.NewMessage(context.Background(), "article_read", readEventV1{Aid: 1, Uid: 2})
+	require.NoError(t, err)
+	v2Msg, err := r.NewMessage(context.Background(), "article_read",
+		readEventV2{Biz: "article", BizId: 3, Uid: 4})
+	require.NoError(t, err)
+
+	future := NewRegistry()
+	future.Register("article_read", "article.read", Version{Version: 3, Sample: readEventV2{}})
+	v3Msg, err := future.NewMessage(context.Background(), "article_read", readEventV2{})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		msg     *sarama.ConsumerMessage
+		want    readEventV2
+		wantErr error
+	}{
+		{
+			name: "没有 envelope 的老消息",
+			msg: &sarama.ConsumerMessage{
+				Topic: "article_read",
+				Value: []byte(`{"Aid":5,"Uid":6}`),
+			},
+			want: readEventV2{Biz: "article", BizId: 5, Uid: 6},
+		},
+		{
+			name: "v1 升级到 v2",
+			msg:  toConsumerMessage(t, v1Msg),
+			want: readEventV2{Biz: "article", BizId: 1, Uid: 2},
+		},
+		{
+			name: "最新版本",
+			msg:  toConsumerMessage(t, v2Msg),
+			want: readEventV2{Biz: "article", BizId: 3, Uid: 4},
+		},
+		{
+			name: "retry topic 按照原始 topic 解析",
+			msg: &sarama.ConsumerMessage{
+				Topic: "article_read_retry",
+				Value: []byte(`{"Aid":7,"Uid":8}`),
+				Headers: []*sarama.RecordHeader{
+					{Key: []byte(HeaderOriginTopic), Value: []byte("article_read")},
+				},
+			},
+			want: readEventV2{Biz: "article", BizId: 7, Uid: 8},
+		},
+		{
+			name:    "不认识的版本",
+			msg:     toConsumerMessage(t, v3Msg),
+			wantErr: ErrUnknownVersion,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var evt readEventV2
+			err := r.Decode(tc.msg, &evt)
+			assert.ErrorIs(t, err, tc.wantErr)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.want, evt)
+		})
+	}
+}
+
+func TestRegistry_NewMessage(t *testing.T) {
+	r := newTestRegistry()
+	pm, err := r.NewMessage(context.Background(), "article_read", readEventV2{BizId: 1})
+	require.NoError(t, err)
+	env, ok, err := DecodeEnvelope(toConsumerMessage(t, pm))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "article.read", env.Type)
+	assert.Equal(t, 2, env.Version)
+	assert.NotZero(t, env.Timestamp)
+}
+
+// consumerReadEvent 消费者在自己的包里面定义的类型，结构和 readEventV1 一样
+type consumerReadEvent struct {
+	Aid int64
+	Uid int64
+}
+
+func TestRegistry_Register(t *testing.T) {
+	// 生产者和消费者在同一个进程里面，各自用自己的类型注册同一个 topic
+	r := NewRegistry()
+	r.Register("article_read", "article.read", Version{Version: 1, Sample: readEventV1{}})
+	r.Register("article_read", "article.read", Version{Version: 1, Sample: consumerReadEvent{}})
+
+	pm, err := r.NewMessage(context.Background(), "article_read", readEventV1{Aid: 1, Uid: 2})
+	require.NoError(t, err)
+	var evt consumerReadEvent
+	err = r.Decode(toConsumerMessage(t, pm), &evt)
+	require.NoError(t, err)
+	assert.Equal(t, consumerReadEvent{Aid: 1, Uid: 2}, evt)
+
+	// 升级之后的类型和 dst 的类型不一样，按照结构解析
+	up := newTestRegistry()
+	type consumerReadEventV2 struct {
+		Biz   string
+		BizId int64
+	}
+	var v2 consumerReadEventV2
+	err = up.Decode(toConsumerMessage(t, pm), &v2)
+	require.NoError(t, err)
+	assert.Equal(t, consumerReadEventV2{Biz: "article", BizId: 1}, v2)
+
+	// 同一个 topic 注册成了不同的事件，说明用错了 topic
+	assert.Panics(t, func() {
+		r.Register("article_read", "article.like", Version{Version: 1, Sample: readEventV1{}})
+	})
+}
+
+func toConsumerMessage(t *testing.T, pm *sarama.ProducerMessage) *sarama.ConsumerMessage {
+	val, err := pm.Value.Encode()
+	require.NoError(t, err)
+	msg := &sarama.ConsumerMessage{Topic: pm.Topic, Value: val}
+	for i := range pm.Headers {
+		msg.Headers = append(msg.Headers, &pm.Headers[i])
+	}
+	return msg
+}
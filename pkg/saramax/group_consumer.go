@@ -0,0 +1,75 @@
+package saramax
+
+import (
+	"context"
+	"errors"
+	"github.com/IBM/sarama"
+	"time"
+	"webook/pkg/logger"
+)
+
+// GroupConsumer 封装了 consumer group 的启动和停止，业务的 Consumer 组合它就可以实现 Consumer 接口
+type GroupConsumer struct {
+	client  sarama.Client
+	groupId string
+	topics  []string
+	handler sarama.ConsumerGroupHandler
+	l       logger.LoggerV1
+
+	cg     sarama.ConsumerGroup
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewGroupConsumer(client sarama.Client, groupId string, topics []string,
+	handler sarama.ConsumerGroupHandler, l logger.LoggerV1) *GroupConsumer {
+	return &GroupConsumer{
+		client:  client,
+		groupId: groupId,
+		topics:  topics,
+		handler: handler,
+		l:       l,
+	}
+}
+
+func (g *GroupConsumer) Start() error {
+	cg, err := sarama.NewConsumerGroupFromClient(g.groupId, g.client)
+	if err != nil {
+		return err
+	}
+	g.cg = cg
+	ctx, cancel := context.WithCancel(context.Background())
+	g.cancel = cancel
+	g.done = make(chan struct{})
+	go func() {
+		defer close(g.done)
+		// 发生 rebalance 的时候 Consume 会返回，所以要放在循环里面
+		for {
+			er := cg.Consume(ctx, g.topics, g.handler)
+			if ctx.Err() != nil || errors.Is(er, sarama.ErrClosedConsumerGroup) {
+				return
+			}
+			if er != nil {
+				g.l.Error("消费出错",
+					logger.String("group", g.groupId),
+					logger.Error(er))
+				time.Sleep(time.Second)
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop 先退出消费循环，再关闭 consumer group，关闭的时候 sarama 会提交 offset
+func (g *GroupConsumer) Stop(ctx context.Context) error {
+	if g == nil || g.cg == nil {
+		return nil
+	}
+	g.cancel()
+	select {
+	case <-g.done:
+	case <-ctx.Done():
+		g.l.Warn("等待消费循环退出超时", logger.String("group", g.groupId))
+	}
+	return g.cg.Close()
+}
@@ -1,20 +1,23 @@
 package saramax
 
 import (
-	"encoding/json"
+	"context"
 	"github.com/IBM/sarama"
 	"webook/pkg/logger"
 )
 
 type Handler[T any] struct {
-	fn func(msg *sarama.ConsumerMessage, event T) error
-	l  logger.LoggerV1
+	fn   func(msg *sarama.ConsumerMessage, event T) error
+	l    logger.LoggerV1
+	opts handlerOptions
 }
 
-func NewHandler[T any](fn func(msg *sarama.ConsumerMessage, event T) error, l logger.LoggerV1) *Handler[T] {
+func NewHandler[T any](fn func(msg *sarama.ConsumerMessage, event T) error, l logger.LoggerV1,
+	opts ...HandlerOption) *Handler[T] {
 	return &Handler[T]{
-		fn: fn,
-		l:  l,
+		fn:   fn,
+		l:    l,
+		opts: newHandlerOptions(opts),
 	}
 }
 
@@ -23,34 +26,67 @@ func (h *Handler[T]) Setup(session sarama.ConsumerGroupSession) error {
 }
 
 func (h *Handler[T]) Cleanup(session sarama.ConsumerGroupSession) error {
+	// rebalance 或者退出的时候，把已经标记的 offset 提交掉
+	session.Commit()
 	return nil
 }
 
 func (h *Handler[T]) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
 	msgs := claim.Messages()
+	ctx := session.Context()
 
 	for msg := range msgs {
-		// 在这里调用业务处理逻辑
-		var t T
-		err := json.Unmarshal(msg.Value, &t)
-		if err != nil {
-			h.l.Error("反序列化失败",
-				logger.String("topic", msg.Topic),
-				logger.Int32("partition", msg.Partition),
-				logger.Int64("offset", msg.Offset),
-				logger.Error(err))
+		if !waitUntilDue(ctx, msg) {
+			// session 结束了，不标记，下一次会重新消费
+			return nil
+		}
+		err := h.handle(ctx, msg)
+		if ctx.Err() != nil {
+			return nil
 		}
-
-		err = h.fn(msg, t)
 		if err != nil {
-			h.l.Error("处理消息失败",
+			// 转发失败了，不能标记，否则这条消息就丢了
+			// 返回之后 session 会结束，从上一次提交的 offset 重新消费
+			h.l.Error("转发失败消息出错",
 				logger.String("topic", msg.Topic),
 				logger.Int32("partition", msg.Partition),
 				logger.Int64("offset", msg.Offset),
 				logger.Error(err))
+			return err
 		}
-
 		session.MarkMessage(msg, "")
 	}
 	return nil
 }
+
+// handle 处理消息，只有在转发失败的时候才返回 error
+func (h *Handler[T]) handle(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	var t T
+	err := h.opts.registry.Decode(msg, &t)
+	if err != nil {
+		h.l.Error("反序列化失败",
+			logger.String("topic", msg.Topic),
+			logger.Int32("partition", msg.Partition),
+			logger.Int64("offset", msg.Offset),
+			logger.Error(err))
+		return h.opts.forward(msg, err, true)
+	}
+
+	// 在这里调用业务处理逻辑
+	err = h.opts.retry(ctx, func() error {
+		return h.fn(msg, t)
+	})
+	if ctx.Err() != nil {
+		// 重试的过程中 session 结束了，交给下一次消费
+		return nil
+	}
+	if err != nil {
+		h.l.Error("处理消息失败",
+			logger.String("topic", msg.Topic),
+			logger.Int32("partition", msg.Partition),
+			logger.Int64("offset", msg.Offset),
+			logger.Error(err))
+		return h.opts.forward(msg, err, false)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package saramax
+
+import (
+	"context"
+	"github.com/IBM/sarama"
+	"hash/crc32"
+	"sync"
+	"webook/pkg/logger"
+)
+
+// OrderedHandler 在一个分区内部并发处理消息
+// 按照 key 把消息哈希到 N 个 worker 上，同一个 key 的消息一定由同一个 worker 按顺序处理，
+// 不同 key 之间并发，下游慢的时候不会把整个分区卡住
+// offset 只会在它前面的所有消息都处理完之后才标记，中间不会出现空洞
+type OrderedHandler[T any] struct {
+	fn      func(msg *sarama.ConsumerMessage, event T) error
+	key     func(msg *sarama.ConsumerMessage, event T) string
+	workers int
+	l       logger.LoggerV1
+	opts    handlerOptions
+}
+
+// NewOrderedHandler key 决定了消息的顺序性，例如按照 biz_id 保证同一个业务的消息有序
+// workers 是每一个分区的并发数
+func NewOrderedHandler[T any](fn func(msg *sarama.ConsumerMessage, event T) error,
+	key func(msg *sarama.ConsumerMessage, event T) string,
+	workers int, l logger.LoggerV1, opts ...HandlerOption) *OrderedHandler[T] {
+	if workers <= 0 {
+		workers = 1
+	}
+	return &OrderedHandler[T]{
+		fn:      fn,
+		key:     key,
+		workers: workers,
+		l:       l,
+		opts:    newHandlerOptions(opts),
+	}
+}
+
+func (h *OrderedHandler[T]) Setup(session sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (h *OrderedHandler[T]) Cleanup(session sarama.ConsumerGroupSession) error {
+	// rebalance 或者退出的时候，把已经标记的 offset 提交掉
+	session.Commit()
+	return nil
+}
+
+type orderedTask[T any] struct {
+	msg   *sarama.ConsumerMessage
+	event T
+	ack   *ackEntry
+}
+
+func (h *OrderedHandler[T]) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	ctx, cancel := context.WithCancel(session.Context())
+	defer cancel()
+	tracker := newAckTracker(session)
+
+	// 转发失败之后，记录下来，整个 claim 停止消费
+	var errOnce sync.Once
+	var firstErr error
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	var wg sync.WaitGroup
+	queues := make([]chan orderedTask[T], h.workers)
+	for i := range queues {
+		queues[i] = make(chan orderedTask[T], 64)
+		wg.Add(1)
+		go func(q chan orderedTask[T]) {
+			defer wg.Done()
+			for task := range q {
+				if ctx.Err() != nil {
+					// 后面的都不标记了，下一次重新消费
+					continue
+				}
+				err := h.handle(ctx, task.msg, task.event)
+				if ctx.Err() != nil {
+					continue
+				}
+				if err != nil {
+					fail(err)
+					continue
+				}
+				tracker.done(task.ack)
+			}
+		}(queues[i])
+	}
+
+	h.dispatch(ctx, claim.Messages(), queues, tracker, fail)
+
+	for _, q := range queues {
+		close(q)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+func (h *OrderedHandler[T]) dispatch(ctx context.Context, msgs <-chan *sarama.ConsumerMessage,
+	queues []chan orderedTask[T], tracker *ackTracker, fail func(err error)) {
+	for {
+		var msg *sarama.ConsumerMessage
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok = <-msgs:
+			if !ok {
+				return
+			}
+		}
+		if !waitUntilDue(ctx, msg) {
+			return
+		}
+		ack := tracker.add(msg)
+
+		var t T
+		err := h.opts.registry.Decode(msg, &t)
+		if err != nil {
+			h.l.Error("反序列化失败",
+				logger.String("topic", msg.Topic),
+				logger.Int32("partition", msg.Partition),
+				logger.Int64("offset", msg.Offset),
+				logger.Error(err))
+			err = h.opts.forward(msg, err, true)
+			if err != nil {
+				fail(h.forwardFailed(msg, err))
+				return
+			}
+			tracker.done(ack)
+			continue
+		}
+
+		idx := crc32.ChecksumIEEE([]byte(h.key(msg, t))) % uint32(len(queues))
+		select {
+		case <-ctx.Done():
+			return
+		case queues[idx] <- orderedTask[T]{msg: msg, event: t, ack: ack}:
+		}
+	}
+}
+
+// handle 处理消息，只有在转发失败的时候才返回 error
+func (h *OrderedHandler[T]) handle(ctx context.Context, msg *sarama.ConsumerMessage, t T) error {
+	err := h.opts.retry(ctx, func() error {
+		return h.fn(msg, t)
+	})
+	if err == nil || ctx.Err() != nil {
+		return nil
+	}
+	h.l.Error("处理消息失败",
+		logger.String("topic", msg.Topic),
+		logger.Int32("partition", msg.Partition),
+		logger.Int64("offset", msg.Offset),
+		logger.Error(err))
+	err = h.opts.forward(msg, err, false)
+	if err != nil {
+		return h.forwardFailed(msg, err)
+	}
+	return nil
+}
+
+func (h *OrderedHandler[T]) forwardFailed(msg *sarama.ConsumerMessage, err error) error {
+	h.l.Error("转发失败消息出错",
+		logger.String("topic", msg.Topic),
+		logger.Int32("partition", msg.Partition),
+		logger.Int64("offset", msg.Offset),
+		logger.Error(err))
+	return err
+}
+
+type ackEntry struct {
+	msg  *sarama.ConsumerMessage
+	done bool
+}
+
+// ackTracker 按照 offset 的顺序记录一个分区里面正在处理的消息
+// 只有队头连续完成的消息才会被标记，所以标记的 offset 之前不会有没处理完的消息
+type ackTracker struct {
+	session sarama.ConsumerGroupSession
+	lock    sync.Mutex
+	pending []*ackEntry
+}
+
+func newAckTracker(session sarama.ConsumerGroupSession) *ackTracker {
+	return &ackTracker{session: session}
+}
+
+func (t *ackTracker) add(msg *sarama.ConsumerMessage) *ackEntry {
+	e := &ackEntry{msg: msg}
+	t.lock.Lock()
+	t.pending = append(t.pending, e)
+	t.lock.Unlock()
+	return e
+}
+
+func (t *ackTracker) done(e *ackEntry) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	e.done = true
+	var last *sarama.ConsumerMessage
+	i := 0
+	for ; i < len(t.pending) && t.pending[i].done; i++ {
+		last = t.pending[i].msg
+	}
+	if last == nil {
+		return
+	}
+	t.pending = t.pending[i:]
+	// MarkMessage 标记的是 offset + 1，所以只需要标记最后一条
+	t.session.MarkMessage(last, "")
+}
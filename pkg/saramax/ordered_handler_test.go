@@ -0,0 +1,119 @@
+package saramax
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+	"webook/pkg/logger"
+)
+
+func TestAckTracker(t *testing.T) {
+	sess := &fakeSession{ctx: context.Background()}
+	tracker := newAckTracker(sess)
+	entries := make([]*ackEntry, 0, 5)
+	for i := 0; i < 5; i++ {
+		entries = append(entries, tracker.add(&sarama.ConsumerMessage{Offset: int64(i)}))
+	}
+
+	// 前面的还没有完成，不能标记
+	tracker.done(entries[2])
+	tracker.done(entries[1])
+	assert.Equal(t, int64(-1), sess.marked())
+
+	// 0 完成之后，0、1、2 都可以标记了
+	tracker.done(entries[0])
+	assert.Equal(t, int64(3), sess.marked())
+
+	tracker.done(entries[4])
+	assert.Equal(t, int64(3), sess.marked())
+	tracker.done(entries[3])
+	assert.Equal(t, int64(5), sess.marked())
+}
+
+type orderedEvent struct {
+	BizId int64
+	Seq   int
+}
+
+func TestOrderedHandler(t *testing.T) {
+	const cnt = 100
+	var lock sync.Mutex
+	got := make(map[int64][]int)
+	h := NewOrderedHandler[orderedEvent](func(msg *sarama.ConsumerMessage, evt orderedEvent) error {
+		// 模拟下游比较慢
+		time.Sleep(time.Millisecond)
+		lock.Lock()
+		got[evt.BizId] = append(got[evt.BizId], evt.Seq)
+		lock.Unlock()
+		return nil
+	}, func(msg *sarama.ConsumerMessage, evt orderedEvent) string {
+		return strconv.FormatInt(evt.BizId, 10)
+	}, 4, logger.NewNoOpLogger())
+
+	msgs := make(chan *sarama.ConsumerMessage, cnt)
+	for i := 0; i < cnt; i++ {
+		val, err := json.Marshal(orderedEvent{BizId: int64(i % 7), Seq: i})
+		require.NoError(t, err)
+		msgs <- &sarama.ConsumerMessage{Offset: int64(i), Value: val}
+	}
+	close(msgs)
+
+	sess := &fakeSession{ctx: context.Background()}
+	err := h.ConsumeClaim(sess, &fakeClaim{msgs: msgs})
+	require.NoError(t, err)
+
+	// 所有消息都处理完了，offset 也全部标记
+	assert.Equal(t, int64(cnt), sess.marked())
+	total := 0
+	for bizId, seqs := range got {
+		total += len(seqs)
+		// 同一个 key 的消息是按顺序处理的
+		for i := 1; i < len(seqs); i++ {
+			assert.Less(t, seqs[i-1], seqs[i], "biz_id %d", bizId)
+		}
+	}
+	assert.Equal(t, cnt, total)
+}
+
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	ctx    context.Context
+	lock   sync.Mutex
+	offset int64
+	isSet  bool
+}
+
+func (f *fakeSession) Context() context.Context {
+	return f.ctx
+}
+
+func (f *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.offset = msg.Offset + 1
+	f.isSet = true
+}
+
+func (f *fakeSession) marked() int64 {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if !f.isSet {
+		return -1
+	}
+	return f.offset
+}
+
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	msgs chan *sarama.ConsumerMessage
+}
+
+func (f *fakeClaim) Messages() <-chan *sarama.ConsumerMessage {
+	return f.msgs
+}
@@ -0,0 +1,123 @@
+package saramax
+
+import (
+	"context"
+	"github.com/IBM/sarama"
+	"time"
+	"webook/pkg/logger"
+)
+
+// Replayer 把死信队列里面的消息重新投递到原始的 topic
+// 一般是修复了 bug 或者下游恢复之后，由人工触发
+type Replayer struct {
+	client   sarama.Client
+	producer sarama.SyncProducer
+	l        logger.LoggerV1
+}
+
+func NewReplayer(client sarama.Client, producer sarama.SyncProducer, l logger.LoggerV1) *Replayer {
+	return &Replayer{client: client, producer: producer, l: l}
+}
+
+// Replay 重放 dlqTopic 里面在 since 之后写入的消息，since 为零值就从头开始，只处理调用时已经存在的消息
+// 返回重放的消息条数
+func (r *Replayer) Replay(ctx context.Context, dlqTopic string, since time.Time) (int, error) {
+	consumer, err := sarama.NewConsumerFromClient(r.client)
+	if err != nil {
+		return 0, err
+	}
+	defer consumer.Close()
+
+	partitions, err := r.client.Partitions(dlqTopic)
+	if err != nil {
+		return 0, err
+	}
+	cnt := 0
+	for _, p := range partitions {
+		n, err := r.replayPartition(ctx, consumer, dlqTopic, p, since)
+		cnt += n
+		if err != nil {
+			return cnt, err
+		}
+	}
+	return cnt, nil
+}
+
+func (r *Replayer) replayPartition(ctx context.Context, consumer sarama.Consumer,
+	topic string, partition int32, since time.Time) (int, error) {
+	ts := sarama.OffsetOldest
+	if !since.IsZero() {
+		ts = since.UnixMilli()
+	}
+	start, err := r.client.GetOffset(topic, partition, ts)
+	if err != nil {
+		return 0, err
+	}
+	// 记录开始的时候的最新 offset，重放期间新进来的消息不处理
+	end, err := r.client.GetOffset(topic, partition, sarama.OffsetNewest)
+	if err != nil {
+		return 0, err
+	}
+	if start < 0 || start >= end {
+		return 0, nil
+	}
+
+	pc, err := consumer.ConsumePartition(topic, partition, start)
+	if err != nil {
+		return 0, err
+	}
+	defer pc.Close()
+
+	cnt := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return cnt, ctx.Err()
+		case msg, ok := <-pc.Messages():
+			if !ok {
+				return cnt, nil
+			}
+			err = r.replay(msg)
+			if err != nil {
+				return cnt, err
+			}
+			cnt++
+			if msg.Offset >= end-1 {
+				return cnt, nil
+			}
+		}
+	}
+}
+
+func (r *Replayer) replay(msg *sarama.ConsumerMessage) error {
+	origin, ok := header(msg, HeaderOriginTopic)
+	if !ok {
+		r.l.Warn("死信消息没有原始 topic，跳过",
+			logger.String("topic", msg.Topic),
+			logger.Int32("partition", msg.Partition),
+			logger.Int64("offset", msg.Offset))
+		return nil
+	}
+	// 去掉重试相关的 header，重放的消息相当于一条新消息
+	headers := make([]sarama.RecordHeader, 0, len(msg.Headers))
+	for _, h := range msg.Headers {
+		if h == nil {
+			continue
+		}
+		switch string(h.Key) {
+		case HeaderOriginTopic, HeaderRetryCount, HeaderRetryAt, HeaderError:
+		default:
+			headers = append(headers, *h)
+		}
+	}
+	pm := &sarama.ProducerMessage{
+		Topic:   origin,
+		Value:   sarama.ByteEncoder(msg.Value),
+		Headers: headers,
+	}
+	if len(msg.Key) > 0 {
+		pm.Key = sarama.ByteEncoder(msg.Key)
+	}
+	_, _, err := r.producer.SendMessage(pm)
+	return err
+}
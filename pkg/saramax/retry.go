@@ -0,0 +1,221 @@
+package saramax
+
+import (
+	"context"
+	"github.com/IBM/sarama"
+	"strconv"
+	"time"
+)
+
+const (
+	// HeaderOriginTopic 消息最开始所在的 topic
+	HeaderOriginTopic = "x-origin-topic"
+	// HeaderRetryCount 经过 retry topic 重新投递的次数
+	HeaderRetryCount = "x-retry-count"
+	// HeaderRetryAt 重新投递的消息最早在什么时候处理，毫秒时间戳
+	HeaderRetryAt = "x-retry-at"
+	// HeaderError 最后一次处理失败的原因
+	HeaderError = "x-error"
+
+	retryTopicSuffix = "_retry"
+	dlqTopicSuffix   = "_dlq"
+)
+
+// RetryTopic 延迟重试使用的 topic，消费者要同时订阅原始 topic 和它
+func RetryTopic(topic string) string {
+	return topic + retryTopicSuffix
+}
+
+// DLQTopic 死信队列
+func DLQTopic(topic string) string {
+	return topic + dlqTopicSuffix
+}
+
+// ExponentialBackoff 指数退避，第一次重试等待 initial，之后每次翻倍，最多等待 max
+func ExponentialBackoff(initial, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := initial
+		for i := 1; i < attempt; i++ {
+			d *= 2
+			if d >= max {
+				return max
+			}
+		}
+		return d
+	}
+}
+
+type HandlerOption func(o *handlerOptions)
+
+type handlerOptions struct {
+	// 单条消息（或者一批消息）在本地最多重试几次，0 表示不重试
+	maxAttempts int
+	backoff     func(attempt int) time.Duration
+	deadLetter  *DeadLetter
+	registry    *Registry
+
+	// 下面两个只对 BatchHandler 生效
+	batchSize     int
+	flushInterval time.Duration
+}
+
+// WithRetry 业务处理失败之后，在本地按照 backoff 重试
+func WithRetry(maxAttempts int, backoff func(attempt int) time.Duration) HandlerOption {
+	return func(o *handlerOptions) {
+		o.maxAttempts = maxAttempts
+		o.backoff = backoff
+	}
+}
+
+// WithDeadLetter 本地重试之后还是失败，转发到 retry topic 或者死信队列
+// 不设置的话，失败的消息只会记录日志
+func WithDeadLetter(dl *DeadLetter) HandlerOption {
+	return func(o *handlerOptions) {
+		o.deadLetter = dl
+	}
+}
+
+// WithRegistry 用指定的 Registry 解析消息，默认是 DefaultRegistry
+func WithRegistry(r *Registry) HandlerOption {
+	return func(o *handlerOptions) {
+		o.registry = r
+	}
+}
+
+func newHandlerOptions(opts []HandlerOption) handlerOptions {
+	o := handlerOptions{
+		backoff:       ExponentialBackoff(time.Millisecond*100, time.Second*5),
+		registry:      DefaultRegistry,
+		batchSize:     defaultBatchSize,
+		flushInterval: defaultFlushInterval,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// retry 执行 fn，失败了就按照配置重试
+// ctx 被取消的时候立刻返回 ctx 的错误
+func (o handlerOptions) retry(ctx context.Context, fn func() error) error {
+	err := fn()
+	for i := 1; err != nil && i <= o.maxAttempts; i++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(o.backoff(i)):
+		}
+		err = fn()
+	}
+	return err
+}
+
+// forward 转发失败的消息，没有设置死信队列就直接丢弃
+func (o handlerOptions) forward(msg *sarama.ConsumerMessage, cause error, poison bool) error {
+	if o.deadLetter == nil {
+		return nil
+	}
+	return o.deadLetter.Forward(msg, cause, poison)
+}
+
+// waitUntilDue 如果是 retry topic 过来的消息，要等到了时间再处理
+// 同一个 retry topic 的延迟是一样的，所以前面的消息没到时间，后面的消息也不会到时间，直接阻塞等待就可以
+// 返回 false 说明等待过程中 ctx 被取消了
+func waitUntilDue(ctx context.Context, msg *sarama.ConsumerMessage) bool {
+	val, ok := header(msg, HeaderRetryAt)
+	if !ok {
+		return true
+	}
+	ms, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return true
+	}
+	d := time.Until(time.UnixMilli(ms))
+	if d <= 0 {
+		return true
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+func header(msg *sarama.ConsumerMessage, key string) (string, bool) {
+	for _, h := range msg.Headers {
+		if h != nil && string(h.Key) == key {
+			return string(h.Value), true
+		}
+	}
+	return "", false
+}
+
+// DeadLetter 本地重试之后依旧失败的消息，先转发到 <topic>_retry 延迟重试，
+// 重新投递超过 MaxRedeliver 次之后，转发到 <topic>_dlq，等待人工处理或者用 Replayer 重放
+type DeadLetter struct {
+	producer sarama.SyncProducer
+	// MaxRedeliver 经过 retry topic 重新投递的最大次数
+	MaxRedeliver int
+	// Delay 重新投递之后，延迟多久再处理
+	Delay time.Duration
+}
+
+func NewDeadLetter(producer sarama.SyncProducer, maxRedeliver int, delay time.Duration) *DeadLetter {
+	return &DeadLetter{
+		producer:     producer,
+		MaxRedeliver: maxRedeliver,
+		Delay:        delay,
+	}
+}
+
+// Forward 转发消息，保留原始的 key、value 和 header，并且带上失败原因
+// poison 表示消息本身有问题（例如反序列化失败），重试也没用，直接进死信队列
+func (d *DeadLetter) Forward(msg *sarama.ConsumerMessage, cause error, poison bool) error {
+	origin := msg.Topic
+	cnt := 0
+	headers := make([]sarama.RecordHeader, 0, len(msg.Headers)+4)
+	for _, h := range msg.Headers {
+		if h == nil {
+			continue
+		}
+		switch string(h.Key) {
+		case HeaderOriginTopic:
+			origin = string(h.Value)
+		case HeaderRetryCount:
+			cnt, _ = strconv.Atoi(string(h.Value))
+		case HeaderRetryAt, HeaderError:
+		default:
+			headers = append(headers, *h)
+		}
+	}
+
+	var topic string
+	if !poison && cnt < d.MaxRedeliver {
+		topic = RetryTopic(origin)
+		cnt++
+		retryAt := time.Now().Add(d.Delay).UnixMilli()
+		headers = append(headers, recordHeader(HeaderRetryAt, strconv.FormatInt(retryAt, 10)))
+	} else {
+		topic = DLQTopic(origin)
+	}
+	headers = append(headers,
+		recordHeader(HeaderOriginTopic, origin),
+		recordHeader(HeaderRetryCount, strconv.Itoa(cnt)),
+		recordHeader(HeaderError, cause.Error()))
+
+	pm := &sarama.ProducerMessage{
+		Topic:   topic,
+		Value:   sarama.ByteEncoder(msg.Value),
+		Headers: headers,
+	}
+	if len(msg.Key) > 0 {
+		pm.Key = sarama.ByteEncoder(msg.Key)
+	}
+	_, _, err := d.producer.SendMessage(pm)
+	return err
+}
+
+func recordHeader(key, val string) sarama.RecordHeader {
+	return sarama.RecordHeader{Key: []byte(key), Value: []byte(val)}
+}
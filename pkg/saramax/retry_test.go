@@ -0,0 +1,98 @@
+package saramax
+
+import (
+	"errors"
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestDeadLetter_Forward(t *testing.T) {
+	testCases := []struct {
+		name      string
+		msg       *sarama.ConsumerMessage
+		poison    bool
+		wantTopic string
+		wantCnt   string
+	}{
+		{
+			name: "第一次失败，进 retry topic",
+			msg: &sarama.ConsumerMessage{
+				Topic: "payment_events",
+				Value: []byte("{}"),
+				Headers: []*sarama.RecordHeader{
+					{Key: []byte("trace_id"), Value: []byte("abc")},
+				},
+			},
+			wantTopic: "payment_events_retry",
+			wantCnt:   "1",
+		},
+		{
+			name: "重试次数用完，进死信队列",
+			msg: &sarama.ConsumerMessage{
+				Topic: "payment_events_retry",
+				Value: []byte("{}"),
+				Headers: []*sarama.RecordHeader{
+					{Key: []byte("trace_id"), Value: []byte("abc")},
+					{Key: []byte(HeaderOriginTopic), Value: []byte("payment_events")},
+					{Key: []byte(HeaderRetryCount), Value: []byte("2")},
+					{Key: []byte(HeaderRetryAt), Value: []byte("123")},
+				},
+			},
+			wantTopic: "payment_events_dlq",
+			wantCnt:   "2",
+		},
+		{
+			name: "消息格式错误，直接进死信队列",
+			msg: &sarama.ConsumerMessage{
+				Topic: "payment_events",
+				Value: []byte("abc"),
+				Headers: []*sarama.RecordHeader{
+					{Key: []byte("trace_id"), Value: []byte("abc")},
+				},
+			},
+			poison:    true,
+			wantTopic: "payment_events_dlq",
+			wantCnt:   "0",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			producer := mocks.NewSyncProducer(t, nil)
+			var sent *sarama.ProducerMessage
+			producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+				sent = msg
+				return nil
+			})
+			dl := NewDeadLetter(producer, 2, time.Minute)
+			err := dl.Forward(tc.msg, errors.New("mock error"), tc.poison)
+			require.NoError(t, err)
+			require.NoError(t, producer.Close())
+
+			assert.Equal(t, tc.wantTopic, sent.Topic)
+			headers := make(map[string]string, len(sent.Headers))
+			for _, h := range sent.Headers {
+				headers[string(h.Key)] = string(h.Value)
+			}
+			// 原始的 header 要保留
+			assert.Equal(t, "abc", headers["trace_id"])
+			assert.Equal(t, "payment_events", headers[HeaderOriginTopic])
+			assert.Equal(t, tc.wantCnt, headers[HeaderRetryCount])
+			assert.Equal(t, "mock error", headers[HeaderError])
+			_, hasRetryAt := headers[HeaderRetryAt]
+			assert.Equal(t, tc.wantTopic == "payment_events_retry", hasRetryAt)
+		})
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(time.Millisecond*100, time.Second)
+	assert.Equal(t, time.Millisecond*100, backoff(1))
+	assert.Equal(t, time.Millisecond*200, backoff(2))
+	assert.Equal(t, time.Millisecond*800, backoff(4))
+	assert.Equal(t, time.Second, backoff(5))
+	assert.Equal(t, time.Second, backoff(10))
+}
@@ -1,5 +1,9 @@
 package saramax
 
+import "context"
+
 type Consumer interface {
 	Start() error
+	// Stop 停止消费，返回之前会提交已经标记过的 offset
+	Stop(ctx context.Context) error
 }
@@ -0,0 +1,86 @@
+// Package totp RFC 6238，和 Google Authenticator 之类的应用兼容：HMAC-SHA1，6 位，30 秒一个周期
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	digits = 6
+	period = 30
+	// skew 前后各允许一个周期，抵消手机和服务器的时间误差
+	skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret 160 位的随机密钥，base32 编码
+func GenerateSecret() (string, error) {
+	data := make([]byte, 20)
+	_, err := rand.Read(data)
+	if err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(data), nil
+}
+
+// URI 生成二维码的内容，扫码之后验证器应用里面显示 issuer (account)
+func URI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(digits))
+	params.Set("period", fmt.Sprint(period))
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// Step t 所在的周期
+func Step(t time.Time) int64 {
+	return t.Unix() / period
+}
+
+// Code 第 step 个周期的动态码
+func Code(secret string, step int64) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", err
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	// RFC 4226 的动态截断
+	offset := sum[len(sum)-1] & 0x0f
+	val := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", digits, val%1000000), nil
+}
+
+// Validate 验证 t 时刻输入的动态码，返回动态码所在的周期
+// 调用方要记录用过的周期，同一个动态码不能用两次
+func Validate(secret, code string, t time.Time) (int64, bool) {
+	if len(code) != digits {
+		return 0, false
+	}
+	cur := Step(t)
+	for step := cur - skew; step <= cur+skew; step++ {
+		expected, err := Code(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
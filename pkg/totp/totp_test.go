@@ -0,0 +1,81 @@
+package totp
+
+import (
+	"encoding/base32"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	// RFC 6238 附录 B 里面 SHA1 的密钥，期望值取 8 位结果的后 6 位
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	testCases := []struct {
+		name string
+		code string
+		now  time.Time
+
+		wantStep int64
+		wantOk   bool
+	}{
+		{
+			name:     "59",
+			code:     "287082",
+			now:      time.Unix(59, 0),
+			wantStep: 1,
+			wantOk:   true,
+		},
+		{
+			name:     "1111111109",
+			code:     "081804",
+			now:      time.Unix(1111111109, 0),
+			wantStep: 37037036,
+			wantOk:   true,
+		},
+		{
+			name:     "1234567890",
+			code:     "005924",
+			now:      time.Unix(1234567890, 0),
+			wantStep: 41152263,
+			wantOk:   true,
+		},
+		{
+			name:     "上一个周期的也可以",
+			code:     "005924",
+			now:      time.Unix(1234567890+30, 0),
+			wantStep: 41152263,
+			wantOk:   true,
+		},
+		{
+			name: "超过了允许的误差",
+			code: "005924",
+			now:  time.Unix(1234567890+90, 0),
+		},
+		{
+			name: "位数不对",
+			code: "89005924",
+			now:  time.Unix(1234567890, 0),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			step, ok := Validate(secret, tc.code, tc.now)
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.wantStep, step)
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	now := time.Now()
+	code, err := Code(secret, Step(now))
+	require.NoError(t, err)
+	step, ok := Validate(secret, code, now)
+	assert.True(t, ok)
+	assert.Equal(t, Step(now), step)
+	assert.Equal(t, "otpauth://totp/webook:a@b.com?algorithm=SHA1&digits=6&issuer=webook&period=30&secret="+secret,
+		URI("webook", "a@b.com", secret))
+}
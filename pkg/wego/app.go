@@ -1,9 +1,16 @@
 package wego
 
 import (
+	"context"
+	"errors"
 	"github.com/robfig/cron/v3"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
 	"webook/pkg/ginx"
 	"webook/pkg/grpcx"
+	"webook/pkg/logger"
 	"webook/pkg/saramax"
 )
 
@@ -12,4 +19,117 @@ type App struct {
 	WebServer  *ginx.Server
 	Consumers  []saramax.Consumer
 	Cron       *cron.Cron
+
+	// ShutdownTimeout 优雅退出的最长时间，默认 30s
+	ShutdownTimeout time.Duration
+	L               logger.LoggerV1
+}
+
+// Run 启动所有的组件，然后阻塞直到收到 SIGINT/SIGTERM，或者某个服务器异常退出
+// 退出的时候按照 定时任务 -> 消费者 -> HTTP 服务器 -> gRPC 服务器 的顺序关闭
+func (app *App) Run() error {
+	if app.L == nil {
+		app.L = logger.NewNoOpLogger()
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// 服务器是阻塞运行的，出错了通过 errCh 通知
+	errCh := make(chan error, 2)
+	err := app.start(errCh)
+	if err != nil {
+		return errors.Join(err, app.shutdown())
+	}
+
+	select {
+	case <-ctx.Done():
+		app.L.Info("收到退出信号，开始优雅退出")
+	case err = <-errCh:
+		app.L.Error("服务器异常退出，开始关闭其它组件", logger.Error(err))
+	}
+	return errors.Join(err, app.shutdown())
+}
+
+func (app *App) start(errCh chan<- error) error {
+	var errs []error
+	for _, c := range app.Consumers {
+		err := c.Start()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if app.Cron != nil {
+		app.Cron.Start()
+	}
+	if app.WebServer != nil {
+		go func() {
+			err := app.WebServer.Start()
+			if !errors.Is(err, http.ErrServerClosed) {
+				errCh <- err
+			}
+		}()
+	}
+	if app.GRPCServer != nil {
+		go func() {
+			err := app.GRPCServer.Serve()
+			if err != nil {
+				errCh <- err
+			}
+		}()
+	}
+	return errors.Join(errs...)
+}
+
+func (app *App) shutdown() error {
+	timeout := app.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = time.Second * 30
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+	if app.Cron != nil {
+		// 不再调度新的任务，并且等待正在运行的任务结束
+		select {
+		case <-app.Cron.Stop().Done():
+		case <-ctx.Done():
+			errs = append(errs, errors.New("等待定时任务结束超时"))
+		}
+	}
+	for _, c := range app.Consumers {
+		err := c.Stop(ctx)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if app.WebServer != nil {
+		err := app.WebServer.Shutdown(ctx)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if app.GRPCServer != nil {
+		errs = append(errs, app.closeGRPCServer(ctx))
+	}
+	err := errors.Join(errs...)
+	if err != nil {
+		app.L.Error("退出过程中出现错误", logger.Error(err))
+	}
+	return err
+}
+
+// closeGRPCServer 超时之后就不再等正在处理的请求了，直接关掉
+func (app *App) closeGRPCServer(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- app.GRPCServer.Close()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		app.GRPCServer.Stop()
+		return ctx.Err()
+	}
 }
@@ -34,26 +34,38 @@ func (p PaymentEvent) ToDomainStatus() domain.RewardStatus {
 	}
 }
 
+const topicPaymentEvents = "payment_events"
+
+func init() {
+	saramax.DefaultRegistry.Register(topicPaymentEvents, "payment.status_changed",
+		saramax.Version{Version: 1, Sample: PaymentEvent{}})
+}
+
 type PaymentEventConsumer struct {
-	client sarama.Client
-	l      logger.LoggerV1
-	svc    service.RewardService
+	client   sarama.Client
+	producer sarama.SyncProducer
+	l        logger.LoggerV1
+	svc      service.RewardService
+	cg       *saramax.GroupConsumer
 }
 
-func (r *PaymentEventConsumer) Start() error {
-	cg, err := sarama.NewConsumerGroupFromClient("reward", r.client)
-	if err != nil {
-		return err
-	}
+func NewPaymentEventConsumer(client sarama.Client, producer sarama.SyncProducer,
+	l logger.LoggerV1, svc service.RewardService) *PaymentEventConsumer {
+	return &PaymentEventConsumer{client: client, producer: producer, l: l, svc: svc}
+}
 
-	go func() {
-		er := cg.Consume(context.Background(), []string{"payment_events"}, saramax.NewHandler[PaymentEvent](r.Consume, r.l))
-		if er != nil {
-			r.l.Error("打赏服务退出消费", logger.Error(er))
-		}
-	}()
+func (r *PaymentEventConsumer) Start() error {
+	// 支付结果丢了，打赏就一直卡在初始状态，所以失败了要重试，最后进死信队列
+	h := saramax.NewHandler[PaymentEvent](r.Consume, r.l,
+		saramax.WithRetry(3, saramax.ExponentialBackoff(time.Millisecond*100, time.Second)),
+		saramax.WithDeadLetter(saramax.NewDeadLetter(r.producer, 3, time.Minute)))
+	r.cg = saramax.NewGroupConsumer(r.client, "reward",
+		[]string{topicPaymentEvents, saramax.RetryTopic(topicPaymentEvents)}, h, r.l)
+	return r.cg.Start()
+}
 
-	return err
+func (r *PaymentEventConsumer) Stop(ctx context.Context) error {
+	return r.cg.Stop(ctx)
 }
 
 func (r *PaymentEventConsumer) Consume(msg *sarama.ConsumerMessage, evt PaymentEvent) error {
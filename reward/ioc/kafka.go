@@ -0,0 +1,38 @@
+package ioc
+
+import (
+	"github.com/IBM/sarama"
+	"github.com/spf13/viper"
+	"webook/pkg/saramax"
+	"webook/reward/events"
+)
+
+func InitKafka() sarama.Client {
+	type Config struct {
+		Addrs []string `yaml:"addrs"`
+	}
+	saramaCfg := sarama.NewConfig()
+	saramaCfg.Producer.Return.Successes = true
+	var cfg Config
+	err := viper.UnmarshalKey("kafka", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	client, err := sarama.NewClient(cfg.Addrs, saramaCfg)
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
+
+func InitSyncProducer(c sarama.Client) sarama.SyncProducer {
+	p, err := sarama.NewSyncProducerFromClient(c)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func InitConsumers(paymentConsumer *events.PaymentEventConsumer) []saramax.Consumer {
+	return []saramax.Consumer{paymentConsumer}
+}
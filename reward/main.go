@@ -8,7 +8,7 @@ import (
 func main() {
 	initViperV2Watch()
 	app := Init()
-	err := app.GRPCServer.Serve()
+	err := app.Run()
 	if err != nil {
 		panic(err)
 	}
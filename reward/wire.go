@@ -5,6 +5,7 @@ package reward
 import (
 	"github.com/google/wire"
 	"webook/pkg/wego"
+	"webook/reward/events"
 	"webook/reward/grpc"
 	"webook/reward/ioc"
 	"webook/reward/repository"
@@ -17,6 +18,8 @@ var thirdPartySet = wire.NewSet(
 	ioc.InitDB,
 	ioc.InitLogger,
 	ioc.InitEtcdClient,
+	ioc.InitKafka,
+	ioc.InitSyncProducer,
 	ioc.InitRedis)
 
 func Init() *wego.App {
@@ -32,7 +35,10 @@ func Init() *wego.App {
 
 		ioc.InitBloomFilter,
 
-		wire.Struct(new(wego.App), "GRPCServer"),
+		events.NewPaymentEventConsumer,
+		ioc.InitConsumers,
+
+		wire.Struct(new(wego.App), "GRPCServer", "Consumers", "L"),
 	)
 	return new(wego.App)
 }
@@ -9,6 +9,7 @@ package reward
 import (
 	"github.com/google/wire"
 	"webook/pkg/wego"
+	"webook/reward/events"
 	"webook/reward/grpc"
 	"webook/reward/ioc"
 	"webook/reward/repository"
@@ -32,12 +33,18 @@ func Init() *wego.App {
 	rewardService := service.NewWechatNativeRewardService(wechatPaymentServiceClient, rewardRepository, loggerV1, accountServiceClient)
 	rewardServiceServer := grpc.NewRewardServiceServer(rewardService)
 	server := ioc.InitGRPCServer(rewardServiceServer, client, loggerV1)
+	saramaClient := ioc.InitKafka()
+	syncProducer := ioc.InitSyncProducer(saramaClient)
+	paymentEventConsumer := events.NewPaymentEventConsumer(saramaClient, syncProducer, loggerV1, rewardService)
+	v := ioc.InitConsumers(paymentEventConsumer)
 	app := &wego.App{
 		GRPCServer: server,
+		Consumers:  v,
+		L:          loggerV1,
 	}
 	return app
 }
 
 // wire.go:
 
-var thirdPartySet = wire.NewSet(ioc.InitDB, ioc.InitLogger, ioc.InitEtcdClient, ioc.InitRedis)
+var thirdPartySet = wire.NewSet(ioc.InitDB, ioc.InitLogger, ioc.InitEtcdClient, ioc.InitKafka, ioc.InitSyncProducer, ioc.InitRedis)
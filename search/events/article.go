@@ -3,6 +3,7 @@ package events
 import (
 	"context"
 	"github.com/IBM/sarama"
+	"strconv"
 	"time"
 	"webook/pkg/logger"
 	"webook/pkg/saramax"
@@ -16,6 +17,7 @@ type ArticleConsumer struct {
 	syncSvc service.SyncService
 	client  sarama.Client
 	l       logger.LoggerV1
+	cg      *saramax.GroupConsumer
 }
 
 func NewArticleConsumer(client sarama.Client,
@@ -36,20 +38,17 @@ type ArticleEvent struct {
 }
 
 func (a *ArticleConsumer) Start() error {
-	cg, err := sarama.NewConsumerGroupFromClient("sync_article",
-		a.client)
-	if err != nil {
-		return err
-	}
-	go func() {
-		err := cg.Consume(context.Background(),
-			[]string{topicSyncArticle},
-			saramax.NewHandler[ArticleEvent](a.l, a.Consume))
-		if err != nil {
-			a.l.Error("退出了消费循环异常", logger.Error(err))
-		}
-	}()
-	return err
+	// 写 ES 比较慢，同一篇文章的消息保证顺序，不同文章之间并发
+	h := saramax.NewOrderedHandler[ArticleEvent](a.Consume,
+		func(msg *sarama.ConsumerMessage, evt ArticleEvent) string {
+			return strconv.FormatInt(evt.Id, 10)
+		}, 8, a.l)
+	a.cg = saramax.NewGroupConsumer(a.client, "sync_article", []string{topicSyncArticle}, h, a.l)
+	return a.cg.Start()
+}
+
+func (a *ArticleConsumer) Stop(ctx context.Context) error {
+	return a.cg.Stop(ctx)
 }
 
 func (a *ArticleConsumer) Consume(sg *sarama.ConsumerMessage,
@@ -9,6 +9,17 @@ import (
 	"webook/search/service"
 )
 
+const topicSyncSearchData = "sync_search_data"
+
+func init() {
+	saramax.DefaultRegistry.Register(topicSyncArticle, "search.sync_article",
+		saramax.Version{Version: 1, Sample: ArticleEvent{}})
+	saramax.DefaultRegistry.Register(topicSyncUser, "search.sync_user",
+		saramax.Version{Version: 1, Sample: UserEvent{}})
+	saramax.DefaultRegistry.Register(topicSyncSearchData, "search.sync_data",
+		saramax.Version{Version: 1, Sample: SyncDataEvent{}})
+}
+
 // 通用的 sync data event
 // 所有的业务方都可以通过这个 event 来同步数据
 type SyncDataEvent struct {
@@ -21,23 +32,21 @@ type SyncDataEventConsumer struct {
 	svc    service.SyncService
 	client sarama.Client
 	l      logger.LoggerV1
+	cg     *saramax.GroupConsumer
 }
 
 func (a *SyncDataEventConsumer) Start() error {
-	cg, err := sarama.NewConsumerGroupFromClient("search_sync_data",
-		a.client)
-	if err != nil {
-		return err
-	}
-	go func() {
-		err := cg.Consume(context.Background(),
-			[]string{"sync_search_data"},
-			saramax.NewHandler[SyncDataEvent](a.l, a.Consume))
-		if err != nil {
-			a.l.Error("退出了消费循环异常", logger.Error(err))
-		}
-	}()
-	return err
+	// 同一个文档的更新要保证顺序
+	h := saramax.NewOrderedHandler[SyncDataEvent](a.Consume,
+		func(msg *sarama.ConsumerMessage, evt SyncDataEvent) string {
+			return evt.IndexName + ":" + evt.DocID
+		}, 8, a.l)
+	a.cg = saramax.NewGroupConsumer(a.client, "search_sync_data", []string{topicSyncSearchData}, h, a.l)
+	return a.cg.Start()
+}
+
+func (a *SyncDataEventConsumer) Stop(ctx context.Context) error {
+	return a.cg.Stop(ctx)
 }
 
 func (a *SyncDataEventConsumer) Consume(sg *sarama.ConsumerMessage,
@@ -1,5 +0,0 @@
-package events
-
-type Consumer interface {
-	Start() error
-}
@@ -3,6 +3,7 @@ package events
 import (
 	"context"
 	"github.com/IBM/sarama"
+	"strconv"
 	"time"
 	"webook/pkg/logger"
 	"webook/pkg/saramax"
@@ -16,6 +17,7 @@ type UserConsumer struct {
 	syncSvc service.SyncService
 	client  sarama.Client
 	l       logger.LoggerV1
+	cg      *saramax.GroupConsumer
 }
 
 type UserEvent struct {
@@ -36,20 +38,16 @@ func NewUserConsumer(client sarama.Client,
 }
 
 func (u *UserConsumer) Start() error {
-	cg, err := sarama.NewConsumerGroupFromClient("sync_user",
-		u.client)
-	if err != nil {
-		return err
-	}
-	go func() {
-		err := cg.Consume(context.Background(),
-			[]string{topicSyncUser},
-			saramax.NewHandler[UserEvent](u.l, u.Consume))
-		if err != nil {
-			u.l.Error("退出了消费循环异常", logger.Error(err))
-		}
-	}()
-	return err
+	h := saramax.NewOrderedHandler[UserEvent](u.Consume,
+		func(msg *sarama.ConsumerMessage, evt UserEvent) string {
+			return strconv.FormatInt(evt.Id, 10)
+		}, 8, u.l)
+	u.cg = saramax.NewGroupConsumer(u.client, "sync_user", []string{topicSyncUser}, h, u.l)
+	return u.cg.Start()
+}
+
+func (u *UserConsumer) Stop(ctx context.Context) error {
+	return u.cg.Stop(ctx)
 }
 
 func (u *UserConsumer) Consume(sg *sarama.ConsumerMessage,
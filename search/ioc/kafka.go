@@ -3,6 +3,7 @@ package ioc
 import (
 	"github.com/IBM/sarama"
 	"github.com/spf13/viper"
+	"webook/pkg/saramax"
 	"webook/search/events"
 )
 
@@ -25,8 +26,8 @@ func InitKafka() sarama.Client {
 }
 
 // NewConsumers 面临的问题依旧是所有的 Consumer 在这里注册一下
-func NewConsumers(articleConsumer *events.ArticleConsumer, userConsumer *events.UserConsumer) []events.Consumer {
-	return []events.Consumer{
+func NewConsumers(articleConsumer *events.ArticleConsumer, userConsumer *events.UserConsumer) []saramax.Consumer {
+	return []saramax.Consumer{
 		articleConsumer,
 		userConsumer,
 	}
@@ -3,21 +3,15 @@ package main
 import (
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
-	"webook/pkg/grpcx"
-	"webook/search/events"
 )
 
 func main() {
 	initViperV2Watch()
 	app := Init()
-	for _, c := range app.consumers {
-		err := c.Start()
-		if err != nil {
-			panic(err)
-		}
+	err := app.Run()
+	if err != nil {
+		panic(err)
 	}
-	err := app.server.Serve()
-	panic(err)
 }
 
 func initViperV2Watch() {
@@ -32,8 +26,3 @@ func initViperV2Watch() {
 		panic(err)
 	}
 }
-
-type App struct {
-	server    *grpcx.Server
-	consumers []events.Consumer
-}
@@ -4,6 +4,7 @@ package main
 
 import (
 	"github.com/google/wire"
+	"webook/pkg/wego"
 	"webook/search/events"
 	"webook/search/grpc"
 	"webook/search/ioc"
@@ -30,7 +31,7 @@ var thirdProvider = wire.NewSet(
 	ioc.InitLogger,
 	ioc.InitKafka)
 
-func Init() *App {
+func Init() *wego.App {
 	wire.Build(
 		thirdProvider,
 		serviceProviderSet,
@@ -40,7 +41,7 @@ func Init() *App {
 		events.NewArticleConsumer,
 		ioc.InitGRPCxServer,
 		ioc.NewConsumers,
-		wire.Struct(new(App), "*"),
+		wire.Struct(new(wego.App), "GRPCServer", "Consumers", "L"),
 	)
-	return new(App)
+	return new(wego.App)
 }
@@ -7,6 +7,7 @@
 package main
 
 import (
+	"webook/pkg/wego"
 	"webook/search/events"
 	"webook/search/grpc"
 	"webook/search/ioc"
@@ -18,7 +19,7 @@ import (
 
 // Injectors from wire.go:
 
-func Init() *App {
+func Init() *wego.App {
 	client := ioc.InitESClient()
 	anyDAO := dao.NewAnyESDAO(client)
 	anyRepository := repository.NewAnyRepository(anyDAO)
@@ -38,9 +39,10 @@ func Init() *App {
 	articleConsumer := events.NewArticleConsumer(saramaClient, loggerV1, syncService)
 	userConsumer := events.NewUserConsumer(saramaClient, loggerV1, syncService)
 	v := ioc.NewConsumers(articleConsumer, userConsumer)
-	app := &App{
-		server:    server,
-		consumers: v,
+	app := &wego.App{
+		GRPCServer: server,
+		Consumers:  v,
+		L:          loggerV1,
 	}
 	return app
 }
@@ -4,25 +4,22 @@ package main
 
 import (
 	"github.com/google/wire"
-	repository2 "webook/interactive/repository"
 	cache2 "webook/interactive/repository/cache"
-	dao2 "webook/interactive/repository/dao"
-	service2 "webook/interactive/service"
+	"webook/internal/client"
 	"webook/internal/events/article"
+	"webook/internal/events/user"
 	"webook/internal/repository"
 	"webook/internal/repository/cache"
 	"webook/internal/repository/dao"
 	"webook/internal/service"
 	"webook/internal/web"
-	ijwt "webook/internal/web/jwt"
 	"webook/ioc"
 )
 
 // 纵向配置
-var interactiveSvcSet = wire.NewSet(dao2.NewGORMInteractiveDAO,
-	cache2.NewInteractiveRedisCache,
-	repository2.NewCachedInteractiveRepository,
-	service2.NewInteractiveService,
+// Interactive 服务熔断之后只读它的缓存，不把整个 Interactive 服务拉进主程序
+var interactiveFallbackSet = wire.NewSet(cache2.NewInteractiveRedisCache,
+	client.NewLocalInteractiveReader,
 )
 
 var rankingSvcSet = wire.NewSet(cache.NewRankingRedisCache,
@@ -39,10 +36,14 @@ func InitWebServer() *App {
 		ioc.InitSaramaClient,
 		ioc.InitSyncProducer,
 		ioc.InitRlockClient,
+		ioc.InitMongoDB,
 
 		// Dao 和 Cache
-		dao.NewUserDAO, dao.NewArticleGORMDAO,
-		cache.NewRedisUserCache, cache.NewRedisCodeCache, cache.NewArticleRedisCache,
+		dao.NewUserDAO, dao.NewUserIdentityDAO, dao.NewGORMMediaDAO, ioc.InitObjectDAO,
+		// 线上库迁移到 MongoDB，迁移期间双写
+		ioc.InitArticleDAO, wire.Bind(new(dao.ArticleDAO), new(*dao.DoubleWriteArticleDAO)),
+		cache.NewRedisUserCache, cache.NewRedisCodeCache, cache.NewArticleRedisCache, cache.NewRedisUserTokenCache,
+		cache.NewRedisLoginGuardCache,
 		// LocalCodeCache
 		//ioc.InitLRU,
 		//ioc.InitExpireTime,
@@ -50,11 +51,17 @@ func InitWebServer() *App {
 
 		// Repository
 		repository.NewCachedUserRepository, repository.NewCachedCodeRepository, repository.NewCachedArticleRepository,
+		repository.NewCachedUserTokenRepository, repository.NewUserIdentityRepository,
+		repository.NewCachedLoginGuardRepository, repository.NewMediaRepository,
 
 		// Service
 		ioc.InitSMSService, ioc.InitWechatService, service.NewUserService, service.NewCodeService, service.NewArticleService,
+		ioc.InitEmailService, ioc.InitAccountService,
+		service.NewTotpService, ioc.InitBindingService, ioc.InitOAuth2Registry,
+		ioc.InitLoginGuardService, service.NewMediaService,
 
-		//interactiveSvcSet,
+		// 熔断之后降级到本地的 InteractiveService
+		interactiveFallbackSet,
 
 		// Intr Client
 		//ioc.InitIntrClient,
@@ -62,7 +69,9 @@ func InitWebServer() *App {
 		//ioc.InitIntrRepositoryClient,
 		// get intr client from etcd
 		ioc.InitEtcd,
-		ioc.InitIntrClientV1,
+		//ioc.InitIntrClientV1,
+		// 接入客户端熔断，熔断后降级到本地实现
+		ioc.InitIntrClientV2,
 
 		// ranking
 		rankingSvcSet,
@@ -70,19 +79,27 @@ func InitWebServer() *App {
 		ioc.InitJobs,
 
 		article.NewSaramaSyncProducer,
+		user.NewSaramaSyncProducer,
 		//events.NewInteractiveReadEventConsumer,
 		//article.NewBatchInteractiveReadEventConsumer,
+		ioc.InitArticleFixConsumer,
 		ioc.InitConsumers,
 
 		// Handler
 		web.NewArticleHandler,
-		ijwt.NewRedisJWTHandler,
+		ioc.InitJWTHandler,
 		web.NewUserHandler,
-		web.NewOAuth2WechatHandler,
+		web.NewOAuth2Handler,
+		web.NewMediaHandler,
 
+		ioc.InitRouteRegistry,
 		ioc.InitGinMiddlewares,
 		ioc.InitWebServer,
 
+		// 数据迁移
+		ioc.InitArticleMigratorProducer,
+		ioc.InitArticleScheduler,
+
 		wire.Struct(new(App), "*"),
 	)
 	return new(App)
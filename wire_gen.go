@@ -8,17 +8,15 @@ package main
 
 import (
 	"github.com/google/wire"
-	repository2 "webook/interactive/repository"
 	cache2 "webook/interactive/repository/cache"
-	dao2 "webook/interactive/repository/dao"
-	service2 "webook/interactive/service"
+	client2 "webook/internal/client"
 	"webook/internal/events/article"
+	"webook/internal/events/user"
 	"webook/internal/repository"
 	"webook/internal/repository/cache"
 	"webook/internal/repository/dao"
 	"webook/internal/service"
 	"webook/internal/web"
-	"webook/internal/web/jwt"
 	"webook/ioc"
 )
 
@@ -30,43 +28,69 @@ import (
 
 func InitWebServer() *App {
 	cmdable := ioc.InitRedis()
-	handler := jwt.NewRedisJWTHandler(cmdable)
 	loggerV1 := ioc.InitLogger()
-	v := ioc.InitGinMiddlewares(cmdable, handler, loggerV1)
+	handler := ioc.InitJWTHandler(cmdable, loggerV1)
+	registry := ioc.InitRouteRegistry()
+	v := ioc.InitGinMiddlewares(cmdable, handler, registry, loggerV1)
 	db := ioc.InitDB(loggerV1)
 	userDAO := dao.NewUserDAO(db)
 	userCache := cache.NewRedisUserCache(cmdable)
 	userRepository := repository.NewCachedUserRepository(userDAO, userCache)
-	userService := service.NewUserService(userRepository)
+	userIdentityDAO := dao.NewUserIdentityDAO(db)
+	userIdentityRepository := repository.NewUserIdentityRepository(userIdentityDAO)
+	userService := service.NewUserService(userRepository, userIdentityRepository)
 	codeCache := cache.NewRedisCodeCache(cmdable)
 	codeRepository := repository.NewCachedCodeRepository(codeCache)
 	smsService := ioc.InitSMSService()
 	codeService := service.NewCodeService(codeRepository, smsService)
-	userHandler := web.NewUserHandler(userService, handler, codeService)
-	wechatService := ioc.InitWechatService(loggerV1)
-	oAuth2WechatHandler := web.NewOAuth2WechatHandler(wechatService, handler, userService)
-	articleDAO := dao.NewArticleGORMDAO(db)
-	articleCache := cache.NewArticleRedisCache(cmdable)
-	articleRepository := repository.NewCachedArticleRepository(articleDAO, articleCache, userRepository)
+	userTokenCache := cache.NewRedisUserTokenCache(cmdable)
+	userTokenRepository := repository.NewCachedUserTokenRepository(userTokenCache)
+	emailService := ioc.InitEmailService()
+	accountService := ioc.InitAccountService(userRepository, userTokenRepository, emailService)
+	totpService := service.NewTotpService(userRepository)
+	bindingService := ioc.InitBindingService(userRepository, userIdentityRepository, codeService, totpService, userTokenRepository, emailService)
+	loginGuardCache := cache.NewRedisLoginGuardCache(cmdable)
+	loginGuardRepository := repository.NewCachedLoginGuardRepository(loginGuardCache)
 	client := ioc.InitSaramaClient()
 	syncProducer := ioc.InitSyncProducer(client)
+	userProducer := user.NewSaramaSyncProducer(syncProducer)
+	loginGuardService := ioc.InitLoginGuardService(cmdable, loginGuardRepository, userRepository, codeService, userProducer, loggerV1)
+	mediaDAO := dao.NewGORMMediaDAO(db)
+	objectDAO := ioc.InitObjectDAO()
+	mediaRepository := repository.NewMediaRepository(mediaDAO, objectDAO)
+	mediaService := service.NewMediaService(mediaRepository, userRepository)
+	userHandler := web.NewUserHandler(userService, handler, codeService, accountService, totpService, bindingService, loginGuardService, mediaService)
+	wechatService := ioc.InitWechatService(loggerV1)
+	oauth2Registry := ioc.InitOAuth2Registry(wechatService, loggerV1)
+	oAuth2Handler := web.NewOAuth2Handler(oauth2Registry, handler, userService, bindingService)
+	database := ioc.InitMongoDB()
+	doubleWriteArticleDAO := ioc.InitArticleDAO(db, database, loggerV1)
+	articleCache := cache.NewArticleRedisCache(cmdable)
+	articleRepository := repository.NewCachedArticleRepository(doubleWriteArticleDAO, articleCache, userRepository)
 	producer := article.NewSaramaSyncProducer(syncProducer)
 	articleService := service.NewArticleService(articleRepository, producer)
 	clientv3Client := ioc.InitEtcd()
-	interactiveServiceClient := ioc.InitIntrClientV1(clientv3Client)
+	interactiveCache := cache2.NewInteractiveRedisCache(cmdable)
+	localInteractiveReader := client2.NewLocalInteractiveReader(interactiveCache)
+	interactiveServiceClient := ioc.InitIntrClientV2(clientv3Client, localInteractiveReader)
 	articleHandler := web.NewArticleHandler(loggerV1, articleService, interactiveServiceClient)
-	engine := ioc.InitWebServer(v, userHandler, oAuth2WechatHandler, articleHandler, loggerV1)
-	v2 := ioc.InitConsumers()
+	mediaHandler := web.NewMediaHandler(mediaService)
+	engine := ioc.InitWebServer(v, registry, userHandler, oAuth2Handler, articleHandler, mediaHandler, loggerV1)
+	fixConsumer := ioc.InitArticleFixConsumer(client, loggerV1, db, database)
+	v2 := ioc.InitConsumers(fixConsumer)
 	rankingCache := cache.NewRankingRedisCache(cmdable)
 	rankingRepository := repository.NewCachedOnlyRankingRepository(rankingCache)
 	rankingService := service.NewBatchRankingService(interactiveServiceClient, articleService, rankingRepository)
 	rlockClient := ioc.InitRlockClient(cmdable)
 	rankingJob := ioc.InitRankingJob(rankingService, loggerV1, rlockClient)
 	cron := ioc.InitJobs(loggerV1, rankingJob)
+	eventsProducer := ioc.InitArticleMigratorProducer(syncProducer)
+	schedulerScheduler := ioc.InitArticleScheduler(loggerV1, db, database, doubleWriteArticleDAO, eventsProducer, cmdable, engine, registry)
 	app := &App{
-		server:    engine,
-		consumers: v2,
-		cron:      cron,
+		server:          engine,
+		consumers:       v2,
+		cron:            cron,
+		articleMigrator: schedulerScheduler,
 	}
 	return app
 }
@@ -74,6 +98,7 @@ func InitWebServer() *App {
 // wire.go:
 
 // 纵向配置
-var interactiveSvcSet = wire.NewSet(dao2.NewGORMInteractiveDAO, cache2.NewInteractiveRedisCache, repository2.NewCachedInteractiveRepository, service2.NewInteractiveService)
+// Interactive 服务熔断之后只读它的缓存，不把整个 Interactive 服务拉进主程序
+var interactiveFallbackSet = wire.NewSet(cache2.NewInteractiveRedisCache, client2.NewLocalInteractiveReader)
 
 var rankingSvcSet = wire.NewSet(cache.NewRankingRedisCache, repository.NewCachedOnlyRankingRepository, service.NewBatchRankingService)